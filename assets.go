@@ -0,0 +1,29 @@
+// Package adventure embeds the game's default stories, templates and static
+// files, so the server is a single self-contained binary.
+package adventure
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed stories templates static
+var assets embed.FS
+
+// Stories returns the embedded stories directory: the story YAML files and
+// each story's scenery and audio.
+func Stories() fs.FS { return sub("stories") }
+
+// Templates returns the embedded HTML templates.
+func Templates() fs.FS { return sub("templates") }
+
+// Static returns the embedded files served under /static/.
+func Static() fs.FS { return sub("static") }
+
+func sub(dir string) fs.FS {
+	f, err := fs.Sub(assets, dir)
+	if err != nil {
+		panic(err) // dir is one of the embedded directories
+	}
+	return f
+}
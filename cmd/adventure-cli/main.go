@@ -0,0 +1,42 @@
+// Package main provides adventure-cli, a terminal client for playing stories
+// without a browser, e.g. for quick playtesting over SSH.
+//
+// Usage:
+//
+//	adventure-cli [-dir stories] [-save adventure.save.json] [-load]
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"adventure/internal/cli"
+	"adventure/internal/game"
+)
+
+func main() {
+	dir := flag.String("dir", "stories", "directory of story YAML files")
+	savePath := flag.String("save", cli.DefaultSavePath, "file used by the save and load commands")
+	load := flag.Bool("load", false, "resume the game saved in the -save file")
+	flag.Parse()
+
+	stories, err := game.LoadStories(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	g := cli.New(&game.Engine{Stories: stories}, os.Stdin, os.Stdout)
+	g.SavePath = *savePath
+	if *load {
+		st, err := cli.Load(*savePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := g.Resume(st); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := g.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
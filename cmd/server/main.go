@@ -2,50 +2,378 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"html/template"
-	"log"
+	"io"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
+	"adventure"
+	"adventure/internal/account"
+	"adventure/internal/analytics"
+	"adventure/internal/config"
 	"adventure/internal/game"
+	"adventure/internal/leaderboard"
+	"adventure/internal/logging"
+	"adventure/internal/overlayfs"
 	"adventure/internal/session"
 	"adventure/internal/web"
 )
 
 func main() {
-	stories, err := game.LoadStories("stories")
+	cfg, flags, err := config.Load(os.Args[0], os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		fs := config.Default().FlagSet(os.Args[0])
+		fs.SetOutput(os.Stderr)
+		fs.PrintDefaults()
+		return
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if flags.Lookup("print-config").Value.String() == "true" {
+		if err := cfg.WriteYAML(os.Stdout); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		fatal(err)
+	}
+
+	level, err := logging.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		fatal(err)
+	}
+	logger, err := logging.New(os.Stderr, level, cfg.Log.Format)
+	if err != nil {
+		fatal(err)
+	}
+	slog.SetDefault(logger)
+	logConfig(cfg)
+
+	storyFiles := overlay(cfg.Dirs.Stories, adventure.Stories())
+	stories, err := game.LoadStoriesFS(storyFiles)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 	if len(stories) == 0 {
-		log.Fatal("no adventure YAML files found in stories/")
+		fatal(errors.New("no adventure YAML files found"))
+	}
+	if cfg.DefaultStory != "" && stories[cfg.DefaultStory] == nil {
+		fatal(fmt.Errorf("default story %q not found", cfg.DefaultStory))
 	}
 
-	tmpl := template.Must(template.ParseFiles(
-		"templates/layout.html",
-		"templates/layout_head.html",
-		"templates/sidebar_left.html",
-		"templates/sidebar_right.html",
-		"templates/sidebar_left_oob.html",
-		"templates/sidebar_right_oob.html",
-		"templates/game.html",
-		"templates/game_response.html",
-		"templates/start.html",
-	))
+	tmpl, err := template.ParseFS(overlay(cfg.Dirs.Templates, adventure.Templates()), "*.html")
+	if err != nil {
+		fatal(err)
+	}
+
+	redisOpts := session.RedisOptions{
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+		Prefix:   cfg.Redis.Prefix,
+		TTL:      cfg.Session.TTL,
+	}
+	storeKind := cfg.Session.Store
+	cookies := cookieOptions(cfg.Cookies)
+	var store session.Store[game.PlayerState]
+	if storeKind == "cookie" {
+		store, err = openStore[game.PlayerState](cfg.Session.CookieFallback, cfg.Session.File, cfg.Session.MaxSessions, redisOpts)
+		if err == nil {
+			store, err = openCookieStore(cfg.Session.Keys, redisOpts.TTL, cookies, store)
+		}
+	} else {
+		store, err = openStore[game.PlayerState](storeKind, cfg.Session.File, cfg.Session.MaxSessions, redisOpts)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	var accountService *account.Service
+	if cfg.Accounts.Enabled {
+		if accountService, err = openAccounts(storeKind, cfg.Accounts.File, redisOpts); err != nil {
+			fatal(err)
+		}
+	}
+	var leaderboardService *leaderboard.Service
+	if cfg.Leaderboards.Enabled {
+		kind := storeKind
+		if kind == "cookie" {
+			kind = cfg.Session.CookieFallback
+		}
+		if leaderboardService, err = openLeaderboards(kind, cfg.Leaderboards.File, redisOpts); err != nil {
+			fatal(err)
+		}
+	}
+
+	recorder, err := openAnalytics(cfg.Analytics.Sink, cfg.Analytics.File, cfg.Analytics.Key)
+	if err != nil {
+		fatal(err)
+	}
+
+	var serverMetrics *web.Metrics
+	if cfg.Metrics.Path != "" {
+		serverMetrics = web.NewMetrics()
+		store = serverMetrics.InstrumentStore(store)
+	}
 
 	srv := &web.Server{
-		Engine: &game.Engine{Stories: stories},
-		Store:  session.NewMemoryStore[game.PlayerState](),
-		Tmpl:   tmpl,
+		Engine:       &game.Engine{Stories: stories},
+		Store:        store,
+		Tmpl:         tmpl,
+		StoryFiles:   storyFiles,
+		StaticFiles:  overlay(cfg.Dirs.Static, adventure.Static()),
+		DefaultStory: cfg.DefaultStory,
+		Cookies:      cookies,
+		Accounts:     accountService,
+		Leaderboards: leaderboardService,
+		Analytics:    recorder,
+		Metrics:      serverMetrics,
+		Logger:       logger,
+
+		AnalyticsPassword: cfg.Analytics.Password,
+		MetricsPath:       cfg.Metrics.Path,
+	}
+	if cfg.Features.Party {
+		srv.Parties = web.NewParties()
+	}
+	if cfg.Features.Spectators {
+		srv.Spectators = web.NewSpectators()
+		srv.Spectators.MaxIdle = cfg.Session.TTL // a link goes with its game
+	}
+	if serverMetrics != nil && cfg.Metrics.Addr != "" {
+		srv.MetricsPath = ""
+		go serveMetrics(cfg.Metrics.Addr, cfg.Metrics.Path, serverMetrics)
 	}
 
 	s := &http.Server{
-		Addr:         ":8080",
+		Addr:         cfg.Addr,
 		Handler:      srv.Routes(),
+		ReadTimeout:  cfg.Timeouts.Read,
+		WriteTimeout: cfg.Timeouts.Write,
+		IdleTimeout:  cfg.Timeouts.Idle,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+	s.RegisterOnShutdown(srv.Drain)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	served := make(chan error, 1)
+	go func() {
+		if cfg.TLS.Cert != "" {
+			slog.Info("listening", slog.String("addr", "https://"+displayAddr(cfg.Addr)))
+			served <- s.ListenAndServeTLS(cfg.TLS.Cert, cfg.TLS.Key)
+			return
+		}
+		slog.Info("listening", slog.String("addr", "http://"+displayAddr(cfg.Addr)))
+		served <- s.ListenAndServe()
+	}()
+	select {
+	case err := <-served:
+		fatal(err)
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the server at once
+
+	slog.Info("shutting down", slog.Duration("drain_timeout", cfg.Timeouts.Drain))
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Drain)
+	defer cancel()
+	if err := s.Shutdown(drainCtx); err != nil {
+		slog.Warn("requests still in flight after the drain timeout were cut off", slog.Any("err", err))
+		s.Close() //nolint:errcheck,gosec // already giving up on them
+	}
+	closeStores(store, accountService, leaderboardService, recorder)
+	slog.Info("stopped")
+}
+
+// closeStores flushes and closes every store once requests have stopped.
+// Failures are logged: there is nothing left to do but report them.
+func closeStores(store session.Store[game.PlayerState], accounts *account.Service, boards *leaderboard.Service, recorder *analytics.Recorder) {
+	closers := map[string]io.Closer{"session store": store}
+	if accounts != nil {
+		closers["account store"] = accounts.Users
+		closers["login store"] = accounts.Logins
+	}
+	if boards != nil {
+		closers["leaderboard store"] = boards.Store
+	}
+	if recorder != nil {
+		if c, ok := recorder.Sink.(io.Closer); ok {
+			closers["analytics sink"] = c
+		}
+	}
+	for name, c := range closers {
+		if err := c.Close(); err != nil {
+			slog.Error("failed to close "+name, slog.Any("err", err))
+		}
+	}
+}
+
+// overlay lays the disk directory dir, when set, over the embedded files.
+func overlay(dir string, embedded fs.FS) fs.FS {
+	if dir == "" {
+		return embedded
+	}
+	return overlayfs.New(os.DirFS(dir), embedded)
+}
+
+// logConfig logs the effective configuration, secrets redacted.
+func logConfig(cfg *config.Config) {
+	var attrs []any
+	for _, kv := range cfg.Flat() {
+		attrs = append(attrs, slog.String(kv[0], kv[1]))
+	}
+	slog.Info("configuration", slog.Group("config", attrs...))
+}
+
+// displayAddr makes a listen address such as ":8080" into one to browse to.
+func displayAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
+}
+
+// cookieOptions turns the cookie settings into the web server's.
+func cookieOptions(c config.Cookies) web.CookieOptions {
+	opts := web.CookieOptions{Secure: c.Secure, Domain: c.Domain}
+	switch c.SameSite {
+	case "strict":
+		opts.SameSite = http.SameSiteStrictMode
+	case "none":
+		opts.SameSite = http.SameSiteNoneMode
+	default:
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	return opts
+}
+
+// openStore opens the session store named by kind. Sessions expire
+// redisOpts.TTL after they were last used, whichever store is chosen.
+func openStore[T any](kind, file string, maxSessions int, redisOpts session.RedisOptions) (session.Store[T], error) {
+	switch kind {
+	case "memory":
+		s := session.NewMemoryStore[T]()
+		s.TTL = redisOpts.TTL
+		s.MaxSessions = maxSessions
+		s.StartJanitor(time.Minute)
+		return s, nil
+	case "file":
+		// Every Put is synced; Close at shutdown just releases the log.
+		s, err := session.OpenFileStore[T](file)
+		if err != nil {
+			return nil, err
+		}
+		s.TTL = redisOpts.TTL
+		return s, nil
+	case "redis":
+		s, err := session.OpenRedisStore[T](redisOpts)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown -session-store %q (want memory, file or redis)", kind)
+}
+
+// openAccounts opens the account and login stores in the same kind of store
+// as the sessions. Accounts never expire; logins do.
+func openAccounts(kind, file string, redisOpts session.RedisOptions) (*account.Service, error) {
+	usersOpts := redisOpts
+	usersOpts.Prefix, usersOpts.TTL = "adventure:account:", 0
+	users, err := openStore[account.Account](kind, file, 0, usersOpts)
+	if err != nil {
+		return nil, err
+	}
+	loginsOpts := redisOpts
+	loginsOpts.Prefix, loginsOpts.TTL = "adventure:login:", account.DefaultLoginTTL
+	logins, err := openStore[string](kind, filepath.Join(filepath.Dir(file), "logins.log"), 0, loginsOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &account.Service{Users: users, Logins: logins}, nil
+}
+
+// openLeaderboards opens the leaderboard store in the same kind of store as
+// the sessions. Boards never expire.
+func openLeaderboards(kind, file string, redisOpts session.RedisOptions) (*leaderboard.Service, error) {
+	redisOpts.Prefix, redisOpts.TTL = "adventure:leaderboard:", 0
+	store, err := openStore[leaderboard.Board](kind, file, 0, redisOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &leaderboard.Service{Store: store}, nil
+}
+
+// serveMetrics serves the metrics at path on their own listener.
+func serveMetrics(addr, path string, m *web.Metrics) {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+path, m.Registry.Handler())
+	s := &http.Server{
+		Addr:         addr,
+		Handler:      mux,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
 	}
-	log.Println("listening on http://localhost:8080")
-	log.Fatal(s.ListenAndServe())
+	slog.Info("serving metrics", slog.String("addr", "http://"+addr+path))
+	fatal(s.ListenAndServe())
+}
+
+// openAnalytics opens the analytics sink named by kind, or returns nil for
+// "off". Run IDs are keyed with key, or a random key when it is empty.
+func openAnalytics(kind, file, key string) (*analytics.Recorder, error) {
+	var runKey []byte
+	if key != "" {
+		runKey = []byte(key)
+	}
+	switch kind {
+	case "off":
+		return nil, nil
+	case "memory":
+		return analytics.NewRecorder(analytics.NewMemorySink(), runKey), nil
+	case "file":
+		sink, err := analytics.OpenFileSink(file)
+		if err != nil {
+			return nil, err
+		}
+		return analytics.NewRecorder(sink, runKey), nil
+	}
+	return nil, fmt.Errorf("unknown -analytics %q (want memory, file or off)", kind)
+}
+
+// openCookieStore keeps sessions in cookies sealed with keys, secrets
+// newest first, set with the server's cookie options, falling back to
+// fallback.
+func openCookieStore(keys []string, ttl time.Duration, opts web.CookieOptions, fallback session.Store[game.PlayerState]) (session.Store[game.PlayerState], error) {
+	var secrets [][]byte
+	for _, k := range keys {
+		secrets = append(secrets, []byte(k))
+	}
+	if len(secrets) == 0 {
+		return nil, errors.New("-session-store=cookie needs SESSION_KEYS")
+	}
+	s, err := session.NewCookieStore(secrets, fallback)
+	if err != nil {
+		return nil, err
+	}
+	s.TTL = ttl
+	s.NewCookie = opts.Cookie
+	return s, nil
+}
+
+// fatal logs err and exits.
+func fatal(err error) {
+	slog.Error("fatal", slog.Any("err", err))
+	os.Exit(1)
 }
@@ -0,0 +1,57 @@
+// Package main provides storygraph, which exports a story's node graph as
+// Graphviz DOT, Mermaid or SVG for review.
+//
+// Usage:
+//
+//	storygraph [-format dot|mermaid|svg] [-o file] stories/roman_adventure.yaml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"adventure/internal/game"
+	"adventure/internal/storygraph"
+)
+
+func main() {
+	format := flag.String("format", storygraph.FormatSVG, "output format: dot, mermaid or svg")
+	out := flag.String("o", "", "output file (default stdout)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: storygraph [-format dot|mermaid|svg] [-o file] story.yaml\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	path := flag.Arg(0)
+	story, err := game.LoadStory(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	title := story.Title
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close() //nolint:errcheck // closed after a successful write below
+		w = f
+	}
+	if err := storygraph.Render(w, storygraph.Build(story, title), *format); err != nil {
+		log.Fatal(err)
+	}
+}
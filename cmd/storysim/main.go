@@ -0,0 +1,66 @@
+// Package main provides storysim, which plays a story thousands of times with
+// a choice policy and reports how balanced it is.
+//
+// Usage:
+//
+//	storysim [-runs 1000] [-policy random|greedy|scripted] [-script camp=north,road=fight] [-json] demo
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"adventure/internal/game"
+	"adventure/internal/sim"
+)
+
+func main() {
+	dir := flag.String("dir", "stories", "directory of story YAML files")
+	runs := flag.Int("runs", 1000, "number of runs to simulate")
+	policyName := flag.String("policy", sim.PolicyRandom, "choice policy: random, greedy or scripted")
+	script := flag.String("script", "", "scripted policy: comma-separated node=choice pairs")
+	difficulty := flag.String("difficulty", "", "difficulty preset ID (default: the story's default)")
+	maxSteps := flag.Int("max-steps", sim.DefaultMaxSteps, "give up on a run after this many steps")
+	seed := flag.Int64("seed", 0, "seed for check and battle dice (0 = random)")
+	asJSON := flag.Bool("json", false, "print the report as JSON instead of tables")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: storysim [flags] story-id\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	stories, err := game.LoadStories(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	policy, err := sim.NewPolicy(*policyName, *script)
+	if err != nil {
+		log.Fatal(err)
+	}
+	report, err := sim.Run(&game.Engine{Stories: stories}, sim.Config{
+		StoryID:    flag.Arg(0),
+		Runs:       *runs,
+		Policy:     policy,
+		PolicyName: *policyName,
+		Difficulty: *difficulty,
+		MaxSteps:   *maxSteps,
+		Seed:       *seed,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteTable(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,61 @@
+// Package main provides storytest, which runs the scripted playthroughs in
+// stories/<id>/tests/*.yaml and reports failures and node/choice coverage.
+//
+// Usage:
+//
+//	storytest [-dir stories] [-v] [story-id ...]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"adventure/internal/game"
+	"adventure/internal/storytest"
+)
+
+func main() {
+	dir := flag.String("dir", "stories", "directory of story YAML files and their tests")
+	verbose := flag.Bool("v", false, "list passing tests and uncovered nodes and choices")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: storytest [flags] [story-id ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	stories, err := game.LoadStories(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	paths, err := storytest.Discover(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	only := flag.Args()
+
+	r := storytest.NewRunner(stories)
+	var results []storytest.Result
+	for _, p := range paths {
+		f, err := storytest.Load(p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(only) > 0 && !slices.Contains(only, f.Story) {
+			continue
+		}
+		results = append(results, r.RunFile(p, f)...)
+	}
+	if len(results) == 0 {
+		log.Fatalf("no story tests found under %s/*/tests/", *dir)
+	}
+
+	failed := storytest.WriteResults(os.Stdout, results, *verbose)
+	fmt.Println()
+	storytest.WriteCoverage(os.Stdout, r.Coverage.Report(stories, r.Coverage.Stories()), *verbose)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -4,5 +4,6 @@ go 1.22
 
 require (
 	github.com/jung-kurt/gofpdf/v2 v2.17.0
+	golang.org/x/crypto v0.33.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,223 @@
+// Package account provides optional local player accounts: a username and a
+// hashed password owning several characters, each a game session.
+package account
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"adventure/internal/session"
+)
+
+// Username and password rules.
+const (
+	MinUsernameLen = 3
+	MaxUsernameLen = 32
+	MinPasswordLen = 8
+	MaxPasswordLen = 256
+)
+
+// DefaultLoginTTL is how long a login lasts without use.
+const DefaultLoginTTL = 30 * 24 * time.Hour
+
+var (
+	// ErrInvalidUsername is returned for a username outside the rules.
+	ErrInvalidUsername = errors.New("usernames are 3 to 32 letters, digits, '-' or '_'")
+	// ErrWeakPassword is returned for a password outside the rules.
+	ErrWeakPassword = errors.New("passwords are at least 8 characters")
+	// ErrUsernameTaken is returned when registering an existing username.
+	ErrUsernameTaken = errors.New("that username is taken")
+	// ErrBadCredentials is returned for an unknown username or wrong password.
+	ErrBadCredentials = errors.New("wrong username or password")
+	// ErrNotFound is returned when an account is missing.
+	ErrNotFound = errors.New("account not found")
+)
+
+// Account is a player's account. Characters are session IDs in the game's
+// session store, in the order they were added.
+type Account struct {
+	Username   string
+	Password   string // see HashPassword
+	Characters []string
+	Created    time.Time
+}
+
+// Owns reports whether the account holds the character with session ID id.
+func (a *Account) Owns(id string) bool {
+	return slices.Contains(a.Characters, id)
+}
+
+// Service registers accounts, checks passwords and tracks logins.
+type Service struct {
+	// Users holds accounts by lower-case username. Accounts never expire,
+	// so use a store without a TTL.
+	Users session.Store[Account]
+	// Logins maps login tokens to usernames; entries expire with LoginTTL.
+	Logins session.Store[string]
+	// LoginTTL is how long a login lasts without use; 0 means DefaultLoginTTL.
+	LoginTTL time.Duration
+	// Iterations is the PBKDF2 work factor for new passwords; 0 means
+	// DefaultIterations.
+	Iterations int
+
+	dummyOnce sync.Once
+	dummy     string // hash checked for unknown usernames, so they take as long
+}
+
+// normalize returns the account key for a username, or an error if it
+// breaks the rules.
+func normalize(username string) (string, error) {
+	u := strings.ToLower(strings.TrimSpace(username))
+	if len(u) < MinUsernameLen || len(u) > MaxUsernameLen {
+		return "", ErrInvalidUsername
+	}
+	for _, c := range u {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' {
+			return "", ErrInvalidUsername
+		}
+	}
+	return u, nil
+}
+
+// Register creates an account.
+func (s *Service) Register(ctx context.Context, username, password string) (Account, error) {
+	u, err := normalize(username)
+	if err != nil {
+		return Account{}, err
+	}
+	if len(password) < MinPasswordLen || len(password) > MaxPasswordLen {
+		return Account{}, ErrWeakPassword
+	}
+	hash, err := HashPassword(password, s.Iterations)
+	if err != nil {
+		return Account{}, err
+	}
+	a := Account{Username: u, Password: hash, Created: time.Now().UTC()}
+	if _, err := s.Users.CompareAndPut(ctx, u, a, 0); errors.Is(err, session.ErrConflict) {
+		return Account{}, ErrUsernameTaken
+	} else if err != nil {
+		return Account{}, err
+	}
+	return a, nil
+}
+
+// Authenticate returns the account if password is right.
+func (s *Service) Authenticate(ctx context.Context, username, password string) (Account, error) {
+	u, err := normalize(username)
+	if err != nil {
+		return Account{}, ErrBadCredentials
+	}
+	a, ok, err := s.Users.Get(ctx, u)
+	if err != nil {
+		return Account{}, err
+	}
+	if !ok {
+		s.dummyOnce.Do(func() {
+			s.dummy, _ = HashPassword("", s.Iterations) //nolint:errcheck // Only fails if crypto/rand does
+		})
+		_, _ = CheckPassword(s.dummy, password) //nolint:errcheck // Only spends the time a real check would
+		return Account{}, ErrBadCredentials
+	}
+	if match, err := CheckPassword(a.Password, password); err != nil {
+		return Account{}, err
+	} else if !match {
+		return Account{}, ErrBadCredentials
+	}
+	return a, nil
+}
+
+// Login starts a login for username and returns its token, which the
+// caller keeps in a cookie.
+func (s *Service) Login(ctx context.Context, username string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	if err := s.Logins.PutTTL(ctx, token, username, s.LoginLifetime()); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// LoggedIn returns the account a login token belongs to, extending the
+// login. It reports false for an unknown or expired token.
+func (s *Service) LoggedIn(ctx context.Context, token string) (Account, bool, error) {
+	if token == "" {
+		return Account{}, false, nil
+	}
+	u, ok, err := s.Logins.Get(ctx, token)
+	if err != nil || !ok {
+		return Account{}, false, err
+	}
+	return s.Users.Get(ctx, u)
+}
+
+// Logout ends a login.
+func (s *Service) Logout(ctx context.Context, token string) error {
+	return s.Logins.Delete(ctx, token)
+}
+
+// AddCharacter adds the session id to the account's characters, if it is
+// not there already.
+func (s *Service) AddCharacter(ctx context.Context, username, id string) error {
+	return s.update(ctx, username, func(a *Account) {
+		if !a.Owns(id) {
+			a.Characters = append(a.Characters, id)
+		}
+	})
+}
+
+// ReplaceCharacter swaps the session old for id in the account's
+// characters, keeping its place, as when a character restarts.
+func (s *Service) ReplaceCharacter(ctx context.Context, username, old, id string) error {
+	return s.update(ctx, username, func(a *Account) {
+		if i := slices.Index(a.Characters, old); i >= 0 && !a.Owns(id) {
+			a.Characters[i] = id
+		} else if !a.Owns(id) {
+			a.Characters = append(a.Characters, id)
+		}
+	})
+}
+
+// RemoveCharacters drops the given sessions from the account's characters.
+func (s *Service) RemoveCharacters(ctx context.Context, username string, ids ...string) error {
+	return s.update(ctx, username, func(a *Account) {
+		a.Characters = slices.DeleteFunc(a.Characters, func(c string) bool { return slices.Contains(ids, c) })
+	})
+}
+
+// update applies f to the account with a compare-and-swap, retrying when
+// another request changed it first.
+func (s *Service) update(ctx context.Context, username string, f func(*Account)) error {
+	for {
+		a, rev, ok, err := s.Users.GetRev(ctx, username)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotFound
+		}
+		f(&a)
+		if _, err := s.Users.CompareAndPut(ctx, username, a, rev); !errors.Is(err, session.ErrConflict) {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// LoginLifetime is how long a login lasts without use.
+func (s *Service) LoginLifetime() time.Duration {
+	if s.LoginTTL <= 0 {
+		return DefaultLoginTTL
+	}
+	return s.LoginTTL
+}
@@ -0,0 +1,113 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"adventure/internal/session"
+)
+
+func testService() *Service {
+	return &Service{
+		Users:      session.NewMemoryStore[Account](),
+		Logins:     session.NewMemoryStore[string](),
+		Iterations: 10,
+	}
+}
+
+func TestService_RegisterAndAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	s := testService()
+	a, err := s.Register(ctx, " Ann_1 ", "long enough")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if a.Username != "ann_1" || a.Password == "long enough" {
+		t.Errorf("Unexpected account %+v", a)
+	}
+	if _, err := s.Register(ctx, "ANN_1", "another password"); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("Expected ErrUsernameTaken, got %v", err)
+	}
+	for _, u := range []string{"an", "ann smith", "änn", "a234567890123456789012345678901234"} {
+		if _, err := s.Register(ctx, u, "long enough"); !errors.Is(err, ErrInvalidUsername) {
+			t.Errorf("Register(%q) = %v, want ErrInvalidUsername", u, err)
+		}
+	}
+	if _, err := s.Register(ctx, "bob", "short"); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("Expected ErrWeakPassword, got %v", err)
+	}
+
+	if got, err := s.Authenticate(ctx, "Ann_1", "long enough"); err != nil || got.Username != "ann_1" {
+		t.Errorf("Authenticate = %+v, %v", got, err)
+	}
+	for _, c := range [][2]string{{"ann_1", "wrong password"}, {"nobody", "long enough"}, {"!", "long enough"}} {
+		if _, err := s.Authenticate(ctx, c[0], c[1]); !errors.Is(err, ErrBadCredentials) {
+			t.Errorf("Authenticate(%q, %q) = %v, want ErrBadCredentials", c[0], c[1], err)
+		}
+	}
+}
+
+func TestService_Logins(t *testing.T) {
+	ctx := context.Background()
+	s := testService()
+	s.LoginTTL = time.Hour
+	_, _ = s.Register(ctx, "ann", "long enough")
+	token, err := s.Login(ctx, "ann")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if a, ok, err := s.LoggedIn(ctx, token); !ok || err != nil || a.Username != "ann" {
+		t.Errorf("LoggedIn = %+v, %v, %v", a, ok, err)
+	}
+	if _, ok, _ := s.LoggedIn(ctx, ""); ok {
+		t.Error("Expected no account without a token")
+	}
+	if _, ok, _ := s.LoggedIn(ctx, "forged"); ok {
+		t.Error("Expected no account for an unknown token")
+	}
+	if err := s.Logout(ctx, token); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if _, ok, _ := s.LoggedIn(ctx, token); ok {
+		t.Error("Expected the login to end")
+	}
+}
+
+func TestService_Characters(t *testing.T) {
+	ctx := context.Background()
+	s := testService()
+	_, _ = s.Register(ctx, "ann", "long enough")
+	_ = s.AddCharacter(ctx, "ann", "s1")
+	_ = s.AddCharacter(ctx, "ann", "s2")
+	_ = s.AddCharacter(ctx, "ann", "s1")
+	_ = s.ReplaceCharacter(ctx, "ann", "s1", "s3")
+	_ = s.ReplaceCharacter(ctx, "ann", "gone", "s4")
+	_ = s.RemoveCharacters(ctx, "ann", "s2")
+	a, _, _ := s.Users.Get(ctx, "ann")
+	if got := a.Characters; len(got) != 2 || got[0] != "s3" || got[1] != "s4" {
+		t.Errorf("Characters = %v, want [s3 s4]", got)
+	}
+	if !a.Owns("s3") || a.Owns("s2") {
+		t.Errorf("Owns is wrong for %v", a.Characters)
+	}
+	if err := s.AddCharacter(ctx, "nobody", "s1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// Concurrent updates all land.
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = s.AddCharacter(ctx, "ann", string(rune('a'+i)))
+		}()
+	}
+	wg.Wait()
+	if a, _, _ := s.Users.Get(ctx, "ann"); len(a.Characters) != 22 {
+		t.Errorf("Expected 22 characters, got %d", len(a.Characters))
+	}
+}
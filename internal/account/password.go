@@ -0,0 +1,65 @@
+package account
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// DefaultIterations is the PBKDF2 work factor for new password hashes,
+	// as OWASP recommends for HMAC-SHA256.
+	DefaultIterations = 600000
+
+	hashScheme = "pbkdf2-sha256"
+	saltLen    = 16
+	keyLen     = sha256.Size
+)
+
+// HashPassword hashes password with PBKDF2-HMAC-SHA256 and a random salt,
+// in the form "pbkdf2-sha256$iterations$salt$key" (base64). The iteration
+// count travels with the hash, so raising it later leaves old hashes valid.
+func HashPassword(password string, iterations int) (string, error) {
+	if iterations <= 0 {
+		iterations = DefaultIterations
+	}
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2.Key([]byte(password), salt, iterations, keyLen, sha256.New)
+	return strings.Join([]string{
+		hashScheme,
+		strconv.Itoa(iterations),
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	}, "$"), nil
+}
+
+// CheckPassword reports whether password matches a hash from HashPassword.
+func CheckPassword(hash, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != hashScheme {
+		return false, fmt.Errorf("account: unknown password hash format")
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false, fmt.Errorf("account: bad iteration count %q", parts[1])
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, fmt.Errorf("account: bad salt: %w", err)
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false, fmt.Errorf("account: bad key: %w", err)
+	}
+	got := pbkdf2.Key([]byte(password), salt, iterations, len(want), sha256.New)
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
@@ -0,0 +1,66 @@
+package account
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCheckPassword_Vectors(t *testing.T) {
+	// PBKDF2-HMAC-SHA256 vectors in the style of RFC 6070, and from RFC 7914
+	// section 11, as hashes CheckPassword accepts.
+	tests := []struct {
+		password, salt string
+		iterations     int
+		key            string
+	}{
+		{"password", "salt", 1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, "348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+		{"pass\x00word", "sa\x00lt", 4096, "89b69d0516f829893c696226650a8687"},
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, tt := range tests {
+		key, _ := hex.DecodeString(tt.key)
+		hash := strings.Join([]string{
+			hashScheme,
+			strconv.Itoa(tt.iterations),
+			base64.RawStdEncoding.EncodeToString([]byte(tt.salt)),
+			base64.RawStdEncoding.EncodeToString(key),
+		}, "$")
+		if ok, err := CheckPassword(hash, tt.password); !ok || err != nil {
+			t.Errorf("CheckPassword(%q, %q, %d) = %v, %v", tt.password, tt.salt, tt.iterations, ok, err)
+		}
+		if ok, _ := CheckPassword(hash, tt.password+"!"); ok {
+			t.Errorf("CheckPassword(%q, %q, %d) accepted a wrong password", tt.password, tt.salt, tt.iterations)
+		}
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse", 1000)
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$1000$") || strings.Contains(hash, "correct") {
+		t.Errorf("Unexpected hash %q", hash)
+	}
+	if again, _ := HashPassword("correct horse", 1000); again == hash {
+		t.Error("Expected a fresh salt for every hash")
+	}
+	if ok, err := CheckPassword(hash, "correct horse"); !ok || err != nil {
+		t.Errorf("CheckPassword(right) = %v, %v", ok, err)
+	}
+	if ok, err := CheckPassword(hash, "wrong horse"); ok || err != nil {
+		t.Errorf("CheckPassword(wrong) = %v, %v", ok, err)
+	}
+	for _, bad := range []string{"", "md5$1$a$b", "pbkdf2-sha256$x$a$b", "pbkdf2-sha256$1$!$b"} {
+		if _, err := CheckPassword(bad, "pw"); err == nil {
+			t.Errorf("Expected an error for hash %q", bad)
+		}
+	}
+}
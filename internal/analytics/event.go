@@ -0,0 +1,212 @@
+// Package analytics records anonymised play events per story and summarises
+// them for authors: how far runs get, which choices players make, how often
+// checks pass and where runs die or are abandoned.
+package analytics
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"adventure/internal/game"
+)
+
+// Event kinds.
+const (
+	KindStart  = "start"  // a run began at the story's start node
+	KindNode   = "node"   // a node was entered
+	KindChoice = "choice" // a choice was taken at Node
+	KindCheck  = "check"  // a stat check at Node passed or failed
+	KindBattle = "battle" // a battle at Node was won, lost or fled
+	KindDeath  = "death"  // the player died; Node is where the fatal move was made
+	KindEnding = "ending" // the run reached the ending Node
+)
+
+// Battle outcomes; checks use game.OutcomeSuccess and game.OutcomeFailure.
+const (
+	OutcomeVictory = game.OutcomeVictory
+	OutcomeDefeat  = game.OutcomeDefeat
+	OutcomeFled    = "fled"
+)
+
+// Event is one thing that happened in a run. Runs are named by an ID that
+// can't be traced back to the player's session.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Story    string    `json:"story"`
+	Run      string    `json:"run"`
+	Kind     string    `json:"kind"`
+	Node     string    `json:"node"`
+	Choice   string    `json:"choice,omitempty"`   // choice key; battle actions are folded into the battle's key
+	Outcome  string    `json:"outcome,omitempty"`  // check or battle outcome
+	Expected float64   `json:"expected,omitempty"` // check: the chance it had to pass
+}
+
+// Sink receives events. Implementations must be safe for concurrent use.
+type Sink interface {
+	Record(ctx context.Context, events ...Event) error
+}
+
+// Source is a sink whose events can be read back, for the analytics page
+// and exports.
+type Source interface {
+	// Events returns the recorded events of a story, oldest first; an empty
+	// storyID returns every story's.
+	Events(ctx context.Context, storyID string) ([]Event, error)
+}
+
+// runIDBytes is how much of the HMAC names a run.
+const runIDBytes = 12
+
+// Recorder turns game steps into events for a sink.
+type Recorder struct {
+	Sink Sink
+
+	key []byte
+	now func() time.Time // for tests; nil means time.Now
+}
+
+// NewRecorder returns a recorder for sink. Run IDs are keyed with key; with
+// a nil key a random one is used, so runs can't be linked across restarts.
+func NewRecorder(sink Sink, key []byte) *Recorder {
+	if key == nil {
+		key = make([]byte, 32)
+		_, _ = rand.Read(key) //nolint:errcheck // crypto/rand.Read does not fail
+	}
+	return &Recorder{Sink: sink, key: key}
+}
+
+// Begin records the start of st's run. It is a no-op on a nil recorder.
+func (r *Recorder) Begin(ctx context.Context, sessionID string, st *game.PlayerState) error {
+	if r == nil {
+		return nil
+	}
+	return r.Sink.Record(ctx, r.event(sessionID, st.StoryID, KindStart, st.NodeID))
+}
+
+// Step records what a step did: the choice taken at before's node, any
+// check or finished battle, the nodes it entered, and a death or ending.
+// Refused choices are not recorded. It is a no-op on a nil recorder.
+func (r *Recorder) Step(ctx context.Context, e *game.Engine, sessionID string, before *game.PlayerState, choiceKey string, res *game.StepResult) error {
+	if r == nil || res.ErrorMessage != "" {
+		return nil
+	}
+	evs := r.stepEvents(e, sessionID, before, choiceKey, res)
+	if len(evs) == 0 {
+		return nil
+	}
+	return r.Sink.Record(ctx, evs...)
+}
+
+func (r *Recorder) stepEvents(e *game.Engine, sessionID string, before *game.PlayerState, choiceKey string, res *game.StepResult) []Event {
+	after := &res.State
+	from := r.event(sessionID, before.StoryID, KindChoice, before.NodeID)
+	ch := choiceAt(e, before, choiceKey)
+	if ch != nil {
+		from.Choice = ch.Key
+	} else {
+		from.Choice = choiceKey
+	}
+	inBattle := ch != nil && ch.Battle != nil
+	var evs []Event
+	// A battle's later rounds are the same choice.
+	if !inBattle || len(before.Enemies) == 0 {
+		evs = append(evs, from)
+	}
+
+	if ch != nil && ch.Check != nil && res.LastOutcome != nil {
+		ev := from
+		ev.Kind, ev.Outcome = KindCheck, *res.LastOutcome
+		ev.Expected = checkChance(before, ch.Check)
+		evs = append(evs, ev)
+	}
+	if inBattle && len(after.Enemies) == 0 {
+		ev := from
+		ev.Kind = KindBattle
+		switch {
+		case after.Stats.Health <= game.MinHealth:
+			ev.Outcome = OutcomeDefeat
+		case choiceKey == ch.Key+":run":
+			ev.Outcome = OutcomeFled
+		default:
+			ev.Outcome = OutcomeVictory
+		}
+		evs = append(evs, ev)
+	}
+
+	// Nodes entered: what the path gained, unless a new run started.
+	if after.StoryID == before.StoryID && len(after.VisitedNodes) > len(before.VisitedNodes) {
+		for _, id := range after.VisitedNodes[len(before.VisitedNodes):] {
+			evs = append(evs, r.event(sessionID, after.StoryID, KindNode, id))
+		}
+	}
+	if after.NodeID == before.NodeID && after.StoryID == before.StoryID {
+		return evs
+	}
+	if after.NodeID == game.DeathNodeID || after.Stats.Health <= game.MinHealth {
+		evs = append(evs, r.event(sessionID, before.StoryID, KindDeath, before.NodeID))
+	}
+	if n, err := e.CurrentNode(after); err == nil && n.Ending && len(after.CallStack) == 0 {
+		evs = append(evs, r.event(sessionID, after.StoryID, KindEnding, after.NodeID))
+	}
+	return evs
+}
+
+func (r *Recorder) event(sessionID, storyID, kind, node string) Event {
+	now := time.Now
+	if r.now != nil {
+		now = r.now
+	}
+	return Event{Time: now().UTC(), Story: storyID, Run: r.runID(sessionID, storyID), Kind: kind, Node: node}
+}
+
+// runID names a session's run of a story without revealing the session.
+func (r *Recorder) runID(sessionID, storyID string) string {
+	m := hmac.New(sha256.New, r.key)
+	m.Write([]byte(sessionID))
+	m.Write([]byte{0})
+	m.Write([]byte(storyID))
+	return hex.EncodeToString(m.Sum(nil)[:runIDBytes])
+}
+
+// choiceAt finds the choice choiceKey names at st's node, including the
+// dynamic battle keys such as "fight:attack:0".
+func choiceAt(e *game.Engine, st *game.PlayerState, choiceKey string) *game.Choice {
+	n, err := e.CurrentNode(st)
+	if err != nil {
+		return nil
+	}
+	for i := range n.Choices {
+		c := &n.Choices[i]
+		if c.Key == choiceKey || (c.Battle != nil && strings.HasPrefix(choiceKey, c.Key+":")) {
+			return c
+		}
+	}
+	return nil
+}
+
+// checkChance is the chance a 2d6 roll-under check had to pass.
+func checkChance(st *game.PlayerState, c *game.Check) float64 {
+	var stat int
+	switch c.Stat {
+	case game.StatStrength:
+		stat = st.Stats.Strength
+	case game.StatLuck:
+		stat = st.Stats.Luck
+	case game.StatHealth:
+		stat = st.Stats.Health
+	}
+	ways := 0
+	for d1 := 1; d1 <= 6; d1++ {
+		for d2 := 1; d2 <= 6; d2++ {
+			if d1+d2 <= stat {
+				ways++
+			}
+		}
+	}
+	return float64(ways) / 36
+}
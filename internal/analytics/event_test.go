@@ -0,0 +1,116 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+
+	"adventure/internal/game"
+)
+
+func testEngine() *game.Engine {
+	story := &game.Story{
+		Start: "gate",
+		Nodes: map[string]*game.Node{
+			"gate": {Text: "A gate.", Choices: []game.Choice{
+				{Key: "climb", Text: "Climb", Check: &game.Check{Stat: game.StatLuck, Roll: "2d6", Target: "stat"}, OnSuccessNext: "yard", OnFailureNext: "gate"},
+				{Key: "fight", Text: "Fight", Next: "gate", Battle: &game.Battle{EnemyName: "Guard", EnemyHealth: 1, OnVictoryNext: "yard", OnDefeatNext: "gate"}},
+			}},
+			"yard": {Text: "Inside.", Choices: []game.Choice{{Key: "on", Text: "On", Next: "hall"}}},
+			"hall": {Text: "Home.", Ending: true},
+		},
+	}
+	return &game.Engine{Stories: map[string]*game.Story{"test": story}, Dice: func() int { return 3 }}
+}
+
+// play applies choices, recording each step, and returns what was recorded.
+func play(t *testing.T, e *game.Engine, r *Recorder, st *game.PlayerState, keys ...string) []Event {
+	t.Helper()
+	sink := r.Sink.(*MemorySink)
+	for _, k := range keys {
+		before := *st
+		res, err := e.ApplyChoice(st, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Step(context.Background(), e, "sid", &before, k, &res); err != nil {
+			t.Fatal(err)
+		}
+		*st = res.State
+	}
+	evs, _ := sink.Events(context.Background(), "")
+	return evs
+}
+
+func kinds(evs []Event) []string {
+	out := make([]string, len(evs))
+	for i, ev := range evs {
+		out[i] = ev.Kind + ":" + ev.Node + ":" + ev.Choice + ":" + ev.Outcome
+	}
+	return out
+}
+
+func TestRecorder_Step(t *testing.T) {
+	e := testEngine()
+	r := NewRecorder(NewMemorySink(), []byte("key"))
+	st := game.NewPlayer("test", "gate")
+	st.Stats = game.Stats{Strength: 12, Luck: 5, Health: 12}
+	if err := r.Begin(context.Background(), "sid", &st); err != nil {
+		t.Fatal(err)
+	}
+	// Luck 5 fails a roll of 6 and stays put; then the guard falls at
+	// once, and a refused choice records nothing.
+	evs := play(t, e, r, &st, "climb", "fight:attack:0", "nope", "on")
+	want := []string{
+		"start:gate::",
+		"choice:gate:climb:", "check:gate:climb:failure",
+		"choice:gate:fight:",
+		"battle:gate:fight:victory", "node:yard::",
+		"choice:yard:on:", "node:hall::", "ending:hall::",
+	}
+	if got := kinds(evs); len(got) != len(want) {
+		t.Fatalf("Events = %v, want %v", got, want)
+	} else {
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Event %d = %s, want %s", i, got[i], want[i])
+			}
+		}
+	}
+	if evs[2].Expected != 10.0/36 {
+		t.Errorf("Expected a 10/36 chance for luck 5, got %v", evs[2].Expected)
+	}
+	for _, ev := range evs {
+		if ev.Run != evs[0].Run || ev.Run == "sid" || len(ev.Run) != 2*runIDBytes || ev.Story != "test" {
+			t.Fatalf("Unexpected run or story in %+v", ev)
+		}
+	}
+	if NewRecorder(NewMemorySink(), []byte("other")).runID("sid", "test") == evs[0].Run {
+		t.Error("Expected run IDs to depend on the key")
+	}
+
+	var none *Recorder
+	if err := none.Step(context.Background(), e, "sid", &st, "on", &game.StepResult{}); err != nil {
+		t.Errorf("Expected a nil recorder to do nothing, got %v", err)
+	}
+}
+
+func TestRecorder_Death(t *testing.T) {
+	e := testEngine()
+	e.Stories["test"].Nodes[game.DeathNodeID] = &game.Node{Text: "Dead.", Ending: true}
+	r := NewRecorder(NewMemorySink(), nil)
+	st := game.NewPlayer("test", "gate")
+	st.Stats = game.Stats{Strength: 1, Luck: 5, Health: 1}
+	e.Dice = func() int { return 1 } // the guard rolls as low as the player but is stronger
+	e.Stories["test"].Nodes["gate"].Choices[1].Battle.EnemyStrength = 6
+	evs := play(t, e, r, &st, "fight:attack:0")
+	got := kinds(evs)
+	want := []string{"choice:gate:fight:", "battle:gate:fight:defeat", "node:death::", "death:gate::", "ending:death::"}
+	if len(got) != len(want) {
+		t.Fatalf("Events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Event %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
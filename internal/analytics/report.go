@@ -0,0 +1,266 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"adventure/internal/game"
+)
+
+// Report summarises a story's events.
+type Report struct {
+	Story     string       `json:"story"`
+	Runs      int          `json:"runs"`
+	Finished  int          `json:"finished"`  // reached an ending
+	Died      int          `json:"died"`      // died, whether or not that was an ending
+	Abandoned int          `json:"abandoned"` // idle without reaching an ending
+	Playing   int          `json:"playing"`   // neither finished nor abandoned yet
+	Funnel    []NodeStat   `json:"funnel"`    // runs that entered each node, most first
+	Choices   []ChoiceStat `json:"choices"`   // by node, most taken first
+	Checks    []CheckStat  `json:"checks"`
+	Battles   []BattleStat `json:"battles"`
+	Endings   []NodeStat   `json:"endings"`
+	Deaths    []NodeStat   `json:"deaths"`   // where the fatal move was made
+	DropOffs  []NodeStat   `json:"dropOffs"` // where abandoned runs were left
+	Since     time.Time    `json:"since"`    // oldest event
+	Until     time.Time    `json:"until"`    // newest event
+}
+
+// NodeStat counts runs at a node.
+type NodeStat struct {
+	Node string  `json:"node"`
+	Runs int     `json:"runs"`
+	Rate float64 `json:"rate"` // share of all runs
+}
+
+// ChoiceStat counts how often a choice was taken.
+type ChoiceStat struct {
+	Node   string  `json:"node"`
+	Choice string  `json:"choice"`
+	Taken  int     `json:"taken"`
+	Share  float64 `json:"share"` // share of the choices taken at the node
+}
+
+// CheckStat compares how often a check passed with the chance it had.
+type CheckStat struct {
+	Node     string  `json:"node"`
+	Choice   string  `json:"choice"`
+	Attempts int     `json:"attempts"`
+	Passed   int     `json:"passed"`
+	PassRate float64 `json:"passRate"`
+	Expected float64 `json:"expected"` // mean chance to pass given the players' stats
+}
+
+// BattleStat counts how battles at a choice ended.
+type BattleStat struct {
+	Node   string `json:"node"`
+	Choice string `json:"choice"`
+	Won    int    `json:"won"`
+	Lost   int    `json:"lost"`
+	Fled   int    `json:"fled"`
+}
+
+// Summarize builds the report for a story's events, oldest first. A run
+// that has not reached an ending counts as abandoned once its last event
+// is older than idleSince; until then it is still being played.
+func Summarize(storyID string, events []Event, idleSince time.Time) *Report {
+	r := &Report{Story: storyID}
+	type runState struct {
+		node     string
+		last     time.Time
+		finished bool
+	}
+	runs := map[string]*runState{}
+	entered := map[string]map[string]bool{} // node -> runs
+	choices := map[[2]string]*ChoiceStat{}
+	takenAt := map[string]int{}
+	checks := map[[2]string]*CheckStat{}
+	battles := map[[2]string]*BattleStat{}
+	endings, deaths := map[string]map[string]bool{}, map[string]map[string]bool{}
+	died := map[string]bool{}
+
+	mark := func(m map[string]map[string]bool, node, run string) {
+		if m[node] == nil {
+			m[node] = map[string]bool{}
+		}
+		m[node][run] = true
+	}
+	for _, ev := range events {
+		if storyID != "" && ev.Story != storyID {
+			continue
+		}
+		if r.Since.IsZero() || ev.Time.Before(r.Since) {
+			r.Since = ev.Time
+		}
+		if ev.Time.After(r.Until) {
+			r.Until = ev.Time
+		}
+		rs := runs[ev.Run]
+		if rs == nil {
+			rs = &runState{}
+			runs[ev.Run] = rs
+		}
+		if ev.Time.After(rs.last) {
+			rs.last = ev.Time
+		}
+		key := [2]string{ev.Node, ev.Choice}
+		switch ev.Kind {
+		case KindStart, KindNode:
+			rs.node = ev.Node
+			mark(entered, ev.Node, ev.Run)
+		case KindChoice:
+			c := choices[key]
+			if c == nil {
+				c = &ChoiceStat{Node: ev.Node, Choice: ev.Choice}
+				choices[key] = c
+			}
+			c.Taken++
+			takenAt[ev.Node]++
+		case KindCheck:
+			c := checks[key]
+			if c == nil {
+				c = &CheckStat{Node: ev.Node, Choice: ev.Choice}
+				checks[key] = c
+			}
+			c.Attempts++
+			if ev.Outcome == game.OutcomeSuccess {
+				c.Passed++
+			}
+			c.Expected += ev.Expected
+		case KindBattle:
+			b := battles[key]
+			if b == nil {
+				b = &BattleStat{Node: ev.Node, Choice: ev.Choice}
+				battles[key] = b
+			}
+			switch ev.Outcome {
+			case OutcomeVictory:
+				b.Won++
+			case OutcomeDefeat:
+				b.Lost++
+			case OutcomeFled:
+				b.Fled++
+			}
+		case KindDeath:
+			died[ev.Run] = true
+			mark(deaths, ev.Node, ev.Run)
+		case KindEnding:
+			rs.finished = true
+			mark(endings, ev.Node, ev.Run)
+		}
+	}
+
+	r.Runs = len(runs)
+	r.Died = len(died)
+	dropOffs := map[string]map[string]bool{}
+	for id, rs := range runs {
+		switch {
+		case rs.finished:
+			r.Finished++
+		case rs.last.Before(idleSince):
+			r.Abandoned++
+			mark(dropOffs, rs.node, id)
+		default:
+			r.Playing++
+		}
+	}
+	r.Funnel = r.nodeStats(entered)
+	r.Endings = r.nodeStats(endings)
+	r.Deaths = r.nodeStats(deaths)
+	r.DropOffs = r.nodeStats(dropOffs)
+
+	for _, c := range choices {
+		c.Share = rate(c.Taken, takenAt[c.Node])
+		r.Choices = append(r.Choices, *c)
+	}
+	sort.Slice(r.Choices, func(i, j int) bool {
+		a, b := r.Choices[i], r.Choices[j]
+		if a.Node != b.Node {
+			return a.Node < b.Node
+		}
+		if a.Taken != b.Taken {
+			return a.Taken > b.Taken
+		}
+		return a.Choice < b.Choice
+	})
+	for _, c := range checks {
+		c.PassRate = rate(c.Passed, c.Attempts)
+		c.Expected /= float64(c.Attempts)
+		r.Checks = append(r.Checks, *c)
+	}
+	sort.Slice(r.Checks, func(i, j int) bool {
+		return lessKey(r.Checks[i].Node, r.Checks[i].Choice, r.Checks[j].Node, r.Checks[j].Choice)
+	})
+	for _, b := range battles {
+		r.Battles = append(r.Battles, *b)
+	}
+	sort.Slice(r.Battles, func(i, j int) bool {
+		return lessKey(r.Battles[i].Node, r.Battles[i].Choice, r.Battles[j].Node, r.Battles[j].Choice)
+	})
+	return r
+}
+
+// nodeStats counts the runs at each node, most first.
+func (r *Report) nodeStats(m map[string]map[string]bool) []NodeStat {
+	out := make([]NodeStat, 0, len(m))
+	for node, runs := range m {
+		out = append(out, NodeStat{Node: node, Runs: len(runs), Rate: rate(len(runs), r.Runs)})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Runs != out[j].Runs {
+			return out[i].Runs > out[j].Runs
+		}
+		return out[i].Node < out[j].Node
+	})
+	return out
+}
+
+func lessKey(n1, c1, n2, c2 string) bool {
+	if n1 != n2 {
+		return n1 < n2
+	}
+	return c1 < c2
+}
+
+func rate(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return float64(n) / float64(of)
+}
+
+// csvHeader is the column order of WriteCSV.
+var csvHeader = []string{"time", "story", "run", "kind", "node", "choice", "outcome", "expected"}
+
+// WriteCSV writes events as CSV with a header row.
+func WriteCSV(w io.Writer, events []Event) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, ev := range events {
+		expected := ""
+		if ev.Kind == KindCheck {
+			expected = strconv.FormatFloat(ev.Expected, 'f', 4, 64)
+		}
+		if err := cw.Write([]string{ev.Time.Format(time.RFC3339Nano), ev.Story, ev.Run, ev.Kind, ev.Node, ev.Choice, ev.Outcome, expected}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes events as a JSON array.
+func WriteJSON(w io.Writer, events []Event) error {
+	if events == nil {
+		events = []Event{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(events)
+}
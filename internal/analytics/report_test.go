@@ -0,0 +1,83 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	at := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	ev := func(run, kind, node, choice, outcome string, minute int) Event {
+		return Event{Time: at.Add(time.Duration(minute) * time.Minute), Story: "a", Run: run, Kind: kind, Node: node, Choice: choice, Outcome: outcome}
+	}
+	events := []Event{
+		// r1 finishes, r2 dies, r3 wanders off, r4 is still playing.
+		ev("r1", KindStart, "gate", "", "", 0),
+		ev("r1", KindChoice, "gate", "climb", "", 1),
+		ev("r1", KindCheck, "gate", "climb", "success", 1),
+		ev("r1", KindNode, "yard", "", "", 1),
+		ev("r1", KindEnding, "yard", "", "", 1),
+		ev("r2", KindStart, "gate", "", "", 0),
+		ev("r2", KindChoice, "gate", "fight", "", 1),
+		ev("r2", KindBattle, "gate", "fight", OutcomeDefeat, 2),
+		ev("r2", KindDeath, "gate", "", "", 2),
+		ev("r3", KindStart, "gate", "", "", 0),
+		ev("r3", KindChoice, "gate", "climb", "", 1),
+		ev("r3", KindCheck, "gate", "climb", "failure", 1),
+		ev("r4", KindStart, "gate", "", "", 50),
+		{Story: "b", Run: "r9", Kind: KindStart, Node: "camp"},
+	}
+	events[2].Expected, events[11].Expected = 0.25, 0.75
+
+	r := Summarize("a", events, at.Add(30*time.Minute))
+	if r.Runs != 4 || r.Finished != 1 || r.Died != 1 || r.Abandoned != 2 || r.Playing != 1 {
+		t.Errorf("Unexpected counts %+v", r)
+	}
+	if f := r.Funnel; len(f) != 2 || f[0] != (NodeStat{Node: "gate", Runs: 4, Rate: 1}) || f[1].Node != "yard" || f[1].Rate != 0.25 {
+		t.Errorf("Unexpected funnel %+v", f)
+	}
+	if c := r.Choices; len(c) != 2 || c[0].Choice != "climb" || c[0].Taken != 2 || c[0].Share != 2.0/3 {
+		t.Errorf("Unexpected choices %+v", c)
+	}
+	if c := r.Checks; len(c) != 1 || c[0].Attempts != 2 || c[0].PassRate != 0.5 || c[0].Expected != 0.5 {
+		t.Errorf("Unexpected checks %+v", c)
+	}
+	if b := r.Battles; len(b) != 1 || b[0].Lost != 1 {
+		t.Errorf("Unexpected battles %+v", b)
+	}
+	// r2 died without an ending node, so it was left at the gate too.
+	if d := r.DropOffs; len(d) != 1 || d[0].Node != "gate" || d[0].Runs != 2 {
+		t.Errorf("Unexpected drop-offs %+v", d)
+	}
+	if len(r.Deaths) != 1 || len(r.Endings) != 1 || !r.Until.Equal(at.Add(50*time.Minute)) {
+		t.Errorf("Unexpected deaths %+v, endings %+v or until %v", r.Deaths, r.Endings, r.Until)
+	}
+}
+
+func TestExport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, testEvents()); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || lines[0] != "time,story,run,kind,node,choice,outcome,expected" {
+		t.Fatalf("Unexpected CSV %q", buf.String())
+	}
+	if lines[3] != "2026-10-14T12:00:00Z,a,r1,check,gate,climb,success,0.5000" {
+		t.Errorf("Unexpected CSV row %q", lines[3])
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, nil); err != nil || strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("Expected an empty array, got %q, %v", buf.String(), err)
+	}
+	buf.Reset()
+	_ = WriteJSON(&buf, testEvents())
+	var back []Event
+	if err := json.Unmarshal(buf.Bytes(), &back); err != nil || len(back) != 3 || back[2].Choice != "climb" {
+		t.Errorf("Round trip failed: %+v, %v", back, err)
+	}
+}
@@ -0,0 +1,144 @@
+package analytics
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultMaxEvents is how many events a MemorySink keeps when MaxEvents is
+// unset.
+const DefaultMaxEvents = 100000
+
+// MemorySink keeps the most recent events in memory.
+type MemorySink struct {
+	// MaxEvents caps the events kept; the oldest are dropped first. 0 means
+	// DefaultMaxEvents.
+	MaxEvents int
+
+	mu     sync.Mutex
+	events []Event
+}
+
+// NewMemorySink returns an empty in-memory sink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Record implements Sink.
+func (m *MemorySink) Record(_ context.Context, events ...Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, events...)
+	maxEvents := m.MaxEvents
+	if maxEvents <= 0 {
+		maxEvents = DefaultMaxEvents
+	}
+	if over := len(m.events) - maxEvents; over > 0 {
+		m.events = append(m.events[:0:0], m.events[over:]...)
+	}
+	return nil
+}
+
+// Events implements Source.
+func (m *MemorySink) Events(_ context.Context, storyID string) ([]Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return filterStory(m.events, storyID), nil
+}
+
+// FileSink appends events to a file as JSON lines.
+type FileSink struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenFileSink opens the event log at path, creating it and its directory
+// if needed.
+func OpenFileSink(path string) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{path: path, f: f}, nil
+}
+
+// Record implements Sink. Events are written in one write, so a crash
+// loses at most a partial last line, which Events skips.
+func (s *FileSink) Record(_ context.Context, events ...Event) error {
+	var buf []byte
+	for i := range events {
+		b, err := json.Marshal(&events[i])
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, b...), '\n')
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return errors.New("analytics: file sink is closed")
+	}
+	_, err := s.f.Write(buf)
+	return err
+}
+
+// Events implements Source by reading the log back.
+func (s *FileSink) Events(_ context.Context, storyID string) ([]Event, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []Event
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break // a partial last line was not fully written
+		}
+		if err != nil {
+			return nil, err
+		}
+		var ev Event
+		if json.Unmarshal(line, &ev) != nil {
+			continue
+		}
+		if storyID == "" || ev.Story == storyID {
+			out = append(out, ev)
+		}
+	}
+	return out, nil
+}
+
+// Close closes the log.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+func filterStory(events []Event, storyID string) []Event {
+	out := make([]Event, 0, len(events))
+	for _, ev := range events {
+		if storyID == "" || ev.Story == storyID {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package analytics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testEvents() []Event {
+	at := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	return []Event{
+		{Time: at, Story: "a", Run: "r1", Kind: KindStart, Node: "gate"},
+		{Time: at, Story: "b", Run: "r2", Kind: KindStart, Node: "camp"},
+		{Time: at, Story: "a", Run: "r1", Kind: KindCheck, Node: "gate", Choice: "climb", Outcome: "success", Expected: 0.5},
+	}
+}
+
+func TestMemorySink(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemorySink()
+	m.MaxEvents = 2
+	_ = m.Record(ctx, testEvents()...)
+	all, _ := m.Events(ctx, "")
+	if len(all) != 2 || all[0].Story != "b" {
+		t.Fatalf("Expected the oldest event dropped, got %+v", all)
+	}
+	if a, _ := m.Events(ctx, "a"); len(a) != 1 || a[0].Kind != KindCheck {
+		t.Errorf("Expected story a's check, got %+v", a)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "data", "events.log")
+	s, err := OpenFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(ctx, testEvents()...); err != nil {
+		t.Fatal(err)
+	}
+	// A torn last line from a crash is skipped.
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	_, _ = f.WriteString(`{"story":"a","ki`)
+	_ = f.Close()
+
+	got, err := s.Events(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Expected != 0.5 || !got[0].Time.Equal(testEvents()[0].Time) {
+		t.Errorf("Unexpected events %+v", got)
+	}
+	_ = s.Close()
+	if err := s.Record(ctx, testEvents()...); err == nil {
+		t.Error("Expected an error after Close")
+	}
+}
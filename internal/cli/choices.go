@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"strconv"
+	"strings"
+
+	"adventure/internal/game"
+)
+
+// option is one thing the player can pick at a node.
+type option struct {
+	Key    string       // key passed to the engine, e.g. "north" or "fight:attack:1"
+	Text   string       // shown in the list and matched by keyword
+	Prompt *game.Prompt // set when the choice asks for a typed answer
+}
+
+// options lists what the player can pick at n. During a battle these are an
+// attack and a luck attack per enemy plus running away, as in the web UI;
+// otherwise the node's available choices.
+func (g *Game) options(n *game.Node) []option {
+	if len(g.st.Enemies) > 0 {
+		for i := range n.Choices {
+			ch := &n.Choices[i]
+			if ch.Battle == nil {
+				continue
+			}
+			var out []option
+			for j, e := range g.st.Enemies {
+				idx := strconv.Itoa(j)
+				out = append(out,
+					option{Key: ch.Key + ":attack:" + idx, Text: "Attack " + e.Name},
+					option{Key: ch.Key + ":luck:" + idx, Text: "Luck " + e.Name})
+			}
+			if ch.Next != "" {
+				out = append(out, option{Key: ch.Key + ":run", Text: "Run away"})
+			}
+			return out
+		}
+	}
+	choices := g.Engine.AvailableChoices(&g.st, n)
+	out := make([]option, 0, len(choices))
+	for i := range choices {
+		ch := &choices[i]
+		text := ch.Text
+		if text == "" {
+			text = ch.Key
+		}
+		out = append(out, option{Key: ch.Key, Text: text, Prompt: ch.Prompt})
+	}
+	return out
+}
+
+// match finds the option the player meant: a 1-based number, an exact key, or
+// the single option whose key or text words start with every word typed.
+// Otherwise it returns nil and a message saying why.
+func match(opts []option, line string) (*option, string) {
+	in := strings.ToLower(strings.TrimSpace(line))
+	if in == "" {
+		return nil, "Type a number, a keyword, or help."
+	}
+	if n, err := strconv.Atoi(in); err == nil {
+		if n >= 1 && n <= len(opts) {
+			return &opts[n-1], ""
+		}
+		return nil, "Pick 1-" + strconv.Itoa(len(opts)) + "."
+	}
+	if o := byKey(opts, in); o != nil {
+		return o, ""
+	}
+	var found []int
+	for i := range opts {
+		if keywordsMatch(&opts[i], strings.Fields(in)) {
+			found = append(found, i)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, "I don't understand \"" + line + "\". Type help for commands."
+	case 1:
+		return &opts[found[0]], ""
+	default:
+		names := make([]string, len(found))
+		for i, j := range found {
+			names[i] = strconv.Itoa(j+1) + ") " + opts[j].Text
+		}
+		return nil, "Did you mean " + strings.Join(names, " or ") + "?"
+	}
+}
+
+// byKey returns the option whose key is line, ignoring case, or nil.
+func byKey(opts []option, line string) *option {
+	line = strings.TrimSpace(line)
+	for i := range opts {
+		if strings.EqualFold(opts[i].Key, line) {
+			return &opts[i]
+		}
+	}
+	return nil
+}
+
+// keywordsMatch reports whether every typed word starts a word of o's text or
+// key. Battle keys ("fight:attack:0") are left out: their 0-based index would
+// clash with the 1-based numbers shown.
+func keywordsMatch(o *option, words []string) bool {
+	text := o.Text
+	if !strings.Contains(o.Key, ":") {
+		text += " " + o.Key
+	}
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	for _, w := range words {
+		ok := false
+		for _, f := range fields {
+			if strings.HasPrefix(f, w) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	opts := []option{
+		{Key: "north", Text: "Head north into the trees"},
+		{Key: "train", Text: "Practice your skills"},
+		{Key: "fight:attack:0", Text: "Attack Goblin"},
+		{Key: "fight:attack:1", Text: "Attack Orc"},
+	}
+	tests := []struct {
+		in, key, msg string
+	}{
+		{"1", "north", ""},
+		{"4", "fight:attack:1", ""},
+		{"5", "", "Pick 1-4."},
+		{"NORTH", "north", ""},
+		{"fight:attack:0", "fight:attack:0", ""},
+		{"practice", "train", ""},
+		{"tra", "train", ""},
+		{"attack orc", "fight:attack:1", ""},
+		{"att", "", "Did you mean 3) Attack Goblin or 4) Attack Orc?"},
+		{"attack 0", "", `I don't understand "attack 0"`},
+		{"", "", "Type a number"},
+	}
+	for _, tt := range tests {
+		o, msg := match(opts, tt.in)
+		switch {
+		case tt.key != "" && (o == nil || o.Key != tt.key):
+			t.Errorf("match(%q) = %+v, %q; want key %q", tt.in, o, msg, tt.key)
+		case tt.key == "" && (o != nil || !strings.Contains(msg, tt.msg)):
+			t.Errorf("match(%q) = %+v, %q; want message %q", tt.in, o, msg, tt.msg)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+// Package cli plays stories in a terminal using game.Engine directly: character
+// creation, checks, prompts and multi-enemy battles, with ZX81-style ASCII
+// dice and panels. Choices are picked by number or by keyword, and games can
+// be saved to and loaded from a local file.
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"adventure/internal/game"
+)
+
+const maxNameLen = 32
+
+var (
+	// errQuit ends the session: the player typed quit or input ran out.
+	errQuit = errors.New("quit")
+	// errNewGame restarts at character creation.
+	errNewGame = errors.New("new game")
+)
+
+const helpText = `Type a choice's number, its key, or words from its text (e.g. "attack goblin").
+Commands:
+  look          show the scene again
+  save [file]   save the game (default: %[1]s)
+  load [file]   load a saved game (default: %[1]s)
+  new           start a new character
+  help          show this help
+  quit          leave the game
+`
+
+// Game is a terminal session. Create it with New.
+type Game struct {
+	Engine *game.Engine
+	// SavePath is the file used by save and load when none is given.
+	SavePath string
+	// RollStats rolls starting stats; nil uses game.RollStatsDetailed.
+	RollStats func() (game.Stats, [3][2]int)
+
+	in      *bufio.Scanner
+	out     io.Writer
+	st      game.PlayerState
+	started bool // st is a game in progress
+}
+
+// New returns a session reading commands from in and writing to out.
+func New(e *game.Engine, in io.Reader, out io.Writer) *Game {
+	return &Game{Engine: e, SavePath: DefaultSavePath, in: bufio.NewScanner(in), out: out}
+}
+
+// Resume continues from a saved state instead of creating a character.
+func (g *Game) Resume(st game.PlayerState) error {
+	if _, err := g.Engine.CurrentNode(&st); err != nil {
+		return err
+	}
+	g.st, g.started = st, true
+	return nil
+}
+
+// State returns the current player state.
+func (g *Game) State() game.PlayerState { return g.st }
+
+// Run plays until the player quits or input ends.
+func (g *Game) Run() error {
+	for {
+		if !g.started {
+			if err := g.create(); err != nil {
+				if errors.Is(err, errQuit) {
+					return nil
+				}
+				return err
+			}
+		}
+		err := g.play()
+		switch {
+		case errors.Is(err, errQuit):
+			return nil
+		case errors.Is(err, errNewGame):
+			g.started = false
+		case err != nil:
+			return err
+		}
+	}
+}
+
+// ask prints prompt and reads a trimmed line. It returns errQuit at end of input.
+func (g *Game) ask(prompt string) (string, error) {
+	fmt.Fprint(g.out, prompt)
+	if !g.in.Scan() {
+		fmt.Fprintln(g.out)
+		if err := g.in.Err(); err != nil {
+			return "", err
+		}
+		return "", errQuit
+	}
+	return strings.TrimSpace(g.in.Text()), nil
+}
+
+// yes asks a yes/no question; an empty answer means def.
+func (g *Game) yes(prompt string, def bool) (bool, error) {
+	line, err := g.ask(prompt)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(line) {
+	case "":
+		return def, nil
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// pick lists items and reads a 1-based number or an item ID; empty picks def.
+func (g *Game) pick(title string, ids, labels []string, def int) (int, error) {
+	fmt.Fprintln(g.out, title)
+	for i, l := range labels {
+		mark := ""
+		if i == def {
+			mark = " (default)"
+		}
+		fmt.Fprintf(g.out, "  %d) %s%s\n", i+1, l, mark)
+	}
+	for {
+		line, err := g.ask("> ")
+		if err != nil {
+			return 0, err
+		}
+		if line == "" && def >= 0 {
+			return def, nil
+		}
+		if n, err := strconv.Atoi(line); err == nil && n >= 1 && n <= len(ids) {
+			return n - 1, nil
+		}
+		for i, id := range ids {
+			if strings.EqualFold(line, id) {
+				return i, nil
+			}
+		}
+		fmt.Fprintf(g.out, "Pick 1-%d.\n", len(ids))
+	}
+}
+
+// create runs character creation: story, difficulty, name, stats with
+// rerolls and, when a hero qualifies, continuing into a sequel. Records from
+// the previous character carry over so endings and sequels keep working.
+func (g *Game) create() error {
+	ids := g.playableStories()
+	if len(ids) == 0 {
+		return errors.New("no playable stories")
+	}
+	storyID := ids[0]
+	if len(ids) > 1 {
+		labels := make([]string, len(ids))
+		def := 0
+		for i, id := range ids {
+			labels[i] = storyTitle(id, g.Engine.Stories[id])
+			if id == game.DefaultStoryID {
+				def = i
+			}
+		}
+		i, err := g.pick("Choose your adventure:", ids, labels, def)
+		if err != nil {
+			return err
+		}
+		storyID = ids[i]
+	}
+	story := g.Engine.Stories[storyID]
+
+	st := game.NewPlayer(storyID, story.Start)
+	st.Records = g.st.Records
+	if len(story.Difficulties) > 0 {
+		dids := make([]string, len(story.Difficulties))
+		labels := make([]string, len(story.Difficulties))
+		def := 0
+		for i, d := range story.Difficulties {
+			dids[i] = d.ID
+			labels[i] = d.Name
+			if labels[i] == "" {
+				labels[i] = storyTitle(d.ID, nil)
+			}
+			if d.Description != "" {
+				labels[i] += " - " + d.Description
+			}
+			if d.ID == story.DefaultDifficulty().ID {
+				def = i
+			}
+		}
+		i, err := g.pick("Choose a difficulty:", dids, labels, def)
+		if err != nil {
+			return err
+		}
+		st.Difficulty = dids[i]
+	}
+	diff := story.ResolveDifficulty(st.Difficulty)
+
+	name, err := g.ask("Name your hero: ")
+	if err != nil {
+		return err
+	}
+	if len(name) > maxNameLen {
+		name = name[:maxNameLen]
+	}
+	st.Name = name
+
+	roll := g.RollStats
+	if roll == nil {
+		roll = game.RollStatsDetailed
+	}
+	allowance := diff.RerollAllowance()
+	for {
+		base, dice := roll()
+		st.BaseStats = base
+		st.Stats = diff.ApplyToStats(base)
+		writeStatDice(g.out, base, dice)
+		if st.Stats != base {
+			fmt.Fprintf(g.out, "With difficulty: Strength %d   Luck %d   Health %d\n", st.Stats.Strength, st.Stats.Luck, st.Stats.Health)
+		}
+		if st.Rerolls >= allowance {
+			break
+		}
+		reroll, err := g.yes(fmt.Sprintf("Reroll? (%d left) [y/N] ", allowance-st.Rerolls), false)
+		if err != nil {
+			return err
+		}
+		if !reroll {
+			break
+		}
+		st.Rerolls++
+	}
+
+	if h := g.Engine.SequelHero(&st, storyID); h != nil {
+		who := h.Name
+		if who == "" {
+			who = "your hero"
+		}
+		cont, err := g.yes(fmt.Sprintf("Continue as %s from %s? [Y/n] ", who, storyTitle(story.Continues.From, g.Engine.Stories[story.Continues.From])), true)
+		if err != nil {
+			return err
+		}
+		if cont {
+			if err := g.Engine.BeginSequel(&st, storyID); err != nil {
+				return err
+			}
+		}
+	}
+	st.MarkBegun()
+	g.st, g.started = st, true
+	return nil
+}
+
+// play runs the story loop until the player quits, starts over or an error occurs.
+func (g *Game) play() error {
+	g.show()
+	for {
+		n, err := g.Engine.CurrentNode(&g.st)
+		if err != nil {
+			return err
+		}
+		opts := g.options(n)
+		if n.Ending || len(opts) == 0 {
+			fmt.Fprintln(g.out, "\n-- THE END --")
+			again, err := g.yes("Play again? [y/N] ", false)
+			if err != nil {
+				return err
+			}
+			if again {
+				return errNewGame
+			}
+			return errQuit
+		}
+
+		line, err := g.ask("> ")
+		if err != nil {
+			return err
+		}
+		// A choice key wins over a command of the same name.
+		o := byKey(opts, line)
+		if o == nil {
+			if done, err := g.command(line); err != nil {
+				return err
+			} else if done {
+				continue
+			}
+			var msg string
+			if o, msg = match(opts, line); o == nil {
+				fmt.Fprintln(g.out, msg)
+				continue
+			}
+		}
+		answer := ""
+		if o.Prompt != nil {
+			if answer, err = g.ask(o.Prompt.Question + " "); err != nil {
+				return err
+			}
+		}
+		res, err := g.Engine.ApplyChoiceWithAnswer(&g.st, o.Key, answer)
+		if err != nil {
+			return err
+		}
+		writeResult(g.out, &res)
+		if res.ErrorMessage == "" {
+			g.show()
+			if res.NewEnding {
+				fmt.Fprintln(g.out, "New ending discovered!")
+			}
+		}
+	}
+}
+
+// command handles the non-choice commands. done reports that the line was a
+// command; a non-nil error ends play (quit, new game or a failed load).
+func (g *Game) command(line string) (done bool, err error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+	arg := g.SavePath
+	if len(fields) > 1 {
+		arg = strings.Join(fields[1:], " ")
+	}
+	switch strings.ToLower(fields[0]) {
+	case "quit", "exit":
+		return true, errQuit
+	case "new":
+		return true, errNewGame
+	case "help", "?":
+		fmt.Fprintf(g.out, helpText, g.SavePath)
+	case "look":
+		g.show()
+	case "save":
+		if err := Save(arg, &g.st); err != nil {
+			fmt.Fprintf(g.out, "Could not save: %v\n", err)
+		} else {
+			fmt.Fprintf(g.out, "Saved to %s.\n", arg)
+		}
+	case "load":
+		st, err := Load(arg)
+		if err == nil {
+			err = g.Resume(st)
+		}
+		if err != nil {
+			fmt.Fprintf(g.out, "Could not load: %v\n", err)
+		} else {
+			fmt.Fprintf(g.out, "Loaded %s.\n", arg)
+			g.show()
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// show draws the current scene.
+func (g *Game) show() {
+	n, err := g.Engine.CurrentNode(&g.st)
+	if err != nil {
+		fmt.Fprintln(g.out, err)
+		return
+	}
+	writeNode(g.out, &g.st, n, g.options(n))
+}
+
+// playableStories returns the IDs of stories a player may start, sorted.
+func (g *Game) playableStories() []string {
+	var ids []string
+	for id, s := range g.Engine.Stories {
+		if !s.Library {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// storyTitle returns s's title, or a capitalised ID when it has none.
+func storyTitle(id string, s *game.Story) string {
+	if s != nil && s.Title != "" {
+		return s.Title
+	}
+	if id == "" {
+		return ""
+	}
+	return strings.ToUpper(id[:1]) + strings.ReplaceAll(id[1:], "_", " ")
+}
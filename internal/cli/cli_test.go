@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"adventure/internal/game"
+)
+
+// roadStories is a small story with a luck check, a riddle, a two-enemy
+// battle and difficulties, plus a library story that must not be offered.
+func roadStories() map[string]*game.Story {
+	return map[string]*game.Story{
+		"road": {
+			Title: "The Road",
+			Start: "camp",
+			Difficulties: []game.Difficulty{
+				{ID: "easy", Name: "Easy", Description: "More health", Stats: game.Stats{Health: 4}},
+				{ID: "normal", Default: true},
+			},
+			Nodes: map[string]*game.Node{
+				"camp": {Text: "A quiet camp.", Choices: []game.Choice{
+					{Key: "sneak", Text: "Sneak into the woods", Check: &game.Check{Stat: game.StatLuck, Roll: "2d6", Target: "stat"}, OnSuccessNext: "home", OnFailureNext: "bridge"},
+					{Key: "bridge", Text: "Walk to the bridge", Next: "bridge"},
+					{Key: "stone", Text: "Read the stone", Prompt: &game.Prompt{
+						Question: "What has keys but opens no locks?",
+						Answers:  []game.Answer{{Match: "piano", Next: "home"}},
+					}},
+					{Key: "look", Text: "Look around", Next: "camp"},
+				}},
+				"bridge": {Text: "Two trolls guard the bridge.", Choices: []game.Choice{
+					{Key: "fight", Text: "Fight the trolls", Next: "camp", Battle: &game.Battle{
+						Enemies: []game.Enemy{
+							{Name: "Big Troll", Strength: 5, Health: 1},
+							{Name: "Small Troll", Strength: 5, Health: 1},
+						},
+						OnVictoryNext: "home",
+					}},
+				}},
+				"home":  {Text: "Home at last.", Ending: true},
+				"death": {Text: "You died.", Ending: true},
+			},
+		},
+		"lib": {Title: "Library", Library: true, Start: "x", Nodes: map[string]*game.Node{"x": {}}},
+	}
+}
+
+// newTestGame returns a game with fixed dice and stats reading the given lines.
+func newTestGame(dice []int, lines ...string) (*Game, *strings.Builder) {
+	var out strings.Builder
+	e := &game.Engine{Stories: roadStories(), Dice: func() int {
+		v := dice[0]
+		dice = dice[1:]
+		return v
+	}}
+	g := New(e, strings.NewReader(strings.Join(lines, "\n")+"\n"), &out)
+	g.RollStats = func() (game.Stats, [3][2]int) {
+		return game.Stats{Strength: 9, Luck: 7, Health: 14}, [3][2]int{{1, 2}, {3, 4}, {5, 3}}
+	}
+	return g, &out
+}
+
+func TestRun_CheckToEnding(t *testing.T) {
+	// Story picked by default (the only playable one), normal difficulty, no reroll.
+	g, out := newTestGame([]int{1, 2}, "", "Ann", "n", "1", "n")
+	if err := g.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	st := g.State()
+	if st.NodeID != "home" || st.Name != "Ann" || st.Difficulty != "normal" || !st.RerollUsed {
+		t.Errorf("Unexpected final state %+v", st)
+	}
+	for _, want := range []string{
+		"Choose a difficulty:", "2) Normal (default)", "Easy - More health",
+		"Strength 9 (2d6+6)", "Reroll? (1 left)",
+		"+-- ANN --", "STRENGTH  9   LUCK  7   HEALTH 14",
+		"1) Sneak into the woods", "Roll: 3  Success!", "Home at last.",
+		"New ending discovered!", "-- THE END --",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in output:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "Choose your adventure") {
+		t.Error("Expected no story menu with one playable story")
+	}
+}
+
+func TestRun_DifficultyAndReroll(t *testing.T) {
+	g, out := newTestGame(nil, "easy", "", "y", "quit")
+	if err := g.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	st := g.State()
+	if st.Difficulty != "easy" || st.Stats.Health != 18 || st.Rerolls != 1 {
+		t.Errorf("Expected easy difficulty after one reroll, got %+v", st)
+	}
+	if !strings.Contains(out.String(), "With difficulty: Strength 9   Luck 7   Health 18") {
+		t.Errorf("Expected difficulty-adjusted stats in output:\n%s", out.String())
+	}
+	if strings.Count(out.String(), "Reroll?") != 1 {
+		t.Errorf("Expected one reroll offer:\n%s", out.String())
+	}
+}
+
+func TestRun_BattleByKeyword(t *testing.T) {
+	g, out := newTestGame([]int{6, 6, 1, 1, 6, 6, 1, 1},
+		"", "", "n", "bridge", "fight", "attack small", "1", "n")
+	if err := g.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	s := out.String()
+	if g.State().NodeID != "home" {
+		t.Errorf("Expected victory, got node %q\n%s", g.State().NodeID, s)
+	}
+	for _, want := range []string{
+		"+-- ENEMIES --", "1 Small Troll", "1) Attack Small Troll", "2) Luck Small Troll", "3) Run away",
+		"YOU                    FOE", "Roll: 12  Victory!",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Expected %q in output:\n%s", want, s)
+		}
+	}
+}
+
+func TestRun_PromptAndCommands(t *testing.T) {
+	g, out := newTestGame(nil, "", "", "n",
+		"help", "look", "zzz", "troll", "9", "read", "wrong", "stone", "Piano", "y",
+		"2", "", "n", "bridge", "quit")
+	if err := g.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	s := out.String()
+	for _, want := range []string{
+		"Commands:", `I don't understand "zzz"`, `I don't understand "troll"`, "Pick 1-4.",
+		"What has keys but opens no locks?", "That does not seem right.", "Home at last.",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Expected %q in output:\n%s", want, s)
+		}
+	}
+	// "look" is a choice key here, so it takes the choice rather than redrawing.
+	if strings.Contains(s, "Could not") {
+		t.Errorf("Unexpected error output:\n%s", s)
+	}
+	// Playing again keeps the records of the first character.
+	if rec := g.State().Records["road"]; len(rec.Endings) != 1 {
+		t.Errorf("Expected records to carry over, got %+v", rec)
+	}
+	if g.State().NodeID != "bridge" {
+		t.Errorf("Expected second run at the bridge, got %q", g.State().NodeID)
+	}
+}
+
+func TestRun_SaveLoadAndNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.json")
+	g, out := newTestGame(nil, "", "Bo", "n", "bridge", "save "+path, "quit")
+	if err := g.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !strings.Contains(out.String(), "Saved to "+path) {
+		t.Fatalf("Expected save confirmation:\n%s", out.String())
+	}
+
+	g2, out2 := newTestGame(nil, "", "Cy", "n", "load "+path, "load "+path+".missing", "new", "", "Di", "n")
+	g2.SavePath = path
+	if err := g2.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	s := out2.String()
+	if !strings.Contains(s, "Loaded "+path) || !strings.Contains(s, "Two trolls guard the bridge.") {
+		t.Errorf("Expected the saved game to load:\n%s", s)
+	}
+	if !strings.Contains(s, "Could not load") {
+		t.Errorf("Expected a load error for a missing file:\n%s", s)
+	}
+	if g2.State().Name != "Di" || g2.State().NodeID != "camp" {
+		t.Errorf("Expected a new character after new, got %+v", g2.State())
+	}
+}
+
+func TestResume(t *testing.T) {
+	g, out := newTestGame(nil, "quit")
+	st := game.NewPlayer("road", "bridge")
+	if err := g.Resume(st); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if err := g.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if strings.Contains(out.String(), "Name your hero") || !strings.Contains(out.String(), "Two trolls") {
+		t.Errorf("Expected to resume without character creation:\n%s", out.String())
+	}
+	if err := g.Resume(game.NewPlayer("nope", "x")); err == nil {
+		t.Error("Expected an error resuming an unknown story")
+	}
+}
+
+func TestRun_StoryMenuAndSequel(t *testing.T) {
+	stories := roadStories()
+	stories["sequel"] = &game.Story{
+		Start:     "dock",
+		Continues: &game.Continuation{From: "road"},
+		Nodes:     map[string]*game.Node{"dock": {Text: "The dock.", Ending: true}},
+	}
+	var out strings.Builder
+	g := New(&game.Engine{Stories: stories}, strings.NewReader("sequel\nEd\n\n\n"), &out)
+	g.RollStats = func() (game.Stats, [3][2]int) { return game.Stats{Strength: 8, Luck: 8, Health: 8}, [3][2]int{} }
+	g.st.Records = map[string]game.StoryRecord{"road": {Endings: []string{"home"}, Hero: &game.Hero{
+		Ending: "home", Name: "Vet", Stats: game.Stats{Strength: 12, Luck: 11, Health: 10},
+	}}}
+	if err := g.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	s := out.String()
+	if !strings.Contains(s, "1) The Road (default)") || !strings.Contains(s, "2) Sequel") || strings.Contains(s, "Library") {
+		t.Errorf("Unexpected story menu:\n%s", s)
+	}
+	if !strings.Contains(s, "Continue as Vet from The Road?") {
+		t.Errorf("Expected a sequel offer:\n%s", s)
+	}
+	if g.State().StoryID != "sequel" || g.State().Stats.Strength != 12 {
+		t.Errorf("Expected the hero carried into the sequel, got %+v", g.State())
+	}
+}
+
+func TestStoryTitle(t *testing.T) {
+	if got := storyTitle("roman_adventure", nil); got != "Roman adventure" {
+		t.Errorf("storyTitle = %q", got)
+	}
+	if got := storyTitle("x", &game.Story{Title: "Ex"}); got != "Ex" {
+		t.Errorf("storyTitle = %q", got)
+	}
+	if got := storyTitle("", nil); got != "" {
+		t.Errorf("storyTitle = %q", got)
+	}
+}
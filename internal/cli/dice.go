@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"strings"
+)
+
+// diePips marks which cells of a die's 3x3 grid hold a pip, by face value.
+var diePips = [7][3]string{
+	{"     ", "     ", "     "},
+	{"     ", "  o  ", "     "},
+	{"o    ", "     ", "    o"},
+	{"o    ", "  o  ", "    o"},
+	{"o   o", "     ", "o   o"},
+	{"o   o", "  o  ", "o   o"},
+	{"o   o", "o   o", "o   o"},
+}
+
+// dieLines returns the five lines of a ZX81-style die showing v (1-6).
+func dieLines(v int) []string {
+	if v < 0 || v > 6 {
+		v = 0
+	}
+	lines := []string{"+-------+"}
+	for _, row := range diePips[v] {
+		lines = append(lines, "| "+row+" |")
+	}
+	return append(lines, "+-------+")
+}
+
+// Dice draws dice side by side, each group labelled above its first die.
+// Groups are separated by a wider gap, e.g. the player's pair and the enemy's.
+func Dice(labels []string, groups ...[2]int) string {
+	var rows [6]strings.Builder
+	for g, pair := range groups {
+		if g > 0 {
+			for i := range rows {
+				rows[i].WriteString("    ")
+			}
+		}
+		label := ""
+		if g < len(labels) {
+			label = labels[g]
+		}
+		rows[0].WriteString(pad(label, 19))
+		a, b := dieLines(pair[0]), dieLines(pair[1])
+		for i := 0; i < 5; i++ {
+			rows[i+1].WriteString(a[i] + " " + b[i])
+		}
+	}
+	var out strings.Builder
+	for i := range rows {
+		line := strings.TrimRight(rows[i].String(), " ")
+		if i == 0 && line == "" {
+			continue
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.String()
+}
+
+// pad right-pads s with spaces to n characters.
+func pad(s string, n int) string {
+	if len(s) >= n {
+		return s
+	}
+	return s + strings.Repeat(" ", n-len(s))
+}
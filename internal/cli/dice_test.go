@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDieLines(t *testing.T) {
+	for v := 1; v <= 6; v++ {
+		lines := dieLines(v)
+		if len(lines) != 5 {
+			t.Fatalf("die %d has %d lines", v, len(lines))
+		}
+		if pips := strings.Count(strings.Join(lines, ""), "o"); pips != v {
+			t.Errorf("die %d shows %d pips", v, pips)
+		}
+	}
+	if pips := strings.Count(strings.Join(dieLines(9), ""), "o"); pips != 0 {
+		t.Errorf("out-of-range die shows %d pips", pips)
+	}
+}
+
+func TestDice(t *testing.T) {
+	got := Dice([]string{"YOU", "FOE"}, [2]int{6, 1}, [2]int{2, 3})
+	want := "" +
+		"YOU                    FOE\n" +
+		"+-------+ +-------+    +-------+ +-------+\n" +
+		"| o   o | |       |    | o     | | o     |\n" +
+		"| o   o | |   o   |    |       | |   o   |\n" +
+		"| o   o | |       |    |     o | |     o |\n" +
+		"+-------+ +-------+    +-------+ +-------+\n"
+	if got != want {
+		t.Errorf("Dice =\n%s\nwant\n%s", got, want)
+	}
+	if got := Dice(nil, [2]int{1, 1}); strings.HasPrefix(got, "\n") || strings.Count(got, "\n") != 5 {
+		t.Errorf("Expected no label line without labels:\n%s", got)
+	}
+}
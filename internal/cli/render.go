@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"adventure/internal/game"
+)
+
+// panelWidth is the inner width of the stats and enemy panels.
+const panelWidth = 44
+
+// outcomeText describes a check or battle outcome for the player.
+var outcomeText = map[string]string{
+	game.OutcomeSuccess:   "Success!",
+	game.OutcomeFailure:   "Failure.",
+	game.OutcomeVictory:   "Victory!",
+	game.OutcomeDefeat:    "You are defeated.",
+	game.OutcomeTie:       "Blades clash. Nobody is hurt.",
+	game.OutcomePlayerHit: "You land a blow.",
+	game.OutcomeEnemyHit:  "You are hit!",
+}
+
+// panel draws a boxed panel with a title in the top border.
+func panel(w io.Writer, title string, lines []string) {
+	top := "+-- " + title + " "
+	fmt.Fprintln(w, top+strings.Repeat("-", max(panelWidth+3-len(top), 0))+"+")
+	for _, l := range lines {
+		fmt.Fprintf(w, "| %s |\n", pad(l, panelWidth))
+	}
+	fmt.Fprintln(w, "+"+strings.Repeat("-", panelWidth+2)+"+")
+}
+
+// writeStats draws the character panel.
+func writeStats(w io.Writer, st *game.PlayerState) {
+	name := st.Name
+	if name == "" {
+		name = "HERO"
+	}
+	panel(w, strings.ToUpper(name), []string{
+		fmt.Sprintf("STRENGTH %2d   LUCK %2d   HEALTH %2d", st.Stats.Strength, st.Stats.Luck, st.Stats.Health),
+	})
+}
+
+// writeEnemies draws the enemy panel, numbering enemies as the battle
+// choices do. It draws nothing outside battle.
+func writeEnemies(w io.Writer, enemies []game.EnemyState) {
+	if len(enemies) == 0 {
+		return
+	}
+	lines := make([]string, 0, len(enemies))
+	for i, e := range enemies {
+		lines = append(lines, fmt.Sprintf("%d %-20s STR %2d   HEALTH %2d", i+1, e.Name, e.Strength, e.Health))
+	}
+	panel(w, "ENEMIES", lines)
+}
+
+// writeResult reports what the last step did: refusals, dice, outcome and
+// unlocked achievements.
+func writeResult(w io.Writer, res *game.StepResult) {
+	if res.ErrorMessage != "" {
+		fmt.Fprintln(w, res.ErrorMessage)
+		return
+	}
+	switch {
+	case res.LastPlayerDice != nil && res.LastEnemyDice != nil:
+		fmt.Fprint(w, Dice([]string{"YOU", "FOE"}, *res.LastPlayerDice, *res.LastEnemyDice))
+	case res.LastPlayerDice != nil:
+		fmt.Fprint(w, Dice([]string{"YOU"}, *res.LastPlayerDice))
+	}
+	if res.LastRoll != nil {
+		line := fmt.Sprintf("Roll: %d", *res.LastRoll)
+		if res.LastOutcome != nil {
+			line += "  " + outcomeText[*res.LastOutcome]
+		}
+		fmt.Fprintln(w, line)
+	}
+	for _, a := range res.Unlocked {
+		title := a.Title
+		if title == "" {
+			title = a.ID
+		}
+		if a.Description != "" {
+			title += " - " + a.Description
+		}
+		fmt.Fprintf(w, "Achievement unlocked: %s\n", title)
+	}
+}
+
+// writeNode draws the scene: text, panels and numbered choices.
+func writeNode(w io.Writer, st *game.PlayerState, n *game.Node, opts []option) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, n.Text)
+	fmt.Fprintln(w)
+	writeStats(w, st)
+	writeEnemies(w, st.Enemies)
+	if n.Ending {
+		return
+	}
+	fmt.Fprintln(w)
+	for i, o := range opts {
+		fmt.Fprintf(w, "  %d) %s\n", i+1, o.Text)
+	}
+}
+
+// writeStatDice shows the dice behind freshly rolled stats.
+func writeStatDice(w io.Writer, stats game.Stats, dice [3][2]int) {
+	fmt.Fprint(w, Dice([]string{"STRENGTH", "LUCK", "HEALTH"}, dice[0], dice[1], dice[2]))
+	fmt.Fprintf(w, "Strength %d (2d6+6)   Luck %d (2d6)   Health %d (2d6+6)\n", stats.Strength, stats.Luck, stats.Health)
+}
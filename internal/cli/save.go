@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"adventure/internal/game"
+)
+
+// saveVersion is bumped when the save format changes incompatibly.
+const saveVersion = 1
+
+// DefaultSavePath is where save and load go when no file is given.
+const DefaultSavePath = "adventure.save.json"
+
+type saveFile struct {
+	Version int              `json:"version"`
+	State   game.PlayerState `json:"state"`
+}
+
+// Save writes st to path as JSON. It writes a temporary file first and renames
+// it, so an interrupted save never leaves a truncated file behind.
+func Save(path string, st *game.PlayerState) error {
+	b, err := json.MarshalIndent(saveFile{Version: saveVersion, State: *st}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load reads a game saved by Save.
+func Load(path string) (game.PlayerState, error) {
+	b, err := os.ReadFile(path) // #nosec G304 -- the player names their own save file
+	if err != nil {
+		return game.PlayerState{}, err
+	}
+	var f saveFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return game.PlayerState{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Version != saveVersion {
+		return game.PlayerState{}, fmt.Errorf("%s: unsupported save version %d", path, f.Version)
+	}
+	return f.State, nil
+}
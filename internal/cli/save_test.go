@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"adventure/internal/game"
+)
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "save.json")
+	st := game.NewPlayer("road", "bridge")
+	st.Name = "Ann"
+	st.Flags["seen"] = true
+	st.Enemies = []game.EnemyState{{Name: "Troll", Strength: 5, Health: 2}}
+	if err := Save(path, &st); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got.Name != "Ann" || got.NodeID != "bridge" || !got.Flags["seen"] || len(got.Enemies) != 1 || got.Enemies[0].Health != 2 {
+		t.Errorf("Round trip lost state: %+v", got)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files left, got %v", entries)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(bad); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+	old := filepath.Join(dir, "old.json")
+	if err := os.WriteFile(old, []byte(`{"version": 99, "state": {}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(old); err == nil || !strings.Contains(err.Error(), "unsupported save version 99") {
+		t.Errorf("Expected a version error, got %v", err)
+	}
+	if err := Save(filepath.Join(dir, "no", "such", "dir.json"), &game.PlayerState{}); err == nil {
+		t.Error("Expected an error saving into a missing directory")
+	}
+}
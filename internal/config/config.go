@@ -0,0 +1,486 @@
+// Package config holds the game server's settings. They come from, in
+// increasing order of precedence, built-in defaults, an optional YAML config
+// file, environment variables (as Cloud Run sets them) and command-line
+// flags.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the server's configuration. The YAML file has the same shape.
+type Config struct {
+	Addr         string       `yaml:"addr"`
+	TLS          TLS          `yaml:"tls"`
+	Dirs         Dirs         `yaml:"dirs"`
+	DefaultStory string       `yaml:"default_story"`
+	Timeouts     Timeouts     `yaml:"timeouts"`
+	Session      Session      `yaml:"session"`
+	Redis        Redis        `yaml:"redis"`
+	Cookies      Cookies      `yaml:"cookies"`
+	Features     Features     `yaml:"features"`
+	Accounts     Accounts     `yaml:"accounts"`
+	Leaderboards Leaderboards `yaml:"leaderboards"`
+	Analytics    Analytics    `yaml:"analytics"`
+	Metrics      Metrics      `yaml:"metrics"`
+	Log          Log          `yaml:"log"`
+}
+
+// TLS names the certificate and key to serve HTTPS with; both empty serves
+// plain HTTP.
+type TLS struct {
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+}
+
+// Dirs are disk directories laid over the stories, templates and static
+// files embedded in the server: a file in one replaces the embedded file of
+// the same name, and new files are added. Empty uses the embedded files alone.
+type Dirs struct {
+	Stories   string `yaml:"stories"`
+	Templates string `yaml:"templates"`
+	Static    string `yaml:"static"`
+}
+
+// Timeouts are the HTTP server's timeouts. Drain is how long shutdown waits
+// for requests in flight.
+type Timeouts struct {
+	Read  time.Duration `yaml:"read"`
+	Write time.Duration `yaml:"write"`
+	Idle  time.Duration `yaml:"idle"`
+	Drain time.Duration `yaml:"drain"`
+}
+
+// Session configures the session store.
+type Session struct {
+	Store          string        `yaml:"store"`           // memory, file, redis or cookie
+	CookieFallback string        `yaml:"cookie_fallback"` // store for sessions too large for a cookie
+	File           string        `yaml:"file"`
+	TTL            time.Duration `yaml:"ttl"`
+	MaxSessions    int           `yaml:"max_sessions"`
+	Keys           []string      `yaml:"keys"` // cookie sealing secrets, newest first
+}
+
+// Redis configures the Redis connection, for any store kept in Redis.
+type Redis struct {
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+	Prefix   string `yaml:"prefix"`
+}
+
+// Cookies configures the cookies the server sets.
+type Cookies struct {
+	Secure   string `yaml:"secure"` // auto, always or never
+	Domain   string `yaml:"domain"`
+	SameSite string `yaml:"same_site"` // lax, strict or none
+}
+
+// Features turns optional parts of the game on and off.
+type Features struct {
+	Party      bool `yaml:"party"`
+	Spectators bool `yaml:"spectators"`
+}
+
+// Accounts configures player accounts.
+type Accounts struct {
+	Enabled bool   `yaml:"enabled"`
+	File    string `yaml:"file"`
+}
+
+// Leaderboards configures leaderboards.
+type Leaderboards struct {
+	Enabled bool   `yaml:"enabled"`
+	File    string `yaml:"file"`
+}
+
+// Analytics configures play analytics.
+type Analytics struct {
+	Sink     string `yaml:"sink"` // memory, file or off
+	File     string `yaml:"file"`
+	Key      string `yaml:"key"`
+	Password string `yaml:"password"`
+}
+
+// Metrics configures the Prometheus metrics.
+type Metrics struct {
+	Path string `yaml:"path"`
+	Addr string `yaml:"addr"`
+}
+
+// Log configures logging.
+type Log struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// Default returns the built-in configuration.
+func Default() *Config {
+	return &Config{
+		Addr:     ":8080",
+		Timeouts: Timeouts{Read: 15 * time.Second, Write: 15 * time.Second, Idle: 60 * time.Second, Drain: 10 * time.Second},
+		Session: Session{
+			Store:          "memory",
+			CookieFallback: "memory",
+			File:           "data/sessions.log",
+			TTL:            7 * 24 * time.Hour,
+			MaxSessions:    100000,
+		},
+		Redis:        Redis{Addr: "localhost:6379", Prefix: "adventure:session:"},
+		Cookies:      Cookies{Secure: "auto", SameSite: "lax"},
+		Features:     Features{Party: true, Spectators: true},
+		Accounts:     Accounts{File: "data/accounts.log"},
+		Leaderboards: Leaderboards{Enabled: true, File: "data/leaderboards.log"},
+		Analytics:    Analytics{Sink: "memory", File: "data/analytics.jsonl"},
+		Metrics:      Metrics{Path: "/metrics"},
+		Log:          Log{Level: "info", Format: "text"},
+	}
+}
+
+// setting binds one field to a flag and an environment variable. A setting
+// without a flag, such as a password, can't be given on the command line,
+// where other users could see it.
+type setting struct {
+	flag   string
+	env    string
+	usage  string
+	ptr    any // *string, *bool, *int, *time.Duration or *[]string
+	secret bool
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"addr", "ADDR", "host:port to listen on (Cloud Run's PORT sets the port)", &c.Addr, false},
+		{"tls-cert", "TLS_CERT", "TLS certificate file; with -tls-key, serves HTTPS", &c.TLS.Cert, false},
+		{"tls-key", "TLS_KEY", "TLS private key file", &c.TLS.Key, false},
+		{"stories", "STORIES_DIR", "directory of stories laid over the embedded ones", &c.Dirs.Stories, false},
+		{"templates", "TEMPLATES_DIR", "directory of HTML templates laid over the embedded ones", &c.Dirs.Templates, false},
+		{"static", "STATIC_DIR", "directory of files served under /static/, laid over the embedded ones", &c.Dirs.Static, false},
+		{"default-story", "DEFAULT_STORY", "story new players start (default: demo, or any story)", &c.DefaultStory, false},
+		{"read-timeout", "READ_TIMEOUT", "HTTP read timeout", &c.Timeouts.Read, false},
+		{"write-timeout", "WRITE_TIMEOUT", "HTTP write timeout", &c.Timeouts.Write, false},
+		{"idle-timeout", "IDLE_TIMEOUT", "HTTP keep-alive idle timeout", &c.Timeouts.Idle, false},
+		{"drain-timeout", "DRAIN_TIMEOUT", "how long shutdown waits for requests in flight", &c.Timeouts.Drain, false},
+		{"session-store", "SESSION_STORE", "where sessions are kept: memory, file, redis or cookie", &c.Session.Store, false},
+		{"cookie-fallback", "COOKIE_FALLBACK", "store for sessions too large for a cookie with -session-store=cookie: memory, file or redis", &c.Session.CookieFallback, false},
+		{"session-file", "SESSION_FILE", "session log for -session-store=file", &c.Session.File, false},
+		{"session-ttl", "SESSION_TTL", "sessions expire this long after they were last used (0: never)", &c.Session.TTL, false},
+		{"max-sessions", "MAX_SESSIONS", "sessions kept by -session-store=memory before the least recently used is evicted (0: no limit)", &c.Session.MaxSessions, false},
+		{"", "SESSION_KEYS", "", &c.Session.Keys, true},
+		{"redis-addr", "REDIS_ADDR", "Redis host:port", &c.Redis.Addr, false},
+		{"", "REDIS_PASSWORD", "", &c.Redis.Password, true},
+		{"redis-db", "REDIS_DB", "Redis database number", &c.Redis.DB, false},
+		{"redis-prefix", "REDIS_PREFIX", "prefix for Redis session keys", &c.Redis.Prefix, false},
+		{"cookie-secure", "COOKIE_SECURE", "when cookies are marked Secure: auto (over TLS), always (behind a TLS proxy) or never", &c.Cookies.Secure, false},
+		{"cookie-domain", "COOKIE_DOMAIN", "domain for cookies (default: the host)", &c.Cookies.Domain, false},
+		{"cookie-samesite", "COOKIE_SAMESITE", "SameSite mode for cookies: lax, strict or none", &c.Cookies.SameSite, false},
+		{"party", "PARTY", "let groups play one game by voting", &c.Features.Party, false},
+		{"spectators", "SPECTATORS", "let players share read-only spectator links", &c.Features.Spectators, false},
+		{"accounts", "ACCOUNTS", "let players log in and keep several characters", &c.Accounts.Enabled, false},
+		{"account-file", "ACCOUNT_FILE", "account log for -session-store=file; logins are kept in logins.log beside it", &c.Accounts.File, false},
+		{"leaderboards", "LEADERBOARDS", "keep per-story leaderboards of scored runs", &c.Leaderboards.Enabled, false},
+		{"leaderboard-file", "LEADERBOARD_FILE", "leaderboard log for -session-store=file", &c.Leaderboards.File, false},
+		{"analytics", "ANALYTICS", "where anonymised play events are recorded: memory, file or off", &c.Analytics.Sink, false},
+		{"analytics-file", "ANALYTICS_FILE", "event log for -analytics=file", &c.Analytics.File, false},
+		{"", "ANALYTICS_KEY", "", &c.Analytics.Key, true},
+		{"", "ANALYTICS_PASSWORD", "", &c.Analytics.Password, true},
+		{"metrics-path", "METRICS_PATH", "path of the Prometheus metrics (empty: no metrics)", &c.Metrics.Path, false},
+		{"metrics-addr", "METRICS_ADDR", "serve the metrics on this host:port instead of the game's listener, to keep them private", &c.Metrics.Addr, false},
+		{"log-level", "LOG_LEVEL", "least severe log level written: debug, info, warn or error", &c.Log.Level, false},
+		{"log-format", "LOG_FORMAT", "log format: text or json", &c.Log.Format, false},
+	}
+}
+
+// ConfigEnv names the config file when -config is not given.
+const ConfigEnv = "CONFIG_FILE"
+
+// Load builds the configuration from the defaults, the config file named by
+// -config or CONFIG_FILE, the environment (looked up with getenv) and args,
+// the command-line arguments without the program name. It also returns the
+// parsed flags, for -print-config. It does not validate the result.
+func Load(name string, args []string, getenv func(string) (string, bool)) (*Config, *flag.FlagSet, error) {
+	c := Default()
+	path, _ := getenv(ConfigEnv)
+	if p, ok := configArg(args); ok {
+		path = p
+	}
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := c.loadEnv(getenv); err != nil {
+		return nil, nil, err
+	}
+	fs := c.FlagSet(name)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return c, fs, nil
+}
+
+// FlagSet returns flags for c's settings, with c's values as their
+// defaults, plus -config and -print-config for the usage message.
+func (c *Config) FlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.String("config", "", "YAML config file (env "+ConfigEnv+")")
+	fs.Bool("print-config", false, "print the effective configuration as YAML and exit")
+	for _, s := range c.settings() {
+		if s.flag != "" {
+			fs.Var(value{s.ptr}, s.flag, s.usage+" (env "+s.env+")")
+		}
+	}
+	return fs
+}
+
+// configArg finds -config's value in args.
+func configArg(args []string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			break
+		}
+		name, val, hasVal := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		if !strings.HasPrefix(a, "-") || name != "config" {
+			continue
+		}
+		if hasVal {
+			return val, true
+		}
+		if i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
+func (c *Config) loadFile(path string) error {
+	b, err := os.ReadFile(path) // #nosec G304 -- the operator names the config file
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv(getenv func(string) (string, bool)) error {
+	if port, ok := getenv("PORT"); ok && port != "" {
+		c.Addr = ":" + port
+	}
+	for _, s := range c.settings() {
+		v, ok := getenv(s.env)
+		if !ok {
+			continue
+		}
+		if err := (value{s.ptr}).Set(v); err != nil {
+			return fmt.Errorf("%s: %w", s.env, err)
+		}
+	}
+	return nil
+}
+
+// Validate reports every problem with the configuration.
+func (c *Config) Validate() error {
+	var errs []error
+	bad := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
+	oneOf := func(name, v string, allowed ...string) {
+		for _, a := range allowed {
+			if v == a {
+				return
+			}
+		}
+		bad("%s is %q; want one of %s", name, v, strings.Join(allowed, ", "))
+	}
+
+	if c.Addr == "" {
+		bad("addr is empty")
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		bad("tls needs both a cert and a key")
+	}
+	for _, f := range []struct{ name, path string }{{"tls cert", c.TLS.Cert}, {"tls key", c.TLS.Key}} {
+		if f.path != "" {
+			if _, err := os.Stat(f.path); err != nil {
+				bad("%s: %w", f.name, err)
+			}
+		}
+	}
+	for _, d := range []struct{ name, path string }{{"stories", c.Dirs.Stories}, {"templates", c.Dirs.Templates}, {"static", c.Dirs.Static}} {
+		if d.path == "" {
+			continue
+		}
+		if info, err := os.Stat(d.path); err != nil {
+			bad("%s dir: %w", d.name, err)
+		} else if !info.IsDir() {
+			bad("%s dir %s is not a directory", d.name, d.path)
+		}
+	}
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{{"read timeout", c.Timeouts.Read}, {"write timeout", c.Timeouts.Write}, {"idle timeout", c.Timeouts.Idle}, {"drain timeout", c.Timeouts.Drain}, {"session ttl", c.Session.TTL}} {
+		if t.d < 0 {
+			bad("%s is negative", t.name)
+		}
+	}
+
+	oneOf("session store", c.Session.Store, "memory", "file", "redis", "cookie")
+	if c.Session.Store == "cookie" {
+		oneOf("cookie fallback", c.Session.CookieFallback, "memory", "file", "redis")
+		if len(c.Session.Keys) == 0 {
+			bad("the cookie session store needs SESSION_KEYS")
+		}
+		if c.Accounts.Enabled {
+			bad("accounts need sessions kept on the server, not the cookie session store")
+		}
+	}
+	if c.Session.MaxSessions < 0 {
+		bad("max sessions is negative")
+	}
+	if c.Redis.DB < 0 {
+		bad("redis db is negative")
+	}
+
+	oneOf("cookie secure", c.Cookies.Secure, "auto", "always", "never")
+	oneOf("cookie samesite", c.Cookies.SameSite, "lax", "strict", "none")
+	if c.Cookies.SameSite == "none" && c.Cookies.Secure != "always" {
+		bad("cookie samesite none needs cookie secure always")
+	}
+
+	oneOf("analytics", c.Analytics.Sink, "memory", "file", "off")
+	if c.Metrics.Path != "" && !strings.HasPrefix(c.Metrics.Path, "/") {
+		bad("metrics path %q does not start with /", c.Metrics.Path)
+	}
+	oneOf("log level", strings.ToLower(c.Log.Level), "debug", "info", "warn", "error")
+	oneOf("log format", c.Log.Format, "text", "json")
+	return errors.Join(errs...)
+}
+
+// redacted is how secrets are printed.
+const redacted = "<redacted>"
+
+// Redacted returns a copy of c with its secrets hidden, for printing.
+func (c *Config) Redacted() *Config {
+	r := *c
+	for _, s := range r.settings() {
+		if !s.secret {
+			continue
+		}
+		switch p := s.ptr.(type) {
+		case *string:
+			if *p != "" {
+				*p = redacted
+			}
+		case *[]string:
+			hidden := make([]string, len(*p))
+			for i := range hidden {
+				hidden[i] = redacted
+			}
+			*p = hidden
+		}
+	}
+	return &r
+}
+
+// WriteYAML writes c, with its secrets redacted, as a YAML config file.
+func (c *Config) WriteYAML(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Flat returns c's settings, secrets redacted, as flag-name (or, for
+// settings without a flag, env-name) and value pairs, for logging.
+func (c *Config) Flat() [][2]string {
+	r := c.Redacted()
+	var out [][2]string
+	for _, s := range r.settings() {
+		name := s.flag
+		if name == "" {
+			name = strings.ToLower(strings.ReplaceAll(s.env, "_", "-"))
+		}
+		out = append(out, [2]string{name, value{s.ptr}.String()})
+	}
+	return out
+}
+
+// value is a flag.Value for a setting's field.
+type value struct {
+	ptr any
+}
+
+func (v value) String() string {
+	switch p := v.ptr.(type) {
+	case *string:
+		return *p
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *int:
+		return strconv.Itoa(*p)
+	case *time.Duration:
+		return p.String()
+	case *[]string:
+		return strings.Join(*p, ",")
+	}
+	return ""
+}
+
+func (v value) Set(s string) error {
+	switch p := v.ptr.(type) {
+	case *string:
+		*p = s
+	case *bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		*p = b
+	case *int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		*p = n
+	case *time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*p = d
+	case *[]string:
+		*p = nil
+		for _, part := range strings.Split(s, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				*p = append(*p, part)
+			}
+		}
+	}
+	return nil
+}
+
+// IsBoolFlag lets bool flags be given without a value.
+func (v value) IsBoolFlag() bool {
+	_, ok := v.ptr.(*bool)
+	return ok
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env returns a getenv over m.
+func env(m map[string]string) func(string) (string, bool) {
+	return func(k string) (string, bool) {
+		v, ok := m[k]
+		return v, ok
+	}
+}
+
+// testDirs makes the stories, templates and static dirs in a temp dir.
+func testDirs(t *testing.T) Dirs {
+	t.Helper()
+	root := t.TempDir()
+	d := Dirs{Stories: filepath.Join(root, "stories"), Templates: filepath.Join(root, "templates"), Static: filepath.Join(root, "static")}
+	for _, p := range []string{d.Stories, d.Templates, d.Static} {
+		if err := os.Mkdir(p, 0o750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return d
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "adventure.yaml")
+	file := `
+addr: ":9000"
+default_story: cave
+session:
+  store: file
+  ttl: 1h
+features:
+  party: false
+log:
+  level: debug
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c, fs, err := Load("server", []string{"-config", path, "-log-level", "warn", "-accounts"}, env(map[string]string{
+		"SESSION_TTL":  "2h",
+		"SESSION_KEYS": "new, old",
+		"PORT":         "7000",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Addr != ":7000" {
+		t.Errorf("Expected PORT to win over the file, got %q", c.Addr)
+	}
+	if c.DefaultStory != "cave" || c.Session.Store != "file" || c.Features.Party || !c.Features.Spectators {
+		t.Errorf("Expected the file's settings, got %+v", c)
+	}
+	if c.Session.TTL != 2*time.Hour {
+		t.Errorf("Expected the environment to win over the file, got %v", c.Session.TTL)
+	}
+	if c.Log.Level != "warn" || !c.Accounts.Enabled {
+		t.Errorf("Expected flags to win, got %q, %v", c.Log.Level, c.Accounts.Enabled)
+	}
+	if strings.Join(c.Session.Keys, "|") != "new|old" {
+		t.Errorf("Unexpected keys %q", c.Session.Keys)
+	}
+	if fs.Lookup("print-config").Value.String() != "false" {
+		t.Error("Expected -print-config unset")
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(path, []byte("adress: x\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Load("server", []string{"--config=" + path}, env(nil)); err == nil {
+		t.Error("Expected an error for an unknown key")
+	}
+	if _, _, err := Load("server", nil, env(map[string]string{"SESSION_TTL": "soon"})); err == nil || !strings.Contains(err.Error(), "SESSION_TTL") {
+		t.Errorf("Expected an error naming SESSION_TTL, got %v", err)
+	}
+	if _, _, err := Load("server", []string{"extra"}, env(nil)); err == nil {
+		t.Error("Expected an error for a stray argument")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c := Default()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Expected the defaults to be valid, got %v", err)
+	}
+	c.Dirs = testDirs(t)
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Expected overlay dirs to be valid, got %v", err)
+	}
+	c.Dirs.Static = filepath.Join(c.Dirs.Static, "missing")
+	c.Session.Store = "cookie"
+	c.Accounts.Enabled = true
+	c.TLS.Cert = "cert.pem"
+	c.Cookies.SameSite = "none"
+	c.Log.Format = "xml"
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("Expected errors")
+	}
+	for _, want := range []string{"SESSION_KEYS", "accounts need", "both a cert and a key", "samesite none", "log format", "static dir"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	c := Default()
+	c.Redis.Password = "hunter2"
+	c.Session.Keys = []string{"k1"}
+	var b strings.Builder
+	if err := c.WriteYAML(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "hunter2") || strings.Contains(b.String(), "k1") {
+		t.Errorf("Expected secrets redacted:\n%s", b.String())
+	}
+	if !strings.Contains(b.String(), "ttl: 168h0m0s") {
+		t.Errorf("Expected durations as strings:\n%s", b.String())
+	}
+	if c.Redis.Password != "hunter2" {
+		t.Error("Expected the original left alone")
+	}
+	for _, kv := range c.Flat() {
+		if kv[0] == "redis-password" && kv[1] != redacted {
+			t.Errorf("Expected redis-password redacted, got %q", kv[1])
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package game
+
+import "sort"
+
+// Endings returns the IDs of the story's ending nodes in sorted order.
+func (s *Story) Endings() []string {
+	if s == nil {
+		return nil
+	}
+	var out []string
+	for id, n := range s.Nodes {
+		if n != nil && n.Ending {
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Record returns the player's record for a story (zero value if none yet).
+func (st *PlayerState) Record(storyID string) StoryRecord {
+	return st.Records[storyID]
+}
+
+// HasEnding reports whether the ending node has been reached in any run.
+func (r StoryRecord) HasEnding(nodeID string) bool {
+	return containsString(r.Endings, nodeID)
+}
+
+// HasAchievement reports whether the achievement has been unlocked.
+func (r StoryRecord) HasAchievement(id string) bool {
+	return containsString(r.Achievements, id)
+}
+
+// recordProgress updates st.Records for the player's current story after a
+// step: it notes a newly reached ending, keeps a surviving hero for sequels,
+// and unlocks any achievements whose triggers now hold. Returns whether the ending is new and what was unlocked.
+func (e *Engine) recordProgress(st *PlayerState) (newEnding bool, unlocked []Achievement) {
+	s := e.story(st)
+	if s == nil {
+		return false, nil
+	}
+	node := s.Nodes[st.NodeID]
+	rec := st.Record(st.StoryID)
+	changed := false
+
+	if node != nil && node.Ending && !rec.HasEnding(st.NodeID) {
+		rec.Endings = append(rec.Endings, st.NodeID)
+		newEnding = true
+		changed = true
+	}
+	if node != nil && node.Ending && st.Stats.Health > MinHealth {
+		rec.Hero = snapshotHero(st)
+		changed = true
+	}
+	for i := range s.Achievements {
+		a := &s.Achievements[i]
+		if a.ID == "" || rec.HasAchievement(a.ID) || !triggerMet(st, node, &a.Trigger) {
+			continue
+		}
+		rec.Achievements = append(rec.Achievements, a.ID)
+		unlocked = append(unlocked, *a)
+		changed = true
+	}
+
+	if changed {
+		if st.Records == nil {
+			st.Records = map[string]StoryRecord{}
+		}
+		st.Records[st.StoryID] = rec
+	}
+	return newEnding, unlocked
+}
+
+// triggerMet reports whether every set field of t holds for st on node.
+func triggerMet(st *PlayerState, node *Node, t *Trigger) bool {
+	if t.Node == "" && t.Flag == "" && t.Defeated == "" && !t.Ending && len(t.AtLeast) == 0 {
+		// An empty trigger never fires; otherwise every achievement would unlock on the first step.
+		return false
+	}
+	if t.Node != "" && st.NodeID != t.Node {
+		return false
+	}
+	if t.Flag != "" && !st.Flags[t.Flag] {
+		return false
+	}
+	if t.Defeated != "" && st.Defeated[t.Defeated] == 0 {
+		return false
+	}
+	if t.Ending && (node == nil || !node.Ending) {
+		return false
+	}
+	for stat, minimum := range t.AtLeast {
+		if getStat(st, stat) < minimum {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package game
+
+import "testing"
+
+func TestStory_Endings(t *testing.T) {
+	story := &Story{Nodes: map[string]*Node{
+		"starved": {Ending: true},
+		"gate":    {},
+		"outside": {Ending: true},
+	}}
+	got := story.Endings()
+	if len(got) != 2 || got[0] != "outside" || got[1] != "starved" {
+		t.Errorf("Expected sorted endings [outside starved], got %v", got)
+	}
+	var none *Story
+	if none.Endings() != nil {
+		t.Error("Expected nil endings for nil story")
+	}
+}
+
+func TestApplyChoice_UnlocksAchievementsAndEndings(t *testing.T) {
+	story := &Story{
+		Start: "gate",
+		Achievements: []Achievement{
+			{ID: "keyholder", Title: "Keyholder", Trigger: Trigger{Flag: "has_key"}},
+			{ID: "escaped", Trigger: Trigger{Node: "outside"}},
+			{ID: "healthy_end", Trigger: Trigger{Ending: true, AtLeast: map[string]int{StatHealth: 10}}},
+			{ID: "empty"},
+		},
+		Nodes: map[string]*Node{
+			"gate": {Text: "A locked gate.", Choices: []Choice{
+				{Key: "search", Text: "Search", Next: "gate", Effects: []Effect{{Op: OpSetFlag, Flag: "has_key"}}},
+				{Key: "open", Text: "Open", Next: "outside", If: &Condition{Flags: []string{"has_key"}}},
+			}},
+			"outside": {Text: "Free.", Ending: true},
+		},
+	}
+	engine := &Engine{Stories: map[string]*Story{"test": story}}
+	player := NewPlayer("test", "gate")
+	player.Stats.Health = 12
+
+	result := mustApply(t, engine, &player, "search")
+	if len(result.Unlocked) != 1 || result.Unlocked[0].ID != "keyholder" {
+		t.Fatalf("Expected keyholder unlocked, got %+v", result.Unlocked)
+	}
+	if !result.State.Flags["has_key"] {
+		t.Error("Expected set_flag effect to set has_key")
+	}
+
+	// Same trigger holds again, but achievements unlock only once.
+	if result := mustApply(t, engine, &player, "search"); len(result.Unlocked) != 0 {
+		t.Errorf("Expected no repeat unlocks, got %+v", result.Unlocked)
+	}
+
+	result = mustApply(t, engine, &player, "open")
+	if !result.NewEnding {
+		t.Error("Expected first visit to an ending to be reported as new")
+	}
+	rec := result.State.Record("test")
+	if !rec.HasEnding("outside") {
+		t.Errorf("Expected ending recorded, got %+v", rec)
+	}
+	for _, id := range []string{"keyholder", "escaped", "healthy_end"} {
+		if !rec.HasAchievement(id) {
+			t.Errorf("Expected achievement %q unlocked, got %v", id, rec.Achievements)
+		}
+	}
+	if rec.HasAchievement("empty") {
+		t.Error("Expected achievement with empty trigger never to unlock")
+	}
+}
+
+func TestBeginRun_KeepsRecordsClearsRunState(t *testing.T) {
+	story := &Story{Start: "gate", Nodes: map[string]*Node{
+		"gate":    {Text: "A locked gate.", Choices: []Choice{{Key: "wait", Text: "Wait", Next: "starved"}}},
+		"starved": {Text: "You waited too long.", Ending: true},
+	}}
+	engine := &Engine{Stories: map[string]*Story{"test": story}}
+	player := NewPlayer("test", "gate")
+	mustApply(t, engine, &player, "wait")
+	player.Flags["has_key"] = true
+	player.Defeated = map[string]int{"Goblin": 1}
+
+	player.BeginRun("test", "gate")
+	if player.NodeID != "gate" || len(player.VisitedNodes) != 1 {
+		t.Errorf("Expected to be back at the start, got node %q path %v", player.NodeID, player.VisitedNodes)
+	}
+	if len(player.Flags) != 0 || player.Defeated != nil {
+		t.Errorf("Expected per-run flags and defeated cleared, got %v %v", player.Flags, player.Defeated)
+	}
+	if !player.Record("test").HasEnding("starved") {
+		t.Error("Expected endings record to survive a new run")
+	}
+
+	// Reaching the same ending again is not new.
+	if result := mustApply(t, engine, &player, "wait"); result.NewEnding {
+		t.Error("Expected repeat ending not to be reported as new")
+	}
+}
+
+func TestApplyChoice_DefeatedEnemyTrigger(t *testing.T) {
+	story := &Story{
+		Start: "arena",
+		Achievements: []Achievement{
+			{ID: "slayer", Trigger: Trigger{Defeated: "Rat"}},
+		},
+		Nodes: map[string]*Node{
+			"arena": {
+				Text: "A rat.",
+				Choices: []Choice{{
+					Key:  "fight",
+					Text: "Fight",
+					Battle: &Battle{
+						Enemies:       []Enemy{{Name: "Rat", Strength: 1, Health: 1}},
+						OnVictoryNext: "won",
+					},
+				}},
+			},
+			"won": {Text: "Won", Ending: true},
+		},
+	}
+	engine := &Engine{Stories: map[string]*Story{"test": story}}
+	player := NewPlayer("test", "arena")
+	player.Stats.Strength = MaxStrength
+	player.Stats.Health = 100
+
+	result := fight(t, engine, &player, "fight:attack:0")
+	if player.NodeID != "won" {
+		t.Fatalf("Expected to win against a 1-health rat, still on %q", player.NodeID)
+	}
+	if player.Defeated["Rat"] != 1 {
+		t.Errorf("Expected one Rat defeated, got %v", player.Defeated)
+	}
+	if len(result.Unlocked) != 1 || result.Unlocked[0].ID != "slayer" {
+		t.Errorf("Expected slayer unlocked on victory, got %+v", result.Unlocked)
+	}
+}
+
+func TestApplyEffects_Flags(t *testing.T) {
+	player := PlayerState{}
+	applyEffects(&player, []Effect{{Op: OpSetFlag, Flag: "lit"}, {Op: OpSetFlag}})
+	if !player.Flags["lit"] || len(player.Flags) != 1 {
+		t.Errorf("Expected only 'lit' set, got %v", player.Flags)
+	}
+	applyEffects(&player, []Effect{{Op: OpClearFlag, Flag: "lit"}})
+	if player.Flags["lit"] {
+		t.Error("Expected clear_flag to clear 'lit'")
+	}
+}
+
+func TestLoadStory_Achievements(t *testing.T) {
+	storyYAML := `start: "a"
+achievements:
+  - id: "brave"
+    title: "Brave"
+    description: "Reached the end with 10 Health"
+    hint: "Stay healthy"
+    trigger:
+      ending: true
+      atLeast:
+        health: 10
+nodes:
+  a:
+    text: "A"
+    title: "The only ending"
+    hint: "Just start"
+    ending: true
+    effects:
+      - op: "set_flag"
+        flag: "seen_a"
+`
+	s := loadStoryYAML(t, storyYAML)
+	if len(s.Achievements) != 1 {
+		t.Fatalf("Expected 1 achievement, got %d", len(s.Achievements))
+	}
+	a := s.Achievements[0]
+	if a.Hint != "Stay healthy" || !a.Trigger.Ending || a.Trigger.AtLeast[StatHealth] != 10 {
+		t.Errorf("Unexpected achievement: %+v", a)
+	}
+	n := s.Nodes["a"]
+	if n.Title != "The only ending" || n.Hint != "Just start" {
+		t.Errorf("Expected title and hint on ending node, got %+v", n)
+	}
+	if n.Effects[0].Op != OpSetFlag || n.Effects[0].Flag != "seen_a" {
+		t.Errorf("Expected set_flag effect, got %+v", n.Effects[0])
+	}
+}
@@ -0,0 +1,133 @@
+package game
+
+// MaxCallDepth is the deepest sub-adventure nesting allowed; calls beyond it
+// are refused so a story cannot recurse forever.
+const MaxCallDepth = 8
+
+// rootStoryID returns the story the player started in, even while inside a sub-adventure.
+func rootStoryID(st *PlayerState) string {
+	if len(st.CallStack) > 0 {
+		return st.CallStack[0].StoryID
+	}
+	return st.StoryID
+}
+
+// rootStory returns the story the player started in.
+func (e *Engine) rootStory(st *PlayerState) *Story {
+	return e.story(&PlayerState{StoryID: rootStoryID(st)})
+}
+
+// entersCall reports whether taking ch enters its sub-adventure: a call on
+// a choice with a check, battle or prompt is ignored.
+func entersCall(ch *Choice) bool {
+	return ch.Call != nil && ch.Prompt == nil && ch.Check == nil && ch.Battle == nil
+}
+
+// callTarget resolves the story ID and entry node of a call from st's current story.
+func (e *Engine) callTarget(st *PlayerState, c *Call) (storyID, nodeID string) {
+	storyID = c.Story
+	if storyID == "" {
+		storyID = st.StoryID
+	}
+	nodeID = c.Node
+	if nodeID == "" {
+		if s := e.Stories[storyID]; s != nil {
+			nodeID = s.Start
+		}
+	}
+	return storyID, nodeID
+}
+
+// validateCall returns a player-facing message if ch's call cannot be entered, else "".
+func (e *Engine) validateCall(st *PlayerState, ch *Choice) string {
+	if len(st.CallStack) >= MaxCallDepth {
+		return "That path loops back on itself too many times."
+	}
+	storyID, nodeID := e.callTarget(st, ch.Call)
+	s := e.Stories[storyID]
+	if s == nil || s.Nodes[nodeID] == nil || (ch.Call.Next == "" && ch.Next == "") {
+		return "That path leads nowhere."
+	}
+	return ""
+}
+
+// enterCall pushes a call frame for ch and switches st to the called story.
+// Returns the entry node ID.
+func (e *Engine) enterCall(st *PlayerState, ch *Choice) string {
+	c := ch.Call
+	storyID, nodeID := e.callTarget(st, c)
+	next := c.Next
+	if next == "" {
+		next = ch.Next
+	}
+	st.CallStack = append(st.CallStack, CallFrame{
+		StoryID:       st.StoryID,
+		Next:          next,
+		OnFailureNext: c.OnFailureNext,
+		Enemies:       c.Enemies,
+		Reward:        c.Reward,
+	})
+	st.StoryID = storyID
+	return nodeID
+}
+
+// unwindReturns resumes the caller while the player stands on a return node,
+// applying the reward on success and the continuation node's entry effects.
+func (e *Engine) unwindReturns(st *PlayerState) {
+	for len(st.CallStack) > 0 {
+		s := e.story(st)
+		if s == nil {
+			return
+		}
+		n := s.Nodes[st.NodeID]
+		if n == nil || !n.Return {
+			return
+		}
+		frame := st.CallStack[len(st.CallStack)-1]
+		st.CallStack = st.CallStack[:len(st.CallStack)-1]
+		if len(st.CallStack) == 0 {
+			st.CallStack = nil
+		}
+
+		next := frame.Next
+		if n.ReturnOutcome == OutcomeFailure {
+			if frame.OnFailureNext != "" {
+				next = frame.OnFailureNext
+			}
+		} else {
+			applyEffects(st, frame.Reward)
+		}
+		st.StoryID = frame.StoryID
+		st.NodeID = next
+		e.enterNode(st)
+	}
+}
+
+// calledEnemies returns the enemies passed by the innermost call, scaled for difficulty.
+func (e *Engine) calledEnemies(st *PlayerState) []EnemyState {
+	if len(st.CallStack) == 0 {
+		return nil
+	}
+	frame := st.CallStack[len(st.CallStack)-1]
+	return getBattleEnemies(&Battle{Enemies: frame.Enemies}, e.Difficulty(st))
+}
+
+// deathStory returns the story whose death node should be used: the current
+// story if it defines one, otherwise the root story, abandoning any active
+// sub-adventures.
+func (e *Engine) deathStory(st *PlayerState) *Story {
+	s := e.story(st)
+	if s != nil && s.Nodes[DeathNodeID] != nil {
+		return s
+	}
+	if len(st.CallStack) == 0 {
+		return s
+	}
+	root := e.rootStory(st)
+	if root == nil || root.Nodes[DeathNodeID] == nil {
+		return s
+	}
+	st.StoryID = rootStoryID(st)
+	st.CallStack = nil
+	return root
+}
@@ -0,0 +1,244 @@
+package game
+
+import "testing"
+
+// duelCall is a choice calling the "lib" library story's ring, where the
+// Champion is passed in to fight.
+var duelCall = Choice{Key: "duel", Text: "Accept the duel", Call: &Call{
+	Story:         "lib",
+	Next:          "cheered",
+	OnFailureNext: "jeered",
+	Enemies:       []Enemy{{Name: "Champion", Strength: 1, Health: 1}},
+	Reward:        []Effect{{Op: OpAdd, Stat: StatLuck, Value: 2}},
+}}
+
+func TestApplyChoice_CallLibraryAndReturnWithReward(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{
+		"main": {Start: "square", Nodes: map[string]*Node{
+			"square":  {Text: "The town square.", Choices: []Choice{duelCall}},
+			"cheered": {Text: "The crowd cheers.", Ending: true},
+		}},
+		"lib": {Library: true, Start: "ring", Nodes: map[string]*Node{
+			"ring": {Text: "A ring of rope.", Choices: []Choice{{Key: "fight", Text: "Fight", Battle: &Battle{OnVictoryNext: "won", OnDefeatNext: "ring"}}}},
+			"won":  {Text: "Victory.", Return: true},
+		}},
+	}}
+	player := NewPlayer("main", "square")
+	player.Stats = Stats{Strength: MaxStrength, Luck: 5, Health: 20}
+
+	result := mustApply(t, engine, &player, "duel")
+	if result.ErrorMessage != "" {
+		t.Fatalf("Unexpected error message: %s", result.ErrorMessage)
+	}
+	if player.StoryID != "lib" || player.NodeID != "ring" || len(player.CallStack) != 1 {
+		t.Fatalf("Expected to enter lib/ring with one frame, got %s/%s %+v", player.StoryID, player.NodeID, player.CallStack)
+	}
+
+	fight(t, engine, &player, "fight:attack:0")
+	if player.StoryID != "main" || player.NodeID != "cheered" {
+		t.Fatalf("Expected to return to main/cheered, got %s/%s", player.StoryID, player.NodeID)
+	}
+	if player.CallStack != nil {
+		t.Errorf("Expected empty call stack after return, got %+v", player.CallStack)
+	}
+	if player.Stats.Luck != 7 {
+		t.Errorf("Expected reward to add 2 luck, got %d", player.Stats.Luck)
+	}
+	if player.Defeated["Champion"] != 1 {
+		t.Errorf("Expected the passed-in Champion defeated, got %v", player.Defeated)
+	}
+	if !player.Record("main").HasEnding("cheered") {
+		t.Error("Expected the caller's ending recorded against the main story")
+	}
+}
+
+func TestApplyChoice_CallFailureReturn(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{
+		"main": {Start: "square", Nodes: map[string]*Node{
+			"square": {Text: "The town square.", Choices: []Choice{duelCall}},
+			"jeered": {Text: "The crowd jeers.", Ending: true},
+		}},
+		"lib": {Library: true, Start: "ring", Nodes: map[string]*Node{
+			"ring":    {Text: "A ring of rope.", Choices: []Choice{{Key: "yield", Text: "Yield", Next: "yielded"}}},
+			"yielded": {Text: "You yield.", Return: true, ReturnOutcome: OutcomeFailure},
+		}},
+	}}
+	player := NewPlayer("main", "square")
+	player.Stats.Luck = 5
+
+	mustApply(t, engine, &player, "duel")
+	mustApply(t, engine, &player, "yield")
+	if player.StoryID != "main" || player.NodeID != "jeered" {
+		t.Fatalf("Expected failure return to main/jeered, got %s/%s", player.StoryID, player.NodeID)
+	}
+	if player.Stats.Luck != 5 {
+		t.Errorf("Expected no reward on failure, got luck %d", player.Stats.Luck)
+	}
+}
+
+func TestApplyChoice_CallSameStory(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{"main": {Start: "square", Nodes: map[string]*Node{
+		"square": {Text: "The town square.", Choices: []Choice{{Key: "well", Text: "Climb down the well", Next: "square", Call: &Call{Node: "well"}}}},
+		"well":   {Text: "Dark and damp.", Choices: []Choice{{Key: "up", Text: "Climb up", Next: "out"}}},
+		"out":    {Text: "Back in the light.", Return: true},
+	}}}}
+	player := NewPlayer("main", "square")
+
+	mustApply(t, engine, &player, "well")
+	if player.StoryID != "main" || player.NodeID != "well" {
+		t.Fatalf("Expected main/well, got %s/%s", player.StoryID, player.NodeID)
+	}
+	mustApply(t, engine, &player, "up")
+	if player.NodeID != "square" || len(player.CallStack) != 0 {
+		t.Errorf("Expected to resume at the choice's next node, got %s %+v", player.NodeID, player.CallStack)
+	}
+	want := []string{"square", "well", "out", "square"}
+	if len(player.VisitedNodes) != len(want) {
+		t.Fatalf("Expected path %v, got %v", want, player.VisitedNodes)
+	}
+	for i := range want {
+		if player.VisitedNodes[i] != want[i] {
+			t.Errorf("Expected path %v, got %v", want, player.VisitedNodes)
+			break
+		}
+	}
+}
+
+func TestApplyChoice_CallDepthLimit(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{"main": {Start: "square", Nodes: map[string]*Node{
+		"square": {Text: "The town square.", Choices: []Choice{{Key: "loop", Text: "Loop", Next: "square", Call: &Call{Node: "square"}}}},
+	}}}}
+	player := NewPlayer("main", "square")
+
+	for i := 0; i < MaxCallDepth; i++ {
+		if result := mustApply(t, engine, &player, "loop"); result.ErrorMessage != "" {
+			t.Fatalf("Call %d refused early: %s", i+1, result.ErrorMessage)
+		}
+	}
+	if result := mustApply(t, engine, &player, "loop"); result.ErrorMessage == "" {
+		t.Error("Expected the call beyond MaxCallDepth to be refused")
+	}
+	if len(player.CallStack) != MaxCallDepth {
+		t.Errorf("Expected stack depth %d, got %d", MaxCallDepth, len(player.CallStack))
+	}
+}
+
+func TestApplyChoice_CallMissingTarget(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{"main": {Start: "square", Nodes: map[string]*Node{
+		"square": {Text: "The town square.", Choices: []Choice{{Key: "nowhere", Text: "Nowhere", Call: &Call{Story: "missing"}}}},
+	}}}}
+	player := NewPlayer("main", "square")
+	result := mustApply(t, engine, &player, "nowhere")
+	if result.ErrorMessage == "" || player.NodeID != "square" || len(player.CallStack) != 0 {
+		t.Errorf("Expected refusal without moving, got %q at %s %+v", result.ErrorMessage, player.NodeID, player.CallStack)
+	}
+}
+
+func TestApplyChoice_DeathInLibraryUnwindsToRoot(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{
+		"main": {Start: "square", Nodes: map[string]*Node{
+			"square": {Text: "The town square.", Choices: []Choice{duelCall}},
+			"death":  {Text: "You died.", Ending: true},
+		}},
+		"lib": {Library: true, Start: "ring", Nodes: map[string]*Node{
+			"ring": {Text: "A ring of rope.", Choices: []Choice{{Key: "hurt", Text: "Trip", Next: "ring", Effects: []Effect{{Op: OpAdd, Stat: StatHealth, Value: -100}}}}},
+		}},
+	}}
+	player := NewPlayer("main", "square")
+	player.Stats.Health = 5
+
+	mustApply(t, engine, &player, "duel")
+	mustApply(t, engine, &player, "hurt")
+	if player.StoryID != "main" || player.NodeID != DeathNodeID {
+		t.Errorf("Expected the root story's death node, got %s/%s", player.StoryID, player.NodeID)
+	}
+	if player.CallStack != nil {
+		t.Errorf("Expected call stack abandoned on death, got %+v", player.CallStack)
+	}
+}
+
+func TestApplyChoice_CallUsesRootDifficulty(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{
+		"main": {
+			Start: "square",
+			Difficulties: []Difficulty{
+				{ID: "normal", Default: true},
+				{ID: "hard", EnemyStrength: 2, EnemyHealth: 3},
+			},
+			Nodes: map[string]*Node{"square": {Text: "The town square.", Choices: []Choice{duelCall}}},
+		},
+		"lib": {Library: true, Start: "ring", Nodes: map[string]*Node{
+			"ring": {Text: "A ring of rope.", Choices: []Choice{
+				{Key: "fight", Text: "Fight", Battle: &Battle{OnVictoryNext: "ring", OnDefeatNext: "ring"}},
+				{Key: "taunt", Text: "Taunt", Next: "ring", If: &Condition{Difficulty: []string{"hard"}}},
+			}},
+		}},
+	}}
+	player := NewPlayer("main", "square")
+	player.Difficulty = "hard"
+	player.Stats = Stats{Strength: 1, Luck: 5, Health: 50}
+
+	mustApply(t, engine, &player, "duel")
+	if d := engine.Difficulty(&player); d == nil || d.ID != "hard" {
+		t.Fatalf("Expected the main story's hard preset inside the library, got %+v", d)
+	}
+	node, err := engine.CurrentNode(&player)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := engine.AvailableChoices(&player, node); len(got) != 2 {
+		t.Errorf("Expected the hard-only choice in the library, got %d choices", len(got))
+	}
+
+	result := mustApply(t, engine, &player, "fight")
+	if len(result.State.Enemies) != 1 {
+		t.Fatalf("Expected the passed champion, got %+v", result.State.Enemies)
+	}
+	// Health may have dropped by one if the player won the first round.
+	if got := result.State.Enemies[0]; got.Strength != 2 || (got.Health != 3 && got.Health != 2) {
+		t.Errorf("Expected the champion scaled by hard, got %+v", got)
+	}
+}
+
+func TestLoadStory_Call(t *testing.T) {
+	storyYAML := `start: "a"
+library: true
+nodes:
+  a:
+    text: "A"
+    choices:
+      - key: "go"
+        text: "Go"
+        call:
+          story: "lib"
+          node: "entry"
+          next: "b"
+          onFailureNext: "c"
+          enemies:
+            - name: "Rat"
+              strength: 2
+              health: 3
+          reward:
+            - op: "add"
+              stat: "luck"
+              value: 1
+  b:
+    text: "B"
+    return: true
+    returnOutcome: "failure"
+`
+	s := loadStoryYAML(t, storyYAML)
+	if !s.Library {
+		t.Error("Expected library: true")
+	}
+	c := s.Nodes["a"].Choices[0].Call
+	if c == nil || c.Story != "lib" || c.Node != "entry" || c.Next != "b" || c.OnFailureNext != "c" {
+		t.Fatalf("Unexpected call: %+v", c)
+	}
+	if len(c.Enemies) != 1 || c.Enemies[0].Health != 3 || len(c.Reward) != 1 {
+		t.Errorf("Expected enemies and reward, got %+v", c)
+	}
+	if b := s.Nodes["b"]; !b.Return || b.ReturnOutcome != OutcomeFailure {
+		t.Errorf("Expected failure return node, got %+v", b)
+	}
+}
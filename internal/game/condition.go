@@ -0,0 +1,86 @@
+package game
+
+// conditionMet reports whether every set field of c holds for st. A nil condition always holds.
+func (e *Engine) conditionMet(st *PlayerState, c *Condition) bool {
+	if c == nil {
+		return true
+	}
+	if len(c.Difficulty) > 0 {
+		chosen := st.Difficulty
+		if d := e.Difficulty(st); d != nil {
+			chosen = d.ID
+		}
+		found := false
+		for _, id := range c.Difficulty {
+			if id == chosen {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, f := range c.Flags {
+		if !st.Flags[f] {
+			return false
+		}
+	}
+	for _, f := range c.NotFlags {
+		if st.Flags[f] {
+			return false
+		}
+	}
+	for _, item := range c.Items {
+		if st.Items[item] <= 0 {
+			return false
+		}
+	}
+	for name, minimum := range c.MinVars {
+		if st.Vars[name] < minimum {
+			return false
+		}
+	}
+	for name, maximum := range c.MaxVars {
+		if st.Vars[name] > maximum {
+			return false
+		}
+	}
+	for nodeID, minimum := range c.MinVisits {
+		if st.Visits[visitKey(st, nodeID)] < minimum {
+			return false
+		}
+	}
+	for nodeID, maximum := range c.MaxVisits {
+		if st.Visits[visitKey(st, nodeID)] > maximum {
+			return false
+		}
+	}
+	return true
+}
+
+// choiceAvailable reports whether ch may be shown and taken at the current node:
+// its condition holds and, for a once-only choice, it has not been taken this run
+// (a once-only battle stays available until that battle is over).
+func (e *Engine) choiceAvailable(st *PlayerState, ch *Choice) bool {
+	inBattle := ch.Battle != nil && len(st.Enemies) > 0
+	if ch.Once && !inBattle && st.Taken[takenKey(st, ch.Key)] > 0 {
+		return false
+	}
+	return e.conditionMet(st, ch.If)
+}
+
+// AvailableChoices returns the choices of st's current node n whose conditions
+// hold, leaving out once-only choices already taken.
+func (e *Engine) AvailableChoices(st *PlayerState, n *Node) []Choice {
+	if n == nil {
+		return nil
+	}
+	out := make([]Choice, 0, len(n.Choices))
+	for i := range n.Choices {
+		if e.choiceAvailable(st, &n.Choices[i]) {
+			out = append(out, n.Choices[i])
+		}
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package game
+
+import "math"
+
+// DefaultRerolls is the number of stat rerolls allowed when the difficulty
+// does not say otherwise.
+const DefaultRerolls = 1
+
+// Difficulty returns the preset with the given ID, or nil if the story has none by that ID.
+func (s *Story) Difficulty(id string) *Difficulty {
+	if s == nil || id == "" {
+		return nil
+	}
+	for i := range s.Difficulties {
+		if s.Difficulties[i].ID == id {
+			return &s.Difficulties[i]
+		}
+	}
+	return nil
+}
+
+// DefaultDifficulty returns the preset marked default, else the first one, else nil.
+func (s *Story) DefaultDifficulty() *Difficulty {
+	if s == nil || len(s.Difficulties) == 0 {
+		return nil
+	}
+	for i := range s.Difficulties {
+		if s.Difficulties[i].Default {
+			return &s.Difficulties[i]
+		}
+	}
+	return &s.Difficulties[0]
+}
+
+// ResolveDifficulty returns the preset for id, falling back to the story default.
+func (s *Story) ResolveDifficulty(id string) *Difficulty {
+	if d := s.Difficulty(id); d != nil {
+		return d
+	}
+	return s.DefaultDifficulty()
+}
+
+// RerollAllowance returns how many stat rerolls this preset allows (DefaultRerolls for nil).
+func (d *Difficulty) RerollAllowance() int {
+	if d == nil || d.Rerolls == nil {
+		return DefaultRerolls
+	}
+	if *d.Rerolls < 0 {
+		return 0
+	}
+	return *d.Rerolls
+}
+
+// ApplyToStats returns base with the preset's starting stat modifiers added,
+// clamped to the global stat bounds. Health never drops below 1 at the start.
+func (d *Difficulty) ApplyToStats(base Stats) Stats {
+	if d == nil {
+		return base
+	}
+	out := Stats{
+		Strength: clampInt(base.Strength+d.Stats.Strength, MinStat, MaxStrength),
+		Luck:     clampInt(base.Luck+d.Stats.Luck, MinStat, MaxLuck),
+		Health:   base.Health + d.Stats.Health,
+	}
+	if out.Health < 1 {
+		out.Health = 1
+	}
+	return out
+}
+
+// scaleEnemy applies the preset's enemy scales to one enemy.
+func (d *Difficulty) scaleEnemy(e EnemyState) EnemyState {
+	if d == nil {
+		return e
+	}
+	e.Strength = scaleStat(e.Strength, d.EnemyStrength, 0)
+	e.Health = scaleStat(e.Health, d.EnemyHealth, 1)
+	return e
+}
+
+// scaleStat multiplies v by scale (0 = unchanged), rounding to nearest and
+// never going below minimum.
+func scaleStat(v int, scale float64, minimum int) int {
+	if scale <= 0 || scale == 1 {
+		return v
+	}
+	n := int(math.Round(float64(v) * scale))
+	if n < minimum {
+		n = minimum
+	}
+	return n
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// Difficulty returns the preset the player chose for their story, or nil.
+// Inside a sub-adventure the preset still comes from the story they started in.
+func (e *Engine) Difficulty(st *PlayerState) *Difficulty {
+	return e.rootStory(st).ResolveDifficulty(st.Difficulty)
+}
@@ -0,0 +1,176 @@
+package game
+
+import "testing"
+
+func TestStory_ResolveDifficulty(t *testing.T) {
+	s := &Story{Difficulties: []Difficulty{{ID: "easy"}, {ID: "normal", Default: true}, {ID: "hard"}}}
+	if d := s.ResolveDifficulty("hard"); d == nil || d.ID != "hard" {
+		t.Errorf("Expected hard preset, got %+v", d)
+	}
+	if d := s.ResolveDifficulty(""); d == nil || d.ID != "normal" {
+		t.Errorf("Expected default preset 'normal', got %+v", d)
+	}
+	if d := s.ResolveDifficulty("missing"); d == nil || d.ID != "normal" {
+		t.Errorf("Expected unknown ID to fall back to default, got %+v", d)
+	}
+	s.Difficulties[1].Default = false
+	if d := s.DefaultDifficulty(); d == nil || d.ID != "easy" {
+		t.Errorf("Expected first preset when none is default, got %+v", d)
+	}
+	var none *Story
+	if d := none.ResolveDifficulty("x"); d != nil {
+		t.Errorf("Expected nil for nil story, got %+v", d)
+	}
+}
+
+func TestDifficulty_RerollAllowance(t *testing.T) {
+	var d *Difficulty
+	if got := d.RerollAllowance(); got != DefaultRerolls {
+		t.Errorf("Expected nil preset to allow %d rerolls, got %d", DefaultRerolls, got)
+	}
+	three, neg := 3, -1
+	if got := (&Difficulty{Rerolls: &three}).RerollAllowance(); got != 3 {
+		t.Errorf("Expected 3 rerolls, got %d", got)
+	}
+	if got := (&Difficulty{Rerolls: &neg}).RerollAllowance(); got != 0 {
+		t.Errorf("Expected negative rerolls to clamp to 0, got %d", got)
+	}
+}
+
+func TestDifficulty_ApplyToStats(t *testing.T) {
+	base := Stats{Strength: 17, Luck: 6, Health: 10}
+	d := &Difficulty{Stats: Stats{Strength: 5, Luck: -20, Health: -20}}
+	got := d.ApplyToStats(base)
+	if got.Strength != MaxStrength {
+		t.Errorf("Expected Strength clamped to %d, got %d", MaxStrength, got.Strength)
+	}
+	if got.Luck != MinStat {
+		t.Errorf("Expected Luck clamped to %d, got %d", MinStat, got.Luck)
+	}
+	if got.Health != 1 {
+		t.Errorf("Expected starting Health at least 1, got %d", got.Health)
+	}
+	var none *Difficulty
+	if none.ApplyToStats(base) != base {
+		t.Error("Expected nil preset to leave stats unchanged")
+	}
+}
+
+func TestApplyChoice_BattleScaledByDifficulty(t *testing.T) {
+	story := &Story{
+		Start: "arena",
+		Difficulties: []Difficulty{
+			{ID: "easy", EnemyStrength: 0.5, EnemyHealth: 0.5},
+			{ID: "normal", Default: true},
+			{ID: "hard", EnemyStrength: 1.5, EnemyHealth: 2},
+		},
+		Nodes: map[string]*Node{
+			"arena": {Text: "A troll waits.", Choices: []Choice{{Key: "fight", Text: "Fight", Battle: &Battle{
+				Enemies:       []Enemy{{Name: "Troll", Strength: 8, Health: 4}},
+				OnVictoryNext: "won",
+			}}}},
+			"won": {Text: "Victory", Ending: true},
+		},
+	}
+	engine := &Engine{Stories: map[string]*Story{"test": story}}
+	tests := []struct {
+		difficulty   string
+		wantStrength int
+		wantHealth   int
+	}{
+		{"easy", 4, 2},
+		{"", 8, 4},
+		{"hard", 12, 8},
+	}
+	for _, tt := range tests {
+		player := NewPlayer("test", "arena")
+		player.Difficulty = tt.difficulty
+		player.Stats.Strength = 1
+		player.Stats.Health = 50
+
+		result := mustApply(t, engine, &player, "fight")
+		if len(result.State.Enemies) != 1 {
+			t.Fatalf("%q: expected 1 enemy, got %d", tt.difficulty, len(result.State.Enemies))
+		}
+		got := result.State.Enemies[0]
+		if got.Strength != tt.wantStrength {
+			t.Errorf("%q: expected enemy Strength %d, got %d", tt.difficulty, tt.wantStrength, got.Strength)
+		}
+		// Health may have dropped by one if the player won the first round.
+		if got.Health != tt.wantHealth && got.Health != tt.wantHealth-1 {
+			t.Errorf("%q: expected enemy Health %d (or one less), got %d", tt.difficulty, tt.wantHealth, got.Health)
+		}
+	}
+}
+
+func TestApplyChoice_ConditionOnDifficulty(t *testing.T) {
+	story := &Story{
+		Start:        "arena",
+		Difficulties: []Difficulty{{ID: "easy"}, {ID: "hard"}},
+		Nodes: map[string]*Node{
+			"arena": {Text: "A troll waits.", Choices: []Choice{
+				{Key: "wait", Text: "Wait", Next: "arena"},
+				{Key: "sneak", Text: "Sneak past", Next: "won", If: &Condition{Difficulty: []string{"easy"}}},
+			}},
+			"won": {Text: "Victory", Ending: true},
+		},
+	}
+	engine := &Engine{Stories: map[string]*Story{"test": story}}
+	node := story.Nodes["arena"]
+
+	player := NewPlayer("test", "arena")
+	player.Difficulty = "hard"
+	if got := engine.AvailableChoices(&player, node); len(got) != 1 {
+		t.Errorf("Expected only the wait choice on hard, got %d choices", len(got))
+	}
+	if result := mustApply(t, engine, &player, "sneak"); result.ErrorMessage == "" {
+		t.Error("Expected gated choice to be rejected on hard")
+	}
+
+	player = NewPlayer("test", "arena")
+	player.Difficulty = "easy"
+	if got := engine.AvailableChoices(&player, node); len(got) != 2 {
+		t.Errorf("Expected both choices on easy, got %d", len(got))
+	}
+	if result := mustApply(t, engine, &player, "sneak"); result.State.NodeID != "won" {
+		t.Errorf("Expected sneak to reach 'won' on easy, got %q", result.State.NodeID)
+	}
+}
+
+func TestLoadStory_Difficulties(t *testing.T) {
+	storyYAML := `start: "a"
+difficulties:
+  - id: "story"
+    name: "Story"
+    enemyStrength: 0.75
+    stats:
+      health: 4
+    rerolls: 2
+  - id: "hard"
+    default: true
+nodes:
+  a:
+    text: "A"
+    choices:
+      - key: "x"
+        text: "Only on story"
+        next: "a"
+        if:
+          difficulty: ["story"]
+`
+	s := loadStoryYAML(t, storyYAML)
+	if len(s.Difficulties) != 2 {
+		t.Fatalf("Expected 2 difficulties, got %d", len(s.Difficulties))
+	}
+	d := s.Difficulties[0]
+	if d.EnemyStrength != 0.75 || d.Stats.Health != 4 || d.RerollAllowance() != 2 {
+		t.Errorf("Unexpected story preset: %+v", d)
+	}
+	if s.DefaultDifficulty().ID != "hard" {
+		t.Errorf("Expected default 'hard', got %q", s.DefaultDifficulty().ID)
+	}
+	c := s.Nodes["a"].Choices[0].If
+	if c == nil || len(c.Difficulty) != 1 || c.Difficulty[0] != "story" {
+		t.Errorf("Expected difficulty condition, got %+v", c)
+	}
+}
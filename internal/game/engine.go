@@ -7,6 +7,8 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 	"unicode"
@@ -42,6 +44,17 @@ const (
 	// OutcomeEnemyHit indicates the enemy hit the player in battle.
 	OutcomeEnemyHit = "enemy_hit"
 
+	// RefusalUnknownChoice: the choice is not on the node or not available.
+	RefusalUnknownChoice = "unknown_choice"
+	// RefusalDeadEnd: the choice has nowhere to go (or a call cannot be entered).
+	RefusalDeadEnd = "dead_end"
+	// RefusalAnswerRequired: a prompt choice was made without an answer.
+	RefusalAnswerRequired = "answer_required"
+	// RefusalWrongAnswer: the answer matched nothing and the prompt has no default.
+	RefusalWrongAnswer = "wrong_answer"
+	// RefusalInvalidCheck: the choice's check is misconfigured in the story.
+	RefusalInvalidCheck = "invalid_check"
+
 	// StatStrength is the stat name for strength.
 	StatStrength = "strength"
 	// StatLuck is the stat name for luck.
@@ -51,13 +64,33 @@ const (
 
 	// OpAdd is the effect operation for adding to a stat.
 	OpAdd = "add"
+	// OpSetFlag is the effect operation for setting a flag.
+	OpSetFlag = "set_flag"
+	// OpClearFlag is the effect operation for clearing a flag.
+	OpClearFlag = "clear_flag"
+	// OpAddItem is the effect operation for gaining an item.
+	OpAddItem = "add_item"
+	// OpRemoveItem is the effect operation for losing an item.
+	OpRemoveItem = "remove_item"
+	// OpSetVar is the effect operation for setting a variable.
+	OpSetVar = "set_var"
+	// OpAddVar is the effect operation for adding to a variable.
+	OpAddVar = "add_var"
+
+	// EffectAlways applies an effect every time (the default mode).
+	EffectAlways = "always"
+	// EffectFirstVisit applies an effect only on the first visit (or first time a choice is taken).
+	EffectFirstVisit = "first_visit"
+	// EffectEveryNth applies an effect on every Nth visit (or every Nth time a choice is taken).
+	EffectEveryNth = "every_nth"
 
 	// HordeName is the display name when 4+ enemies are combined.
 	HordeName = "Horde"
 )
 
-// getBattleEnemies returns initial enemy state from battle (Enemies list or legacy single-enemy fields).
-func getBattleEnemies(b *Battle) []EnemyState {
+// getBattleEnemies returns initial enemy state from battle (Enemies list or legacy single-enemy fields),
+// scaled by the difficulty preset when d is non-nil.
+func getBattleEnemies(b *Battle, d *Difficulty) []EnemyState {
 	if len(b.Enemies) > 0 {
 		out := make([]EnemyState, 0, len(b.Enemies))
 		for _, e := range b.Enemies {
@@ -65,7 +98,7 @@ func getBattleEnemies(b *Battle) []EnemyState {
 			if h <= 0 {
 				h = 1
 			}
-			out = append(out, EnemyState{Name: e.Name, Strength: e.Strength, Health: h})
+			out = append(out, d.scaleEnemy(EnemyState{Name: e.Name, Strength: e.Strength, Health: h}))
 		}
 		return out
 	}
@@ -74,7 +107,7 @@ func getBattleEnemies(b *Battle) []EnemyState {
 		if h <= 0 {
 			h = 1
 		}
-		return []EnemyState{{Name: b.EnemyName, Strength: b.EnemyStrength, Health: h}}
+		return []EnemyState{d.scaleEnemy(EnemyState{Name: b.EnemyName, Strength: b.EnemyStrength, Health: h})}
 	}
 	return nil
 }
@@ -103,6 +136,9 @@ const DefaultStoryID = "demo"
 // Engine manages game state and resolves player choices.
 type Engine struct {
 	Stories map[string]*Story // story ID -> Story
+	// Dice rolls one d6 for checks and battles; nil uses crypto/rand. Set it
+	// for reproducible or faster play (simulations, tests).
+	Dice func() int
 }
 
 // StepResult contains the result of applying a player choice, including
@@ -114,6 +150,10 @@ type StepResult struct {
 	LastEnemyDice  *[2]int // battle only
 	LastOutcome    *string // "success"/"failure"
 	ErrorMessage   string
+	ErrorCode      string        // machine-readable reason for ErrorMessage (Refusal*)
+	Unlocked       []Achievement // achievements unlocked by this step
+	NewEnding      bool          // true if this step reached an ending not reached before
+	Score          *Score        // set when this step finished a scored run
 }
 
 // DefaultAvatar is the avatar ID used for new players.
@@ -133,6 +173,7 @@ func NewPlayer(storyID, startNodeID string) PlayerState {
 		},
 		Flags:        map[string]bool{},
 		VisitedNodes: []string{startNodeID},
+		Visits:       map[string]int{startNodeID: 1},
 	}
 }
 
@@ -189,8 +230,16 @@ func (e *Engine) ApplyChoiceWithAnswer(st *PlayerState, choiceKey, answer string
 			}
 		}
 	}
+	if ch != nil && !e.choiceAvailable(st, ch) {
+		ch = nil
+	}
 	if ch == nil {
-		return StepResult{State: *st, ErrorMessage: "That choice doesn't exist."}, nil
+		return refuse(st, RefusalUnknownChoice, "That choice doesn't exist."), nil
+	}
+	if ch.Call != nil {
+		if msg := e.validateCall(st, ch); msg != "" {
+			return refuse(st, RefusalDeadEnd, msg), nil
+		}
 	}
 
 	var lastRoll *int
@@ -202,23 +251,32 @@ func (e *Engine) ApplyChoiceWithAnswer(st *PlayerState, choiceKey, answer string
 	if ch.Prompt != nil {
 		promptNext, promptMsg := resolvePromptNext(ch.Prompt, answer, ch.Next)
 		if promptNext == "" {
-			return StepResult{State: *st, ErrorMessage: promptMsg}, nil
+			code := RefusalWrongAnswer
+			if NormalizeAnswer(answer) == "" {
+				code = RefusalAnswerRequired
+			}
+			return refuse(st, code, promptMsg), nil
 		}
 		next = promptNext
-		applyEffects(st, ch.Effects)
-	} else {
-		// Apply node-level effects first (optional; here we only do choice effects + destination effects)
-		applyEffects(st, ch.Effects)
 	}
+	// Any accepted step leaves character creation behind, however the run was
+	// started, so the difficulty can't be switched afterwards.
+	st.MarkBegun()
+	// Choice effects count times taken; later rounds of a battle are the same take.
+	taken := 0
+	if ch.Battle == nil || len(st.Enemies) == 0 {
+		taken = markTaken(st, ch.Key)
+	}
+	applyEffectsOnVisit(st, ch.Effects, taken)
 	if ch.Check != nil && ch.Prompt == nil {
-		d1, d2 := roll2d6Ex()
+		d1, d2 := e.roll2d6()
 		roll := d1 + d2
 		lastRoll = &roll
 		lastPlayerDice = &[2]int{d1, d2}
 
 		ok, err := checkRoll(st, *ch.Check, roll)
 		if err != nil {
-			return StepResult{State: *st, ErrorMessage: err.Error()}, nil
+			return refuse(st, RefusalInvalidCheck, err.Error()), nil
 		}
 		var outcome string
 		if ok {
@@ -247,45 +305,51 @@ func (e *Engine) ApplyChoiceWithAnswer(st *PlayerState, choiceKey, answer string
 		st.Enemies = nil
 	}
 
+	oldStoryID, oldNodeID := st.StoryID, st.NodeID
+	// Sub-adventure: push a frame and jump into the called graph.
+	if entersCall(ch) {
+		next = e.enterCall(st, ch)
+	}
+
 	if next == "" {
-		return StepResult{State: *st, ErrorMessage: "No destination for that choice."}, nil
+		return refuse(st, RefusalDeadEnd, "No destination for that choice."), nil
 	}
 
-	oldNodeID := st.NodeID
 	st.NodeID = next
 	if st.VisitedNodes == nil {
 		st.VisitedNodes = []string{}
 	}
-	if st.NodeID != oldNodeID {
-		st.VisitedNodes = append(st.VisitedNodes, st.NodeID)
-	}
-
-	// Apply destination node effects on entry, but avoid re-applying the same
-	// node's effects when we intentionally stay on the same node (e.g. during
-	// multi-round battles).
-	s := e.story(st)
-	if s != nil && st.NodeID != oldNodeID {
-		dst := s.Nodes[st.NodeID]
-		if dst != nil && len(dst.Effects) > 0 {
-			applyEffects(st, dst.Effects)
-		}
+	// Enter the destination (path, visit count, entry effects), but not when we
+	// intentionally stay on the same node (e.g. during multi-round battles).
+	if st.NodeID != oldNodeID || st.StoryID != oldStoryID {
+		e.enterNode(st)
 	}
+	e.unwindReturns(st)
 
 	// Global health-based game over: if health is 0 or below after all
 	// effects, transition to a dedicated death node when available.
 	if st.Stats.Health <= MinHealth {
 		st.Stats.Health = MinHealth
-		if s != nil {
+		if s := e.deathStory(st); s != nil {
 			if _, ok := s.Nodes[DeathNodeID]; ok {
 				if st.NodeID != DeathNodeID {
 					st.VisitedNodes = append(st.VisitedNodes, DeathNodeID)
+					st.NodeID = DeathNodeID
+					markVisit(st)
 				}
-				st.NodeID = DeathNodeID
 			}
 		}
 	}
 
-	return StepResult{State: *st, LastRoll: lastRoll, LastPlayerDice: lastPlayerDice, LastEnemyDice: lastEnemyDice, LastOutcome: lastOutcome}, nil
+	newEnding, unlocked := e.recordProgress(st)
+	score := e.scoreRun(st, st.NodeID != oldNodeID || st.StoryID != oldStoryID)
+
+	return StepResult{State: *st, LastRoll: lastRoll, LastPlayerDice: lastPlayerDice, LastEnemyDice: lastEnemyDice, LastOutcome: lastOutcome, Unlocked: unlocked, NewEnding: newEnding, Score: score}, nil
+}
+
+// refuse returns a StepResult rejecting the choice with a reason code and message.
+func refuse(st *PlayerState, code, msg string) StepResult {
+	return StepResult{State: *st, ErrorCode: code, ErrorMessage: msg}
 }
 
 // applyBattle handles one battle round (or run). Returns next node ID or "" if caller should keep next.
@@ -293,7 +357,12 @@ func (e *Engine) applyBattle(st *PlayerState, ch *Choice, choiceKey string, last
 	b := ch.Battle
 	// Initialize enemies from battle if first round.
 	if len(st.Enemies) == 0 {
-		st.Enemies = collapseToHorde(getBattleEnemies(b))
+		enemies := getBattleEnemies(b, e.Difficulty(st))
+		if len(enemies) == 0 {
+			// A battle without enemies in a sub-adventure fights whoever the caller passed in.
+			enemies = e.calledEnemies(st)
+		}
+		st.Enemies = collapseToHorde(enemies)
 		if len(st.Enemies) == 0 {
 			return b.OnVictoryNext
 		}
@@ -357,6 +426,10 @@ func (e *Engine) applyBattle(st *PlayerState, ch *Choice, choiceKey string, last
 
 	st.Enemies[enemyIndex].Health = newHealth
 	if newHealth <= 0 {
+		if st.Defeated == nil {
+			st.Defeated = map[string]int{}
+		}
+		st.Defeated[st.Enemies[enemyIndex].Name]++
 		st.Enemies = append(st.Enemies[:enemyIndex], st.Enemies[enemyIndex+1:]...)
 	}
 	if len(st.Enemies) == 0 {
@@ -379,8 +452,8 @@ func (e *Engine) resolveBattleRound(st *PlayerState, enemyStrength, enemyHealth,
 		enemyHealth = 1
 	}
 
-	pd1, pd2 := roll2d6Ex()
-	ed1, ed2 := roll2d6Ex()
+	pd1, pd2 := e.roll2d6()
+	ed1, ed2 := e.roll2d6()
 	playerRoll := pd1 + pd2
 	enemyRoll := ed1 + ed2
 
@@ -419,6 +492,61 @@ func (e *Engine) resolveBattleRound(st *PlayerState, enemyStrength, enemyHealth,
 	return updatedState, newEnemyHealth, playerDice, enemyDice, outcome
 }
 
+// BeginRun places the player at the start of a story for a new run, clearing
+// per-run state (position, path, flags, items, variables, enemies) but
+// keeping stats, identity and cross-run Records.
+func (st *PlayerState) BeginRun(storyID, startNodeID string) {
+	st.StoryID = storyID
+	st.NodeID = startNodeID
+	st.VisitedNodes = []string{startNodeID}
+	st.Visits = map[string]int{startNodeID: 1}
+	st.Taken = nil
+	st.Flags = map[string]bool{}
+	st.Items = nil
+	st.Vars = nil
+	st.Enemies = nil
+	st.Defeated = nil
+	st.CallStack = nil
+}
+
+// MarkBegun marks the adventure as begun: no rerolls remain, and the
+// difficulty and stats stay fixed until the player restarts.
+func (st *PlayerState) MarkBegun() {
+	st.RerollUsed = true
+	st.Begun = true
+}
+
+// Clone returns a deep copy of st, sharing no maps or slices with it, so a
+// copy can be stepped without touching the original.
+func (st PlayerState) Clone() PlayerState {
+	st.Flags = maps.Clone(st.Flags)
+	st.Items = maps.Clone(st.Items)
+	st.Vars = maps.Clone(st.Vars)
+	st.Enemies = slices.Clone(st.Enemies)
+	st.VisitedNodes = slices.Clone(st.VisitedNodes)
+	st.Defeated = maps.Clone(st.Defeated)
+	st.CallStack = slices.Clone(st.CallStack)
+	st.Visits = maps.Clone(st.Visits)
+	st.Taken = maps.Clone(st.Taken)
+	if st.Records != nil {
+		records := make(map[string]StoryRecord, len(st.Records))
+		for id, rec := range st.Records {
+			rec.Endings = slices.Clone(rec.Endings)
+			rec.Achievements = slices.Clone(rec.Achievements)
+			if rec.Hero != nil {
+				h := *rec.Hero
+				h.Flags = maps.Clone(h.Flags)
+				h.Items = maps.Clone(h.Items)
+				h.Vars = maps.Clone(h.Vars)
+				rec.Hero = &h
+			}
+			records[id] = rec
+		}
+		st.Records = records
+	}
+	return st
+}
+
 // HasEnemies returns true if the player is in an active battle.
 func (st *PlayerState) HasEnemies() bool {
 	return len(st.Enemies) > 0
@@ -432,7 +560,7 @@ func resolvePromptNext(prompt *Prompt, answer, fallback string) (next, message s
 		return "", "No destination for that choice."
 	}
 
-	normalized := normalizeAnswer(answer)
+	normalized := NormalizeAnswer(answer)
 	if normalized == "" {
 		return "", promptFailureMessage(prompt, "Please enter an answer.")
 	}
@@ -457,12 +585,12 @@ func resolvePromptNext(prompt *Prompt, answer, fallback string) (next, message s
 
 func promptAnswerMatches(ans Answer, normalized string) bool {
 	if ans.Match != "" {
-		if normalizeAnswer(ans.Match) == normalized {
+		if NormalizeAnswer(ans.Match) == normalized {
 			return true
 		}
 	}
 	for _, m := range ans.Matches {
-		if normalizeAnswer(m) == normalized {
+		if NormalizeAnswer(m) == normalized {
 			return true
 		}
 	}
@@ -476,7 +604,9 @@ func promptFailureMessage(prompt *Prompt, fallback string) string {
 	return fallback
 }
 
-func normalizeAnswer(answer string) string {
+// NormalizeAnswer lowercases answer, drops punctuation and collapses spaces,
+// so that answers which differ only in those ways compare equal.
+func NormalizeAnswer(answer string) string {
 	answer = strings.ToLower(answer)
 	var b strings.Builder
 	for _, r := range answer {
@@ -521,7 +651,28 @@ func setStat(st *PlayerState, stat string, v int) {
 
 func applyEffects(st *PlayerState, effs []Effect) {
 	for _, ef := range effs {
-		if ef.Op != OpAdd {
+		switch ef.Op {
+		case OpSetFlag, OpClearFlag:
+			if ef.Flag == "" {
+				continue
+			}
+			if st.Flags == nil {
+				st.Flags = map[string]bool{}
+			}
+			if ef.Op == OpSetFlag {
+				st.Flags[ef.Flag] = true
+			} else {
+				delete(st.Flags, ef.Flag)
+			}
+			continue
+		case OpAddItem, OpRemoveItem:
+			applyItemEffect(st, ef)
+			continue
+		case OpSetVar, OpAddVar:
+			applyVarEffect(st, ef)
+			continue
+		case OpAdd:
+		default:
 			continue
 		}
 		cur := getStat(st, ef.Stat)
@@ -561,6 +712,58 @@ func applyEffects(st *PlayerState, effs []Effect) {
 	}
 }
 
+// applyItemEffect gives or takes ef.Value (default 1) of ef.Item. Counts
+// never go below zero, and an item the player no longer carries is dropped.
+func applyItemEffect(st *PlayerState, ef Effect) {
+	if ef.Item == "" {
+		return
+	}
+	n := ef.Value
+	if n <= 0 {
+		n = 1
+	}
+	if ef.Op == OpRemoveItem {
+		n = -n
+	}
+	if st.Items == nil {
+		st.Items = map[string]int{}
+	}
+	if nv := st.Items[ef.Item] + n; nv > 0 {
+		st.Items[ef.Item] = nv
+	} else {
+		delete(st.Items, ef.Item)
+	}
+}
+
+// applyVarEffect sets or adds to ef.Var, within the effect's clamps.
+func applyVarEffect(st *PlayerState, ef Effect) {
+	if ef.Var == "" {
+		return
+	}
+	if st.Vars == nil {
+		st.Vars = map[string]int{}
+	}
+	nv := ef.Value
+	if ef.Op == OpAddVar {
+		nv += st.Vars[ef.Var]
+	}
+	if ef.ClampMax != nil && nv > *ef.ClampMax {
+		nv = *ef.ClampMax
+	}
+	if ef.ClampMin != nil && nv < *ef.ClampMin {
+		nv = *ef.ClampMin
+	}
+	st.Vars[ef.Var] = nv
+}
+
+// roll2d6 rolls two dice with the engine's Dice, falling back to crypto/rand.
+func (e *Engine) roll2d6() (d1, d2 int) {
+	if e.Dice == nil {
+		return roll2d6Ex()
+	}
+	return e.Dice(), e.Dice()
+}
+
 // roll2d6Ex returns the two d6 values for display.
 func roll2d6Ex() (d1, d2 int) {
 	d1 = d6()
@@ -46,6 +46,27 @@ func TestNewPlayer(t *testing.T) {
 	}
 }
 
+func TestPlayerState_Clone(t *testing.T) {
+	st := NewPlayer("test", "start")
+	st.Flags = map[string]bool{"key": true}
+	st.Items = map[string]int{"torch": 1}
+	st.Vars = map[string]int{"gold": 5}
+	st.Records = map[string]StoryRecord{"test": {Endings: []string{"end"}, Hero: &Hero{Flags: map[string]bool{"key": true}, Items: map[string]int{"torch": 1}}}}
+	c := st.Clone()
+	c.Flags["other"] = true
+	c.Items["torch"]++
+	c.Vars["gold"]++
+	c.Visits["start"]++
+	c.VisitedNodes[0] = "elsewhere"
+	c.Records["test"].Endings[0] = "other"
+	c.Records["test"].Hero.Flags["other"] = true
+	c.Records["test"].Hero.Items["torch"]++
+	if st.Flags["other"] || st.Items["torch"] != 1 || st.Vars["gold"] != 5 || st.Visits["start"] != 1 || st.VisitedNodes[0] != "start" ||
+		st.Records["test"].Endings[0] != "end" || st.Records["test"].Hero.Flags["other"] || st.Records["test"].Hero.Items["torch"] != 1 {
+		t.Errorf("Expected the clone to share nothing, original now %+v", st)
+	}
+}
+
 func TestHasEnemies(t *testing.T) {
 	player := NewPlayer("test", "start")
 	if player.HasEnemies() {
@@ -395,6 +416,39 @@ func TestApplyChoice_InvalidChoice(t *testing.T) {
 	}
 }
 
+func TestApplyChoice_RefusalCodes(t *testing.T) {
+	story := &Story{
+		Start: "start",
+		Nodes: map[string]*Node{
+			"start": {Choices: []Choice{
+				{Key: "riddle", Prompt: &Prompt{Answers: []Answer{{Match: "echo", Next: "start"}}}},
+				{Key: "void"},
+				{Key: "odd", Check: &Check{Stat: StatLuck, Roll: "3d6", Target: "stat"}, Next: "start"},
+			}},
+		},
+	}
+	engine := &Engine{Stories: map[string]*Story{"test": story}}
+	tests := []struct {
+		choice, answer, code string
+	}{
+		{"missing", "", RefusalUnknownChoice},
+		{"riddle", "", RefusalAnswerRequired},
+		{"riddle", "bread", RefusalWrongAnswer},
+		{"void", "", RefusalDeadEnd},
+		{"odd", "", RefusalInvalidCheck},
+	}
+	for _, tt := range tests {
+		player := NewPlayer("test", "start")
+		result, err := engine.ApplyChoiceWithAnswer(&player, tt.choice, tt.answer)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.ErrorCode != tt.code || result.ErrorMessage == "" {
+			t.Errorf("%s/%q: got code %q (%q), want %q", tt.choice, tt.answer, result.ErrorCode, result.ErrorMessage, tt.code)
+		}
+	}
+}
+
 func TestApplyChoice_DestinationEffects(t *testing.T) {
 	story := &Story{
 		Start: "start",
@@ -506,6 +560,49 @@ func TestApplyEffects(t *testing.T) {
 	}
 }
 
+func TestApplyEffects_ItemsAndVars(t *testing.T) {
+	player := PlayerState{}
+	most := 10
+	applyEffects(&player, []Effect{
+		{Op: OpAddItem, Item: "torch"},
+		{Op: OpAddItem, Item: "arrow", Value: 3},
+		{Op: OpAddItem},
+		{Op: OpSetVar, Var: "gold", Value: 4},
+		{Op: OpAddVar, Var: "gold", Value: 20, ClampMax: &most},
+		{Op: OpAddVar, Var: "fame", Value: -2},
+	})
+	if player.Items["torch"] != 1 || player.Items["arrow"] != 3 || len(player.Items) != 2 {
+		t.Errorf("Expected a torch and 3 arrows, got %v", player.Items)
+	}
+	if player.Vars["gold"] != 10 || player.Vars["fame"] != -2 {
+		t.Errorf("Expected gold clamped to 10 and fame -2, got %v", player.Vars)
+	}
+	applyEffects(&player, []Effect{{Op: OpRemoveItem, Item: "torch", Value: 5}, {Op: OpRemoveItem, Item: "arrow"}})
+	if _, ok := player.Items["torch"]; ok || player.Items["arrow"] != 2 {
+		t.Errorf("Expected the torch gone and 2 arrows left, got %v", player.Items)
+	}
+}
+
+func TestConditionMet_ItemsAndVars(t *testing.T) {
+	engine := &Engine{}
+	player := PlayerState{Items: map[string]int{"key": 1}, Vars: map[string]int{"gold": 5}}
+	for _, tt := range []struct {
+		c    Condition
+		want bool
+	}{
+		{Condition{Items: []string{"key"}}, true},
+		{Condition{Items: []string{"key", "lamp"}}, false},
+		{Condition{MinVars: map[string]int{"gold": 5}}, true},
+		{Condition{MinVars: map[string]int{"gold": 6}}, false},
+		{Condition{MaxVars: map[string]int{"gold": 4}}, false},
+		{Condition{MaxVars: map[string]int{"fame": 0}}, true},
+	} {
+		if got := engine.conditionMet(&player, &tt.c); got != tt.want {
+			t.Errorf("conditionMet(%+v) = %v, want %v", tt.c, got, tt.want)
+		}
+	}
+}
+
 func TestCheckRoll(t *testing.T) {
 	player := NewPlayer("test", "start")
 	player.Stats.Strength = 10
@@ -983,3 +1080,36 @@ func TestApplyChoice_HordeInit(t *testing.T) {
 		t.Errorf("Expected horde health > 0 (sum 8 minus possible round damage), got %d", result.State.Enemies[0].Health)
 	}
 }
+
+func TestApplyChoice_EngineDice(t *testing.T) {
+	story := &Story{
+		Start: "start",
+		Nodes: map[string]*Node{
+			"start": {
+				Text: "Test your luck",
+				Choices: []Choice{{
+					Key:           "try",
+					Check:         &Check{Stat: StatLuck, Roll: "2d6", Target: "stat"},
+					OnSuccessNext: "success",
+					OnFailureNext: "failure",
+				}},
+			},
+			"success": {Text: "You succeeded!"},
+			"failure": {Text: "You failed!"},
+		},
+	}
+	engine := &Engine{Stories: map[string]*Story{"test": story}, Dice: func() int { return 6 }}
+	player := NewPlayer("test", "start")
+	player.Stats.Luck = 11
+
+	result, err := engine.ApplyChoice(&player, "try")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *result.LastRoll != 12 || *result.LastPlayerDice != [2]int{6, 6} {
+		t.Errorf("Expected the engine's dice to roll double six, got %v %v", *result.LastRoll, *result.LastPlayerDice)
+	}
+	if player.NodeID != "failure" {
+		t.Errorf("Expected 12 > Luck 11 to fail, got %q", player.NodeID)
+	}
+}
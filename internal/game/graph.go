@@ -0,0 +1,168 @@
+package game
+
+import "sort"
+
+// Edge kinds, one per way a story can move the player between nodes.
+const (
+	EdgeNext          = "next"           // choice next (the continuation after a call returns, or running from a battle)
+	EdgeSuccess       = "success"        // check onSuccessNext
+	EdgeFailure       = "failure"        // check onFailureNext
+	EdgeVictory       = "victory"        // battle onVictoryNext
+	EdgeDefeat        = "defeat"         // battle onDefeatNext
+	EdgeAnswer        = "answer"         // prompt answer next
+	EdgeDefaultAnswer = "default_answer" // prompt defaultNext
+	EdgeCall          = "call"           // call into a node of the same story
+	EdgeDeath         = "death"          // implicit move to the death node when health reaches 0
+)
+
+// Edge is a possible move between two nodes of a story.
+type Edge struct {
+	From  string
+	To    string
+	Kind  string // one of the Edge* constants
+	Label string // choice key, or the matched answer for EdgeAnswer
+}
+
+// Edges returns every edge of the story sorted by From, To, Kind and Label.
+// Nodes that can lose health (battles or negative health effects) get an
+// implicit EdgeDeath to the death node when the story defines one.
+func (s *Story) Edges() []Edge {
+	if s == nil {
+		return nil
+	}
+	_, hasDeath := s.Nodes[DeathNodeID]
+	seen := map[Edge]bool{}
+	var out []Edge
+	add := func(e Edge) {
+		if e.To == "" || seen[e] {
+			return
+		}
+		seen[e] = true
+		out = append(out, e)
+	}
+	for id, n := range s.Nodes {
+		if n == nil {
+			continue
+		}
+		for i := range n.Choices {
+			ch := &n.Choices[i]
+			switch {
+			case ch.Prompt != nil:
+				for _, a := range ch.Prompt.Answers {
+					label := a.Match
+					if label == "" && len(a.Matches) > 0 {
+						label = a.Matches[0]
+					}
+					add(Edge{From: id, To: a.Next, Kind: EdgeAnswer, Label: label})
+				}
+				def := ch.Prompt.DefaultNext
+				if def == "" {
+					def = ch.Next
+				}
+				add(Edge{From: id, To: def, Kind: EdgeDefaultAnswer, Label: ch.Key})
+				continue
+			case ch.Battle != nil:
+				add(Edge{From: id, To: ch.Battle.OnVictoryNext, Kind: EdgeVictory, Label: ch.Key})
+				add(Edge{From: id, To: ch.Battle.OnDefeatNext, Kind: EdgeDefeat, Label: ch.Key})
+				// Running away (battle:run) leaves by the choice's own next.
+				add(Edge{From: id, To: ch.Next, Kind: EdgeNext, Label: ch.Key})
+				continue
+			case ch.Check != nil:
+				success, failure := ch.Next, ch.Next
+				if ch.OnSuccessNext != "" {
+					success = ch.OnSuccessNext
+				}
+				if ch.OnFailureNext != "" {
+					failure = ch.OnFailureNext
+				}
+				add(Edge{From: id, To: success, Kind: EdgeSuccess, Label: ch.Key})
+				add(Edge{From: id, To: failure, Kind: EdgeFailure, Label: ch.Key})
+				continue
+			case ch.Call != nil:
+				if ch.Call.Story == "" {
+					entry := ch.Call.Node
+					if entry == "" {
+						entry = s.Start
+					}
+					add(Edge{From: id, To: entry, Kind: EdgeCall, Label: ch.Key})
+				}
+				next := ch.Call.Next
+				if next == "" {
+					next = ch.Next
+				}
+				add(Edge{From: id, To: next, Kind: EdgeNext, Label: ch.Key})
+				if ch.Call.OnFailureNext != "" {
+					add(Edge{From: id, To: ch.Call.OnFailureNext, Kind: EdgeFailure, Label: ch.Key})
+				}
+				continue
+			}
+			add(Edge{From: id, To: ch.Next, Kind: EdgeNext, Label: ch.Key})
+		}
+		if hasDeath && id != DeathNodeID && mayHurt(n) {
+			add(Edge{From: id, To: DeathNodeID, Kind: EdgeDeath})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Label < b.Label
+	})
+	return out
+}
+
+// Reachable returns the set of node IDs reachable from the story's start node.
+func (s *Story) Reachable() map[string]bool {
+	seen := map[string]bool{}
+	if s == nil || s.Nodes[s.Start] == nil {
+		return seen
+	}
+	adj := map[string][]string{}
+	for _, e := range s.Edges() {
+		adj[e.From] = append(adj[e.From], e.To)
+	}
+	queue := []string{s.Start}
+	seen[s.Start] = true
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, to := range adj[id] {
+			if !seen[to] && s.Nodes[to] != nil {
+				seen[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+	return seen
+}
+
+// mayHurt reports whether entering n or acting on it can lower health:
+// its entry effects, a choice's effects or a battle.
+func mayHurt(n *Node) bool {
+	if hurts(n.Effects) {
+		return true
+	}
+	for i := range n.Choices {
+		if n.Choices[i].Battle != nil || hurts(n.Choices[i].Effects) {
+			return true
+		}
+	}
+	return false
+}
+
+// hurts reports whether any effect can lower health.
+func hurts(effs []Effect) bool {
+	for _, ef := range effs {
+		if ef.Op == OpAdd && ef.Stat == StatHealth && ef.Value < 0 {
+			return true
+		}
+	}
+	return false
+}
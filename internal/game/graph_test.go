@@ -0,0 +1,87 @@
+package game
+
+import "testing"
+
+func graphStory() *Story {
+	return &Story{
+		Start: "start",
+		Nodes: map[string]*Node{
+			"start": {
+				Text: "Start",
+				Choices: []Choice{
+					{Key: "walk", Next: "check"},
+					{Key: "ask", Prompt: &Prompt{
+						Answers:     []Answer{{Match: "yes", Next: "won"}, {Matches: []string{"no", "nope"}, Next: "check"}},
+						DefaultNext: "start",
+					}},
+					{Key: "dive", Next: "start", Call: &Call{Node: "well", Next: "won", OnFailureNext: "start"}},
+				},
+			},
+			"check": {
+				Text: "Check",
+				Choices: []Choice{
+					{Key: "jump", Check: &Check{Stat: StatLuck}, OnSuccessNext: "arena", Next: "start"},
+				},
+			},
+			"arena": {
+				Text: "Arena",
+				Choices: []Choice{
+					{Key: "fight", Battle: &Battle{OnVictoryNext: "won", OnDefeatNext: "death"}},
+				},
+			},
+			"well":     {Text: "Well", Return: true},
+			"won":      {Text: "Won", Ending: true},
+			"death":    {Text: "Dead", Ending: true},
+			"orphan":   {Text: "Nobody comes here", Effects: []Effect{{Op: OpAdd, Stat: StatHealth, Value: -1}}},
+			"orphaned": {Text: "Nor here"},
+		},
+	}
+}
+
+func TestStory_Edges(t *testing.T) {
+	got := graphStory().Edges()
+	want := []Edge{
+		{From: "arena", To: "death", Kind: EdgeDeath},
+		{From: "arena", To: "death", Kind: EdgeDefeat, Label: "fight"},
+		{From: "arena", To: "won", Kind: EdgeVictory, Label: "fight"},
+		{From: "check", To: "arena", Kind: EdgeSuccess, Label: "jump"},
+		{From: "check", To: "start", Kind: EdgeFailure, Label: "jump"},
+		{From: "orphan", To: "death", Kind: EdgeDeath},
+		{From: "start", To: "check", Kind: EdgeAnswer, Label: "no"},
+		{From: "start", To: "check", Kind: EdgeNext, Label: "walk"},
+		{From: "start", To: "start", Kind: EdgeDefaultAnswer, Label: "ask"},
+		{From: "start", To: "start", Kind: EdgeFailure, Label: "dive"},
+		{From: "start", To: "well", Kind: EdgeCall, Label: "dive"},
+		{From: "start", To: "won", Kind: EdgeAnswer, Label: "yes"},
+		{From: "start", To: "won", Kind: EdgeNext, Label: "dive"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d edges, got %d: %+v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Edge %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+	var none *Story
+	if none.Edges() != nil {
+		t.Error("Expected nil edges for nil story")
+	}
+}
+
+func TestStory_Reachable(t *testing.T) {
+	r := graphStory().Reachable()
+	for _, id := range []string{"start", "check", "arena", "well", "won", "death"} {
+		if !r[id] {
+			t.Errorf("Expected %q reachable", id)
+		}
+	}
+	for _, id := range []string{"orphan", "orphaned"} {
+		if r[id] {
+			t.Errorf("Expected %q unreachable", id)
+		}
+	}
+	if len((&Story{Start: "missing"}).Reachable()) != 0 {
+		t.Error("Expected nothing reachable from a missing start")
+	}
+}
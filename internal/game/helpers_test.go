@@ -0,0 +1,43 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// loadStoryYAML writes storyYAML to a temporary file and loads it with LoadStory.
+func loadStoryYAML(t *testing.T, storyYAML string) *Story {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "story.yaml")
+	if err := os.WriteFile(path, []byte(storyYAML), 0o600); err != nil {
+		t.Fatalf("Failed to write story: %v", err)
+	}
+	s, err := LoadStory(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return s
+}
+
+// mustApply applies a choice, failing the test on an engine error.
+func mustApply(t *testing.T, e *Engine, st *PlayerState, key string) StepResult {
+	t.Helper()
+	result, err := e.ApplyChoice(st, key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return result
+}
+
+// fight repeats a battle choice until the player leaves the node they are on
+// (or 50 rounds pass) and returns the last result.
+func fight(t *testing.T, e *Engine, st *PlayerState, key string) StepResult {
+	t.Helper()
+	storyID, nodeID := st.StoryID, st.NodeID
+	var result StepResult
+	for i := 0; i < 50 && st.StoryID == storyID && st.NodeID == nodeID; i++ {
+		result = mustApply(t, e, st, key)
+	}
+	return result
+}
@@ -0,0 +1,267 @@
+package game
+
+import "fmt"
+
+// Scoring is a story's score model: points for the ending reached, for each
+// point of health left, for each step taken and for each enemy defeated.
+// Runs of a story with a score model are scored when they reach an ending.
+type Scoring struct {
+	Endings map[string]int `yaml:"endings"` // ending node ID -> points
+	Ending  int            `yaml:"ending"`  // points for an ending not in Endings
+	Health  int            `yaml:"health"`  // per point of health left
+	Step    int            `yaml:"step"`    // per step taken; usually negative
+	Enemy   int            `yaml:"enemy"`   // per enemy defeated
+}
+
+// Score is a scored run and what its total is made of. Total is never
+// below 0.
+type Score struct {
+	Total    int    `json:"total"`
+	Ending   string `json:"ending"`   // ending node ID
+	Health   int    `json:"health"`   // health left
+	Steps    int    `json:"steps"`    // moves between nodes
+	Defeated int    `json:"defeated"` // enemies defeated
+}
+
+// Score scores st's run, which has reached an ending.
+func (sc *Scoring) Score(st *PlayerState) Score {
+	s := Score{Ending: st.NodeID, Health: st.Stats.Health, Steps: len(st.VisitedNodes) - 1}
+	if s.Steps < 0 {
+		s.Steps = 0
+	}
+	for _, n := range st.Defeated {
+		s.Defeated += n
+	}
+	points, ok := sc.Endings[st.NodeID]
+	if !ok {
+		points = sc.Ending
+	}
+	s.Total = points + s.Health*sc.Health + s.Steps*sc.Step + s.Defeated*sc.Enemy
+	if s.Total < 0 {
+		s.Total = 0
+	}
+	return s
+}
+
+// scoreRun scores st's run if this step entered an ending of a story with a
+// score model, outside any sub-adventure; otherwise it returns nil.
+func (e *Engine) scoreRun(st *PlayerState, entered bool) *Score {
+	s := e.story(st)
+	if !entered || s == nil || s.Scoring == nil || len(st.CallStack) > 0 {
+		return nil
+	}
+	if n := s.Nodes[st.NodeID]; n == nil || !n.Ending {
+		return nil
+	}
+	sc := s.Scoring.Score(st)
+	return &sc
+}
+
+// VerifyRun checks that st's finished run is one the story allows, using
+// the path in VisitedNodes: it must go from the start to the ending st is
+// on, every step must follow an edge, enter a sub-adventure through a call
+// choice or resume the caller where the call said when a sub-adventure
+// returns, the death node can only follow a step that may cost health, and
+// no more enemies can be defeated than the battles on the way hold. It is a
+// basic guard against tampered state before a score is published.
+//
+// A path can sometimes be read more than one way, as when two choices call
+// the same node with different continuations, so every reading is followed
+// and the run passes if any of them does.
+func (e *Engine) VerifyRun(st *PlayerState) error {
+	s := e.story(st)
+	if s == nil {
+		return fmt.Errorf("unknown story %q", st.StoryID)
+	}
+	path := st.VisitedNodes
+	if len(st.CallStack) > 0 {
+		return fmt.Errorf("run is inside a sub-adventure")
+	}
+	if len(path) == 0 || path[0] != s.Start {
+		return fmt.Errorf("run does not begin at the start")
+	}
+	if path[len(path)-1] != st.NodeID {
+		return fmt.Errorf("run does not end where the player is")
+	}
+	if n := s.Nodes[st.NodeID]; n == nil || !n.Ending {
+		return fmt.Errorf("run has not reached an ending")
+	}
+
+	moves := map[string]map[[2]string]bool{}
+	readings := []runReading{{storyID: st.StoryID}}
+	readings[0].enter(s.Nodes[path[0]])
+	for i := 1; i < len(path); i++ {
+		var next []runReading
+		seen := map[string]bool{}
+		for _, r := range readings {
+			for _, nr := range e.runSteps(r, path[i-1], path[i], moves) {
+				if k := nr.key(); !seen[k] {
+					seen[k] = true
+					next = append(next, nr)
+				}
+			}
+		}
+		if len(next) == 0 {
+			return fmt.Errorf("step %d: no way from %q to %q", i, path[i-1], path[i])
+		}
+		if len(next) > maxRunReadings {
+			return fmt.Errorf("step %d: too many ways to read the run", i)
+		}
+		readings = next
+	}
+
+	defeated := 0
+	for _, n := range st.Defeated {
+		defeated += n
+	}
+	met := -1
+	for _, r := range readings {
+		if len(r.stack) == 0 && r.storyID == st.StoryID {
+			met = max(met, r.enemies)
+		}
+	}
+	if met < 0 {
+		return fmt.Errorf("run ends inside a sub-adventure")
+	}
+	if defeated > met {
+		return fmt.Errorf("%d enemies defeated but only %d met", defeated, met)
+	}
+	return nil
+}
+
+// maxRunReadings caps the readings of a path VerifyRun follows at once.
+const maxRunReadings = 64
+
+// runReading is one way of reading a run's path up to a node: the story the
+// player is in there and the sub-adventure calls they are inside.
+type runReading struct {
+	storyID string
+	stack   []CallFrame
+	risky   bool // the step that reached the node, or acting on it, may cost health
+	enemies int  // enemies in the battles met so far
+}
+
+// key identifies the reading for deduplication.
+func (r *runReading) key() string {
+	return fmt.Sprint(r.storyID, r.stack, r.risky, r.enemies)
+}
+
+// enter records arriving at n and counts the enemies n's battles hold.
+func (r *runReading) enter(n *Node) {
+	r.risky = r.risky || mayHurt(n)
+	for i := range n.Choices {
+		b := n.Choices[i].Battle
+		if b == nil {
+			continue
+		}
+		met := len(getBattleEnemies(b, nil))
+		if met == 0 && len(r.stack) > 0 {
+			met = len(getBattleEnemies(&Battle{Enemies: r.stack[len(r.stack)-1].Enemies}, nil))
+		}
+		r.enemies += met
+	}
+}
+
+// runSteps returns the readings that follow r when the path moves from the
+// node from to the node to.
+func (e *Engine) runSteps(r runReading, from, to string, moves map[string]map[[2]string]bool) []runReading {
+	s := e.Stories[r.storyID]
+	if s == nil || s.Nodes[from] == nil {
+		return nil
+	}
+	var out []runReading
+	// arrive enters to in story storyID under stack, if the story has it.
+	// A move that continues the step keeps its risk; a new step starts
+	// with the risk of acting on from.
+	arrive := func(storyID string, stack []CallFrame, cont bool) {
+		ts := e.Stories[storyID]
+		if ts == nil || ts.Nodes[to] == nil {
+			return
+		}
+		nr := runReading{storyID: storyID, stack: stack, risky: mayHurt(s.Nodes[from]), enemies: r.enemies}
+		if cont {
+			nr.risky = r.risky
+		}
+		nr.enter(ts.Nodes[to])
+		out = append(out, nr)
+	}
+
+	// Standing on a return node inside a sub-adventure, the same step
+	// resumes the caller.
+	if n := s.Nodes[from]; n.Return && len(r.stack) > 0 {
+		frame := r.stack[len(r.stack)-1]
+		next := frame.Next
+		if n.ReturnOutcome == OutcomeFailure && frame.OnFailureNext != "" {
+			next = frame.OnFailureNext
+		}
+		if to == next {
+			r.risky = r.risky || n.ReturnOutcome != OutcomeFailure && hurts(frame.Reward)
+			arrive(frame.StoryID, r.stack[:len(r.stack)-1], true)
+		}
+		return out
+	}
+
+	if to == DeathNodeID && r.risky {
+		// The current story's death node, or else the root story's,
+		// abandoning any sub-adventures.
+		if s.Nodes[DeathNodeID] != nil || len(r.stack) == 0 {
+			arrive(r.storyID, r.stack, true)
+		} else {
+			arrive(r.stack[0].StoryID, nil, true)
+		}
+	}
+	m := moves[r.storyID]
+	if m == nil {
+		m = s.runMoves()
+		moves[r.storyID] = m
+	}
+	if m[[2]string{from, to}] {
+		arrive(r.storyID, r.stack, false)
+	}
+	if len(r.stack) < MaxCallDepth {
+		for i := range s.Nodes[from].Choices {
+			ch := &s.Nodes[from].Choices[i]
+			if !entersCall(ch) {
+				continue
+			}
+			storyID, nodeID := e.callTarget(&PlayerState{StoryID: r.storyID}, ch.Call)
+			if nodeID != to {
+				continue
+			}
+			next := ch.Call.Next
+			if next == "" {
+				next = ch.Next
+			}
+			frame := CallFrame{StoryID: r.storyID, Next: next, OnFailureNext: ch.Call.OnFailureNext, Enemies: ch.Call.Enemies, Reward: ch.Call.Reward}
+			arrive(storyID, append(r.stack[:len(r.stack):len(r.stack)], frame), false)
+		}
+	}
+	return out
+}
+
+// runMoves returns the moves between nodes of s that take one step without
+// entering a sub-adventure or dying: its edges less death edges and the
+// edges of call choices, which VerifyRun follows through their frames.
+func (s *Story) runMoves() map[[2]string]bool {
+	m := map[[2]string]bool{}
+	for _, ed := range s.Edges() {
+		if ed.Kind == EdgeDeath || ed.Kind == EdgeCall {
+			continue
+		}
+		if n := s.Nodes[ed.From]; n != nil && (ed.Kind == EdgeNext || ed.Kind == EdgeFailure) && callsWith(n, ed.Label) {
+			continue
+		}
+		m[[2]string{ed.From, ed.To}] = true
+	}
+	return m
+}
+
+// callsWith reports whether n's choice key enters a sub-adventure.
+func callsWith(n *Node, key string) bool {
+	for i := range n.Choices {
+		if n.Choices[i].Key == key && entersCall(&n.Choices[i]) {
+			return true
+		}
+	}
+	return false
+}
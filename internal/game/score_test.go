@@ -0,0 +1,197 @@
+package game
+
+import "testing"
+
+func TestScoring_Score(t *testing.T) {
+	st := NewPlayer("test", "road")
+	st.NodeID, st.VisitedNodes = "castle", []string{"road", "castle"}
+	st.Stats.Health = 4
+	st.Defeated = map[string]int{"Bandit": 1}
+	sc := &Scoring{Endings: map[string]int{"castle": 100}, Ending: 10, Health: 5, Step: -2, Enemy: 20}
+	got := sc.Score(&st)
+	want := Score{Total: 100 + 4*5 - 2 + 20, Ending: "castle", Health: 4, Steps: 1, Defeated: 1}
+	if got != want {
+		t.Errorf("Score = %+v, want %+v", got, want)
+	}
+
+	// Endings without their own points use Ending, and totals stop at 0.
+	sc = &Scoring{Ending: 1, Step: -10}
+	st.NodeID = "ditch"
+	if got := sc.Score(&st); got.Total != 0 {
+		t.Errorf("Expected a floor of 0, got %d", got.Total)
+	}
+}
+
+func TestApplyChoice_ScoresEndings(t *testing.T) {
+	story := &Story{
+		Start:   "road",
+		Scoring: &Scoring{Ending: 10, Health: 5, Step: -2},
+		Nodes: map[string]*Node{
+			"road":  {Text: "A road.", Choices: []Choice{{Key: "walk", Text: "Walk", Next: "ditch"}}},
+			"ditch": {Text: "Lost.", Ending: true},
+		},
+	}
+	engine := &Engine{Stories: map[string]*Story{"test": story}}
+	st := NewPlayer("test", "road")
+	st.Stats.Health = 10
+	res, err := engine.ApplyChoice(&st, "walk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Score == nil || res.Score.Total != 10+50-2 || res.Score.Ending != "ditch" {
+		t.Fatalf("Expected a score for the ditch, got %+v", res.Score)
+	}
+	if err := engine.VerifyRun(&res.State); err != nil {
+		t.Errorf("VerifyRun: %v", err)
+	}
+
+	// Stories without a score model are not scored.
+	story.Scoring = nil
+	st = NewPlayer("test", "road")
+	if res, _ := engine.ApplyChoice(&st, "walk"); res.Score != nil {
+		t.Errorf("Expected no score, got %+v", res.Score)
+	}
+}
+
+func TestVerifyRun(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{"test": {Start: "road", Nodes: map[string]*Node{
+		"road": {Text: "A road.", Choices: []Choice{
+			{Key: "fight", Text: "Fight", Battle: &Battle{EnemyName: "Bandit", EnemyHealth: 1, OnVictoryNext: "castle", OnDefeatNext: "ditch"}},
+			{Key: "walk", Text: "Walk", Next: "ditch"},
+		}},
+		"castle": {Text: "Home.", Ending: true},
+		"ditch":  {Text: "Lost.", Ending: true},
+	}}}}
+	finished := func(path []string, defeated int) *PlayerState {
+		st := NewPlayer("test", "road")
+		st.VisitedNodes = path
+		st.NodeID = path[len(path)-1]
+		st.Defeated = map[string]int{"Bandit": defeated}
+		return &st
+	}
+	if err := engine.VerifyRun(finished([]string{"road", "castle"}, 1)); err != nil {
+		t.Errorf("Expected a fair run to pass, got %v", err)
+	}
+	for name, st := range map[string]*PlayerState{
+		"no ending":      finished([]string{"road"}, 0),
+		"wrong start":    finished([]string{"castle"}, 0),
+		"no such edge":   finished([]string{"road", "ditch", "castle"}, 0),
+		"too many kills": finished([]string{"road", "castle"}, 3),
+	} {
+		if err := engine.VerifyRun(st); err == nil {
+			t.Errorf("%s: expected VerifyRun to fail", name)
+		}
+	}
+	st := finished([]string{"road", "castle"}, 0)
+	st.NodeID = "ditch"
+	if err := engine.VerifyRun(st); err == nil {
+		t.Error("Expected a path not ending on the current node to fail")
+	}
+}
+
+func TestVerifyRun_Calls(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{
+		"test": {Start: "gate", Nodes: map[string]*Node{
+			"gate": {Text: "A gate.", Choices: []Choice{
+				{Key: "duel", Text: "Duel", Call: &Call{Story: "arena", Next: "hall", OnFailureNext: "ditch", Enemies: []Enemy{{Name: "Rat", Strength: 1, Health: 1}}}},
+				{Key: "walk", Text: "Walk", Next: "field"},
+			}},
+			"field": {Text: "A field.", Choices: []Choice{{Key: "rest", Text: "Rest", Next: "hall"}}},
+			"hall":  {Text: "Home.", Ending: true},
+			"ditch": {Text: "Lost.", Ending: true},
+			"death": {Text: "Dead.", Ending: true},
+		}},
+		"arena": {Library: true, Start: "ring", Nodes: map[string]*Node{
+			"ring": {Text: "A ring.", Choices: []Choice{
+				{Key: "fight", Text: "Fight", Battle: &Battle{OnVictoryNext: "won"}},
+				{Key: "yield", Text: "Yield", Next: "lost"},
+			}},
+			"won":  {Text: "Won.", Return: true},
+			"lost": {Text: "Lost.", Return: true, ReturnOutcome: OutcomeFailure},
+		}},
+	}}
+	engine.Dice = func() int { return 6 }
+	st := NewPlayer("test", "gate")
+	mustApply(t, engine, &st, "duel")
+	fight(t, engine, &st, "fight")
+	if st.NodeID != "hall" || st.Defeated["Rat"] != 1 {
+		t.Fatalf("Expected to win the duel and reach the hall, got %q %v", st.NodeID, st.Defeated)
+	}
+	if err := engine.VerifyRun(&st); err != nil {
+		t.Errorf("Expected a played run through a call to pass, got %v", err)
+	}
+
+	finished := func(path []string, defeated int) *PlayerState {
+		st := NewPlayer("test", "gate")
+		st.VisitedNodes = path
+		st.NodeID = path[len(path)-1]
+		st.Defeated = map[string]int{"Rat": defeated}
+		return &st
+	}
+	for name, st := range map[string]*PlayerState{
+		"failure return":  finished([]string{"gate", "ring", "lost", "ditch"}, 0),
+		"death in a call": finished([]string{"gate", "ring", "death"}, 0),
+		"walk":            finished([]string{"gate", "field", "hall"}, 0),
+	} {
+		if err := engine.VerifyRun(st); err != nil {
+			t.Errorf("%s: expected VerifyRun to pass, got %v", name, err)
+		}
+	}
+	for name, st := range map[string]*PlayerState{
+		"call skipped":          finished([]string{"gate", "hall"}, 0),
+		"wrong continuation":    finished([]string{"gate", "ring", "won", "ditch"}, 0),
+		"failure as success":    finished([]string{"gate", "ring", "lost", "hall"}, 0),
+		"no return":             finished([]string{"gate", "ring", "hall"}, 0),
+		"death without harm":    finished([]string{"gate", "field", "death"}, 0),
+		"too many called kills": finished([]string{"gate", "ring", "won", "hall"}, 2),
+	} {
+		if err := engine.VerifyRun(st); err == nil {
+			t.Errorf("%s: expected VerifyRun to fail", name)
+		}
+	}
+}
+
+func TestVerifyRun_EveryEnding(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{"test": {Start: "camp", Nodes: map[string]*Node{
+		"camp": {Text: "A camp.", Choices: []Choice{
+			{Key: "north", Text: "North", Next: "road"},
+			{Key: "east", Text: "East", Next: "bridge"},
+			{Key: "south", Text: "South", Next: "river"},
+		}},
+		"road": {Text: "A road.", Choices: []Choice{
+			{Key: "band", Text: "Fight the band", Next: "forest", Battle: &Battle{EnemyName: "Bandit", EnemyStrength: 1, EnemyHealth: 2, OnVictoryNext: "clearing"}},
+		}},
+		"forest": {Text: "A forest.", Choices: []Choice{{Key: "sneak", Text: "Sneak", Next: "clearing"}}},
+		"bridge": {Text: "A bridge.", Choices: []Choice{
+			{Key: "troll", Text: "Fight the troll", Next: "camp", Battle: &Battle{EnemyName: "Troll", EnemyStrength: 50, EnemyHealth: 50}},
+		}},
+		"clearing": {Text: "Safe.", Ending: true},
+		"river":    {Text: "Swept away.", Ending: true},
+		"death":    {Text: "Dead.", Ending: true},
+	}}}}
+	engine.Dice = func() int { return 6 }
+
+	for name, keys := range map[string][]string{
+		"walk":     {"south"},
+		"win":      {"north", "band"},
+		"run away": {"north", "band:run", "sneak"},
+		"lose":     {"east", "troll"},
+		"run back": {"east", "troll:run", "south"},
+	} {
+		st := NewPlayer("test", "camp")
+		st.Stats = Stats{Strength: 10, Luck: 8, Health: 12}
+		for _, key := range keys {
+			if key == "band" || key == "troll" {
+				fight(t, engine, &st, key)
+			} else {
+				mustApply(t, engine, &st, key)
+			}
+		}
+		if node := engine.Stories["test"].Nodes[st.NodeID]; node == nil || !node.Ending {
+			t.Fatalf("%s: expected to reach an ending, got %q", name, st.NodeID)
+		}
+		if err := engine.VerifyRun(&st); err != nil {
+			t.Errorf("%s: expected %v to pass, got %v", name, st.VisitedNodes, err)
+		}
+	}
+}
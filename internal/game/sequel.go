@@ -0,0 +1,101 @@
+package game
+
+import (
+	"fmt"
+	"maps"
+)
+
+// snapshotHero copies the parts of st a sequel may carry over.
+func snapshotHero(st *PlayerState) *Hero {
+	h := &Hero{Ending: st.NodeID, Name: st.Name, Avatar: st.Avatar, Stats: st.Stats}
+	for k, v := range st.Flags {
+		if !v {
+			continue
+		}
+		if h.Flags == nil {
+			h.Flags = map[string]bool{}
+		}
+		h.Flags[k] = true
+	}
+	for k, n := range st.Items {
+		if n <= 0 {
+			continue
+		}
+		if h.Items == nil {
+			h.Items = map[string]int{}
+		}
+		h.Items[k] = n
+	}
+	if len(st.Vars) > 0 {
+		h.Vars = maps.Clone(st.Vars)
+	}
+	return h
+}
+
+// SequelHero returns the hero st may carry into storyID, or nil when the story
+// is not a sequel or the player has not reached a qualifying ending of its prequel.
+func (e *Engine) SequelHero(st *PlayerState, storyID string) *Hero {
+	s := e.Stories[storyID]
+	if s == nil || s.Continues == nil {
+		return nil
+	}
+	c := s.Continues
+	h := st.Record(c.From).Hero
+	if h == nil {
+		return nil
+	}
+	if len(c.Endings) > 0 && !containsString(c.Endings, h.Ending) {
+		return nil
+	}
+	return h
+}
+
+// BeginSequel starts a new run of storyID with the hero from its prequel,
+// carrying over stats, flags, items and variables through the story's import
+// mapping. Stats not listed keep their current (freshly rolled) values.
+// Returns an error, leaving st unchanged, if the hero does not qualify.
+func (e *Engine) BeginSequel(st *PlayerState, storyID string) error {
+	h := e.SequelHero(st, storyID)
+	if h == nil {
+		return fmt.Errorf("no hero has reached a qualifying ending before %s", storyID)
+	}
+	s := e.Stories[storyID]
+	st.BeginRun(storyID, s.Start)
+
+	stats := s.Continues.Stats
+	if len(stats) == 0 {
+		stats = []string{StatStrength, StatLuck, StatHealth}
+	}
+	for _, stat := range stats {
+		setStat(st, stat, getStat(&PlayerState{Stats: h.Stats}, stat))
+	}
+	for from, to := range s.Continues.Flags {
+		if h.Flags[from] && to != "" {
+			st.Flags[to] = true
+		}
+	}
+	for from, to := range s.Continues.Items {
+		if n := h.Items[from]; n > 0 && to != "" {
+			if st.Items == nil {
+				st.Items = map[string]int{}
+			}
+			st.Items[to] += n
+		}
+	}
+	for from, to := range s.Continues.Vars {
+		if v, ok := h.Vars[from]; ok && to != "" {
+			if st.Vars == nil {
+				st.Vars = map[string]int{}
+			}
+			st.Vars[to] = v
+		}
+	}
+	if h.Name != "" {
+		st.Name = h.Name
+	}
+	if h.Avatar != "" {
+		st.Avatar = h.Avatar
+	}
+	st.MarkBegun()
+	return nil
+}
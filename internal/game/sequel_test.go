@@ -0,0 +1,133 @@
+package game
+
+import "testing"
+
+func TestBeginSequel_CarriesHero(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{
+		"one": {Start: "road", Nodes: map[string]*Node{
+			"road": {Text: "A fork in the road.", Choices: []Choice{{Key: "left", Text: "Left", Next: "castle", Effects: []Effect{
+				{Op: OpSetFlag, Flag: "met_king"},
+				{Op: OpAddItem, Item: "crown"},
+				{Op: OpAddItem, Item: "torch"},
+				{Op: OpSetVar, Var: "gold", Value: 30},
+			}}}},
+			"castle": {Text: "The castle.", Ending: true},
+		}},
+		"two": {
+			Start: "throne",
+			Continues: &Continuation{
+				From:    "one",
+				Endings: []string{"castle"},
+				Stats:   []string{StatStrength},
+				Flags:   map[string]string{"met_king": "royal_favour"},
+				Items:   map[string]string{"crown": "old_crown"},
+				Vars:    map[string]string{"gold": "treasury"},
+			},
+			Nodes: map[string]*Node{"throne": {Text: "The throne room.", Ending: true}},
+		},
+	}}
+	player := NewPlayer("one", "road")
+	player.Name = "Ada"
+	player.Avatar = "female_old"
+	player.Stats = Stats{Strength: 15, Luck: 9, Health: 4}
+
+	if engine.SequelHero(&player, "two") != nil {
+		t.Fatal("Expected no sequel hero before finishing the prequel")
+	}
+	mustApply(t, engine, &player, "left")
+	h := player.Record("one").Hero
+	if h == nil || h.Ending != "castle" || h.Stats.Strength != 15 || !h.Flags["met_king"] || h.Items["crown"] != 1 || h.Vars["gold"] != 30 {
+		t.Fatalf("Expected hero snapshot at the castle, got %+v", h)
+	}
+
+	// A new character with fresh rolls continues as Ada.
+	player.Name = ""
+	player.Stats = Stats{Strength: 7, Luck: 7, Health: 20}
+	if err := engine.BeginSequel(&player, "two"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if player.StoryID != "two" || player.NodeID != "throne" {
+		t.Errorf("Expected sequel start, got %s/%s", player.StoryID, player.NodeID)
+	}
+	if player.Stats.Strength != 15 || player.Stats.Luck != 7 || player.Stats.Health != 20 {
+		t.Errorf("Expected only Strength carried over, got %+v", player.Stats)
+	}
+	if !player.Flags["royal_favour"] || player.Flags["met_king"] {
+		t.Errorf("Expected met_king mapped to royal_favour, got %v", player.Flags)
+	}
+	if player.Items["old_crown"] != 1 || len(player.Items) != 1 {
+		t.Errorf("Expected only the crown carried over, as old_crown, got %v", player.Items)
+	}
+	if player.Vars["treasury"] != 30 || len(player.Vars) != 1 {
+		t.Errorf("Expected gold carried over as treasury, got %v", player.Vars)
+	}
+	if player.Name != "Ada" || player.Avatar != "female_old" || !player.RerollUsed || !player.Begun {
+		t.Errorf("Expected hero identity carried over and the run begun, got %q %q %v %v", player.Name, player.Avatar, player.RerollUsed, player.Begun)
+	}
+}
+
+func TestBeginSequel_RequiresEnding(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{
+		"one": {Start: "road", Nodes: map[string]*Node{
+			"road":  {Text: "A fork in the road.", Choices: []Choice{{Key: "right", Text: "Right", Next: "swamp"}}},
+			"swamp": {Text: "The swamp.", Ending: true},
+		}},
+		"two": {
+			Start:     "throne",
+			Continues: &Continuation{From: "one", Endings: []string{"castle"}},
+			Nodes:     map[string]*Node{"throne": {Text: "The throne room.", Ending: true}},
+		},
+	}}
+	player := NewPlayer("one", "road")
+	mustApply(t, engine, &player, "right")
+	if err := engine.BeginSequel(&player, "two"); err == nil {
+		t.Error("Expected the swamp ending not to qualify")
+	}
+	if player.StoryID != "one" {
+		t.Errorf("Expected state unchanged on refusal, got story %q", player.StoryID)
+	}
+	if err := engine.BeginSequel(&player, "one"); err == nil {
+		t.Error("Expected a story without continues to be refused")
+	}
+}
+
+func TestRecordProgress_DeadHeroNotKept(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{"one": {Start: "road", Nodes: map[string]*Node{
+		"road":  {Text: "A fork in the road.", Choices: []Choice{{Key: "fall", Text: "Jump", Next: "road", Effects: []Effect{{Op: OpAdd, Stat: StatHealth, Value: -100}}}}},
+		"death": {Text: "Dead.", Ending: true},
+	}}}}
+	player := NewPlayer("one", "road")
+	player.Stats.Health = 5
+	mustApply(t, engine, &player, "fall")
+	if player.NodeID != DeathNodeID {
+		t.Fatalf("Expected death, got %q", player.NodeID)
+	}
+	if player.Record("one").Hero != nil {
+		t.Error("Expected no hero kept from a fatal ending")
+	}
+}
+
+func TestLoadStory_Continues(t *testing.T) {
+	storyYAML := `start: "a"
+continues:
+  from: "demo"
+  endings: ["clearing"]
+  stats: ["luck"]
+  flags:
+    asked_riddle: "heard_whispers"
+  items:
+    lantern: "lantern"
+  vars:
+    gold: "purse"
+nodes:
+  a:
+    text: "A"
+    ending: true
+`
+	s := loadStoryYAML(t, storyYAML)
+	c := s.Continues
+	if c == nil || c.From != "demo" || len(c.Endings) != 1 || c.Stats[0] != StatLuck || c.Flags["asked_riddle"] != "heard_whispers" ||
+		c.Items["lantern"] != "lantern" || c.Vars["gold"] != "purse" {
+		t.Errorf("Unexpected continuation: %+v", c)
+	}
+}
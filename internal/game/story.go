@@ -1,7 +1,10 @@
 package game
 
 import (
+	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -16,6 +19,10 @@ func LoadStory(path string) (*Story, error) {
 	if err != nil {
 		return nil, err
 	}
+	return parseStory(b)
+}
+
+func parseStory(b []byte) (*Story, error) {
 	var s Story
 	if err := yaml.Unmarshal(b, &s); err != nil {
 		return nil, err
@@ -25,7 +32,13 @@ func LoadStory(path string) (*Story, error) {
 
 // LoadStories loads all *.yaml files from dir and returns a map of story ID (filename without extension) to Story.
 func LoadStories(dir string) (map[string]*Story, error) {
-	entries, err := os.ReadDir(dir)
+	return LoadStoriesFS(os.DirFS(dir))
+}
+
+// LoadStoriesFS loads all *.yaml files at the root of fsys, such as the
+// stories embedded in the server, keyed by story ID as LoadStories does.
+func LoadStoriesFS(fsys fs.FS) (map[string]*Story, error) {
+	entries, err := fs.ReadDir(fsys, ".")
 	if err != nil {
 		return nil, err
 	}
@@ -34,15 +47,18 @@ func LoadStories(dir string) (map[string]*Story, error) {
 		if e.IsDir() || !strings.HasSuffix(strings.ToLower(e.Name()), ".yaml") {
 			continue
 		}
-		id := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		id := strings.TrimSuffix(e.Name(), path.Ext(e.Name()))
 		if id == "" {
 			continue
 		}
-		path := filepath.Join(dir, e.Name())
-		s, err := LoadStory(path)
+		b, err := fs.ReadFile(fsys, e.Name())
 		if err != nil {
 			return nil, err
 		}
+		s, err := parseStory(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
 		stories[id] = s
 	}
 	return stories, nil
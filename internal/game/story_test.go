@@ -3,7 +3,9 @@ package game
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 const testStartNode = "node1"
@@ -323,3 +325,24 @@ func TestLoadStories_InvalidDir(t *testing.T) {
 		t.Error("Expected error for nonexistent directory")
 	}
 }
+
+func TestLoadStoriesFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"one.yaml":            {Data: []byte("start: a\nnodes:\n  a:\n    text: A\n    ending: true\n")},
+		"one/scenery/a.png":   {Data: []byte("png")},
+		"broken.txt":          {Data: []byte("not yaml")},
+		"nested/ignored.yaml": {Data: []byte("start: b\n")},
+	}
+	stories, err := LoadStoriesFS(fsys)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(stories) != 1 || stories["one"] == nil || stories["one"].Start != "a" {
+		t.Errorf("Expected just story 'one', got %v", stories)
+	}
+
+	fsys["bad.yaml"] = &fstest.MapFile{Data: []byte("start: [")}
+	if _, err := LoadStoriesFS(fsys); err == nil || !strings.Contains(err.Error(), "bad.yaml") {
+		t.Errorf("Expected an error naming bad.yaml, got %v", err)
+	}
+}
@@ -22,17 +22,96 @@ type PlayerState struct {
 	Name         string // character display name
 	Avatar       string // avatar ID e.g. "male_young"
 	Stats        Stats
-	RerollUsed   bool // true once stats have been rerolled on setup
+	BaseStats    Stats  // rolled stats before difficulty modifiers
+	Difficulty   string // chosen difficulty preset ID; empty = story default
+	Rerolls      int    // number of stat rerolls used on setup
+	RerollUsed   bool   // true once no rerolls remain (or the adventure has begun)
+	Begun        bool   // true once the adventure has begun; difficulty and stats are then fixed
 	Flags        map[string]bool
-	Enemies      []EnemyState // 1–3 shown individually; 4+ stored as one "Horde" entry
-	VisitedNodes []string     // node IDs in order visited (for treasure map)
+	Items        map[string]int // item name -> number carried this run
+	Vars         map[string]int // story variables e.g. gold or reputation, this run
+	Enemies      []EnemyState   // 1–3 shown individually; 4+ stored as one "Horde" entry
+	VisitedNodes []string       // node IDs in order visited (for treasure map)
+	Defeated     map[string]int // enemy name -> number defeated this run
+	CallStack    []CallFrame    // active sub-adventure calls, outermost first
+	Visits       map[string]int // node key -> times entered this run (see visitKey)
+	Taken        map[string]int // node key + "#" + choice key -> times taken this run
+
+	// Records holds what the player has discovered per story across runs;
+	// BeginRun leaves it untouched.
+	Records map[string]StoryRecord
+}
+
+// CallFrame records where to resume when a sub-adventure returns.
+type CallFrame struct {
+	StoryID       string   // caller's story
+	Next          string   // caller node to resume at after a successful return
+	OnFailureNext string   // caller node after a failure return; empty = Next
+	Enemies       []Enemy  // enemies passed to the sub-adventure's battles
+	Reward        []Effect // applied on a successful return
+}
+
+// StoryRecord is a player's discoveries in one story, kept across runs.
+type StoryRecord struct {
+	Endings      []string // ending node IDs, in the order first reached
+	Achievements []string // unlocked achievement IDs, in unlock order
+	Hero         *Hero    // the character as they were at the most recent ending
+}
+
+// Hero is a snapshot of a character at an ending, used to continue into a sequel.
+type Hero struct {
+	Ending string // ending node ID reached
+	Name   string
+	Avatar string
+	Stats  Stats
+	Flags  map[string]bool
+	Items  map[string]int
+	Vars   map[string]int
 }
 
 // Story represents a complete adventure story with nodes and choices.
 type Story struct {
-	Title string           `yaml:"title"` // optional display name; if empty, derived from ID
-	Start string           `yaml:"start"`
-	Nodes map[string]*Node `yaml:"nodes"`
+	Title        string           `yaml:"title"`   // optional display name; if empty, derived from ID
+	Library      bool             `yaml:"library"` // only reachable via call; hidden from the start page
+	Start        string           `yaml:"start"`
+	Difficulties []Difficulty     `yaml:"difficulties"` // optional presets offered at character creation
+	Continues    *Continuation    `yaml:"continues"`    // optional; lets a hero from another story carry on here
+	Achievements []Achievement    `yaml:"achievements"`
+	Scoring      *Scoring         `yaml:"scoring"` // optional; scores runs that reach an ending
+	Nodes        map[string]*Node `yaml:"nodes"`
+}
+
+// Achievement is a story-defined accomplishment, unlocked the first time its
+// trigger holds after a step.
+type Achievement struct {
+	ID          string  `yaml:"id"`
+	Title       string  `yaml:"title"`
+	Description string  `yaml:"description"`
+	Hint        string  `yaml:"hint"` // spoiler-safe hint shown while locked
+	Trigger     Trigger `yaml:"trigger"`
+}
+
+// Trigger describes when an achievement unlocks. All set fields must hold.
+type Trigger struct {
+	Node     string         `yaml:"node"`     // player is on this node
+	Flag     string         `yaml:"flag"`     // flag is set
+	Defeated string         `yaml:"defeated"` // an enemy with this name has been defeated this run
+	Ending   bool           `yaml:"ending"`   // player is on an ending node
+	AtLeast  map[string]int `yaml:"atLeast"`  // stat name -> minimum value e.g. {health: 10}
+}
+
+// Difficulty is a story-defined preset chosen at character creation. Enemy
+// scales multiply each enemy's Strength and Health (0 means unchanged); Stats
+// are added to the rolled starting stats.
+type Difficulty struct {
+	ID            string  `yaml:"id"`
+	Name          string  `yaml:"name"`
+	Description   string  `yaml:"description"`
+	Default       bool    `yaml:"default"`
+	EnemyStrength float64 `yaml:"enemyStrength"` // e.g. 0.75 for weaker foes, 1.25 for tougher ones
+	EnemyHealth   float64 `yaml:"enemyHealth"`
+	Stats         Stats   `yaml:"stats"`   // starting stat modifiers e.g. {health: 4}
+	Rerolls       *int    `yaml:"rerolls"` // stat rerolls allowed on setup; nil = 1
 }
 
 // Node represents a single location or scene in the adventure.
@@ -44,20 +123,64 @@ type Node struct {
 	Choices        []Choice `yaml:"choices"`
 	Effects        []Effect `yaml:"effects"`
 	Ending         bool     `yaml:"ending"`
+	Title          string   `yaml:"title"`         // optional short name e.g. for the endings catalogue
+	Hint           string   `yaml:"hint"`          // spoiler-safe hint shown for an undiscovered ending
+	Return         bool     `yaml:"return"`        // entering this node returns from the current sub-adventure
+	ReturnOutcome  string   `yaml:"returnOutcome"` // "success" (default) or "failure"
 }
 
 // Choice represents a player action available at a node.
 type Choice struct {
-	Key           string   `yaml:"key"`
-	Text          string   `yaml:"text"`
-	Next          string   `yaml:"next"`
-	Mode          string   `yaml:"mode"` // e.g. "battle_attack", "battle_luck"
-	Check         *Check   `yaml:"check"`
-	OnSuccessNext string   `yaml:"onSuccessNext"`
-	OnFailureNext string   `yaml:"onFailureNext"`
-	Effects       []Effect `yaml:"effects"`
-	Battle        *Battle  `yaml:"battle"`
-	Prompt        *Prompt  `yaml:"prompt"`
+	Key           string     `yaml:"key"`
+	Text          string     `yaml:"text"`
+	Next          string     `yaml:"next"`
+	Mode          string     `yaml:"mode"` // e.g. "battle_attack", "battle_luck"
+	Check         *Check     `yaml:"check"`
+	OnSuccessNext string     `yaml:"onSuccessNext"`
+	OnFailureNext string     `yaml:"onFailureNext"`
+	Effects       []Effect   `yaml:"effects"`
+	Battle        *Battle    `yaml:"battle"`
+	Prompt        *Prompt    `yaml:"prompt"`
+	If            *Condition `yaml:"if"`   // optional; the choice is hidden unless the condition holds
+	Call          *Call      `yaml:"call"` // optional; enter a sub-adventure instead of moving to Next
+	Once          bool       `yaml:"once"` // hidden after it has been taken once this run
+}
+
+// Call enters a sub-adventure: a node graph in this story or in a shared
+// library story. When play reaches a node with return: true, it resumes at
+// Next in the caller (OnFailureNext for a failure return). Call is mutually
+// exclusive with check, battle and prompt on the same choice.
+type Call struct {
+	Story         string   `yaml:"story"`         // library story ID; empty = same story
+	Node          string   `yaml:"node"`          // entry node; empty = the called story's start
+	Next          string   `yaml:"next"`          // continuation after a successful return; empty = the choice's next
+	OnFailureNext string   `yaml:"onFailureNext"` // continuation after a failure return; empty = Next
+	Enemies       []Enemy  `yaml:"enemies"`       // used by battles in the sub-adventure that define no enemies
+	Reward        []Effect `yaml:"reward"`        // applied on a successful return
+}
+
+// Continuation declares a story as a sequel: a hero who reached one of the
+// required endings of From may start here with their stats, flags, items and
+// variables mapped in.
+type Continuation struct {
+	From    string            `yaml:"from"`    // prequel story ID
+	Endings []string          `yaml:"endings"` // ending node IDs that qualify; empty = any ending survived
+	Stats   []string          `yaml:"stats"`   // stats carried over; empty = all
+	Flags   map[string]string `yaml:"flags"`   // prequel flag -> flag set in this story
+	Items   map[string]string `yaml:"items"`   // prequel item -> item carried in this story, same count
+	Vars    map[string]string `yaml:"vars"`    // prequel variable -> variable in this story, same value
+}
+
+// Condition gates a choice on the player's state. All set fields must hold.
+type Condition struct {
+	Difficulty []string       `yaml:"difficulty"` // chosen difficulty ID must be one of these
+	Flags      []string       `yaml:"flags"`      // all of these flags must be set
+	NotFlags   []string       `yaml:"notFlags"`   // none of these flags may be set
+	Items      []string       `yaml:"items"`      // all of these items must be carried
+	MinVars    map[string]int `yaml:"minVars"`    // variable -> at least this value (unset variables are 0)
+	MaxVars    map[string]int `yaml:"maxVars"`    // variable -> at most this value
+	MinVisits  map[string]int `yaml:"minVisits"`  // node ID -> entered at least this many times this run
+	MaxVisits  map[string]int `yaml:"maxVisits"`  // node ID -> entered at most this many times (0 = never)
 }
 
 // Prompt defines a question that expects a typed answer.
@@ -84,13 +207,18 @@ type Check struct {
 	Target string `yaml:"target"` // "stat" (roll <= stat)
 }
 
-// Effect modifies player stats when applied.
+// Effect modifies player stats, flags, items or variables when applied.
 type Effect struct {
-	Op       string `yaml:"op"`   // "add"
-	Stat     string `yaml:"stat"` // "health" | "strength" | "luck"
-	Value    int    `yaml:"value"`
+	Op       string `yaml:"op"`    // "add" | "set_flag" | "clear_flag" | "add_item" | "remove_item" | "set_var" | "add_var"
+	Stat     string `yaml:"stat"`  // "health" | "strength" | "luck"
+	Flag     string `yaml:"flag"`  // flag name for set_flag / clear_flag
+	Item     string `yaml:"item"`  // item name for add_item / remove_item
+	Var      string `yaml:"var"`   // variable name for set_var / add_var
+	Value    int    `yaml:"value"` // amount; items default to 1
 	ClampMax *int   `yaml:"clampMax"`
 	ClampMin *int   `yaml:"clampMin"`
+	Mode     string `yaml:"mode"`  // "always" (default) | "first_visit" | "every_nth"
+	Every    int    `yaml:"every"` // for every_nth: apply on visits Every, 2*Every, ...
 }
 
 // Enemy is a single enemy definition in story YAML.
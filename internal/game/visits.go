@@ -0,0 +1,73 @@
+package game
+
+// visitKey identifies nodeID of the player's current story in Visits. Nodes of
+// the story the player started in use their plain ID; nodes of a library
+// story entered via call are prefixed with its ID so the counts never collide.
+func visitKey(st *PlayerState, nodeID string) string {
+	if st.StoryID == rootStoryID(st) {
+		return nodeID
+	}
+	return st.StoryID + "/" + nodeID
+}
+
+// takenKey identifies a choice on the player's current node in Taken.
+func takenKey(st *PlayerState, choiceKey string) string {
+	return visitKey(st, st.NodeID) + "#" + choiceKey
+}
+
+// markVisit counts a visit to the player's current node and returns the new count.
+func markVisit(st *PlayerState) int {
+	if st.Visits == nil {
+		st.Visits = map[string]int{}
+	}
+	k := visitKey(st, st.NodeID)
+	st.Visits[k]++
+	return st.Visits[k]
+}
+
+// markTaken counts taking a choice on the current node and returns the new count.
+func markTaken(st *PlayerState, choiceKey string) int {
+	if st.Taken == nil {
+		st.Taken = map[string]int{}
+	}
+	k := takenKey(st, choiceKey)
+	st.Taken[k]++
+	return st.Taken[k]
+}
+
+// enterNode records arriving at st.NodeID: it extends the path, counts the
+// visit and applies the node's entry effects for that visit.
+func (e *Engine) enterNode(st *PlayerState) {
+	st.VisitedNodes = append(st.VisitedNodes, st.NodeID)
+	n := markVisit(st)
+	if s := e.story(st); s != nil {
+		if dst := s.Nodes[st.NodeID]; dst != nil {
+			applyEffectsOnVisit(st, dst.Effects, n)
+		}
+	}
+}
+
+// effectDue reports whether ef applies on the nth visit (or nth time a choice
+// is taken). n is 0 for repeat rounds of a battle, where only "always" applies.
+func effectDue(ef Effect, n int) bool {
+	switch ef.Mode {
+	case EffectFirstVisit:
+		return n == 1
+	case EffectEveryNth:
+		if n <= 0 {
+			return false
+		}
+		return ef.Every <= 1 || n%ef.Every == 0
+	default:
+		return true
+	}
+}
+
+// applyEffectsOnVisit applies the effects that are due on the nth visit.
+func applyEffectsOnVisit(st *PlayerState, effs []Effect, n int) {
+	for _, ef := range effs {
+		if effectDue(ef, n) {
+			applyEffects(st, []Effect{ef})
+		}
+	}
+}
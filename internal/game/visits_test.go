@@ -0,0 +1,170 @@
+package game
+
+import "testing"
+
+func TestApplyChoice_OnceChoice(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{"test": {Start: "camp", Nodes: map[string]*Node{
+		"camp": {Text: "Camp.", Choices: []Choice{
+			{Key: "rest", Text: "Rest", Next: "camp", Once: true, Effects: []Effect{{Op: OpAdd, Stat: StatHealth, Value: 1}}},
+			{Key: "walk", Text: "Walk", Next: "camp"},
+		}},
+	}}}}
+	player := NewPlayer("test", "camp")
+	player.Stats.Health = 5
+
+	mustApply(t, engine, &player, "rest")
+	if player.Stats.Health != 6 {
+		t.Fatalf("Expected rest to heal once, got %d", player.Stats.Health)
+	}
+	if result := mustApply(t, engine, &player, "rest"); result.ErrorMessage == "" {
+		t.Error("Expected a once-only choice to be refused the second time")
+	}
+	node, _ := engine.CurrentNode(&player)
+	for _, ch := range engine.AvailableChoices(&player, node) {
+		if ch.Key == "rest" {
+			t.Error("Expected a taken once-only choice to be hidden")
+		}
+	}
+
+	player.BeginRun("test", "camp")
+	if got := engine.AvailableChoices(&player, node); len(got) != 2 {
+		t.Errorf("Expected rest available again in a new run, got %+v", got)
+	}
+}
+
+func TestApplyChoice_EffectModes(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{"test": {Start: "camp", Nodes: map[string]*Node{
+		"camp": {
+			Text: "Camp.",
+			Effects: []Effect{
+				{Op: OpAdd, Stat: StatLuck, Value: 1, Mode: EffectFirstVisit},
+				{Op: OpAdd, Stat: StatStrength, Value: 1, Mode: EffectEveryNth, Every: 2},
+			},
+			Choices: []Choice{
+				{Key: "forage", Text: "Forage", Next: "camp", Effects: []Effect{{Op: OpAdd, Stat: StatHealth, Value: 1, Mode: EffectEveryNth, Every: 3}}},
+				{Key: "walk", Text: "Walk", Next: "path"},
+			},
+		},
+		"path": {Text: "Path.", Choices: []Choice{{Key: "back", Text: "Back", Next: "camp"}}},
+	}}}}
+	player := NewPlayer("test", "camp")
+	player.Stats = Stats{Strength: 5, Luck: 5, Health: 5}
+
+	// Choice effects count times taken: the forage bonus lands on the 3rd.
+	for i := 0; i < 3; i++ {
+		mustApply(t, engine, &player, "forage")
+	}
+	if player.Stats.Health != 6 {
+		t.Errorf("Expected one every-3rd heal after 3 forages, got health %d", player.Stats.Health)
+	}
+
+	// Node effects count visits; NewPlayer counts the start as visit 1.
+	mustApply(t, engine, &player, "walk")
+	mustApply(t, engine, &player, "back") // camp visit 2
+	mustApply(t, engine, &player, "walk")
+	mustApply(t, engine, &player, "back") // camp visit 3
+	if player.Visits["camp"] != 3 || player.Visits["path"] != 2 {
+		t.Errorf("Unexpected visit counts %v", player.Visits)
+	}
+	if player.Stats.Luck != 5 {
+		t.Errorf("Expected first_visit luck never reapplied, got %d", player.Stats.Luck)
+	}
+	if player.Stats.Strength != 6 {
+		t.Errorf("Expected every-2nd strength once (visit 2), got %d", player.Stats.Strength)
+	}
+}
+
+func TestConditionMet_Visits(t *testing.T) {
+	engine := &Engine{Stories: map[string]*Story{"test": {Start: "camp", Nodes: map[string]*Node{
+		"camp": {Text: "Camp.", Choices: []Choice{
+			{Key: "walk", Text: "Walk", Next: "path"},
+			{Key: "veteran", Text: "Veteran", Next: "path", If: &Condition{MinVisits: map[string]int{"camp": 3}}},
+			{Key: "fresh", Text: "Fresh", Next: "path", If: &Condition{MaxVisits: map[string]int{"path": 0}}},
+		}},
+		"path": {Text: "Path.", Choices: []Choice{{Key: "back", Text: "Back", Next: "camp"}}},
+	}}}}
+	player := NewPlayer("test", "camp")
+
+	if result := mustApply(t, engine, &player, "veteran"); result.ErrorMessage == "" {
+		t.Error("Expected veteran refused before 3 camp visits")
+	}
+	mustApply(t, engine, &player, "fresh")
+	mustApply(t, engine, &player, "back")
+	if result := mustApply(t, engine, &player, "fresh"); result.ErrorMessage == "" {
+		t.Error("Expected fresh refused once the path was visited")
+	}
+	mustApply(t, engine, &player, "walk")
+	mustApply(t, engine, &player, "back")
+	mustApply(t, engine, &player, "veteran")
+	if player.NodeID != "path" {
+		t.Errorf("Expected veteran allowed after 3 camp visits, at %q", player.NodeID)
+	}
+}
+
+func TestEffectDue(t *testing.T) {
+	tests := []struct {
+		ef   Effect
+		n    int
+		want bool
+	}{
+		{Effect{}, 0, true},
+		{Effect{Mode: EffectAlways}, 5, true},
+		{Effect{Mode: EffectFirstVisit}, 1, true},
+		{Effect{Mode: EffectFirstVisit}, 2, false},
+		{Effect{Mode: EffectFirstVisit}, 0, false},
+		{Effect{Mode: EffectEveryNth, Every: 3}, 3, true},
+		{Effect{Mode: EffectEveryNth, Every: 3}, 4, false},
+		{Effect{Mode: EffectEveryNth}, 4, true},
+		{Effect{Mode: EffectEveryNth, Every: 2}, 0, false},
+	}
+	for _, tt := range tests {
+		if got := effectDue(tt.ef, tt.n); got != tt.want {
+			t.Errorf("effectDue(%+v, %d) = %v, want %v", tt.ef, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestVisitKey_Library(t *testing.T) {
+	player := NewPlayer("lib", "ring")
+	player.CallStack = []CallFrame{{StoryID: "main"}}
+	if got := visitKey(&player, "ring"); got != "lib/ring" {
+		t.Errorf("Expected library node key prefixed, got %q", got)
+	}
+	player.CallStack = nil
+	if got := visitKey(&player, "ring"); got != "ring" {
+		t.Errorf("Expected root node key unprefixed, got %q", got)
+	}
+}
+
+func TestLoadStory_VisitSemantics(t *testing.T) {
+	storyYAML := `start: "a"
+nodes:
+  a:
+    text: "A"
+    effects:
+      - op: "add"
+        stat: "luck"
+        value: 1
+        mode: "every_nth"
+        every: 2
+    choices:
+      - key: "once"
+        text: "Once"
+        next: "a"
+        once: true
+        if:
+          minVisits:
+            a: 1
+          maxVisits:
+            b: 0
+`
+	s := loadStoryYAML(t, storyYAML)
+	n := s.Nodes["a"]
+	if n.Effects[0].Mode != EffectEveryNth || n.Effects[0].Every != 2 {
+		t.Errorf("Unexpected effect: %+v", n.Effects[0])
+	}
+	ch := n.Choices[0]
+	if maxB, ok := ch.If.MaxVisits["b"]; !ch.Once || ch.If.MinVisits["a"] != 1 || !ok || maxB != 0 {
+		t.Errorf("Unexpected choice: %+v", ch)
+	}
+}
@@ -0,0 +1,182 @@
+// Package leaderboard keeps the best scored runs of each story, all-time
+// and for the current week.
+package leaderboard
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"adventure/internal/game"
+	"adventure/internal/session"
+)
+
+// DefaultSize is how many entries a board keeps when Service.Size is unset.
+const DefaultSize = 100
+
+// Board periods.
+const (
+	AllTime = "all"
+	Weekly  = "week"
+)
+
+// ErrUnknownPeriod is returned for a period other than AllTime or Weekly.
+var ErrUnknownPeriod = errors.New("unknown leaderboard period")
+
+// Entry is a scored run on a board. A character holds at most one entry
+// per board, its best.
+type Entry struct {
+	Character  string // CharacterKey of the character's session
+	Name       string
+	Avatar     string
+	Difficulty string
+	Score      game.Score
+	At         time.Time
+}
+
+// CharacterKey returns the Character of a session's entries: a hash of the
+// session ID, which lets anyone holding it play as the character and so is
+// never stored on a board.
+func CharacterKey(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:])
+}
+
+// Board is a story's best entries for one period, best first.
+type Board struct {
+	Entries []Entry
+}
+
+// Ranks is where a submitted run placed, 1-based; 0 means it did not make
+// the board.
+type Ranks struct {
+	AllTime int `json:"allTime"`
+	Weekly  int `json:"weekly"`
+}
+
+// Service records scored runs and reads the boards.
+type Service struct {
+	// Store holds boards by story and period. Boards never expire, so use a
+	// store without a TTL.
+	Store session.Store[Board]
+	// Size is how many entries each board keeps; 0 means DefaultSize.
+	Size int
+
+	now func() time.Time // for tests; nil means time.Now
+}
+
+// Submit records e on the story's all-time and weekly boards and returns
+// where it placed on each.
+func (s *Service) Submit(ctx context.Context, storyID string, e Entry) (Ranks, error) {
+	if e.At.IsZero() {
+		e.At = s.clock()
+	}
+	var r Ranks
+	var err error
+	if r.AllTime, err = s.submit(ctx, key(storyID, AllTime, e.At), e); err != nil {
+		return Ranks{}, err
+	}
+	if r.Weekly, err = s.submit(ctx, key(storyID, Weekly, e.At), e); err != nil {
+		return Ranks{}, err
+	}
+	return r, nil
+}
+
+// submit adds e to the board at key, retrying on concurrent updates.
+func (s *Service) submit(ctx context.Context, key string, e Entry) (int, error) {
+	for {
+		b, rev, _, err := s.Store.GetRev(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		rank, changed := b.add(e, s.size())
+		if !changed {
+			return rank, nil
+		}
+		if _, err := s.Store.CompareAndPut(ctx, key, b, rev); !errors.Is(err, session.ErrConflict) {
+			return rank, err
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Top returns up to n of the best entries on the story's board for period,
+// which is AllTime or Weekly (this week).
+func (s *Service) Top(ctx context.Context, storyID, period string, n int) ([]Entry, error) {
+	if period != AllTime && period != Weekly {
+		return nil, ErrUnknownPeriod
+	}
+	b, _, err := s.Store.Get(ctx, key(storyID, period, s.clock()))
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 && len(b.Entries) > n {
+		b.Entries = b.Entries[:n]
+	}
+	return b.Entries, nil
+}
+
+// add places e on the board, replacing the character's earlier entry if e
+// beats it, and keeps at most size entries. It returns e's rank (0 if it is
+// not on the board) and whether the board changed.
+func (b *Board) add(e Entry, size int) (int, bool) {
+	if i := slices.IndexFunc(b.Entries, func(o Entry) bool { return o.Character == e.Character }); i >= 0 {
+		if !better(e, b.Entries[i]) {
+			return 0, false
+		}
+		b.Entries = slices.Delete(b.Entries, i, i+1)
+	}
+	i, _ := slices.BinarySearchFunc(b.Entries, e, func(o, e Entry) int {
+		if better(o, e) {
+			return -1
+		}
+		return 1
+	})
+	if i >= size {
+		return 0, false
+	}
+	b.Entries = slices.Insert(b.Entries, i, e)
+	if len(b.Entries) > size {
+		b.Entries = b.Entries[:size]
+	}
+	return i + 1, true
+}
+
+// better reports whether a ranks above b: a higher total, or the same total
+// reached earlier.
+func better(a, b Entry) bool {
+	if a.Score.Total != b.Score.Total {
+		return a.Score.Total > b.Score.Total
+	}
+	return a.At.Before(b.At)
+}
+
+func (s *Service) size() int {
+	if s.Size <= 0 {
+		return DefaultSize
+	}
+	return s.Size
+}
+
+func (s *Service) clock() time.Time {
+	if s.now == nil {
+		return time.Now()
+	}
+	return s.now()
+}
+
+// key is the store key of the story's board for period at t; weekly boards
+// are keyed by ISO week, so a new week starts an empty board.
+func key(storyID, period string, t time.Time) string {
+	if period == Weekly {
+		y, w := t.UTC().ISOWeek()
+		return fmt.Sprintf("%s/%d-W%02d", storyID, y, w)
+	}
+	return storyID + "/" + AllTime
+}
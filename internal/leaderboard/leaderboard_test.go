@@ -0,0 +1,100 @@
+package leaderboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"adventure/internal/game"
+	"adventure/internal/session"
+)
+
+func testService(now *time.Time) *Service {
+	return &Service{
+		Store: session.NewMemoryStore[Board](),
+		Size:  3,
+		now:   func() time.Time { return *now },
+	}
+}
+
+func entry(character string, total int) Entry {
+	return Entry{Character: character, Name: character, Score: game.Score{Total: total}}
+}
+
+func names(es []Entry) []string {
+	out := make([]string, len(es))
+	for i, e := range es {
+		out[i] = e.Name
+	}
+	return out
+}
+
+func TestService_SubmitAndTop(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	s := testService(&now)
+
+	for _, e := range []Entry{entry("ann", 50), entry("bob", 80), entry("cat", 50)} {
+		if _, err := s.Submit(ctx, "demo", e); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+		now = now.Add(time.Minute)
+	}
+	// A worse run by the same character does not replace its best.
+	if r, _ := s.Submit(ctx, "demo", entry("bob", 10)); r != (Ranks{}) {
+		t.Errorf("Expected no rank for a worse run, got %+v", r)
+	}
+	// A better one moves it up; ties go to whoever got there first.
+	if r, _ := s.Submit(ctx, "demo", entry("cat", 90)); r != (Ranks{AllTime: 1, Weekly: 1}) {
+		t.Errorf("Expected cat to top both boards, got %+v", r)
+	}
+	// The board is full and 5 does not make it.
+	if r, _ := s.Submit(ctx, "demo", entry("dan", 5)); r.AllTime != 0 {
+		t.Errorf("Expected dan to miss the board, got %+v", r)
+	}
+	got, err := s.Top(ctx, "demo", AllTime, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(names(got)) != "[cat bob ann]" {
+		t.Errorf("Expected [cat bob ann], got %v", names(got))
+	}
+	if got, _ := s.Top(ctx, "demo", Weekly, 2); len(got) != 2 {
+		t.Errorf("Expected 2 entries, got %d", len(got))
+	}
+
+	// Next week starts a new weekly board; all-time carries on.
+	now = now.Add(7 * 24 * time.Hour)
+	if r, _ := s.Submit(ctx, "demo", entry("dan", 5)); r != (Ranks{AllTime: 0, Weekly: 1}) {
+		t.Errorf("Expected dan to top the new week only, got %+v", r)
+	}
+	if got, _ := s.Top(ctx, "other", AllTime, 10); len(got) != 0 {
+		t.Errorf("Expected an empty board for another story, got %v", got)
+	}
+	if _, err := s.Top(ctx, "demo", "month", 10); !errors.Is(err, ErrUnknownPeriod) {
+		t.Errorf("Expected ErrUnknownPeriod, got %v", err)
+	}
+}
+
+func TestService_ConcurrentSubmits(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	s := testService(&now)
+	s.Size = 0
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = s.Submit(ctx, "demo", entry(fmt.Sprint("c", i), i))
+		}()
+	}
+	wg.Wait()
+	got, _ := s.Top(ctx, "demo", AllTime, 0)
+	if len(got) != 20 || got[0].Score.Total != 19 || got[19].Score.Total != 0 {
+		t.Errorf("Expected all 20 entries in order, got %d", len(got))
+	}
+}
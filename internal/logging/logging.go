@@ -0,0 +1,139 @@
+// Package logging sets up the server's structured logger and carries
+// request-scoped fields, such as the request ID and the story and node being
+// played, to every line logged with a request's context.
+package logging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Formats for New.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New returns a logger writing to w at level in format, FormatText or
+// FormatJSON. Lines logged with a context carry the context's fields.
+func New(w io.Writer, level slog.Level, format string) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch format {
+	case FormatText, "":
+		h = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want text or json)", format)
+	}
+	return slog.New(NewHandler(h)), nil
+}
+
+// ParseLevel parses a level name: debug, info, warn or error.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+	}
+	return l, nil
+}
+
+// fields are the attributes added to a context's log lines. Handlers add to
+// them as they learn more, such as which session a request is for, so they
+// are shared rather than copied into each derived context.
+type fields struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+type fieldsKey struct{}
+
+// NewContext returns ctx with an empty set of log fields, replacing any it
+// had.
+func NewContext(ctx context.Context, attrs ...slog.Attr) context.Context {
+	return context.WithValue(ctx, fieldsKey{}, &fields{attrs: attrs})
+}
+
+// Add sets fields on ctx's log lines, replacing fields with the same keys.
+// It is a no-op on a context without fields.
+func Add(ctx context.Context, attrs ...slog.Attr) {
+	f, _ := ctx.Value(fieldsKey{}).(*fields)
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+next:
+	for _, a := range attrs {
+		for i := range f.attrs {
+			if f.attrs[i].Key == a.Key {
+				f.attrs[i] = a
+				continue next
+			}
+		}
+		f.attrs = append(f.attrs, a)
+	}
+}
+
+// Fields returns a copy of ctx's log fields.
+func Fields(ctx context.Context) []slog.Attr {
+	f, _ := ctx.Value(fieldsKey{}).(*fields)
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]slog.Attr(nil), f.attrs...)
+}
+
+// HashID shortens a secret ID, such as a session ID, to a name that can be
+// logged: the same ID always logs the same, but the log can't be used to
+// take over the session.
+func HashID(id string) string {
+	if id == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Handler adds a context's fields to each record.
+type Handler struct {
+	next slog.Handler
+}
+
+// NewHandler wraps next to add context fields.
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{next: next}
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := Fields(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{next: h.next.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew_JSONWithContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := New(&buf, slog.LevelInfo, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewContext(context.Background(), slog.String("request_id", "r1"))
+	Add(ctx, slog.String("story", "demo"), slog.String("node", "start"))
+	Add(ctx, slog.String("node", "cave"))
+	log.InfoContext(ctx, "step", "choice", "left")
+	log.Debug("hidden")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Expected one JSON line, got %q: %v", buf.String(), err)
+	}
+	for k, want := range map[string]string{"msg": "step", "request_id": "r1", "story": "demo", "node": "cave", "choice": "left"} {
+		if line[k] != want {
+			t.Errorf("Expected %s=%q, got %v", k, want, line[k])
+		}
+	}
+}
+
+func TestNew_Text(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := New(&buf, slog.LevelDebug, FormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.With("a", 1).Debug("hello")
+	if got := buf.String(); !strings.Contains(got, "msg=hello") || !strings.Contains(got, "a=1") {
+		t.Errorf("Unexpected line %q", got)
+	}
+	if _, err := New(&buf, slog.LevelInfo, "xml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{"debug": slog.LevelDebug, "INFO": slog.LevelInfo, "warn": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := ParseLevel(in); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v", in, got, err)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+}
+
+func TestHashID(t *testing.T) {
+	h := HashID("0123456789abcdef")
+	if len(h) != 12 || h != HashID("0123456789abcdef") {
+		t.Errorf("Unexpected hash %q", h)
+	}
+	if HashID("") != "" {
+		t.Error("Expected no hash for no ID")
+	}
+}
+
+func TestAddWithoutFieldsIsNoOp(t *testing.T) {
+	Add(context.Background(), slog.String("a", "b"))
+	if Fields(context.Background()) != nil {
+		t.Error("Expected no fields")
+	}
+}
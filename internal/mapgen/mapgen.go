@@ -4,9 +4,9 @@ package mapgen
 
 import (
 	"bytes"
+	"io/fs"
 	"math"
-	"os"
-	"path/filepath"
+	"path"
 	"strconv"
 	"strings"
 
@@ -32,10 +32,10 @@ var sceneryExtensions = []string{".png", ".jpg", ".jpeg"}
 // Generate returns PDF bytes for a treasure map: visited nodes as illustrated
 // scenes (beach, forest, bridge, battle, etc.) along a path. If visitedNodes
 // is nil or empty, currentID is used as the only stop.
-// If storyID and storiesDir are non-empty, scenery images are loaded from
-// storiesDir/storyID/scenery/<filename> and embedded when available; otherwise
+// If storyID is non-empty and stories is non-nil, scenery images are loaded
+// from storyID/scenery/<filename> in stories and embedded when available; otherwise
 // vector icons are drawn (drawScene).
-func Generate(st *game.Story, visitedNodes []string, currentID, title, storyID, storiesDir string) ([]byte, error) {
+func Generate(st *game.Story, visitedNodes []string, currentID, title, storyID string, stories fs.FS) ([]byte, error) {
 	if st == nil || st.Nodes == nil {
 		return nil, nil
 	}
@@ -129,7 +129,7 @@ func Generate(st *game.Story, visitedNodes []string, currentID, title, storyID,
 	for i := range stops {
 		x, y := positions[i][0], positions[i][1]
 		isCurrent := stops[i].id == currentID
-		imgData, imgType := tryLoadSceneImage(storiesDir, storyID, stops[i].scenery)
+		imgData, imgType := tryLoadSceneImage(stories, storyID, stops[i].scenery)
 		if imgData != nil && imgType != "" {
 			drawSceneImage(pdf, x, y, imgData, imgType, stops[i].isBattle, isCurrent, i, stops[i].id)
 		} else {
@@ -161,22 +161,18 @@ func Generate(st *game.Story, visitedNodes []string, currentID, title, storyID,
 	return buf.Bytes(), nil
 }
 
-// tryLoadSceneImage loads scenery image from storiesDir/storyID/scenery/<filename>.
-// Returns (data, imageType) or (nil, "") if storiesDir/storyID empty or file not found.
+// tryLoadSceneImage loads scenery image storyID/scenery/<filename> from stories.
+// Returns (data, imageType) or (nil, "") if stories is nil, storyID empty or file not found.
 // Path is validated (no traversal); filename is scenery value with optional extension.
-func tryLoadSceneImage(storiesDir, storyID, scenery string) (data []byte, imgType string) {
-	if storiesDir == "" || storyID == "" || scenery == "" {
+func tryLoadSceneImage(stories fs.FS, storyID, scenery string) (data []byte, imgType string) {
+	if stories == nil || storyID == "" || scenery == "" {
 		return nil, ""
 	}
-	safeFilename := filepath.Clean(scenery)
-	if safeFilename == "" || safeFilename == "." || strings.Contains(safeFilename, "..") ||
-		filepath.IsAbs(safeFilename) || strings.Contains(safeFilename, string(filepath.Separator)) {
+	if strings.ContainsAny(scenery, `/\`) || !fs.ValidPath(storyID) {
 		return nil, ""
 	}
-	baseDir := filepath.Join(storiesDir, storyID, "scenery")
-	resolved := filepath.Join(baseDir, safeFilename)
-	rel, err := filepath.Rel(baseDir, resolved)
-	if err != nil || strings.Contains(rel, "..") {
+	resolved := path.Join(storyID, "scenery", scenery)
+	if !fs.ValidPath(resolved) || path.Dir(resolved) != path.Join(storyID, "scenery") {
 		return nil, ""
 	}
 	candidates := []string{resolved}
@@ -184,7 +180,7 @@ func tryLoadSceneImage(storiesDir, storyID, scenery string) (data []byte, imgTyp
 		candidates = append(candidates, resolved+ext)
 	}
 	for _, p := range candidates {
-		data, err := os.ReadFile(p) // #nosec G304 -- p is under validated baseDir
+		data, err := fs.ReadFile(stories, p)
 		if err != nil {
 			continue
 		}
@@ -8,12 +8,13 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"testing/fstest"
 
 	"adventure/internal/game"
 )
 
 func TestGenerate_NilStory(t *testing.T) {
-	b, err := Generate(nil, []string{"a"}, "a", "Test", "", "")
+	b, err := Generate(nil, []string{"a"}, "a", "Test", "", nil)
 	if err != nil {
 		t.Fatalf("Generate: %v", err)
 	}
@@ -29,7 +30,7 @@ func TestGenerate_EmptyVisitedUsesCurrent(t *testing.T) {
 			"a": {Text: "Start", Scenery: "forest"},
 		},
 	}
-	b, err := Generate(st, nil, "a", "Test", "", "")
+	b, err := Generate(st, nil, "a", "Test", "", nil)
 	if err != nil {
 		t.Fatalf("Generate: %v", err)
 	}
@@ -52,7 +53,7 @@ func TestGenerate_PathReturnsPDF(t *testing.T) {
 		},
 	}
 	visited := []string{"a", "b", "c"}
-	b, err := Generate(st, visited, "b", "Test Adventure", "", "")
+	b, err := Generate(st, visited, "b", "Test Adventure", "", nil)
 	if err != nil {
 		t.Fatalf("Generate: %v", err)
 	}
@@ -89,7 +90,7 @@ func TestGenerate_WithSceneryImage_EmbedsImage(t *testing.T) {
 			"a": {Text: "Start", Scenery: "forest"},
 		},
 	}
-	b, err := Generate(st, []string{"a"}, "a", "Test", storyID, tmpDir)
+	b, err := Generate(st, []string{"a"}, "a", "Test", storyID, os.DirFS(tmpDir))
 	if err != nil {
 		t.Fatalf("Generate: %v", err)
 	}
@@ -112,3 +113,25 @@ func bytesPrefix(b, prefix []byte) bool {
 	}
 	return true
 }
+
+func TestTryLoadSceneImage(t *testing.T) {
+	stories := fstest.MapFS{
+		"s/scenery/forest.png": {Data: []byte("\x89PNG....")},
+		"s/scenery/cave.jpg":   {Data: []byte("\xff\xd8\xff\xe0")},
+		"s/secret.png":         {Data: []byte("\x89PNG....")},
+	}
+	if _, typ := tryLoadSceneImage(stories, "s", "forest"); typ != "png" {
+		t.Errorf("Expected forest as png, got %q", typ)
+	}
+	if _, typ := tryLoadSceneImage(stories, "s", "cave"); typ != "jpeg" {
+		t.Errorf("Expected cave as jpeg, got %q", typ)
+	}
+	for _, scenery := range []string{"../secret", "..", "a/../../secret", "missing"} {
+		if data, _ := tryLoadSceneImage(stories, "s", scenery); data != nil {
+			t.Errorf("Expected no image for %q", scenery)
+		}
+	}
+	if data, _ := tryLoadSceneImage(nil, "s", "forest"); data != nil {
+		t.Error("Expected no image without stories")
+	}
+}
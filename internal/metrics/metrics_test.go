@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("steps_total", "Steps taken.", "story")
+	c.Inc("demo")
+	c.Add(2, "demo")
+	c.Inc(`a"b\c`)
+	h := r.Histogram("latency_seconds", "Request latency.", []float64{0.1, 1}, "route")
+	h.Observe(0.05, "/play")
+	h.Observe(0.5, "/play")
+	h.Observe(3, "/play")
+	r.GaugeFunc("sessions", "Live sessions.\nPer store.", func() float64 { return 7 })
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP steps_total Steps taken.
+# TYPE steps_total counter
+steps_total{story="a\"b\\c"} 1
+steps_total{story="demo"} 3
+# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/play",le="0.1"} 1
+latency_seconds_bucket{route="/play",le="1"} 2
+latency_seconds_bucket{route="/play",le="+Inf"} 3
+latency_seconds_sum{route="/play"} 3.55
+latency_seconds_count{route="/play"} 3
+# HELP sessions Live sessions.\nPer store.
+# TYPE sessions gauge
+sessions 7
+`
+	if got := b.String(); got != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+	if v := c.Value("demo"); v != 3 {
+		t.Errorf("Expected 3, got %v", v)
+	}
+}
+
+func TestNilMetricsAreNoOps(t *testing.T) {
+	var c *Counter
+	var h *Histogram
+	c.Inc("x")
+	h.Observe(1, "x")
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("x", "")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic")
+		}
+	}()
+	r.Counter("x", "")
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("x_total", "X.").Inc()
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "x_total 1\n") {
+		t.Errorf("Unexpected body %q", rec.Body.String())
+	}
+}
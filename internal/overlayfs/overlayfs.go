@@ -0,0 +1,76 @@
+// Package overlayfs layers one fs.FS over another, so files on disk can
+// override the defaults embedded in the binary.
+package overlayfs
+
+import (
+	"errors"
+	"io/fs"
+	"sort"
+)
+
+// FS reads each file from Upper when it has it, and from Lower otherwise.
+// Directory listings merge both, Upper winning where a name is in both.
+type FS struct {
+	Upper, Lower fs.FS
+}
+
+// New returns upper layered over lower.
+func New(upper, lower fs.FS) *FS {
+	return &FS{Upper: upper, Lower: lower}
+}
+
+// Open opens name from the upper layer, or the lower one when the upper
+// doesn't have it. A directory opened this way lists only its own layer;
+// use fs.ReadDir for the merged listing.
+func (o *FS) Open(name string) (fs.File, error) {
+	f, err := o.Upper.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.Lower.Open(name)
+	}
+	return f, err
+}
+
+// Stat describes name as Open would find it.
+func (o *FS) Stat(name string) (fs.FileInfo, error) {
+	info, err := fs.Stat(o.Upper, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fs.Stat(o.Lower, name)
+	}
+	return info, err
+}
+
+// ReadFile reads name as Open would find it.
+func (o *FS) ReadFile(name string) ([]byte, error) {
+	b, err := fs.ReadFile(o.Upper, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fs.ReadFile(o.Lower, name)
+	}
+	return b, err
+}
+
+// ReadDir lists the directory name in both layers, sorted by name.
+func (o *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	upper, uerr := fs.ReadDir(o.Upper, name)
+	if uerr != nil && !errors.Is(uerr, fs.ErrNotExist) {
+		return nil, uerr
+	}
+	lower, lerr := fs.ReadDir(o.Lower, name)
+	if lerr != nil && !errors.Is(lerr, fs.ErrNotExist) {
+		return nil, lerr
+	}
+	if uerr != nil && lerr != nil {
+		return nil, uerr
+	}
+	seen := make(map[string]bool, len(upper))
+	entries := append([]fs.DirEntry(nil), upper...)
+	for _, e := range upper {
+		seen[e.Name()] = true
+	}
+	for _, e := range lower {
+		if !seen[e.Name()] {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
@@ -0,0 +1,51 @@
+package overlayfs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	lower := fstest.MapFS{
+		"a.yaml":          {Data: []byte("lower a")},
+		"b.yaml":          {Data: []byte("lower b")},
+		"b/scenery/x.png": {Data: []byte("x")},
+	}
+	upper := fstest.MapFS{
+		"b.yaml": {Data: []byte("upper b")},
+		"c.yaml": {Data: []byte("upper c")},
+	}
+	o := New(upper, lower)
+
+	for name, want := range map[string]string{"a.yaml": "lower a", "b.yaml": "upper b", "c.yaml": "upper c", "b/scenery/x.png": "x"} {
+		b, err := fs.ReadFile(o, name)
+		if err != nil || string(b) != want {
+			t.Errorf("ReadFile(%s) = %q, %v; want %q", name, b, err, want)
+		}
+	}
+	if _, err := fs.ReadFile(o, "d.yaml"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist for a file in neither layer, got %v", err)
+	}
+
+	entries, err := fs.ReadDir(o, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got, want := len(names), 4; got != want || names[0] != "a.yaml" || names[3] != "c.yaml" {
+		t.Errorf("ReadDir = %v; want a.yaml, b, b.yaml, c.yaml", names)
+	}
+	if _, err := fs.ReadDir(o, "nope"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist for a directory in neither layer, got %v", err)
+	}
+
+	matches, err := fs.Glob(o, "*.yaml")
+	if err != nil || len(matches) != 3 {
+		t.Errorf("Glob = %v, %v; want 3 matches", matches, err)
+	}
+}
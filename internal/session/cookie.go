@@ -0,0 +1,415 @@
+package session
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCookieName is the cookie a CookieStore keeps sessions in.
+	DefaultCookieName = "adventure_state"
+	// DefaultCookieMaxSize is the largest encoded session a CookieStore puts
+	// in a cookie; browsers drop cookies over about 4KB, name and attributes
+	// included.
+	DefaultCookieMaxSize = 3800
+	// MinCookieKeyLen is the shortest secret NewCookieStore accepts.
+	MinCookieKeyLen = 32
+
+	cookieVersion  = 1
+	cookieMaxAge   = 400 * 24 * time.Hour // the longest browsers keep a cookie
+	cookieMaxPlain = 1 << 20              // cap on a decompressed session
+)
+
+// CookieStore is a Store that keeps each session in an encrypted, signed and
+// compressed cookie on the player's browser, so the server holds nothing.
+// It needs the request's cookies, which handlers pass it in the context with
+// WithCookies; used without them, as the JSON API does, it is Fallback.
+//
+// Sessions whose cookie would exceed MaxSize are kept in Fallback instead,
+// with the cookie only naming them. Either way the cookie carries the
+// session's revision and expiry, and a cookie that is tampered with, sealed
+// with an unknown key or expired is treated as a missing session.
+//
+// A browser can run two requests at once with the same cookie, so revisions
+// only catch stale pages, not concurrent writes; the last response wins.
+type CookieStore[T any] struct {
+	// Name is the session cookie's name; empty means DefaultCookieName.
+	Name string
+	// TTL is the expiry used by Put; 0 keeps sessions as long as browsers do.
+	TTL time.Duration
+	// MaxSize caps the encoded cookie; 0 means DefaultCookieMaxSize.
+	MaxSize int
+	// Fallback holds sessions too large for a cookie, and every session used
+	// without WithCookies.
+	Fallback Store[T]
+	// NewCookie makes the session cookie, so it can share the server's
+	// Secure, Domain and SameSite settings; maxAge is as in http.Cookie. Nil
+	// means an HTTP-only, host-only, SameSite=Lax cookie, Secure over TLS.
+	NewCookie func(r *http.Request, name, value string, maxAge int) *http.Cookie
+
+	keys []cookieKey
+	now  func() time.Time
+}
+
+// cookieKey is the pair of keys derived from one secret.
+type cookieKey struct {
+	enc []byte // AES-256
+	mac []byte // HMAC-SHA256
+}
+
+// cookiePayload is what a session cookie holds, before compression and
+// encryption. TTL and Exp are in milliseconds, as in the file store's log.
+type cookiePayload[T any] struct {
+	ID     string `json:"id"`
+	Rev    int64  `json:"rev"`
+	TTL    int64  `json:"ttl,omitempty"`
+	Exp    int64  `json:"exp,omitempty"` // unix; 0: never
+	Server bool   `json:"srv,omitempty"` // the value is in Fallback
+	V      *T     `json:"v,omitempty"`
+}
+
+// NewCookieStore creates a cookie store that seals cookies with the first of
+// keys and opens them with any of them, so a new key can be put first while
+// cookies sealed with the old ones are still accepted.
+func NewCookieStore[T any](keys [][]byte, fallback Store[T]) (*CookieStore[T], error) {
+	if len(keys) == 0 {
+		return nil, errors.New("session: cookie store needs at least one key")
+	}
+	s := &CookieStore[T]{Fallback: fallback, now: time.Now}
+	for i, k := range keys {
+		if len(k) < MinCookieKeyLen {
+			return nil, fmt.Errorf("session: cookie key %d is %d bytes, want at least %d", i+1, len(k), MinCookieKeyLen)
+		}
+		s.keys = append(s.keys, cookieKey{
+			enc: deriveKey(k, "adventure session cookie encryption"),
+			mac: deriveKey(k, "adventure session cookie signature"),
+		})
+	}
+	return s, nil
+}
+
+// deriveKey derives a 32-byte key for one purpose from secret.
+func deriveKey(secret []byte, purpose string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(purpose))
+	return h.Sum(nil)
+}
+
+// Get returns the session in the request's cookie, resetting its expiry.
+func (s *CookieStore[T]) Get(ctx context.Context, id string) (value T, ok bool, err error) {
+	value, _, ok, err = s.GetRev(ctx, id)
+	return value, ok, err
+}
+
+// GetRev returns the session in the request's cookie and its revision,
+// resetting its expiry.
+func (s *CookieStore[T]) GetRev(ctx context.Context, id string) (value T, rev int64, ok bool, err error) {
+	j := jarFrom(ctx)
+	if j == nil {
+		return s.Fallback.GetRev(ctx, id)
+	}
+	p, ok := s.read(j, id)
+	if !ok {
+		return value, 0, false, nil
+	}
+	if p.Server {
+		value, ok, err = s.Fallback.Get(ctx, id)
+		if err != nil || !ok {
+			return value, 0, false, err
+		}
+	} else {
+		value = *p.V
+	}
+	if p.TTL > 0 {
+		// Reseal with the expiry pushed back. Once the response has started
+		// the header is ignored, which only costs the extension.
+		ttl := time.Duration(p.TTL) * time.Millisecond
+		p.Exp = s.now().Add(ttl).UnixMilli()
+		if sealed, err := s.seal(&p); err == nil {
+			j.set(s.cookie(j.r, sealed, ttl))
+		}
+	}
+	return value, p.Rev, true, nil
+}
+
+// CompareAndPut stores a value if the session is still at revision rev.
+func (s *CookieStore[T]) CompareAndPut(ctx context.Context, id string, v T, rev int64) (int64, error) {
+	if jarFrom(ctx) == nil {
+		return s.Fallback.CompareAndPut(ctx, id, v, rev)
+	}
+	return s.put(ctx, id, v, s.TTL, &rev)
+}
+
+// Put stores a value with the store's default TTL.
+func (s *CookieStore[T]) Put(ctx context.Context, id string, v T) error {
+	return s.PutTTL(ctx, id, v, s.TTL)
+}
+
+// PutTTL stores a value that expires ttl after its last use.
+func (s *CookieStore[T]) PutTTL(ctx context.Context, id string, v T, ttl time.Duration) error {
+	if jarFrom(ctx) == nil {
+		return s.Fallback.PutTTL(ctx, id, v, ttl)
+	}
+	_, err := s.put(ctx, id, v, ttl, nil)
+	return err
+}
+
+// put seals v into the response's cookie, or into Fallback when it is too
+// large, and returns its new revision. With want set, it first checks the
+// cookie is still at that revision.
+func (s *CookieStore[T]) put(ctx context.Context, id string, v T, ttl time.Duration, want *int64) (int64, error) {
+	j := jarFrom(ctx)
+	prev, _ := s.read(j, id)
+	if want != nil && prev.Rev != *want {
+		return prev.Rev, ErrConflict
+	}
+	p := cookiePayload[T]{ID: id, Rev: prev.Rev + 1, TTL: ttl.Milliseconds(), V: &v}
+	if ttl > 0 {
+		p.Exp = s.now().Add(ttl).UnixMilli()
+	}
+	sealed, err := s.seal(&p)
+	if err != nil {
+		return 0, err
+	}
+	if len(sealed) > s.maxSize() {
+		if err := s.Fallback.PutTTL(ctx, id, v, ttl); err != nil {
+			return 0, err
+		}
+		p.Server, p.V = true, nil
+		if sealed, err = s.seal(&p); err != nil {
+			return 0, err
+		}
+	} else if prev.Server {
+		if err := s.Fallback.Delete(ctx, id); err != nil {
+			return 0, err
+		}
+	}
+	j.set(s.cookie(j.r, sealed, ttl))
+	return p.Rev, nil
+}
+
+// Delete removes the session, clearing the cookie if it holds it.
+func (s *CookieStore[T]) Delete(ctx context.Context, id string) error {
+	if j := jarFrom(ctx); j != nil {
+		if _, ok := s.read(j, id); ok {
+			c := s.cookie(j.r, "", 0)
+			c.MaxAge = -1
+			j.set(c)
+		}
+	}
+	return s.Fallback.Delete(ctx, id)
+}
+
+// List returns the sessions in Fallback; those in cookies cannot be listed.
+func (s *CookieStore[T]) List(ctx context.Context) ([]string, error) {
+	return s.Fallback.List(ctx)
+}
+
+// NewID generates a new unique session ID.
+func (s *CookieStore[T]) NewID() string { return newID() }
+
+// Ping pings Fallback; sessions in cookies need no store.
+func (s *CookieStore[T]) Ping(ctx context.Context) error { return s.Fallback.Ping(ctx) }
+
+// Close closes Fallback.
+func (s *CookieStore[T]) Close() error { return s.Fallback.Close() }
+
+func (s *CookieStore[T]) name() string {
+	if s.Name == "" {
+		return DefaultCookieName
+	}
+	return s.Name
+}
+
+func (s *CookieStore[T]) maxSize() int {
+	if s.MaxSize <= 0 {
+		return DefaultCookieMaxSize
+	}
+	return s.MaxSize
+}
+
+// cookie returns the session cookie carrying value.
+func (s *CookieStore[T]) cookie(r *http.Request, value string, ttl time.Duration) *http.Cookie {
+	if ttl <= 0 || ttl > cookieMaxAge {
+		ttl = cookieMaxAge
+	}
+	if s.NewCookie != nil {
+		return s.NewCookie(r, s.name(), value, int(ttl/time.Second))
+	}
+	return &http.Cookie{
+		Name:     s.name(),
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(ttl / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// read opens the request's session cookie, reporting false unless it is
+// genuine, unexpired and holds the session id.
+func (s *CookieStore[T]) read(j *cookieJar, id string) (cookiePayload[T], bool) {
+	value, ok := j.get(s.name())
+	if !ok {
+		return cookiePayload[T]{}, false
+	}
+	p, err := s.open(value)
+	if err != nil || p.ID != id || p.Exp != 0 && !s.now().Before(time.UnixMilli(p.Exp)) {
+		return cookiePayload[T]{}, false
+	}
+	if !p.Server && p.V == nil {
+		return cookiePayload[T]{}, false
+	}
+	return p, true
+}
+
+// seal compresses p, encrypts it with AES-CTR under a random IV and signs
+// the result with HMAC-SHA256: version | IV | ciphertext | MAC, in base64.
+func (s *CookieStore[T]) seal(p *cookiePayload[T]) (string, error) {
+	plain, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	var z bytes.Buffer
+	fw, _ := flate.NewWriter(&z, flate.BestCompression) //nolint:errcheck // Only fails for an invalid level
+	if _, err := fw.Write(plain); err != nil {
+		return "", err
+	}
+	if err := fw.Close(); err != nil {
+		return "", err
+	}
+
+	key := s.keys[0]
+	block, err := aes.NewCipher(key.enc)
+	if err != nil {
+		return "", err
+	}
+	msg := make([]byte, 1+aes.BlockSize+z.Len(), 1+aes.BlockSize+z.Len()+sha256.Size)
+	msg[0] = cookieVersion
+	iv := msg[1 : 1+aes.BlockSize]
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	cipher.NewCTR(block, iv).XORKeyStream(msg[1+aes.BlockSize:], z.Bytes())
+	msg = append(msg, s.sign(key, msg)...)
+	return base64.RawURLEncoding.EncodeToString(msg), nil
+}
+
+// open reverses seal, trying every key.
+func (s *CookieStore[T]) open(value string) (cookiePayload[T], error) {
+	var p cookiePayload[T]
+	msg, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return p, err
+	}
+	if len(msg) < 1+aes.BlockSize+sha256.Size || msg[0] != cookieVersion {
+		return p, errors.New("session: malformed cookie")
+	}
+	body, mac := msg[:len(msg)-sha256.Size], msg[len(msg)-sha256.Size:]
+	for _, key := range s.keys {
+		if !hmac.Equal(mac, s.sign(key, body)) {
+			continue
+		}
+		block, err := aes.NewCipher(key.enc)
+		if err != nil {
+			return p, err
+		}
+		z := make([]byte, len(body)-1-aes.BlockSize)
+		cipher.NewCTR(block, body[1:1+aes.BlockSize]).XORKeyStream(z, body[1+aes.BlockSize:])
+		plain, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(z)), cookieMaxPlain))
+		if err != nil {
+			return p, err
+		}
+		return p, json.Unmarshal(plain, &p)
+	}
+	return p, errors.New("session: cookie signature mismatch")
+}
+
+// sign returns the MAC of msg, bound to the cookie's name so a value cannot
+// be replayed under another cookie.
+func (s *CookieStore[T]) sign(key cookieKey, msg []byte) []byte {
+	h := hmac.New(sha256.New, key.mac)
+	h.Write([]byte(s.name()))
+	h.Write([]byte{0})
+	h.Write(msg)
+	return h.Sum(nil)
+}
+
+type cookieJarKey struct{}
+
+// cookieJar gives a CookieStore one request's cookies and the response to
+// set them on. Cookies set during the request replace the request's own, so
+// a Get after a Put sees the new session.
+type cookieJar struct {
+	w http.ResponseWriter
+	r *http.Request
+
+	mu      sync.Mutex
+	cookies map[string]*http.Cookie // set during the request, by name
+}
+
+// WithCookies returns a copy of ctx through which a CookieStore reads r's
+// cookies and sets cookies on w. Other stores ignore it.
+func WithCookies(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+	return context.WithValue(ctx, cookieJarKey{}, &cookieJar{w: w, r: r, cookies: map[string]*http.Cookie{}})
+}
+
+// WithoutCookies returns a copy of ctx through which a CookieStore sees no
+// request cookies, as for a request that reads another player's session.
+func WithoutCookies(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cookieJarKey{}, (*cookieJar)(nil))
+}
+
+func jarFrom(ctx context.Context) *cookieJar {
+	j, _ := ctx.Value(cookieJarKey{}).(*cookieJar)
+	return j
+}
+
+// get returns the cookie's value as the browser will next send it.
+func (j *cookieJar) get(name string) (string, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if c, ok := j.cookies[name]; ok {
+		return c.Value, c.MaxAge >= 0
+	}
+	c, err := j.r.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+	return c.Value, true
+}
+
+// set sets c on the response, replacing any earlier Set-Cookie for it.
+func (j *cookieJar) set(c *http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.cookies[c.Name] = c
+	h := j.w.Header()
+	kept := h.Values("Set-Cookie")[:0:0]
+	for _, v := range h.Values("Set-Cookie") {
+		if !strings.HasPrefix(v, c.Name+"=") {
+			kept = append(kept, v)
+		}
+	}
+	h.Del("Set-Cookie")
+	for _, v := range kept {
+		h.Add("Set-Cookie", v)
+	}
+	http.SetCookie(j.w, c)
+}
@@ -0,0 +1,279 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testKeyOld = []byte("0123456789abcdef0123456789abcdef-old")
+	testKeyNew = []byte("0123456789abcdef0123456789abcdef-new")
+)
+
+func newTestCookieStore(t *testing.T, keys ...[]byte) *CookieStore[testValue] {
+	t.Helper()
+	if len(keys) == 0 {
+		keys = [][]byte{testKeyNew}
+	}
+	s, err := NewCookieStore[testValue](keys, NewMemoryStore[testValue]())
+	if err != nil {
+		t.Fatalf("NewCookieStore: %v", err)
+	}
+	return s
+}
+
+// browser keeps the cookies set on its responses and sends them with its
+// next request, like a browser would.
+type browser struct {
+	cookies map[string]*http.Cookie
+}
+
+// do runs f with a context carrying the browser's cookies and returns the
+// response f's Set-Cookie headers went to.
+func (b *browser) do(f func(ctx context.Context)) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range b.cookies {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	f(WithCookies(context.Background(), w, r))
+	if b.cookies == nil {
+		b.cookies = map[string]*http.Cookie{}
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge < 0 {
+			delete(b.cookies, c.Name)
+		} else {
+			b.cookies[c.Name] = c
+		}
+	}
+	return w
+}
+
+func TestCookieStore_Suite(t *testing.T) {
+	// Without WithCookies, a cookie store is its fallback.
+	testStoreSuite(t, func(t *testing.T) Store[testValue] { return newTestCookieStore(t) })
+}
+
+func TestCookieStore_RoundTrip(t *testing.T) {
+	s := newTestCookieStore(t)
+	var b browser
+	want := testValue{Name: "Ann", Score: 3, Flags: map[string]bool{"key": true}}
+	b.do(func(ctx context.Context) {
+		if err := s.Put(ctx, "a", want); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		// A Get in the same request sees the Put.
+		if got, ok, _ := s.Get(ctx, "a"); !ok || got.Score != 3 {
+			t.Errorf("Get after Put = %+v, %v", got, ok)
+		}
+	})
+	c := b.cookies[DefaultCookieName]
+	if c == nil || !c.HttpOnly || strings.Contains(c.Value, "Ann") {
+		t.Fatalf("Expected an opaque HttpOnly cookie, got %+v", c)
+	}
+	b.do(func(ctx context.Context) {
+		got, rev, ok, err := s.GetRev(ctx, "a")
+		if err != nil || !ok || rev != 1 {
+			t.Fatalf("GetRev = %v, %v, %v", rev, ok, err)
+		}
+		if got.Name != want.Name || !got.Flags["key"] {
+			t.Errorf("GetRev = %+v, want %+v", got, want)
+		}
+		if _, ok, _ := s.Get(ctx, "b"); ok {
+			t.Error("Expected the cookie to hold only session a")
+		}
+	})
+	if ids, _ := s.List(context.Background()); len(ids) != 0 {
+		t.Errorf("Expected nothing kept on the server, got %v", ids)
+	}
+}
+
+func TestCookieStore_NewCookie(t *testing.T) {
+	s := newTestCookieStore(t)
+	s.TTL = time.Hour
+	s.NewCookie = func(r *http.Request, name, value string, maxAge int) *http.Cookie {
+		return &http.Cookie{Name: name, Value: value, Path: "/", Domain: "example.com", MaxAge: maxAge, Secure: true, SameSite: http.SameSiteStrictMode}
+	}
+	var b browser
+	w := b.do(func(ctx context.Context) { _ = s.Put(ctx, "a", testValue{}) })
+	c := w.Result().Cookies()[0]
+	if c.Name != DefaultCookieName || c.Domain != "example.com" || !c.Secure || c.SameSite != http.SameSiteStrictMode || c.MaxAge != 3600 {
+		t.Errorf("Expected the cookie from NewCookie, got %+v", c)
+	}
+	w = b.do(func(ctx context.Context) { _ = s.Delete(ctx, "a") })
+	if c := w.Result().Cookies()[0]; c.Domain != "example.com" || c.MaxAge >= 0 {
+		t.Errorf("Expected the deletion on the same domain, got %+v", c)
+	}
+}
+
+func TestCookieStore_Tampered(t *testing.T) {
+	s := newTestCookieStore(t)
+	var b browser
+	b.do(func(ctx context.Context) { _ = s.Put(ctx, "a", testValue{Score: 1}) })
+
+	c := b.cookies[DefaultCookieName]
+	v := []byte(c.Value)
+	if i := len(v) / 2; v[i] == 'A' {
+		v[i] = 'B'
+	} else {
+		v[i] = 'A'
+	}
+	c.Value = string(v)
+	b.do(func(ctx context.Context) {
+		if _, ok, err := s.Get(ctx, "a"); ok || err != nil {
+			t.Errorf("Get with a tampered cookie = %v, %v", ok, err)
+		}
+	})
+
+	c.Value = "not a cookie"
+	b.do(func(ctx context.Context) {
+		if _, ok, err := s.Get(ctx, "a"); ok || err != nil {
+			t.Errorf("Get with a garbage cookie = %v, %v", ok, err)
+		}
+	})
+}
+
+func TestCookieStore_KeyRotation(t *testing.T) {
+	old := newTestCookieStore(t, testKeyOld)
+	var b browser
+	b.do(func(ctx context.Context) { _ = old.Put(ctx, "a", testValue{Score: 1}) })
+
+	if _, err := NewCookieStore[testValue](nil, NewMemoryStore[testValue]()); err == nil {
+		t.Error("Expected an error without keys")
+	}
+	if _, err := NewCookieStore[testValue]([][]byte{[]byte("short")}, NewMemoryStore[testValue]()); err == nil {
+		t.Error("Expected an error for a short key")
+	}
+
+	onlyNew := newTestCookieStore(t, testKeyNew)
+	b.do(func(ctx context.Context) {
+		if _, ok, _ := onlyNew.Get(ctx, "a"); ok {
+			t.Error("Expected a cookie sealed with an unknown key to be refused")
+		}
+	})
+	rotated := newTestCookieStore(t, testKeyNew, testKeyOld)
+	b.do(func(ctx context.Context) {
+		got, ok, _ := rotated.Get(ctx, "a")
+		if !ok || got.Score != 1 {
+			t.Fatalf("Expected the old key to still open the cookie, got %+v %v", got, ok)
+		}
+		_ = rotated.Put(ctx, "a", testValue{Score: 2})
+	})
+	b.do(func(ctx context.Context) {
+		if got, ok, _ := onlyNew.Get(ctx, "a"); !ok || got.Score != 2 {
+			t.Errorf("Expected Put to reseal with the new key, got %+v %v", got, ok)
+		}
+	})
+}
+
+func TestCookieStore_Expiry(t *testing.T) {
+	s := newTestCookieStore(t)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	s.TTL = time.Hour
+	var b browser
+	w := b.do(func(ctx context.Context) { _ = s.Put(ctx, "a", testValue{Score: 1}) })
+	if c := w.Result().Cookies()[0]; c.MaxAge != 3600 {
+		t.Errorf("MaxAge = %d, want 3600", c.MaxAge)
+	}
+
+	now = now.Add(45 * time.Minute)
+	b.do(func(ctx context.Context) {
+		if _, ok, _ := s.Get(ctx, "a"); !ok {
+			t.Fatal("Expected a before its TTL")
+		}
+	})
+	now = now.Add(45 * time.Minute)
+	b.do(func(ctx context.Context) {
+		if _, ok, _ := s.Get(ctx, "a"); !ok {
+			t.Fatal("Expected Get to slide a's expiry")
+		}
+	})
+	// The browser may keep the cookie longer than asked; the seal still
+	// carries the expiry.
+	now = now.Add(2 * time.Hour)
+	b.do(func(ctx context.Context) {
+		if _, ok, _ := s.Get(ctx, "a"); ok {
+			t.Error("Expected a to expire")
+		}
+	})
+}
+
+func TestCookieStore_CompareAndPut(t *testing.T) {
+	s := newTestCookieStore(t)
+	var b browser
+	b.do(func(ctx context.Context) {
+		if rev, err := s.CompareAndPut(ctx, "a", testValue{Score: 1}, 0); err != nil || rev != 1 {
+			t.Fatalf("CompareAndPut(0) = %v, %v", rev, err)
+		}
+	})
+	b.do(func(ctx context.Context) {
+		if _, err := s.CompareAndPut(ctx, "a", testValue{Score: 2}, 0); !errors.Is(err, ErrConflict) {
+			t.Errorf("Expected ErrConflict for a stale revision, got %v", err)
+		}
+		if rev, err := s.CompareAndPut(ctx, "a", testValue{Score: 2}, 1); err != nil || rev != 2 {
+			t.Errorf("CompareAndPut(1) = %v, %v", rev, err)
+		}
+	})
+	b.do(func(ctx context.Context) {
+		if err := s.Delete(ctx, "a"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	})
+	if _, ok := b.cookies[DefaultCookieName]; ok {
+		t.Error("Expected Delete to clear the cookie")
+	}
+}
+
+func TestCookieStore_Fallback(t *testing.T) {
+	s := newTestCookieStore(t)
+	s.MaxSize = 500
+	var b browser
+	// Random-looking text does not compress below the limit.
+	var sb strings.Builder
+	for range 20 {
+		sb.WriteString(newID())
+	}
+	big := testValue{Name: sb.String()}
+	b.do(func(ctx context.Context) {
+		if err := s.Put(ctx, "a", big); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	})
+	if n := len(b.cookies[DefaultCookieName].Value); n > s.MaxSize {
+		t.Errorf("Expected a small cookie naming the session, got %d bytes", n)
+	}
+	if ids, _ := s.List(context.Background()); len(ids) != 1 {
+		t.Fatalf("Expected the session on the server, got %v", ids)
+	}
+	b.do(func(ctx context.Context) {
+		got, rev, ok, _ := s.GetRev(ctx, "a")
+		if !ok || got.Name != big.Name || rev != 1 {
+			t.Errorf("GetRev = %d, %v, %v", len(got.Name), rev, ok)
+		}
+		// Shrinking moves it back into the cookie.
+		_ = s.Put(ctx, "a", testValue{Score: 1})
+	})
+	if ids, _ := s.List(context.Background()); len(ids) != 0 {
+		t.Errorf("Expected the server copy removed, got %v", ids)
+	}
+	b.do(func(ctx context.Context) {
+		if got, rev, ok, _ := s.GetRev(ctx, "a"); !ok || got.Score != 1 || rev != 2 {
+			t.Errorf("GetRev = %+v, %v, %v", got, rev, ok)
+		}
+	})
+
+	// Repetitive state, like a long VisitedNodes, compresses well.
+	b.do(func(ctx context.Context) {
+		_ = s.Put(ctx, "a", testValue{Name: strings.Repeat("forest-path ", 200)})
+	})
+	if ids, _ := s.List(context.Background()); len(ids) != 0 {
+		t.Errorf("Expected compressible state to fit in the cookie, got %v", ids)
+	}
+}
@@ -0,0 +1,415 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultCompactMin is the smallest log, in records, that FileStore compacts
+// automatically.
+const DefaultCompactMin = 1024
+
+// ErrClosed is returned by a FileStore after Close.
+var ErrClosed = errors.New("session: store closed")
+
+// FileStore is a Store that survives restarts. Every Put appends a JSON
+// record to a log file and syncs it before returning; the latest value per
+// ID is kept in memory and the log is replayed on open. A crash can only
+// tear the last record, which is dropped on the next open. Delete appends a
+// record marking the session gone.
+//
+// Each record carries its session's expiry. Get slides the expiry in memory
+// only, so after a restart a session expires TTL after its last Put.
+//
+// Revisions come from one counter for the whole store, so an ID deleted and
+// created again never repeats one. The counter is the highest revision in
+// the log; a compacted log starts with a record for no ID that keeps it.
+//
+// The log grows with every Put, so it is compacted, rewriting only the
+// latest live values to a temporary file that is synced and renamed over
+// the log, once it holds more than twice as many records as sessions (and
+// at least CompactMin). Compact can also be called directly.
+type FileStore[T any] struct {
+	// CompactMin is the smallest log, in records, compacted automatically.
+	CompactMin int
+	// TTL is the expiry used by Put; 0 keeps sessions until deleted.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	path    string
+	f       logFile
+	m       map[string]fileEntry[T]
+	records int   // session records in the log, live or overwritten
+	rev     int64 // last revision written, to any ID
+	now     func() time.Time
+}
+
+// logFile is the open log. It is an *os.File except in tests, which
+// substitute one whose writes fail.
+type logFile interface {
+	io.ReadWriteSeeker
+	io.Closer
+	Sync() error
+	Truncate(size int64) error
+}
+
+type fileEntry[T any] struct {
+	v       T
+	rev     int64
+	ttl     time.Duration
+	expires time.Time // zero: never
+}
+
+// fileRecord is one line of the log: a value, or a deletion when Deleted.
+type fileRecord[T any] struct {
+	ID      string `json:"id"`
+	Value   *T     `json:"v,omitempty"`
+	Rev     int64  `json:"rev,omitempty"`
+	TTL     int64  `json:"ttl,omitempty"` // milliseconds
+	Expires int64  `json:"exp,omitempty"` // Unix milliseconds
+	Deleted bool   `json:"del,omitempty"`
+}
+
+func (s *FileStore[T]) record(id string, e *fileEntry[T]) fileRecord[T] {
+	rec := fileRecord[T]{ID: id, Value: &e.v, Rev: e.rev, TTL: e.ttl.Milliseconds()}
+	if !e.expires.IsZero() {
+		rec.Expires = e.expires.UnixMilli()
+	}
+	return rec
+}
+
+// OpenFileStore opens the log at path, creating it and its directory if
+// needed, and loads the sessions in it.
+func OpenFileStore[T any](path string) (*FileStore[T], error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileStore[T]{CompactMin: DefaultCompactMin, path: path, f: f, m: map[string]fileEntry[T]{}, now: time.Now}
+	if err := s.load(); err != nil {
+		f.Close() //nolint:errcheck,gosec // already failing
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// load replays the log. A torn last record (no trailing newline) is cut off;
+// a bad record before the end means the file is damaged and is an error.
+// Sessions that have expired since are left out.
+func (s *FileStore[T]) load() error {
+	r := bufio.NewReader(s.f)
+	var good int64
+	for line := 1; ; line++ {
+		b, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(b) > 0 {
+				// Torn write: drop it so the next append starts on a fresh line.
+				if err := s.f.Truncate(good); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var rec fileRecord[T]
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		s.rev = max(s.rev, rec.Rev)
+		switch {
+		case rec.ID == "":
+			// The revision counter kept by compact; not a session record.
+		case rec.Deleted:
+			delete(s.m, rec.ID)
+		case rec.Value == nil:
+			return fmt.Errorf("line %d: no value", line)
+		default:
+			e := fileEntry[T]{v: *rec.Value, rev: rec.Rev, ttl: time.Duration(rec.TTL) * time.Millisecond}
+			if rec.Expires != 0 {
+				e.expires = time.UnixMilli(rec.Expires)
+			}
+			s.m[rec.ID] = e
+		}
+		if rec.ID != "" {
+			s.records++
+		}
+		good += int64(len(b))
+	}
+	now := s.now()
+	for id, e := range s.m {
+		if expired(e.expires, now) {
+			delete(s.m, id)
+		}
+	}
+	_, err := s.f.Seek(good, io.SeekStart)
+	return err
+}
+
+// Get retrieves a value from the store by ID, resetting its expiry.
+func (s *FileStore[T]) Get(ctx context.Context, id string) (value T, ok bool, err error) {
+	value, _, ok, err = s.GetRev(ctx, id)
+	return value, ok, err
+}
+
+// GetRev retrieves a value and its revision, resetting its expiry.
+func (s *FileStore[T]) GetRev(_ context.Context, id string) (value T, rev int64, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return value, 0, false, ErrClosed
+	}
+	e, ok := s.live(id)
+	if !ok {
+		return value, 0, false, nil
+	}
+	if e.ttl > 0 {
+		e.expires = s.now().Add(e.ttl)
+		s.m[id] = e
+	}
+	return e.v, e.rev, true, nil
+}
+
+// live returns id's entry, forgetting it if it has expired. s.mu must be held.
+func (s *FileStore[T]) live(id string) (fileEntry[T], bool) {
+	e, ok := s.m[id]
+	if ok && expired(e.expires, s.now()) {
+		delete(s.m, id)
+		return fileEntry[T]{}, false
+	}
+	return e, ok
+}
+
+// CompareAndPut stores a value if the entry is still at revision rev.
+func (s *FileStore[T]) CompareAndPut(_ context.Context, id string, v T, rev int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return 0, ErrClosed
+	}
+	if e, _ := s.live(id); e.rev != rev {
+		return e.rev, ErrConflict
+	}
+	return s.put(id, v, s.TTL)
+}
+
+// Put stores a value with the given ID.
+func (s *FileStore[T]) Put(ctx context.Context, id string, v T) error {
+	return s.PutTTL(ctx, id, v, s.TTL)
+}
+
+// PutTTL stores a value that expires ttl after its last use.
+func (s *FileStore[T]) PutTTL(_ context.Context, id string, v T, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrClosed
+	}
+	_, err := s.put(id, v, ttl)
+	return err
+}
+
+// put appends the value to the log, syncs it and then makes it visible,
+// returning its new revision. Compaction waits until the value is visible,
+// since it rewrites the log from s.m. s.mu must be held.
+func (s *FileStore[T]) put(id string, v T, ttl time.Duration) (int64, error) {
+	e := fileEntry[T]{v: v, rev: s.rev + 1, ttl: ttl}
+	if ttl > 0 {
+		e.expires = s.now().Add(ttl)
+	}
+	if err := s.append(s.record(id, &e)); err != nil {
+		return 0, err
+	}
+	s.rev = e.rev
+	s.m[id] = e
+	s.maybeCompact()
+	return e.rev, nil
+}
+
+// Delete appends a deletion record, syncs it and then forgets the session.
+func (s *FileStore[T]) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrClosed
+	}
+	if _, ok := s.m[id]; !ok {
+		return nil
+	}
+	if err := s.append(fileRecord[T]{ID: id, Deleted: true}); err != nil {
+		return err
+	}
+	delete(s.m, id)
+	s.maybeCompact()
+	return nil
+}
+
+// append writes rec to the log and syncs it. A failed write or sync is cut
+// back off the log, so no torn record is left for the next append to
+// follow. s.mu must be held.
+func (s *FileStore[T]) append(rec fileRecord[T]) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	off, err := s.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := s.f.Write(append(b, '\n')); err != nil {
+		return s.rollback(off, err)
+	}
+	if err := s.f.Sync(); err != nil {
+		return s.rollback(off, err)
+	}
+	s.records++
+	return nil
+}
+
+// maybeCompact compacts the log once it has grown enough. It runs after a
+// write is applied to s.m, which compact rewrites the log from. s.mu must
+// be held.
+func (s *FileStore[T]) maybeCompact() {
+	if s.records >= s.CompactMin && s.records > 2*len(s.m) {
+		// The record is already durable; a failed compaction is retried on a later write.
+		_ = s.compact() //nolint:errcheck // see above
+	}
+}
+
+// rollback truncates the log back to off after a failed append and returns
+// err. If the log cannot be cut back, the store is closed rather than let a
+// later append land after the partial record. s.mu must be held.
+func (s *FileStore[T]) rollback(off int64, err error) error {
+	if terr := s.f.Truncate(off); terr != nil {
+		s.f.Close() //nolint:errcheck,gosec // already failing
+		s.f = nil
+		return errors.Join(err, terr)
+	}
+	if _, serr := s.f.Seek(off, io.SeekStart); serr != nil {
+		s.f.Close() //nolint:errcheck,gosec // already failing
+		s.f = nil
+		return errors.Join(err, serr)
+	}
+	return err
+}
+
+// List returns the IDs of the sessions that have not expired.
+func (s *FileStore[T]) List(_ context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil, ErrClosed
+	}
+	now := s.now()
+	ids := make([]string, 0, len(s.m))
+	for id, e := range s.m {
+		if !expired(e.expires, now) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// NewID generates a new unique session ID.
+func (s *FileStore[T]) NewID() string { return newID() }
+
+// Compact rewrites the log with only the latest value of each live session.
+func (s *FileStore[T]) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrClosed
+	}
+	return s.compact()
+}
+
+// compact writes the live records to a temporary file, syncs it, renames it
+// over the log and syncs the directory, so a crash leaves either the old log
+// or the new one. s.mu must be held.
+func (s *FileStore[T]) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".compact-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // no-op after a successful rename
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	// Keep the revision counter even if its highest revision was deleted.
+	// This record is not counted in s.records.
+	if err := enc.Encode(fileRecord[T]{Rev: s.rev, Deleted: true}); err != nil {
+		tmp.Close() //nolint:errcheck,gosec // already failing
+		return err
+	}
+	now := s.now()
+	for id, e := range s.m {
+		if expired(e.expires, now) {
+			delete(s.m, id)
+			continue
+		}
+		if err := enc.Encode(s.record(id, &e)); err != nil {
+			tmp.Close() //nolint:errcheck,gosec // already failing
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close() //nolint:errcheck,gosec // already failing
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close() //nolint:errcheck,gosec // already failing
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		tmp.Close() //nolint:errcheck,gosec // already failing
+		return err
+	}
+	syncDir(filepath.Dir(s.path))
+	// tmp is now the log, positioned at its end.
+	s.f.Close() //nolint:errcheck,gosec // the old log is replaced
+	s.f = tmp
+	s.records = len(s.m)
+	return nil
+}
+
+// syncDir makes a rename in dir durable. Not every platform supports
+// syncing a directory, so failures are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync() //nolint:errcheck // best effort, see above
+		d.Close()    //nolint:errcheck,gosec // read-only handle
+	}
+}
+
+// Ping reports ErrClosed once the store is closed.
+func (s *FileStore[T]) Ping(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Close syncs and closes the log. The store cannot be used afterwards.
+func (s *FileStore[T]) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrClosed
+	}
+	err := errors.Join(s.f.Sync(), s.f.Close())
+	s.f = nil
+	return err
+}
@@ -0,0 +1,320 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestFileStore(t *testing.T, path string) *FileStore[testValue] {
+	t.Helper()
+	s, err := OpenFileStore[testValue](path)
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestFileStore_Suite(t *testing.T) {
+	testStoreSuite(t, func(t *testing.T) Store[testValue] {
+		return openTestFileStore(t, filepath.Join(t.TempDir(), "sessions.log"))
+	})
+}
+
+func TestFileStore_Reopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "data", "sessions.log")
+	s := openTestFileStore(t, path)
+	_ = s.Put(ctx, "a", testValue{Score: 1})
+	_ = s.Put(ctx, "b", testValue{Score: 2})
+	_ = s.Put(ctx, "a", testValue{Score: 3})
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, _, err := s.Get(ctx, "a"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+	if err := s.Put(ctx, "a", testValue{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+	if err := s.Ping(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected Ping to report ErrClosed after Close, got %v", err)
+	}
+
+	s = openTestFileStore(t, path)
+	if a, _, _ := s.Get(ctx, "a"); a.Score != 3 {
+		t.Errorf("Expected a=3 after reopening, got %+v", a)
+	}
+	if b, _, _ := s.Get(ctx, "b"); b.Score != 2 {
+		t.Errorf("Expected b=2 after reopening, got %+v", b)
+	}
+}
+
+func TestFileStore_TornWrite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.log")
+	s := openTestFileStore(t, path)
+	_ = s.Put(ctx, "a", testValue{Score: 1})
+	s.Close()
+
+	// A crash mid-append leaves a record without its newline.
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	_, _ = f.WriteString(`{"id":"b","v":{"Sco`)
+	f.Close()
+
+	s = openTestFileStore(t, path)
+	if _, ok, _ := s.Get(ctx, "b"); ok {
+		t.Error("Expected the torn record to be dropped")
+	}
+	_ = s.Put(ctx, "c", testValue{Score: 3})
+	s.Close()
+
+	s = openTestFileStore(t, path)
+	a, _, _ := s.Get(ctx, "a")
+	c, _, _ := s.Get(ctx, "c")
+	if a.Score != 1 || c.Score != 3 {
+		t.Errorf("Expected records around the torn one to survive, got %+v %+v", a, c)
+	}
+}
+
+// failingLog is a log whose writes stop after a few bytes, or whose syncs
+// fail, while failing is set.
+type failingLog struct {
+	logFile
+	failWrite, failSync bool
+}
+
+func (f *failingLog) Write(b []byte) (int, error) {
+	if f.failWrite {
+		n, _ := f.logFile.Write(b[:len(b)/2])
+		return n, errors.New("disk full")
+	}
+	return f.logFile.Write(b)
+}
+
+func (f *failingLog) Sync() error {
+	if f.failSync {
+		return errors.New("sync failed")
+	}
+	return f.logFile.Sync()
+}
+
+func TestFileStore_FailedAppend(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.log")
+	s := openTestFileStore(t, path)
+	_ = s.Put(ctx, "a", testValue{Score: 1})
+	log := &failingLog{logFile: s.f}
+	s.f = log
+
+	log.failWrite = true
+	if err := s.Put(ctx, "b", testValue{Score: 2}); err == nil {
+		t.Fatal("Expected a failed write to fail the Put")
+	}
+	log.failWrite, log.failSync = false, true
+	if err := s.Delete(ctx, "a"); err == nil {
+		t.Fatal("Expected a failed sync to fail the Delete")
+	}
+	log.failSync = false
+	if err := s.Put(ctx, "c", testValue{Score: 3}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, ok, _ := s.Get(ctx, "b"); ok {
+		t.Error("Expected the failed Put not to be visible")
+	}
+	s.Close()
+
+	s = openTestFileStore(t, path)
+	a, _, _ := s.Get(ctx, "a")
+	c, _, _ := s.Get(ctx, "c")
+	if a.Score != 1 || c.Score != 3 {
+		t.Errorf("Expected the log to reopen with a and c, got %+v %+v", a, c)
+	}
+	if _, ok, _ := s.Get(ctx, "b"); ok {
+		t.Error("Expected no trace of the failed Put")
+	}
+}
+
+func TestFileStore_Damaged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.log")
+	if err := os.WriteFile(path, []byte("{\"id\":\"a\",\"v\":{}}\nnot json\n{\"id\":\"b\",\"v\":{}}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileStore[testValue](path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error naming line 2, got %v", err)
+	}
+}
+
+func TestFileStore_Compact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.log")
+	s := openTestFileStore(t, path)
+	for i := 0; i < 50; i++ {
+		_ = s.Put(ctx, "a", testValue{Score: i})
+		_ = s.Put(ctx, "b", testValue{Score: -i})
+	}
+	before, _ := os.Stat(path)
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size()/10 {
+		t.Errorf("Expected compaction to shrink the log, %d -> %d bytes", before.Size(), after.Size())
+	}
+	_ = s.Put(ctx, "c", testValue{Score: 7})
+	s.Close()
+
+	s = openTestFileStore(t, path)
+	a, _, _ := s.Get(ctx, "a")
+	b, _, _ := s.Get(ctx, "b")
+	c, _, _ := s.Get(ctx, "c")
+	if a.Score != 49 || b.Score != -49 || c.Score != 7 {
+		t.Errorf("Expected latest values after compaction, got %+v %+v %+v", a, b, c)
+	}
+	if s.records != 3 {
+		t.Errorf("Expected 3 records, got %d", s.records)
+	}
+	if matches, _ := filepath.Glob(path + ".compact-*"); len(matches) != 0 {
+		t.Errorf("Expected no leftover temp files, got %v", matches)
+	}
+}
+
+func TestFileStore_AutoCompact(t *testing.T) {
+	ctx := context.Background()
+	s := openTestFileStore(t, filepath.Join(t.TempDir(), "sessions.log"))
+	s.CompactMin = 10
+	for i := 0; i < 9; i++ {
+		_ = s.Put(ctx, "a", testValue{Score: i})
+	}
+	if s.records != 9 {
+		t.Fatalf("Expected no compaction below CompactMin, got %d records", s.records)
+	}
+	_ = s.Put(ctx, "a", testValue{Score: 9})
+	if s.records != 1 {
+		t.Errorf("Expected compaction at CompactMin, got %d records", s.records)
+	}
+	if a, _, _ := s.Get(ctx, "a"); a.Score != 9 {
+		t.Errorf("Expected the latest value, got %+v", a)
+	}
+}
+
+func TestFileStore_AutoCompactKeepsWrites(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.log")
+	reopen := func(s *FileStore[testValue]) *FileStore[testValue] {
+		s.Close()
+		s = openTestFileStore(t, path)
+		s.CompactMin = 4
+		return s
+	}
+	s := openTestFileStore(t, path)
+	s.CompactMin = 4
+	for i := 1; i <= 4; i++ {
+		_ = s.Put(ctx, "a", testValue{Score: i})
+	}
+	if s.records != 1 {
+		t.Fatalf("Expected the fourth Put to compact, got %d records", s.records)
+	}
+	s = reopen(s)
+	if a, _, _ := s.Get(ctx, "a"); a.Score != 4 {
+		t.Errorf("Expected the Put that compacted to survive reopening, got %+v", a)
+	}
+
+	_ = s.Put(ctx, "b", testValue{Score: 1})
+	_ = s.Put(ctx, "b", testValue{Score: 2})
+	_ = s.Delete(ctx, "b")
+	if s.records != 1 {
+		t.Fatalf("Expected the Delete to compact, got %d records", s.records)
+	}
+	s = reopen(s)
+	if _, ok, _ := s.Get(ctx, "b"); ok {
+		t.Error("Expected the Delete that compacted to survive reopening")
+	}
+}
+
+func TestFileStore_ExpiryAndDelete(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.log")
+	s := openTestFileStore(t, path)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	s.TTL = time.Hour
+	_ = s.Put(ctx, "a", testValue{Score: 1})
+	_ = s.PutTTL(ctx, "b", testValue{Score: 2}, 0)
+	_ = s.Put(ctx, "c", testValue{Score: 3})
+	if err := s.Delete(ctx, "c"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	now = now.Add(45 * time.Minute)
+	if _, ok, _ := s.Get(ctx, "a"); !ok {
+		t.Fatal("Expected a before its TTL")
+	}
+	now = now.Add(45 * time.Minute)
+	if _, ok, _ := s.Get(ctx, "a"); !ok {
+		t.Fatal("Expected Get to slide a's expiry")
+	}
+	now = now.Add(2 * time.Hour)
+	if _, ok, _ := s.Get(ctx, "a"); ok {
+		t.Error("Expected a to expire")
+	}
+	if ids, _ := s.List(ctx); len(ids) != 1 || ids[0] != "b" {
+		t.Errorf("List = %v, want [b]", ids)
+	}
+	s.Close()
+
+	s = openTestFileStore(t, path)
+	if _, ok, _ := s.Get(ctx, "c"); ok {
+		t.Error("Expected the deletion to survive reopening")
+	}
+	if got, ok, _ := s.Get(ctx, "b"); !ok || got.Score != 2 {
+		t.Errorf("Expected b without a TTL to survive, got %+v %v", got, ok)
+	}
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if s.records != 2 {
+		t.Errorf("Expected the deletion to be compacted away, got %d records", s.records)
+	}
+}
+
+func TestFileStore_RevisionsSurviveCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.log")
+	s := openTestFileStore(t, path)
+	_, _ = s.CompareAndPut(ctx, "a", testValue{Score: 1}, 0)
+	rev, _ := s.CompareAndPut(ctx, "b", testValue{Score: 2}, 0)
+	_ = s.Delete(ctx, "b")
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	s.Close()
+
+	// The highest revision belonged to the deleted b.
+	s = openTestFileStore(t, path)
+	if s.records != 1 {
+		t.Errorf("Expected 1 session record, got %d", s.records)
+	}
+	if got, err := s.CompareAndPut(ctx, "b", testValue{Score: 3}, 0); err != nil || got <= rev {
+		t.Errorf("CompareAndPut(recreate) = %d, %v; want a revision after %d", got, err, rev)
+	}
+}
+
+func TestFileStore_ExpiredOnOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.log")
+	past := time.Now().Add(-time.Minute).UnixMilli()
+	log := fmt.Sprintf("{\"id\":\"old\",\"v\":{},\"ttl\":1000,\"exp\":%d}\n{\"id\":\"new\",\"v\":{}}\n", past)
+	if err := os.WriteFile(path, []byte(log), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := openTestFileStore(t, path)
+	if ids, _ := s.List(context.Background()); len(ids) != 1 || ids[0] != "new" {
+		t.Errorf("List = %v, want [new]", ids)
+	}
+}
@@ -1,48 +1,248 @@
-// Package session provides in-memory storage for game sessions.
+// Package session provides storage for game sessions, in memory, in a file
+// on local disk or in Redis.
 package session
 
 import (
+	"container/list"
 	"context"
-	"crypto/rand"
-	"encoding/hex"
 	"sync"
+	"time"
 )
 
-// MemoryStore is an in-memory implementation of Store that uses a map
-// protected by a read-write mutex for thread safety.
+// MemoryStore is an in-memory implementation of Store. Set TTL and
+// MaxSessions before use; a janitor started with StartJanitor removes expired
+// sessions in the background, and Put evicts the least recently used session
+// once MaxSessions are held.
+//
+// Values that implement Cloner are copied on the way in and out, so like
+// the stores that serialise them, a value read from the store can be
+// changed without changing the stored one.
 type MemoryStore[T any] struct {
-	mu sync.RWMutex
-	m  map[string]T
+	// TTL is the expiry used by Put; 0 keeps sessions until deleted or evicted.
+	TTL time.Duration
+	// MaxSessions caps the sessions held; 0 means no cap.
+	MaxSessions int
+
+	mu          sync.Mutex
+	m           map[string]*list.Element // value is *memoryEntry[T]
+	lru         *list.List               // most recently used at the front
+	rev         int64                    // last revision handed out, to any ID
+	now         func() time.Time
+	stopJanitor func()
+}
+
+// Cloner is implemented by values holding maps or slices that a
+// MemoryStore must deep-copy to keep callers from sharing them.
+type Cloner[T any] interface {
+	Clone() T
+}
+
+// clone returns a copy of v that shares nothing with it, when T is a Cloner.
+func clone[T any](v T) T {
+	if c, ok := any(v).(Cloner[T]); ok {
+		return c.Clone()
+	}
+	return v
+}
+
+type memoryEntry[T any] struct {
+	id      string
+	v       T
+	rev     int64
+	ttl     time.Duration
+	expires time.Time // zero: never
 }
 
 // NewMemoryStore creates a new in-memory store.
 func NewMemoryStore[T any]() *MemoryStore[T] {
-	return &MemoryStore[T]{m: map[string]T{}}
+	return &MemoryStore[T]{m: map[string]*list.Element{}, lru: list.New(), now: time.Now}
 }
 
-// Get retrieves a value from the store by ID.
-func (s *MemoryStore[T]) Get(_ context.Context, id string) (value T, ok bool, err error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	value, ok = s.m[id]
-	return value, ok, nil
+// Get retrieves a value from the store by ID, resetting its expiry.
+func (s *MemoryStore[T]) Get(ctx context.Context, id string) (value T, ok bool, err error) {
+	value, _, ok, err = s.GetRev(ctx, id)
+	return value, ok, err
+}
+
+// GetRev retrieves a value and its revision, resetting its expiry.
+func (s *MemoryStore[T]) GetRev(_ context.Context, id string) (value T, rev int64, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el := s.live(id)
+	if el == nil {
+		return value, 0, false, nil
+	}
+	e := el.Value.(*memoryEntry[T])
+	s.touch(el, e)
+	return clone(e.v), e.rev, true, nil
+}
+
+// CompareAndPut stores a value if the entry is still at revision rev.
+func (s *MemoryStore[T]) CompareAndPut(_ context.Context, id string, v T, rev int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var cur int64
+	if el := s.live(id); el != nil {
+		cur = el.Value.(*memoryEntry[T]).rev
+	}
+	if cur != rev {
+		return cur, ErrConflict
+	}
+	return s.put(id, v, s.TTL), nil
 }
 
 // Put stores a value in the store with the given ID.
-func (s *MemoryStore[T]) Put(_ context.Context, id string, v T) error {
+func (s *MemoryStore[T]) Put(ctx context.Context, id string, v T) error {
+	return s.PutTTL(ctx, id, v, s.TTL)
+}
+
+// PutTTL stores a value that expires ttl after its last use.
+func (s *MemoryStore[T]) PutTTL(_ context.Context, id string, v T, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(id, v, ttl)
+	return nil
+}
+
+// put stores v, evicting the least recently used entry if the store is full,
+// and returns its new revision. Revisions come from one counter for the
+// whole store, so an ID deleted and created again never repeats one.
+// s.mu must be held.
+func (s *MemoryStore[T]) put(id string, v T, ttl time.Duration) int64 {
+	s.rev++
+	v = clone(v)
+	if el := s.live(id); el != nil {
+		e := el.Value.(*memoryEntry[T])
+		e.v, e.ttl = v, ttl
+		e.rev = s.rev
+		s.touch(el, e)
+		return e.rev
+	}
+	e := &memoryEntry[T]{id: id, v: v, ttl: ttl, rev: s.rev}
+	el := s.lru.PushFront(e)
+	s.m[id] = el
+	s.touch(el, e)
+	for s.MaxSessions > 0 && s.lru.Len() > s.MaxSessions {
+		s.remove(s.lru.Back())
+	}
+	return e.rev
+}
+
+// Delete removes a value from the store.
+func (s *MemoryStore[T]) Delete(_ context.Context, id string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.m[id] = v
+	if el := s.m[id]; el != nil {
+		s.remove(el)
+	}
 	return nil
 }
 
+// List returns the IDs of the sessions that have not expired.
+func (s *MemoryStore[T]) List(_ context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	ids := make([]string, 0, len(s.m))
+	for id, el := range s.m {
+		if !expired(el.Value.(*memoryEntry[T]).expires, now) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
 // NewID generates a new unique session ID.
-func (s *MemoryStore[T]) NewID() string {
-	b := make([]byte, 16)
-	if _, err := rand.Read(b); err != nil {
-		// Fallback: if crypto/rand fails, return a deterministic but unique ID
-		// This should never happen in practice, but we handle it gracefully
-		return hex.EncodeToString([]byte("fallback-id"))
-	}
-	return hex.EncodeToString(b)
+func (s *MemoryStore[T]) NewID() string { return newID() }
+
+// Len returns the number of sessions held, expired ones not yet swept included.
+func (s *MemoryStore[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// Sweep removes expired sessions and returns how many it removed.
+func (s *MemoryStore[T]) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now, n := s.now(), 0
+	for el := s.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if expired(el.Value.(*memoryEntry[T]).expires, now) {
+			s.remove(el)
+			n++
+		}
+		el = prev
+	}
+	return n
+}
+
+// StartJanitor sweeps expired sessions every interval until the returned
+// function is called.
+func (s *MemoryStore[T]) StartJanitor(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				s.Sweep()
+			}
+		}
+	}()
+	var once sync.Once
+	stop = func() { once.Do(func() { close(done) }) }
+	s.mu.Lock()
+	s.stopJanitor = stop
+	s.mu.Unlock()
+	return stop
+}
+
+// Ping always succeeds: the store is in this process.
+func (s *MemoryStore[T]) Ping(context.Context) error { return nil }
+
+// Close stops the janitor. There is nothing to flush: the sessions go with
+// the process. The store keeps working afterwards.
+func (s *MemoryStore[T]) Close() error {
+	s.mu.Lock()
+	stop := s.stopJanitor
+	s.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+	return nil
+}
+
+// live returns id's element, removing it if it has expired. s.mu must be held.
+func (s *MemoryStore[T]) live(id string) *list.Element {
+	el := s.m[id]
+	if el != nil && expired(el.Value.(*memoryEntry[T]).expires, s.now()) {
+		s.remove(el)
+		return nil
+	}
+	return el
+}
+
+// touch marks e most recently used and restarts its expiry. s.mu must be held.
+func (s *MemoryStore[T]) touch(el *list.Element, e *memoryEntry[T]) {
+	s.lru.MoveToFront(el)
+	e.expires = time.Time{}
+	if e.ttl > 0 {
+		e.expires = s.now().Add(e.ttl)
+	}
+}
+
+// remove drops el. s.mu must be held.
+func (s *MemoryStore[T]) remove(el *list.Element) {
+	delete(s.m, el.Value.(*memoryEntry[T]).id)
+	s.lru.Remove(el)
+}
+
+// expired reports whether an expiry time has passed; the zero time never does.
+func expired(expires, now time.Time) bool {
+	return !expires.IsZero() && !now.Before(expires)
 }
@@ -3,6 +3,7 @@ package session
 import (
 	"context"
 	"testing"
+	"time"
 )
 
 func TestMemoryStore_GetPut(t *testing.T) {
@@ -123,3 +124,70 @@ func TestMemoryStore_Concurrent(t *testing.T) {
 		t.Error("Expected value to exist after concurrent writes")
 	}
 }
+
+func TestMemoryStore_SlidingTTL(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore[int]()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	store.TTL = time.Hour
+
+	_ = store.Put(ctx, "a", 1)
+	_ = store.PutTTL(ctx, "b", 2, 0)
+	now = now.Add(50 * time.Minute)
+	if _, ok, _ := store.Get(ctx, "a"); !ok {
+		t.Fatal("Expected a before its TTL")
+	}
+	now = now.Add(50 * time.Minute)
+	if _, ok, _ := store.Get(ctx, "a"); !ok {
+		t.Fatal("Expected Get to slide a's expiry")
+	}
+	now = now.Add(2 * time.Hour)
+	if ids, _ := store.List(ctx); len(ids) != 1 || ids[0] != "b" {
+		t.Errorf("List = %v, want [b]", ids)
+	}
+	if n := store.Sweep(); n != 1 || store.Len() != 1 {
+		t.Errorf("Sweep removed %d, %d left; want 1 and 1", n, store.Len())
+	}
+	if _, ok, _ := store.Get(ctx, "b"); !ok {
+		t.Error("Expected b without a TTL to stay")
+	}
+}
+
+func TestMemoryStore_LRU(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore[int]()
+	store.MaxSessions = 2
+	_ = store.Put(ctx, "a", 1)
+	_ = store.Put(ctx, "b", 2)
+	_, _, _ = store.Get(ctx, "a") // b is now least recently used
+	_ = store.Put(ctx, "c", 3)
+	if _, ok, _ := store.Get(ctx, "b"); ok {
+		t.Error("Expected the least recently used session to be evicted")
+	}
+	for _, id := range []string{"a", "c"} {
+		if _, ok, _ := store.Get(ctx, id); !ok {
+			t.Errorf("Expected %s to stay", id)
+		}
+	}
+	_ = store.Put(ctx, "a", 10) // overwriting does not evict
+	if store.Len() != 2 {
+		t.Errorf("Expected 2 sessions, got %d", store.Len())
+	}
+}
+
+func TestMemoryStore_Janitor(t *testing.T) {
+	store := NewMemoryStore[int]()
+	_ = store.PutTTL(context.Background(), "a", 1, time.Millisecond)
+	stop := store.StartJanitor(time.Millisecond)
+	defer stop()
+	deadline := time.Now().Add(time.Second)
+	for store.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the janitor to sweep the expired session")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	stop() // safe to call twice
+}
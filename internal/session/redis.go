@@ -0,0 +1,446 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnavailable wraps failures to reach a remote store, such as a refused
+// connection or a timeout. Callers treat it like any other store error.
+var ErrUnavailable = errors.New("session: store unavailable")
+
+// Defaults for RedisOptions fields left zero.
+const (
+	DefaultRedisPoolSize = 8
+	DefaultRedisTimeout  = 3 * time.Second
+)
+
+// RedisOptions configures a RedisStore.
+type RedisOptions struct {
+	Addr     string        // host:port
+	Password string        // sent with AUTH when set
+	DB       int           // selected with SELECT when non-zero
+	Prefix   string        // prepended to every key, e.g. "adventure:session:"
+	TTL      time.Duration // expiry used by Put; 0 keeps sessions forever
+	PoolSize int           // idle connections kept; 0 means DefaultRedisPoolSize
+	Timeout  time.Duration // dial and per-command timeout; 0 means DefaultRedisTimeout
+}
+
+// RedisStore is a Store kept in a Redis (or Redis protocol compatible)
+// server, so several server replicas can share sessions. Values are stored
+// as JSON under Prefix+ID, together with their TTL so Get can slide the
+// key's expiry.
+//
+// Revisions come from a counter under Prefix+"#rev", shared by every ID, so
+// an ID deleted and created again never repeats one. Session IDs are hex,
+// so the counter never collides with one.
+type RedisStore[T any] struct {
+	opts RedisOptions
+	pool chan *redisConn
+}
+
+// redisRevKey is the suffix of the revision counter's key.
+const redisRevKey = "#rev"
+
+// redisRecord is the JSON stored under a session's key.
+type redisRecord[T any] struct {
+	Rev   int64 `json:"rev"`
+	TTL   int64 `json:"ttl,omitempty"` // milliseconds
+	Value T     `json:"v"`
+}
+
+// OpenRedisStore connects to the server in opts and checks it answers.
+func OpenRedisStore[T any](opts RedisOptions) (*RedisStore[T], error) {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = DefaultRedisPoolSize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultRedisTimeout
+	}
+	s := &RedisStore[T]{opts: opts, pool: make(chan *redisConn, opts.PoolSize)}
+	if _, err := s.do(context.Background(), "PING"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get retrieves a value from the store by ID, resetting its expiry.
+func (s *RedisStore[T]) Get(ctx context.Context, id string) (value T, ok bool, err error) {
+	value, _, ok, err = s.GetRev(ctx, id)
+	return value, ok, err
+}
+
+// GetRev retrieves a value and its revision, resetting its expiry.
+func (s *RedisStore[T]) GetRev(ctx context.Context, id string) (value T, rev int64, ok bool, err error) {
+	reply, err := s.do(ctx, "GET", s.opts.Prefix+id)
+	if err != nil {
+		return value, 0, false, err
+	}
+	var rec redisRecord[T]
+	if ok, err = decodeRecord(id, reply, &rec); !ok || err != nil {
+		return value, 0, false, err
+	}
+	if rec.TTL > 0 {
+		if _, err := s.do(ctx, "PEXPIRE", s.opts.Prefix+id, strconv.FormatInt(rec.TTL, 10)); err != nil {
+			return value, 0, false, err
+		}
+	}
+	return rec.Value, rec.Rev, true, nil
+}
+
+// decodeRecord decodes a GET reply into rec, reporting false for a missing key.
+func decodeRecord[T any](id string, reply any, rec *redisRecord[T]) (bool, error) {
+	if reply == nil {
+		return false, nil
+	}
+	b, isBulk := reply.([]byte)
+	if !isBulk {
+		return false, fmt.Errorf("session: GET %s: unexpected reply %v", id, reply)
+	}
+	if err := json.Unmarshal(b, rec); err != nil {
+		return false, fmt.Errorf("session: GET %s: %w", id, err)
+	}
+	return true, nil
+}
+
+// Put stores a value with the given ID.
+func (s *RedisStore[T]) Put(ctx context.Context, id string, v T) error {
+	return s.PutTTL(ctx, id, v, s.opts.TTL)
+}
+
+// PutTTL stores a value that expires ttl after its last use.
+func (s *RedisStore[T]) PutTTL(ctx context.Context, id string, v T, ttl time.Duration) error {
+	_, err := s.put(ctx, id, v, ttl, nil)
+	return err
+}
+
+// CompareAndPut stores a value if the entry is still at revision rev.
+func (s *RedisStore[T]) CompareAndPut(ctx context.Context, id string, v T, rev int64) (int64, error) {
+	return s.put(ctx, id, v, s.opts.TTL, &rev)
+}
+
+// put writes v with the next revision, and only if the current one is *want
+// when want is set. It WATCHes the key, reads its revision and writes in a
+// MULTI/EXEC transaction, starting over if the key changed in between. An
+// aborted transaction means another client wrote, so retrying makes
+// progress; a Get's PEXPIRE counts as a change too, which is why the
+// revision is checked again rather than reporting a conflict straight away.
+func (s *RedisStore[T]) put(ctx context.Context, id string, v T, ttl time.Duration, want *int64) (int64, error) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	key, ms := s.opts.Prefix+id, ttl.Milliseconds()
+	var rev int64
+	err = s.withConn(ctx, func(c *redisConn) error {
+		for ctx.Err() == nil {
+			if _, err := c.roundTrip(ctx, s.opts.Timeout, []string{"WATCH", key}); err != nil {
+				return err
+			}
+			reply, err := c.roundTrip(ctx, s.opts.Timeout, []string{"GET", key})
+			var cur redisRecord[json.RawMessage]
+			if err == nil {
+				_, err = decodeRecord(id, reply, &cur)
+			}
+			if err == nil && want != nil && cur.Rev != *want {
+				rev, err = cur.Rev, ErrConflict
+			}
+			if err != nil {
+				c.roundTrip(ctx, s.opts.Timeout, []string{"UNWATCH"}) //nolint:errcheck,gosec // already failing
+				return err
+			}
+			// A revision drawn for a transaction that then fails is
+			// skipped; revisions only have to increase.
+			reply, err = c.roundTrip(ctx, s.opts.Timeout, []string{"INCR", s.opts.Prefix + redisRevKey})
+			next, ok := reply.(int64)
+			if err == nil && !ok {
+				err = fmt.Errorf("session: INCR: unexpected reply %v", reply)
+			}
+			if err != nil {
+				c.roundTrip(ctx, s.opts.Timeout, []string{"UNWATCH"}) //nolint:errcheck,gosec // already failing
+				return err
+			}
+			b, err := json.Marshal(redisRecord[json.RawMessage]{Rev: next, TTL: ms, Value: value})
+			if err != nil {
+				return err
+			}
+			args := []string{"SET", key, string(b)}
+			if ms > 0 {
+				args = append(args, "PX", strconv.FormatInt(ms, 10))
+			}
+			reply, err = c.transaction(ctx, s.opts.Timeout, args)
+			if err != nil {
+				return err
+			}
+			if reply != nil {
+				rev = next
+				return nil
+			}
+		}
+		return ctx.Err()
+	})
+	return rev, err
+}
+
+// Delete removes a value from the store.
+func (s *RedisStore[T]) Delete(ctx context.Context, id string) error {
+	_, err := s.do(ctx, "DEL", s.opts.Prefix+id)
+	return err
+}
+
+// List returns the IDs under the store's prefix, walking the keyspace with SCAN.
+func (s *RedisStore[T]) List(ctx context.Context) ([]string, error) {
+	match := redisGlobEscape(s.opts.Prefix) + "*"
+	var ids []string
+	cursor := "0"
+	for {
+		reply, err := s.do(ctx, "SCAN", cursor, "MATCH", match, "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+		items, _ := reply.([]any)
+		if len(items) != 2 {
+			return nil, fmt.Errorf("session: SCAN: unexpected reply %v", reply)
+		}
+		next, _ := items[0].([]byte)
+		keys, _ := items[1].([]any)
+		for _, k := range keys {
+			if b, ok := k.([]byte); ok {
+				if id := strings.TrimPrefix(string(b), s.opts.Prefix); id != redisRevKey {
+					ids = append(ids, id)
+				}
+			}
+		}
+		if cursor = string(next); cursor == "0" || cursor == "" {
+			return ids, nil
+		}
+	}
+}
+
+// redisGlobEscape escapes the characters SCAN's MATCH treats specially.
+func redisGlobEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// NewID generates a new unique session ID.
+func (s *RedisStore[T]) NewID() string { return newID() }
+
+// Ping sends Redis a PING.
+func (s *RedisStore[T]) Ping(ctx context.Context) error {
+	_, err := s.do(ctx, "PING")
+	return err
+}
+
+// Close closes the idle connections. Every write has already been sent, so
+// there is nothing to flush.
+func (s *RedisStore[T]) Close() error {
+	for {
+		select {
+		case c := <-s.pool:
+			c.Close() //nolint:errcheck,gosec // closing idle connections
+		default:
+			return nil
+		}
+	}
+}
+
+// redisConn is one connection speaking RESP.
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "session: redis: " + string(e) }
+
+// do runs one command on a pooled connection.
+func (s *RedisStore[T]) do(ctx context.Context, args ...string) (any, error) {
+	var reply any
+	err := s.withConn(ctx, func(c *redisConn) error {
+		var err error
+		reply, err = c.roundTrip(ctx, s.opts.Timeout, args)
+		return err
+	})
+	return reply, err
+}
+
+// withConn runs fn on a pooled connection. A network failure (ErrUnavailable)
+// closes the connection; any other error, such as an error reply, leaves it
+// usable and returns it to the pool.
+func (s *RedisStore[T]) withConn(ctx context.Context, fn func(*redisConn) error) error {
+	c, err := s.conn(ctx)
+	if err != nil {
+		return err
+	}
+	err = fn(c)
+	if errors.Is(err, ErrUnavailable) {
+		c.Close() //nolint:errcheck,gosec // already failing
+		return err
+	}
+	s.release(c)
+	return err
+}
+
+// release returns c to the pool, or closes it if the pool is full.
+func (s *RedisStore[T]) release(c *redisConn) {
+	select {
+	case s.pool <- c:
+	default:
+		c.Close() //nolint:errcheck,gosec // pool is full
+	}
+}
+
+// conn takes an idle connection or dials a new one, authenticating and
+// selecting the database.
+func (s *RedisStore[T]) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-s.pool:
+		return c, nil
+	default:
+	}
+	d := net.Dialer{Timeout: s.opts.Timeout}
+	nc, err := d.DialContext(ctx, "tcp", s.opts.Addr)
+	if err != nil {
+		return nil, unavailable("dial", err)
+	}
+	c := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+	var setup [][]string
+	if s.opts.Password != "" {
+		setup = append(setup, []string{"AUTH", s.opts.Password})
+	}
+	if s.opts.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.opts.DB)})
+	}
+	for _, args := range setup {
+		if _, err := c.roundTrip(ctx, s.opts.Timeout, args); err != nil {
+			c.Close() //nolint:errcheck,gosec // already failing
+			if errors.Is(err, ErrUnavailable) {
+				return nil, err
+			}
+			return nil, unavailable(args[0], err)
+		}
+	}
+	return c, nil
+}
+
+// roundTrip writes a command and reads its reply within timeout (or the
+// context's deadline, if sooner). Failures other than error replies leave
+// the connection unusable and are wrapped in ErrUnavailable.
+func (c *redisConn) roundTrip(ctx context.Context, timeout time.Duration, args []string) (any, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, unavailable(args[0], err)
+	}
+	if _, err := c.Write(appendCommand(nil, args)); err != nil {
+		return nil, unavailable(args[0], err)
+	}
+	reply, err := readReply(c.r)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		return nil, unavailable(args[0], err)
+	}
+	return reply, err
+}
+
+func unavailable(cmd string, err error) error {
+	return fmt.Errorf("%w: %s: %v", ErrUnavailable, cmd, err)
+}
+
+// transaction runs args between MULTI and EXEC, returning EXEC's reply: nil
+// when a watched key changed and the command was not run.
+func (c *redisConn) transaction(ctx context.Context, timeout time.Duration, args []string) (any, error) {
+	if _, err := c.roundTrip(ctx, timeout, []string{"MULTI"}); err != nil {
+		return nil, err
+	}
+	if _, err := c.roundTrip(ctx, timeout, args); err != nil {
+		c.roundTrip(ctx, timeout, []string{"DISCARD"}) //nolint:errcheck,gosec // already failing
+		return nil, err
+	}
+	return c.roundTrip(ctx, timeout, []string{"EXEC"})
+}
+
+// appendCommand encodes args as a RESP array of bulk strings.
+func appendCommand(b []byte, args []string) []byte {
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(len(args)), 10)
+	b = append(b, "\r\n"...)
+	for _, a := range args {
+		b = append(b, '$')
+		b = strconv.AppendInt(b, int64(len(a)), 10)
+		b = append(b, "\r\n"...)
+		b = append(b, a...)
+		b = append(b, "\r\n"...)
+	}
+	return b
+}
+
+// readReply reads one RESP reply: a string for simple strings, int64 for
+// integers, []byte for bulk strings, []any for arrays, nil for null, and a
+// redisError for error replies.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("malformed bulk length %q", body)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("malformed array length %q", body)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unknown reply type %q", kind)
+}
@@ -0,0 +1,517 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is an in-process stand-in for a Redis server that speaks enough
+// RESP for RedisStore: PING, AUTH, SELECT, GET, SET with PX or EX, DEL,
+// PEXPIRE, PTTL, SCAN and WATCH/MULTI/EXEC transactions, with expiry on a
+// clock tests can move.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu    sync.Mutex
+	now   time.Time
+	data  map[string]fakeEntry
+	vers  map[string]int // bumped on every change to a key, for WATCH
+	conns map[net.Conn]struct{}
+	cmds  []string // command names received, for assertions
+	// beforeExec, when set, runs before EXEC checks watched keys, with f.mu held.
+	beforeExec func()
+}
+
+type fakeEntry struct {
+	val     []byte
+	expires time.Time // zero: never
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeRedis{ln: ln, password: password, now: time.Unix(1e9, 0), data: map[string]fakeEntry{}, vers: map[string]int{}, conns: map[net.Conn]struct{}{}}
+	go f.serve()
+	t.Cleanup(f.close)
+	return f
+}
+
+func (f *fakeRedis) addr() string { return f.ln.Addr().String() }
+
+// advance moves the fake's clock, expiring keys.
+func (f *fakeRedis) advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// dropConnections closes every open client connection, as a restart would.
+func (f *fakeRedis) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for c := range f.conns {
+		c.Close()
+	}
+}
+
+func (f *fakeRedis) close() {
+	f.ln.Close()
+	f.dropConnections()
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		c, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns[c] = struct{}{}
+		f.mu.Unlock()
+		go f.handle(c)
+	}
+}
+
+func (f *fakeRedis) handle(c net.Conn) {
+	defer func() {
+		f.mu.Lock()
+		delete(f.conns, c)
+		f.mu.Unlock()
+		c.Close()
+	}()
+	r := bufio.NewReader(c)
+	authed := f.password == ""
+	var (
+		watched map[string]int // key -> version when watched
+		queued  [][]string     // commands inside MULTI; nil outside
+	)
+	for {
+		req, err := readReply(r)
+		if err != nil {
+			return
+		}
+		items, _ := req.([]any)
+		args := make([]string, len(items))
+		for i, it := range items {
+			b, _ := it.([]byte)
+			args[i] = string(b)
+		}
+		if len(args) == 0 {
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+		if !authed && cmd != "AUTH" {
+			fmt.Fprint(c, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		if cmd == "AUTH" {
+			authed = len(args) == 2 && args[1] == f.password
+		}
+		switch {
+		case cmd == "WATCH":
+			f.mu.Lock()
+			f.cmds = append(f.cmds, cmd)
+			f.expire()
+			if watched == nil {
+				watched = map[string]int{}
+			}
+			for _, k := range args[1:] {
+				watched[k] = f.vers[k]
+			}
+			f.mu.Unlock()
+			fmt.Fprint(c, "+OK\r\n")
+		case cmd == "UNWATCH":
+			watched = nil
+			fmt.Fprint(c, "+OK\r\n")
+		case cmd == "MULTI":
+			queued = [][]string{}
+			fmt.Fprint(c, "+OK\r\n")
+		case cmd == "DISCARD":
+			watched, queued = nil, nil
+			fmt.Fprint(c, "+OK\r\n")
+		case cmd == "EXEC":
+			fmt.Fprint(c, f.execMulti(watched, queued))
+			watched, queued = nil, nil
+		case queued != nil:
+			queued = append(queued, args)
+			fmt.Fprint(c, "+QUEUED\r\n")
+		default:
+			fmt.Fprint(c, f.exec(cmd, args[1:]))
+		}
+	}
+}
+
+// execMulti runs a transaction's commands, or none if a watched key changed.
+func (f *fakeRedis) execMulti(watched map[string]int, queued [][]string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cmds = append(f.cmds, "EXEC")
+	f.expire()
+	if f.beforeExec != nil {
+		f.beforeExec()
+	}
+	for k, v := range watched {
+		if f.vers[k] != v {
+			return "*-1\r\n"
+		}
+	}
+	out := "*" + strconv.Itoa(len(queued)) + "\r\n"
+	for _, args := range queued {
+		out += f.run(strings.ToUpper(args[0]), args[1:])
+	}
+	return out
+}
+
+// expire drops keys whose time has come. f.mu must be held.
+func (f *fakeRedis) expire() {
+	for k, e := range f.data {
+		if !e.expires.IsZero() && !f.now.Before(e.expires) {
+			delete(f.data, k)
+			f.vers[k]++
+		}
+	}
+}
+
+// exec runs one command and returns its encoded reply.
+func (f *fakeRedis) exec(cmd string, args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cmds = append(f.cmds, cmd)
+	f.expire()
+	return f.run(cmd, args)
+}
+
+// run executes a command. f.mu must be held.
+func (f *fakeRedis) run(cmd string, args []string) string {
+	bulk := func(b []byte) string { return "$" + strconv.Itoa(len(b)) + "\r\n" + string(b) + "\r\n" }
+	switch {
+	case cmd == "PING":
+		return "+PONG\r\n"
+	case cmd == "AUTH" && len(args) == 1:
+		if args[0] != f.password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		return "+OK\r\n"
+	case cmd == "SELECT" && len(args) == 1:
+		return "+OK\r\n"
+	case cmd == "GET" && len(args) == 1:
+		e, ok := f.data[args[0]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(e.val)
+	case cmd == "SET" && (len(args) == 2 || len(args) == 4):
+		e := fakeEntry{val: []byte(args[1])}
+		if len(args) == 4 {
+			n, err := strconv.Atoi(args[3])
+			if err != nil || n <= 0 {
+				return "-ERR invalid expire time\r\n"
+			}
+			switch strings.ToUpper(args[2]) {
+			case "PX":
+				e.expires = f.now.Add(time.Duration(n) * time.Millisecond)
+			case "EX":
+				e.expires = f.now.Add(time.Duration(n) * time.Second)
+			default:
+				return "-ERR syntax error\r\n"
+			}
+		}
+		f.data[args[0]] = e
+		f.vers[args[0]]++
+		return "+OK\r\n"
+	case cmd == "DEL":
+		n := 0
+		for _, k := range args {
+			if _, ok := f.data[k]; ok {
+				delete(f.data, k)
+				f.vers[k]++
+				n++
+			}
+		}
+		return ":" + strconv.Itoa(n) + "\r\n"
+	case cmd == "INCR" && len(args) == 1:
+		n := 0
+		if e, ok := f.data[args[0]]; ok {
+			var err error
+			if n, err = strconv.Atoi(string(e.val)); err != nil {
+				return "-ERR value is not an integer or out of range\r\n"
+			}
+		}
+		n++
+		f.data[args[0]] = fakeEntry{val: []byte(strconv.Itoa(n))}
+		f.vers[args[0]]++
+		return ":" + strconv.Itoa(n) + "\r\n"
+	case cmd == "PEXPIRE" && len(args) == 2:
+		e, ok := f.data[args[0]]
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		if !ok {
+			return ":0\r\n"
+		}
+		e.expires = f.now.Add(time.Duration(n) * time.Millisecond)
+		f.data[args[0]] = e
+		f.vers[args[0]]++
+		return ":1\r\n"
+	case cmd == "SCAN" && len(args) >= 1:
+		// Everything in one batch, filtered by MATCH.
+		pattern := "*"
+		for i := 1; i+1 < len(args); i += 2 {
+			if strings.ToUpper(args[i]) == "MATCH" {
+				pattern = args[i+1]
+			}
+		}
+		var keys []string
+		for k := range f.data {
+			if ok, _ := path.Match(pattern, k); ok {
+				keys = append(keys, bulk([]byte(k)))
+			}
+		}
+		return "*2\r\n" + bulk([]byte("0")) + "*" + strconv.Itoa(len(keys)) + "\r\n" + strings.Join(keys, "")
+	case cmd == "PTTL" && len(args) == 1:
+		e, ok := f.data[args[0]]
+		switch {
+		case !ok:
+			return ":-2\r\n"
+		case e.expires.IsZero():
+			return ":-1\r\n"
+		}
+		return ":" + strconv.FormatInt(e.expires.Sub(f.now).Milliseconds(), 10) + "\r\n"
+	}
+	return "-ERR unknown command '" + cmd + "'\r\n"
+}
+
+func openTestRedisStore(t *testing.T, f *fakeRedis, opts RedisOptions) *RedisStore[testValue] {
+	t.Helper()
+	opts.Addr = f.addr()
+	s, err := OpenRedisStore[testValue](opts)
+	if err != nil {
+		t.Fatalf("OpenRedisStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestRedisStore_Suite(t *testing.T) {
+	testStoreSuite(t, func(t *testing.T) Store[testValue] {
+		return openTestRedisStore(t, newFakeRedis(t, ""), RedisOptions{})
+	})
+}
+
+func TestRedisStore_PrefixAndTTL(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeRedis(t, "")
+	s := openTestRedisStore(t, f, RedisOptions{Prefix: "adv:", TTL: time.Hour})
+	if err := s.Put(ctx, "a", testValue{Score: 1}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	f.mu.Lock()
+	e, ok := f.data["adv:a"]
+	f.mu.Unlock()
+	if !ok || e.expires.IsZero() {
+		t.Fatalf("Expected a prefixed key with an expiry, got %+v %v", e, ok)
+	}
+
+	f.advance(59 * time.Minute)
+	_ = s.Put(ctx, "a", testValue{Score: 2}) // resets the TTL
+	f.advance(59 * time.Minute)
+	if got, ok, _ := s.Get(ctx, "a"); !ok || got.Score != 2 {
+		t.Fatalf("Expected Put to extend the TTL, got %+v %v", got, ok)
+	}
+	f.advance(59 * time.Minute)
+	if _, ok, _ := s.Get(ctx, "a"); !ok {
+		t.Fatal("Expected Get to extend the TTL")
+	}
+	f.advance(61 * time.Minute)
+	if _, ok, err := s.Get(ctx, "a"); ok || err != nil {
+		t.Errorf("Expected the session to expire, got %v %v", ok, err)
+	}
+
+	// Per-entry TTLs: none at all, or a shorter one that Get slides by its own length.
+	_ = s.PutTTL(ctx, "forever", testValue{}, 0)
+	_ = s.PutTTL(ctx, "short", testValue{}, time.Minute)
+	f.advance(50 * time.Second)
+	if _, ok, _ := s.Get(ctx, "short"); !ok {
+		t.Fatal("Expected the short session before its TTL")
+	}
+	f.advance(50 * time.Second)
+	if _, ok, _ := s.Get(ctx, "short"); !ok {
+		t.Fatal("Expected Get to slide the short TTL")
+	}
+	f.advance(2 * time.Minute)
+	if _, ok, _ := s.Get(ctx, "short"); ok {
+		t.Error("Expected the short session to expire")
+	}
+	f.mu.Lock()
+	e = f.data["adv:forever"]
+	f.data["other:x"] = fakeEntry{val: []byte("{}")}
+	f.mu.Unlock()
+	if !e.expires.IsZero() {
+		t.Errorf("Expected no expiry with a zero TTL, got %v", e.expires)
+	}
+	if ids, err := s.List(ctx); err != nil || len(ids) != 1 || ids[0] != "forever" {
+		t.Errorf("List = %v, %v; want only keys under the prefix", ids, err)
+	}
+	if got := redisGlobEscape(`a*b?[c]\`); got != `a\*b\?\[c\]\\` {
+		t.Errorf("redisGlobEscape = %q", got)
+	}
+}
+
+func TestRedisStore_AuthAndSelect(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+	if _, err := OpenRedisStore[testValue](RedisOptions{Addr: f.addr(), Password: "wrong"}); err == nil {
+		t.Error("Expected a wrong password to fail")
+	}
+	s := openTestRedisStore(t, f, RedisOptions{Password: "secret", DB: 2})
+	if err := s.Put(context.Background(), "a", testValue{}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	f.mu.Lock()
+	cmds := strings.Join(f.cmds, " ")
+	f.mu.Unlock()
+	if !strings.HasPrefix(cmds, "AUTH AUTH SELECT PING") {
+		t.Errorf("Expected AUTH and SELECT before commands, got %s", cmds)
+	}
+}
+
+func TestRedisStore_Unavailable(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeRedis(t, "")
+	s := openTestRedisStore(t, f, RedisOptions{Timeout: time.Second})
+	_ = s.Put(ctx, "a", testValue{Score: 1})
+
+	// A dropped connection is retired; the next command reconnects.
+	f.dropConnections()
+	if _, _, err := s.Get(ctx, "a"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable on a dropped connection, got %v", err)
+	}
+	if got, ok, err := s.Get(ctx, "a"); err != nil || !ok || got.Score != 1 {
+		t.Errorf("Expected to reconnect, got %+v %v %v", got, ok, err)
+	}
+
+	f.close()
+	if err := s.Put(ctx, "a", testValue{}); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable with the server down, got %v", err)
+	}
+	if err := s.Ping(ctx); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected Ping to report ErrUnavailable with the server down, got %v", err)
+	}
+	if _, err := OpenRedisStore[testValue](RedisOptions{Addr: f.addr(), Timeout: time.Second}); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected OpenRedisStore to fail fast, got %v", err)
+	}
+}
+
+func TestRedisStore_ErrorReplies(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeRedis(t, "")
+	s := openTestRedisStore(t, f, RedisOptions{})
+	f.mu.Lock()
+	f.data["bad"] = fakeEntry{val: []byte("not json")}
+	f.mu.Unlock()
+	if _, _, err := s.Get(ctx, "bad"); err == nil || errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected a decode error, got %v", err)
+	}
+	if _, err := s.do(ctx, "NOPE"); err == nil || errors.Is(err, ErrUnavailable) || !strings.Contains(err.Error(), "unknown command") {
+		t.Errorf("Expected the server's error reply, got %v", err)
+	}
+	// Error replies leave the connection usable.
+	if _, ok, err := s.Get(ctx, "missing"); ok || err != nil {
+		t.Errorf("Get after an error reply = %v %v", ok, err)
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"+OK\r\n", "OK"},
+		{":42\r\n", "42"},
+		{"$3\r\nabc\r\n", "[97 98 99]"},
+		{"$-1\r\n", "<nil>"},
+		{"*2\r\n$1\r\na\r\n:1\r\n", "[[97] 1]"},
+		{"*-1\r\n", "<nil>"},
+	}
+	for _, tt := range tests {
+		got, err := readReply(bufio.NewReader(strings.NewReader(tt.in)))
+		if err != nil || fmt.Sprint(got) != tt.want {
+			t.Errorf("readReply(%q) = %v, %v; want %s", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "+OK\n", "$x\r\n", "$5\r\nab", "?1\r\n", "*x\r\n"} {
+		if _, err := readReply(bufio.NewReader(strings.NewReader(bad))); err == nil {
+			t.Errorf("readReply(%q): expected an error", bad)
+		}
+	}
+	if _, err := readReply(bufio.NewReader(strings.NewReader("-ERR boom\r\n"))); err == nil || err.Error() != "session: redis: ERR boom" {
+		t.Errorf("Expected an error reply, got %v", err)
+	}
+	if got := string(appendCommand(nil, []string{"GET", "k"})); got != "*2\r\n$3\r\nGET\r\n$1\r\nk\r\n" {
+		t.Errorf("appendCommand = %q", got)
+	}
+}
+
+func TestRedisStore_AbortedTransaction(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeRedis(t, "")
+	s := openTestRedisStore(t, f, RedisOptions{TTL: time.Hour})
+	rev, _ := s.CompareAndPut(ctx, "a", testValue{Score: 1}, 0)
+
+	// Another replica's Get slides the expiry between WATCH and EXEC: the key
+	// changed but its revision did not, so the write is retried, not refused.
+	aborts := 1
+	f.mu.Lock()
+	f.beforeExec = func() {
+		if aborts > 0 {
+			aborts--
+			f.vers["a"]++
+		}
+	}
+	f.cmds = nil
+	f.mu.Unlock()
+	rev, err := s.CompareAndPut(ctx, "a", testValue{Score: 2}, rev)
+	if err != nil {
+		t.Fatalf("Expected the retried write to succeed, got %v", err)
+	}
+	f.mu.Lock()
+	cmds := strings.Join(f.cmds, " ")
+	f.mu.Unlock()
+	if strings.Count(cmds, "EXEC") != 2 {
+		t.Errorf("Expected one aborted and one committed EXEC, got %s", cmds)
+	}
+
+	// A real write in between is a conflict.
+	f.mu.Lock()
+	f.beforeExec = func() {
+		f.data["a"] = fakeEntry{val: []byte(`{"rev":9,"v":{}}`)}
+		f.vers["a"]++
+		f.beforeExec = nil
+	}
+	f.mu.Unlock()
+	if _, err := s.CompareAndPut(ctx, "a", testValue{Score: 3}, rev); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+	if got, rev, _, _ := s.GetRev(ctx, "a"); rev != 9 || got.Score != 0 {
+		t.Errorf("Expected the other write to stand, got %+v at %d", got, rev)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := s.Put(ctx, "a", testValue{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled Put to stop, got %v", err)
+	}
+}
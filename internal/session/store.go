@@ -1,10 +1,59 @@
 package session
 
-import "context"
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrConflict is returned by CompareAndPut when the entry was written, or
+// deleted, since the revision it was given.
+var ErrConflict = errors.New("session: revision conflict")
 
 // Store defines the interface for session storage backends.
+//
+// Entries can expire: each has a TTL, and expiry is sliding, so a Get or Put
+// pushes it TTL into the future. An expired entry behaves as if deleted.
+//
+// Entries also have a revision, which every write increases. Read it with
+// GetRev and pass it to CompareAndPut to update an entry only if nobody else
+// has since; a missing entry is at revision 0.
+//
+// A store is closed when the server shuts down, after the last request has
+// finished, so that anything it buffers is written out.
 type Store[T any] interface {
+	// Get returns the value for id, resetting its expiry.
 	Get(ctx context.Context, id string) (T, bool, error)
+	// GetRev is Get that also returns the entry's revision.
+	GetRev(ctx context.Context, id string) (T, int64, bool, error)
+	// CompareAndPut stores v with the default TTL if id is still at revision
+	// rev, returning the new revision, and otherwise returns ErrConflict.
+	CompareAndPut(ctx context.Context, id string, v T, rev int64) (int64, error)
+	// Put stores v under id with the store's default TTL.
 	Put(ctx context.Context, id string, v T) error
+	// PutTTL stores v under id with its own TTL; 0 means it never expires.
+	PutTTL(ctx context.Context, id string, v T, ttl time.Duration) error
+	// Delete removes id. Deleting a missing ID is not an error.
+	Delete(ctx context.Context, id string) error
+	// List returns the IDs of the live entries, in no particular order.
+	List(ctx context.Context) ([]string, error)
 	NewID() string
+	// Ping reports whether the store can be reached, for readiness checks.
+	Ping(ctx context.Context) error
+	// Close flushes the store and releases its resources. Whether the store
+	// can be used afterwards depends on the store.
+	Close() error
+}
+
+// newID returns a random 32-character hex session ID.
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// Fallback: if crypto/rand fails, return a deterministic but unique ID
+		// This should never happen in practice, but we handle it gracefully
+		return hex.EncodeToString([]byte("fallback-id"))
+	}
+	return hex.EncodeToString(b)
 }
@@ -0,0 +1,234 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testValue is a struct so the suite checks that whole values round-trip.
+type testValue struct {
+	Name  string
+	Score int
+	Flags map[string]bool
+}
+
+// testStoreSuite runs the behaviour every Store must share against stores
+// made by newStore.
+func testStoreSuite(t *testing.T, newStore func(t *testing.T) Store[testValue]) {
+	ctx := context.Background()
+
+	t.Run("GetPut", func(t *testing.T) {
+		store := newStore(t)
+		want := testValue{Name: "Ann", Score: 3, Flags: map[string]bool{"key": true}}
+		if err := store.Put(ctx, "a", want); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		got, ok, err := store.Get(ctx, "a")
+		if err != nil || !ok {
+			t.Fatalf("Get = %v, %v", ok, err)
+		}
+		if got.Name != want.Name || got.Score != want.Score || !got.Flags["key"] {
+			t.Errorf("Get = %+v, want %+v", got, want)
+		}
+		if _, ok, err := store.Get(ctx, "missing"); ok || err != nil {
+			t.Errorf("Get(missing) = %v, %v", ok, err)
+		}
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		store := newStore(t)
+		for i := 1; i <= 3; i++ {
+			if err := store.Put(ctx, "a", testValue{Score: i}); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+		}
+		if got, _, _ := store.Get(ctx, "a"); got.Score != 3 {
+			t.Errorf("Expected the last value, got %+v", got)
+		}
+	})
+
+	t.Run("DeleteList", func(t *testing.T) {
+		store := newStore(t)
+		for _, id := range []string{"a", "b", "c"} {
+			if err := store.Put(ctx, id, testValue{Name: id}); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+		}
+		if err := store.Delete(ctx, "b"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if err := store.Delete(ctx, "missing"); err != nil {
+			t.Errorf("Delete(missing): %v", err)
+		}
+		if _, ok, err := store.Get(ctx, "b"); ok || err != nil {
+			t.Errorf("Get after Delete = %v, %v", ok, err)
+		}
+		ids, err := store.List(ctx)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		sort.Strings(ids)
+		if got := strings.Join(ids, ","); got != "a,c" {
+			t.Errorf("List = %s, want a,c", got)
+		}
+		// A deleted ID can be used again.
+		if err := store.Put(ctx, "b", testValue{Score: 2}); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if got, ok, _ := store.Get(ctx, "b"); !ok || got.Score != 2 {
+			t.Errorf("Get after re-Put = %+v, %v", got, ok)
+		}
+	})
+
+	t.Run("PutTTL", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.PutTTL(ctx, "a", testValue{Score: 1}, time.Hour); err != nil {
+			t.Fatalf("PutTTL: %v", err)
+		}
+		if err := store.PutTTL(ctx, "b", testValue{Score: 2}, 0); err != nil {
+			t.Fatalf("PutTTL: %v", err)
+		}
+		for id, want := range map[string]int{"a": 1, "b": 2} {
+			if got, ok, err := store.Get(ctx, id); !ok || err != nil || got.Score != want {
+				t.Errorf("Get(%s) = %+v, %v, %v", id, got, ok, err)
+			}
+		}
+	})
+
+	t.Run("CompareAndPut", func(t *testing.T) {
+		store := newStore(t)
+		if _, rev, ok, err := store.GetRev(ctx, "a"); ok || rev != 0 || err != nil {
+			t.Fatalf("GetRev(missing) = %d, %v, %v", rev, ok, err)
+		}
+		rev, err := store.CompareAndPut(ctx, "a", testValue{Score: 1}, 0)
+		if err != nil || rev == 0 {
+			t.Fatalf("CompareAndPut(new) = %d, %v", rev, err)
+		}
+		if _, err := store.CompareAndPut(ctx, "a", testValue{Score: 9}, 0); !errors.Is(err, ErrConflict) {
+			t.Errorf("Expected ErrConflict creating an existing entry, got %v", err)
+		}
+		if err := store.Put(ctx, "a", testValue{Score: 2}); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		got, rev2, ok, err := store.GetRev(ctx, "a")
+		if err != nil || !ok || got.Score != 2 || rev2 <= rev {
+			t.Fatalf("GetRev after Put = %+v, %d, %v, %v; want a revision after %d", got, rev2, ok, err, rev)
+		}
+		if _, err := store.CompareAndPut(ctx, "a", testValue{Score: 9}, rev); !errors.Is(err, ErrConflict) {
+			t.Errorf("Expected ErrConflict at a stale revision, got %v", err)
+		}
+		rev3, err := store.CompareAndPut(ctx, "a", testValue{Score: 3}, rev2)
+		if err != nil || rev3 <= rev2 {
+			t.Fatalf("CompareAndPut = %d, %v", rev3, err)
+		}
+		if got, _, _ := store.Get(ctx, "a"); got.Score != 3 {
+			t.Errorf("Expected the compared write, got %+v", got)
+		}
+		_ = store.Delete(ctx, "a")
+		if _, err := store.CompareAndPut(ctx, "a", testValue{Score: 4}, rev3); !errors.Is(err, ErrConflict) {
+			t.Errorf("Expected ErrConflict on a deleted entry, got %v", err)
+		}
+		rev4, err := store.CompareAndPut(ctx, "a", testValue{Score: 4}, 0)
+		if err != nil || rev4 <= rev3 {
+			t.Fatalf("CompareAndPut(recreate) = %d, %v; want a revision after %d", rev4, err, rev3)
+		}
+		// A revision read before the delete must not match the new entry.
+		if _, err := store.CompareAndPut(ctx, "a", testValue{Score: 9}, rev); !errors.Is(err, ErrConflict) {
+			t.Errorf("Expected ErrConflict at a revision from before the delete, got %v", err)
+		}
+	})
+
+	t.Run("CompareAndPutRace", func(t *testing.T) {
+		store := newStore(t)
+		rev, _ := store.CompareAndPut(ctx, "a", testValue{}, 0)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		wins := 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, err := store.CompareAndPut(ctx, "a", testValue{Score: i}, rev)
+				if err != nil && !errors.Is(err, ErrConflict) {
+					t.Errorf("CompareAndPut: %v", err)
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if err == nil {
+					wins++
+				}
+			}(i)
+		}
+		wg.Wait()
+		if wins != 1 {
+			t.Errorf("Expected exactly one write at the same revision to win, got %d", wins)
+		}
+	})
+
+	t.Run("NewID", func(t *testing.T) {
+		store := newStore(t)
+		ids := map[string]bool{}
+		for i := 0; i < 100; i++ {
+			id := store.NewID()
+			if ids[id] || len(id) != 32 {
+				t.Fatalf("Bad or duplicate ID %q", id)
+			}
+			ids[id] = true
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		store := newStore(t)
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				id := fmt.Sprintf("s%d", i)
+				for j := 0; j < 10; j++ {
+					if err := store.Put(ctx, id, testValue{Score: j}); err != nil {
+						t.Errorf("Put: %v", err)
+					}
+					if err := store.Put(ctx, "shared", testValue{Score: i}); err != nil {
+						t.Errorf("Put: %v", err)
+					}
+					if _, _, err := store.Get(ctx, "shared"); err != nil {
+						t.Errorf("Get: %v", err)
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+		for i := 0; i < 20; i++ {
+			if got, ok, _ := store.Get(ctx, fmt.Sprintf("s%d", i)); !ok || got.Score != 9 {
+				t.Errorf("s%d = %+v, %v; want the last write", i, got, ok)
+			}
+		}
+		if _, ok, _ := store.Get(ctx, "shared"); !ok {
+			t.Error("Expected the shared value to exist")
+		}
+	})
+
+	t.Run("PingClose", func(t *testing.T) {
+		store := newStore(t)
+		if err := store.Ping(ctx); err != nil {
+			t.Errorf("Ping: %v", err)
+		}
+		if err := store.Put(ctx, "a", testValue{Score: 1}); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if err := store.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	})
+}
+
+func TestMemoryStore_Suite(t *testing.T) {
+	testStoreSuite(t, func(*testing.T) Store[testValue] { return NewMemoryStore[testValue]() })
+}
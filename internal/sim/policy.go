@@ -0,0 +1,209 @@
+package sim
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"strings"
+
+	"adventure/internal/game"
+)
+
+// Policy names accepted by NewPolicy.
+const (
+	PolicyRandom   = "random"
+	PolicyGreedy   = "greedy"
+	PolicyScripted = "scripted"
+)
+
+const (
+	// greedySamples is how many times Greedy replays each move to estimate its value.
+	greedySamples = 6
+	// wrongAnswer is typed to take a prompt's default route.
+	wrongAnswer = "xyzzy"
+)
+
+// Move is one thing a player can submit at a node: a choice key (with the
+// battle action suffix for battles) and, for prompts, a typed answer.
+type Move struct {
+	Choice string // the node's choice key
+	Key    string // key passed to the engine, e.g. "fight:attack:0"
+	Answer string // typed answer for prompt choices
+	Battle bool
+}
+
+// Turn is what a policy sees when it has to pick a move.
+type Turn struct {
+	Engine *game.Engine
+	State  *game.PlayerState
+	Moves  []Move
+	Taken  map[string]int // node ID + "/" + move key -> times taken this run
+	Rand   *rand.Rand
+}
+
+// Policy picks the next move for a simulated player.
+type Policy interface {
+	Choose(t *Turn) Move
+}
+
+// NewPolicy returns the named policy. script is only used by the scripted
+// policy: comma-separated node=choice pairs, e.g. "camp=north,road=fight".
+func NewPolicy(name, script string) (Policy, error) {
+	switch name {
+	case PolicyRandom:
+		return Random{}, nil
+	case PolicyGreedy:
+		return Greedy{}, nil
+	case PolicyScripted:
+		return ParseScript(script)
+	default:
+		return nil, fmt.Errorf("unknown policy %q (want %s, %s or %s)", name, PolicyRandom, PolicyGreedy, PolicyScripted)
+	}
+}
+
+// Moves lists every move available to st at node: one per plain choice, an
+// attack and a luck attack per enemy (plus run, when the battle allows it) for
+// battles, and each listed answer plus a wrong one for prompts.
+func Moves(e *game.Engine, st *game.PlayerState, node *game.Node) []Move {
+	var out []Move
+	for _, ch := range e.AvailableChoices(st, node) {
+		switch {
+		case ch.Battle != nil:
+			enemies := len(st.Enemies)
+			if enemies == 0 {
+				enemies = 1
+			}
+			for i := 0; i < enemies; i++ {
+				out = append(out,
+					Move{Choice: ch.Key, Key: fmt.Sprintf("%s:attack:%d", ch.Key, i), Battle: true},
+					Move{Choice: ch.Key, Key: fmt.Sprintf("%s:luck:%d", ch.Key, i), Battle: true})
+			}
+			if ch.Next != "" {
+				out = append(out, Move{Choice: ch.Key, Key: ch.Key + ":run", Battle: true})
+			}
+		case ch.Prompt != nil:
+			for _, a := range ch.Prompt.Answers {
+				answer := a.Match
+				if answer == "" && len(a.Matches) > 0 {
+					answer = a.Matches[0]
+				}
+				if answer != "" && a.Next != "" {
+					out = append(out, Move{Choice: ch.Key, Key: ch.Key, Answer: answer})
+				}
+			}
+			if ch.Prompt.DefaultNext != "" || ch.Next != "" {
+				out = append(out, Move{Choice: ch.Key, Key: ch.Key, Answer: wrongAnswer})
+			}
+		default:
+			out = append(out, Move{Choice: ch.Key, Key: ch.Key})
+		}
+	}
+	return out
+}
+
+// Random picks uniformly among the available moves.
+type Random struct{}
+
+// Choose implements Policy.
+func (Random) Choose(t *Turn) Move {
+	return t.Moves[t.Rand.Intn(len(t.Moves))]
+}
+
+// Greedy replays every move a few times on a copy of the state and takes the
+// one with the best average outcome: surviving and finishing weigh most, then
+// health, strength and luck. Moves it has already taken at the node count
+// against them so it does not loop forever on a free bonus.
+type Greedy struct{}
+
+// Choose implements Policy.
+func (Greedy) Choose(t *Turn) Move {
+	best, bestScore := 0, 0.0
+	for i, m := range t.Moves {
+		total := 0.0
+		for n := 0; n < greedySamples; n++ {
+			after := cloneState(t.State)
+			if _, err := t.Engine.ApplyChoiceWithAnswer(after, m.Key, m.Answer); err != nil {
+				total -= 1000
+				continue
+			}
+			total += score(t.Engine, after)
+		}
+		s := total/greedySamples - 4*float64(t.Taken[t.State.NodeID+"/"+m.Key])
+		if i == 0 || s > bestScore {
+			best, bestScore = i, s
+		}
+	}
+	return t.Moves[best]
+}
+
+// score rates a state for Greedy.
+func score(e *game.Engine, st *game.PlayerState) float64 {
+	if st.NodeID == game.DeathNodeID || st.Stats.Health <= game.MinHealth {
+		return -100
+	}
+	v := float64(3*st.Stats.Health + st.Stats.Strength + st.Stats.Luck)
+	if n, err := e.CurrentNode(st); err == nil && n.Ending {
+		v += 30
+	}
+	return v
+}
+
+// Scripted takes the scripted choice at each node it lists (attacking the
+// first enemy in battles) and falls back to Random everywhere else.
+type Scripted struct {
+	Script map[string]string // node ID -> choice key
+}
+
+// ParseScript parses "node=choice,node=choice" into a Scripted policy.
+func ParseScript(s string) (Scripted, error) {
+	p := Scripted{Script: map[string]string{}}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		node, choice, ok := strings.Cut(pair, "=")
+		if !ok || node == "" || choice == "" {
+			return Scripted{}, fmt.Errorf("bad script entry %q (want node=choice)", pair)
+		}
+		p.Script[node] = choice
+	}
+	if len(p.Script) == 0 {
+		return Scripted{}, fmt.Errorf("scripted policy needs a script, e.g. camp=north,road=fight")
+	}
+	return p, nil
+}
+
+// Choose implements Policy.
+func (p Scripted) Choose(t *Turn) Move {
+	if want, ok := p.Script[t.State.NodeID]; ok {
+		for _, m := range t.Moves {
+			if m.Choice == want && (!m.Battle || strings.HasSuffix(m.Key, ":attack:0")) {
+				return m
+			}
+		}
+	}
+	return Random{}.Choose(t)
+}
+
+// cloneState deep-copies st so a replay cannot touch the original's maps or slices.
+func cloneState(st *game.PlayerState) *game.PlayerState {
+	c := *st
+	c.Flags = maps.Clone(st.Flags)
+	c.Visits = maps.Clone(st.Visits)
+	c.Taken = maps.Clone(st.Taken)
+	c.Defeated = maps.Clone(st.Defeated)
+	c.Enemies = slices.Clone(st.Enemies)
+	c.VisitedNodes = slices.Clip(st.VisitedNodes)
+	c.CallStack = slices.Clip(st.CallStack)
+	if st.Records != nil {
+		c.Records = make(map[string]game.StoryRecord, len(st.Records))
+		for k, r := range st.Records {
+			r.Endings = slices.Clip(r.Endings)
+			r.Achievements = slices.Clip(r.Achievements)
+			c.Records[k] = r
+		}
+	}
+	return &c
+}
@@ -0,0 +1,125 @@
+package sim
+
+import (
+	"math/rand"
+	"testing"
+
+	"adventure/internal/game"
+)
+
+func TestMoves(t *testing.T) {
+	node := &game.Node{Choices: []game.Choice{
+		{Key: "walk", Next: "b"},
+		{Key: "fight", Next: "b", Battle: &game.Battle{EnemyName: "Rat", EnemyHealth: 1}},
+		{Key: "ask", Prompt: &game.Prompt{Answers: []game.Answer{{Matches: []string{"echo"}, Next: "c"}}, DefaultNext: "d"}},
+		{Key: "hidden", Next: "b", If: &game.Condition{Flags: []string{"never"}}},
+	}}
+	engine := &game.Engine{}
+	st := game.NewPlayer("s", "a")
+	got := Moves(engine, &st, node)
+	want := []Move{
+		{Choice: "walk", Key: "walk"},
+		{Choice: "fight", Key: "fight:attack:0", Battle: true},
+		{Choice: "fight", Key: "fight:luck:0", Battle: true},
+		{Choice: "fight", Key: "fight:run", Battle: true},
+		{Choice: "ask", Key: "ask", Answer: "echo"},
+		{Choice: "ask", Key: "ask", Answer: wrongAnswer},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d moves, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Move %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	// During a battle each remaining enemy can be targeted.
+	st.Enemies = []game.EnemyState{{Name: "A", Health: 1}, {Name: "B", Health: 1}}
+	if n := len(Moves(engine, &st, &game.Node{Choices: node.Choices[1:2]})); n != 5 {
+		t.Errorf("Expected attack+luck for two enemies plus run, got %d moves", n)
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	for _, name := range []string{PolicyRandom, PolicyGreedy} {
+		if _, err := NewPolicy(name, ""); err != nil {
+			t.Errorf("NewPolicy(%q): %v", name, err)
+		}
+	}
+	p, err := NewPolicy(PolicyScripted, " camp=north , road=fight,")
+	if err != nil {
+		t.Fatalf("NewPolicy(scripted): %v", err)
+	}
+	if s := p.(Scripted); s.Script["camp"] != "north" || s.Script["road"] != "fight" {
+		t.Errorf("Unexpected script %v", s.Script)
+	}
+	for _, bad := range []string{"", "camp", "=north", "camp="} {
+		if _, err := NewPolicy(PolicyScripted, bad); err == nil {
+			t.Errorf("Expected an error for script %q", bad)
+		}
+	}
+	if _, err := NewPolicy("smart", ""); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
+
+func TestGreedy_AvoidsDeathAndLoops(t *testing.T) {
+	story := &game.Story{
+		Start: "camp",
+		Nodes: map[string]*game.Node{
+			"camp": {Text: "Camp", Choices: []game.Choice{
+				{Key: "cliff", Next: "camp", Effects: []game.Effect{{Op: game.OpAdd, Stat: game.StatHealth, Value: -50}}},
+				{Key: "rest", Next: "camp", Effects: []game.Effect{{Op: game.OpAdd, Stat: game.StatHealth, Value: 1}}},
+				{Key: "leave", Next: "home"},
+			}},
+			"home":  {Text: "Home", Ending: true},
+			"death": {Text: "Dead", Ending: true},
+		},
+	}
+	engine := &game.Engine{Stories: map[string]*game.Story{"s": story}, Dice: func() int { return 3 }}
+	st := game.NewPlayer("s", "camp")
+	taken := map[string]int{}
+	turn := func() Move {
+		node, _ := engine.CurrentNode(&st)
+		return Greedy{}.Choose(&Turn{Engine: engine, State: &st, Moves: Moves(engine, &st, node), Taken: taken, Rand: rand.New(rand.NewSource(1))})
+	}
+	original := st.Stats.Health
+	// Resting is worth +3 but finishing is worth +30: greedy leaves at once.
+	if m := turn(); m.Key != "leave" {
+		t.Errorf("Expected greedy to finish rather than rest or jump, got %q", m.Key)
+	}
+	if st.Stats.Health != original || st.NodeID != "camp" || len(st.VisitedNodes) != 1 {
+		t.Error("Expected replays not to change the real state")
+	}
+}
+
+func TestScripted_FallsBackToRandom(t *testing.T) {
+	moves := []Move{{Choice: "a", Key: "a"}, {Choice: "fight", Key: "fight:luck:0", Battle: true}, {Choice: "fight", Key: "fight:attack:0", Battle: true}}
+	st := game.NewPlayer("s", "n")
+	turn := &Turn{State: &st, Moves: moves, Rand: rand.New(rand.NewSource(1))}
+	if m := (Scripted{Script: map[string]string{"n": "fight"}}).Choose(turn); m.Key != "fight:attack:0" {
+		t.Errorf("Expected scripted battle to attack the first enemy, got %q", m.Key)
+	}
+	m := (Scripted{Script: map[string]string{"other": "x"}}).Choose(turn)
+	if m.Key == "" {
+		t.Error("Expected a random fallback move")
+	}
+}
+
+func TestCloneState_Independent(t *testing.T) {
+	st := game.NewPlayer("s", "a")
+	st.Flags["x"] = true
+	st.Enemies = []game.EnemyState{{Name: "Rat", Health: 2}}
+	st.Records = map[string]game.StoryRecord{"s": {Endings: []string{"e"}}}
+	c := cloneState(&st)
+	c.Flags["y"] = true
+	c.Enemies[0].Health = 0
+	c.VisitedNodes = append(c.VisitedNodes, "b")
+	rec := c.Records["s"]
+	rec.Endings = append(rec.Endings, "f")
+	c.Records["s"] = rec
+	if st.Flags["y"] || st.Enemies[0].Health != 2 || len(st.VisitedNodes) != 1 || len(st.Records["s"].Endings) != 1 {
+		t.Errorf("Expected the original untouched, got %+v", st)
+	}
+}
@@ -0,0 +1,66 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteTable writes the report as aligned plain-text tables.
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Story: %s\tPolicy: %s\tRuns: %d", r.Story, r.Policy, r.Runs)
+	if r.Difficulty != "" {
+		fmt.Fprintf(tw, "\tDifficulty: %s", r.Difficulty)
+	}
+	fmt.Fprintf(tw, "\n\n")
+
+	fmt.Fprintf(tw, "Outcome\tRuns\tRate\n")
+	fmt.Fprintf(tw, "win\t%d\t%s\n", r.Wins, pct(r.WinRate))
+	fmt.Fprintf(tw, "death\t%d\t%s\n", r.Deaths, pct(r.DeathRate))
+	fmt.Fprintf(tw, "stuck\t%d\t%s\n", r.Stuck, pct(rate(r.Stuck, r.Runs)))
+	fmt.Fprintf(tw, "Average steps: %.1f\n\n", r.AvgSteps)
+
+	fmt.Fprintf(tw, "Ending\tRuns\tRate\n")
+	for _, e := range r.Endings {
+		name := e.Node
+		if e.Death {
+			name += " (death)"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", name, e.Count, pct(e.Rate))
+	}
+
+	if len(r.Deadliest) > 0 {
+		fmt.Fprintf(tw, "\nDeadliest node\tDeaths\tShare\n")
+		for _, n := range r.Deadliest {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", n.Node, n.Deaths, pct(n.Rate))
+		}
+	}
+
+	if len(r.Battles) > 0 {
+		fmt.Fprintf(tw, "\nBattle\tFought\tWon\tLost\tFled\tAvg health left\n")
+		for _, b := range r.Battles {
+			fmt.Fprintf(tw, "%s/%s\t%d\t%d\t%d\t%d\t%.1f\n", b.Node, b.Choice, b.Fought, b.Won, b.Lost, b.Fled, b.AvgHealthLeft)
+		}
+	}
+	return tw.Flush()
+}
+
+func rate(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return float64(n) / float64(of)
+}
+
+func pct(f float64) string {
+	return fmt.Sprintf("%.1f%%", 100*f)
+}
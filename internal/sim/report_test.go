@@ -0,0 +1,58 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func sampleReport() *Report {
+	return &Report{
+		Story: "demo", Policy: PolicyGreedy, Difficulty: "hard", Runs: 10,
+		Wins: 6, Deaths: 3, Stuck: 1, WinRate: 0.6, DeathRate: 0.3, AvgSteps: 4.25,
+		Endings:   []EndingStat{{Node: "clearing", Count: 6, Rate: 0.6}, {Node: "death", Count: 3, Rate: 0.3, Death: true}},
+		Deadliest: []NodeStat{{Node: "road", Deaths: 3, Rate: 1}},
+		Battles:   []BattleStat{{Node: "road", Choice: "fight", Fought: 5, Won: 2, Lost: 3, AvgHealthLeft: 7.5}},
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	var buf bytes.Buffer
+	if err := sampleReport().WriteTable(&buf); err != nil {
+		t.Fatalf("WriteTable: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"Story: demo", "Policy: greedy", "Difficulty: hard",
+		"win      6     60.0%",
+		"stuck    1     10.0%",
+		"Average steps: 4.2",
+		"death (death)",
+		"Deadliest node",
+		"road/fight",
+		"7.5",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected table to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := sampleReport().WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got["winRate"] != 0.6 || got["policy"] != PolicyGreedy {
+		t.Errorf("Unexpected JSON %v", got)
+	}
+	battles, ok := got["battles"].([]any)
+	if !ok || len(battles) != 1 || battles[0].(map[string]any)["avgHealthLeft"] != 7.5 {
+		t.Errorf("Expected battle stats in JSON, got %v", got["battles"])
+	}
+}
@@ -0,0 +1,260 @@
+// Package sim plays a story many times with a choice policy to measure its
+// balance: how often runs end in each ending or in death, where players die,
+// how long runs take and how much health is left after each battle.
+package sim
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"adventure/internal/game"
+)
+
+// DefaultMaxSteps caps a single run; runs that hit it are reported as stuck.
+const DefaultMaxSteps = 500
+
+// Config controls a simulation.
+type Config struct {
+	StoryID    string
+	Runs       int
+	Policy     Policy
+	PolicyName string // for the report
+	Difficulty string // preset ID; empty = story default
+	MaxSteps   int    // 0 = DefaultMaxSteps
+	Seed       int64  // seeds check and battle dice; 0 = time-based
+	// RollStats rolls starting stats; nil uses game.RollStatsDetailed.
+	RollStats func() game.Stats
+}
+
+// Report is the aggregated result of a simulation.
+type Report struct {
+	Story      string       `json:"story"`
+	Policy     string       `json:"policy"`
+	Difficulty string       `json:"difficulty,omitempty"`
+	Runs       int          `json:"runs"`
+	Wins       int          `json:"wins"`
+	Deaths     int          `json:"deaths"`
+	Stuck      int          `json:"stuck"` // hit the step cap or had no moves left
+	WinRate    float64      `json:"winRate"`
+	DeathRate  float64      `json:"deathRate"`
+	AvgSteps   float64      `json:"avgSteps"`
+	Endings    []EndingStat `json:"endings"`
+	Deadliest  []NodeStat   `json:"deadliest"`
+	Battles    []BattleStat `json:"battles"`
+	stepsTotal int
+}
+
+// EndingStat counts runs that finished on one ending node.
+type EndingStat struct {
+	Node  string  `json:"node"`
+	Count int     `json:"count"`
+	Rate  float64 `json:"rate"`
+	Death bool    `json:"death"`
+}
+
+// NodeStat counts deaths caused by a move made at a node.
+type NodeStat struct {
+	Node   string  `json:"node"`
+	Deaths int     `json:"deaths"`
+	Rate   float64 `json:"rate"` // share of all deaths
+}
+
+// BattleStat summarises one battle choice (node and choice key).
+type BattleStat struct {
+	Node            string  `json:"node"`
+	Choice          string  `json:"choice"`
+	Fought          int     `json:"fought"`
+	Won             int     `json:"won"`
+	Lost            int     `json:"lost"`
+	Fled            int     `json:"fled"`
+	AvgHealthLeft   float64 `json:"avgHealthLeft"` // after victories
+	healthLeftTotal int
+}
+
+// outcome of a single run.
+type outcome struct {
+	ending    string // final node when it is an ending
+	died      bool
+	stuck     bool
+	steps     int
+	fatalNode string
+}
+
+// Run plays cfg.Runs runs of the story and aggregates the results.
+func Run(e *game.Engine, cfg Config) (*Report, error) {
+	story := e.Stories[cfg.StoryID]
+	if story == nil {
+		return nil, fmt.Errorf("unknown story: %s", cfg.StoryID)
+	}
+	if cfg.Policy == nil {
+		return nil, fmt.Errorf("no policy")
+	}
+	if cfg.MaxSteps <= 0 {
+		cfg.MaxSteps = DefaultMaxSteps
+	}
+	if cfg.RollStats == nil {
+		cfg.RollStats = func() game.Stats {
+			s, _ := game.RollStatsDetailed()
+			return s
+		}
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed)) //nolint:gosec // simulation dice, not security sensitive
+	// Play on a copy of the engine so the caller's dice are untouched.
+	simEngine := &game.Engine{Stories: e.Stories, Dice: func() int { return rng.Intn(6) + 1 }}
+
+	rep := &Report{Story: cfg.StoryID, Policy: cfg.PolicyName, Difficulty: cfg.Difficulty, Runs: cfg.Runs}
+	endings := map[string]*EndingStat{}
+	fatal := map[string]int{}
+	battles := map[string]*BattleStat{}
+	for i := 0; i < cfg.Runs; i++ {
+		o, err := playOnce(simEngine, story, &cfg, rng, battles)
+		if err != nil {
+			return nil, err
+		}
+		rep.stepsTotal += o.steps
+		switch {
+		case o.died:
+			rep.Deaths++
+			fatal[o.fatalNode]++
+		case o.stuck:
+			rep.Stuck++
+		default:
+			rep.Wins++
+		}
+		if o.ending != "" {
+			es := endings[o.ending]
+			if es == nil {
+				es = &EndingStat{Node: o.ending, Death: o.died}
+				endings[o.ending] = es
+			}
+			es.Count++
+		}
+	}
+	rep.finish(endings, fatal, battles)
+	return rep, nil
+}
+
+// playOnce plays a single run, updating the per-battle stats as it goes.
+func playOnce(e *game.Engine, story *game.Story, cfg *Config, rng *rand.Rand, battles map[string]*BattleStat) (outcome, error) {
+	st := game.NewPlayer(cfg.StoryID, story.Start)
+	st.Difficulty = cfg.Difficulty
+	st.BaseStats = cfg.RollStats()
+	st.Stats = story.ResolveDifficulty(cfg.Difficulty).ApplyToStats(st.BaseStats)
+	st.MarkBegun()
+
+	var o outcome
+	taken := map[string]int{}
+	var battle *BattleStat // battle in progress
+	for o.steps < cfg.MaxSteps {
+		node, err := e.CurrentNode(&st)
+		if err != nil {
+			return o, err
+		}
+		if node.Ending {
+			o.ending = st.NodeID
+			break
+		}
+		moves := Moves(e, &st, node)
+		if len(moves) == 0 {
+			break
+		}
+		m := cfg.Policy.Choose(&Turn{Engine: e, State: &st, Moves: moves, Taken: taken, Rand: rng})
+		from := st.NodeID
+		res, err := e.ApplyChoiceWithAnswer(&st, m.Key, m.Answer)
+		if err != nil {
+			return o, err
+		}
+		if res.ErrorMessage != "" {
+			return o, fmt.Errorf("policy chose %q at %s: %s", m.Key, from, res.ErrorMessage)
+		}
+		o.steps++
+		taken[from+"/"+m.Key]++
+
+		if m.Battle && battle == nil {
+			key := from + "/" + m.Choice
+			battle = battles[key]
+			if battle == nil {
+				battle = &BattleStat{Node: from, Choice: m.Choice}
+				battles[key] = battle
+			}
+			battle.Fought++
+		}
+		if battle != nil && len(st.Enemies) == 0 {
+			switch {
+			case st.Stats.Health <= game.MinHealth:
+				battle.Lost++
+			case m.Battle && m.Key == m.Choice+":run", !m.Battle:
+				battle.Fled++
+			default:
+				battle.Won++
+				battle.healthLeftTotal += st.Stats.Health
+			}
+			battle = nil
+		}
+
+		if st.NodeID == game.DeathNodeID || st.Stats.Health <= game.MinHealth {
+			o.died = true
+			o.fatalNode = from
+			if n, err := e.CurrentNode(&st); err == nil && n.Ending {
+				o.ending = st.NodeID
+			}
+			return o, nil
+		}
+	}
+	o.stuck = o.ending == ""
+	return o, nil
+}
+
+// finish computes rates and sorts the breakdowns (most frequent first).
+func (r *Report) finish(endings map[string]*EndingStat, fatal map[string]int, battles map[string]*BattleStat) {
+	if r.Runs > 0 {
+		r.WinRate = float64(r.Wins) / float64(r.Runs)
+		r.DeathRate = float64(r.Deaths) / float64(r.Runs)
+		r.AvgSteps = float64(r.stepsTotal) / float64(r.Runs)
+	}
+	for _, es := range endings {
+		es.Rate = float64(es.Count) / float64(r.Runs)
+		r.Endings = append(r.Endings, *es)
+	}
+	sort.Slice(r.Endings, func(i, j int) bool {
+		if r.Endings[i].Count != r.Endings[j].Count {
+			return r.Endings[i].Count > r.Endings[j].Count
+		}
+		return r.Endings[i].Node < r.Endings[j].Node
+	})
+	for node, n := range fatal {
+		r.Deadliest = append(r.Deadliest, NodeStat{Node: node, Deaths: n, Rate: float64(n) / float64(r.Deaths)})
+	}
+	sort.Slice(r.Deadliest, func(i, j int) bool {
+		if r.Deadliest[i].Deaths != r.Deadliest[j].Deaths {
+			return r.Deadliest[i].Deaths > r.Deadliest[j].Deaths
+		}
+		return r.Deadliest[i].Node < r.Deadliest[j].Node
+	})
+	for _, b := range battles {
+		if b.Won > 0 {
+			b.AvgHealthLeft = float64(b.healthLeftTotal) / float64(b.Won)
+		}
+		r.Battles = append(r.Battles, *b)
+	}
+	sort.Slice(r.Battles, func(i, j int) bool {
+		li, lj := r.Battles[i].lossRate(), r.Battles[j].lossRate()
+		if li != lj {
+			return li > lj
+		}
+		return r.Battles[i].Node+r.Battles[i].Choice < r.Battles[j].Node+r.Battles[j].Choice
+	})
+}
+
+func (b *BattleStat) lossRate() float64 {
+	if b.Fought == 0 {
+		return 0
+	}
+	return float64(b.Lost) / float64(b.Fought)
+}
@@ -0,0 +1,123 @@
+package sim
+
+import (
+	"testing"
+
+	"adventure/internal/game"
+)
+
+// arenaEngine has one fork: a safe path to an ending and an arena fight that
+// a strong hero always wins and a weak one always loses.
+func arenaEngine(enemyStrength int) *game.Engine {
+	story := &game.Story{
+		Start: "gate",
+		Nodes: map[string]*game.Node{
+			"gate": {Text: "Gate", Choices: []game.Choice{
+				{Key: "safe", Next: "home"},
+				{Key: "arena", Next: "arena"},
+			}},
+			"arena": {Text: "Arena", Choices: []game.Choice{
+				{Key: "fight", Battle: &game.Battle{
+					Enemies:       []game.Enemy{{Name: "Champion", Strength: enemyStrength, Health: 2}},
+					OnVictoryNext: "glory",
+				}},
+			}},
+			"home":  {Text: "Home", Ending: true},
+			"glory": {Text: "Glory", Ending: true},
+			"death": {Text: "Dead", Ending: true},
+		},
+	}
+	return &game.Engine{Stories: map[string]*game.Story{"arena": story}}
+}
+
+func fixedStats(s game.Stats) func() game.Stats {
+	return func() game.Stats { return s }
+}
+
+func TestRun_ScriptedArenaVictory(t *testing.T) {
+	policy, err := ParseScript("gate=arena,arena=fight")
+	if err != nil {
+		t.Fatalf("ParseScript: %v", err)
+	}
+	rep, err := Run(arenaEngine(0), Config{
+		StoryID:    "arena",
+		Runs:       50,
+		Policy:     policy,
+		PolicyName: PolicyScripted,
+		Seed:       1,
+		RollStats:  fixedStats(game.Stats{Strength: 18, Luck: 6, Health: 10}),
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// 18 + 2d6 always beats 0 + 2d6, so every run wins in two rounds.
+	if rep.Wins != 50 || rep.Deaths != 0 || rep.WinRate != 1 {
+		t.Errorf("Expected all wins, got %+v", rep)
+	}
+	if len(rep.Endings) != 1 || rep.Endings[0].Node != "glory" || rep.Endings[0].Rate != 1 {
+		t.Errorf("Expected every run to end in glory, got %+v", rep.Endings)
+	}
+	if rep.AvgSteps != 3 {
+		t.Errorf("Expected 3 steps per run (arena, two rounds), got %.2f", rep.AvgSteps)
+	}
+	if len(rep.Battles) != 1 {
+		t.Fatalf("Expected one battle, got %+v", rep.Battles)
+	}
+	b := rep.Battles[0]
+	if b.Node != "arena" || b.Choice != "fight" || b.Fought != 50 || b.Won != 50 || b.AvgHealthLeft != 10 {
+		t.Errorf("Unexpected battle stats %+v", b)
+	}
+}
+
+func TestRun_ScriptedArenaDeath(t *testing.T) {
+	policy, err := ParseScript("gate=arena,arena=fight")
+	if err != nil {
+		t.Fatalf("ParseScript: %v", err)
+	}
+	rep, err := Run(arenaEngine(30), Config{
+		StoryID:   "arena",
+		Runs:      20,
+		Policy:    policy,
+		Seed:      1,
+		RollStats: fixedStats(game.Stats{Strength: 1, Luck: 6, Health: 3}),
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if rep.Deaths != 20 || rep.DeathRate != 1 {
+		t.Errorf("Expected every run to die, got %+v", rep)
+	}
+	if len(rep.Deadliest) != 1 || rep.Deadliest[0].Node != "arena" || rep.Deadliest[0].Rate != 1 {
+		t.Errorf("Expected the arena as the deadliest node, got %+v", rep.Deadliest)
+	}
+	if len(rep.Endings) != 1 || !rep.Endings[0].Death {
+		t.Errorf("Expected the death ending recorded as death, got %+v", rep.Endings)
+	}
+	if rep.Battles[0].Lost != 20 {
+		t.Errorf("Expected 20 lost battles, got %+v", rep.Battles[0])
+	}
+}
+
+func TestRun_StuckAndErrors(t *testing.T) {
+	story := &game.Story{
+		Start: "loop",
+		Nodes: map[string]*game.Node{
+			"loop": {Text: "Loop", Choices: []game.Choice{{Key: "again", Next: "loop"}}},
+		},
+	}
+	engine := &game.Engine{Stories: map[string]*game.Story{"loop": story}}
+	rep, err := Run(engine, Config{StoryID: "loop", Runs: 3, Policy: Random{}, MaxSteps: 10, Seed: 1})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if rep.Stuck != 3 || rep.AvgSteps != 10 {
+		t.Errorf("Expected 3 stuck runs of 10 steps, got %+v", rep)
+	}
+
+	if _, err := Run(engine, Config{StoryID: "missing", Runs: 1, Policy: Random{}}); err == nil {
+		t.Error("Expected an error for an unknown story")
+	}
+	if _, err := Run(engine, Config{StoryID: "loop", Runs: 1}); err == nil {
+		t.Error("Expected an error without a policy")
+	}
+}
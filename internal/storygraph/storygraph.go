@@ -0,0 +1,282 @@
+// Package storygraph renders a story's node graph for review: Graphviz DOT,
+// Mermaid flowcharts, and a self-contained SVG that needs no external tools.
+// Edges are coloured and labelled by kind; endings, battles and nodes
+// unreachable from the start are highlighted.
+package storygraph
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"adventure/internal/game"
+)
+
+// Format names accepted by Render.
+const (
+	FormatDOT     = "dot"
+	FormatMermaid = "mermaid"
+	FormatSVG     = "svg"
+)
+
+// edgeColors maps each game.Edge kind to its stroke colour.
+var edgeColors = map[string]string{
+	game.EdgeNext:          "#4a90d9",
+	game.EdgeSuccess:       "#2e9e44",
+	game.EdgeFailure:       "#d9822b",
+	game.EdgeVictory:       "#1f7a1f",
+	game.EdgeDefeat:        "#c0392b",
+	game.EdgeAnswer:        "#8e44ad",
+	game.EdgeDefaultAnswer: "#b07cc6",
+	game.EdgeCall:          "#16a085",
+	game.EdgeDeath:         "#555555",
+}
+
+// edgeKinds lists the edge kinds in legend order.
+var edgeKinds = []string{
+	game.EdgeNext, game.EdgeSuccess, game.EdgeFailure, game.EdgeVictory, game.EdgeDefeat,
+	game.EdgeAnswer, game.EdgeDefaultAnswer, game.EdgeCall, game.EdgeDeath,
+}
+
+// Node fill colours by highlight.
+const (
+	fillPlain       = "#ffffff"
+	fillEnding      = "#d4f4d4"
+	fillBattle      = "#f8d0d0"
+	fillDeath       = "#444444"
+	fillUnreachable = "#eeeeee"
+	colorMuted      = "#999999"
+)
+
+// Node is a story node with the highlights used when rendering.
+type Node struct {
+	ID          string
+	Rank        int // breadth-first depth from the start; unreachable nodes sit below the rest
+	Start       bool
+	Ending      bool
+	Battle      bool
+	Death       bool
+	Unreachable bool
+}
+
+// Graph is a story prepared for rendering.
+type Graph struct {
+	Title string
+	Nodes []Node // sorted by Rank, then ID
+	Edges []game.Edge
+}
+
+// Build prepares s for rendering. Edges to undefined nodes are kept so that
+// broken links show up; the missing targets are added as unreachable nodes.
+func Build(s *game.Story, title string) *Graph {
+	g := &Graph{Title: title}
+	if s == nil {
+		return g
+	}
+	g.Edges = s.Edges()
+	reachable := s.Reachable()
+
+	ranks := map[string]int{}
+	if reachable[s.Start] {
+		ranks[s.Start] = 0
+		queue := []string{s.Start}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			for _, e := range g.Edges {
+				if _, ok := ranks[e.To]; e.From == id && !ok && reachable[e.To] {
+					ranks[e.To] = ranks[id] + 1
+					queue = append(queue, e.To)
+				}
+			}
+		}
+	}
+	maxRank := -1
+	for _, r := range ranks {
+		if r > maxRank {
+			maxRank = r
+		}
+	}
+
+	ids := map[string]bool{}
+	for id := range s.Nodes {
+		ids[id] = true
+	}
+	for _, e := range g.Edges {
+		ids[e.To] = true
+	}
+	for id := range ids {
+		n := Node{ID: id, Start: id == s.Start, Death: id == game.DeathNodeID, Unreachable: !reachable[id]}
+		if r, ok := ranks[id]; ok {
+			n.Rank = r
+		} else {
+			n.Rank = maxRank + 1
+		}
+		if sn := s.Nodes[id]; sn != nil {
+			n.Ending = sn.Ending
+			for i := range sn.Choices {
+				if sn.Choices[i].Battle != nil {
+					n.Battle = true
+				}
+			}
+		}
+		g.Nodes = append(g.Nodes, n)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		if g.Nodes[i].Rank != g.Nodes[j].Rank {
+			return g.Nodes[i].Rank < g.Nodes[j].Rank
+		}
+		return g.Nodes[i].ID < g.Nodes[j].ID
+	})
+	return g
+}
+
+// Render writes g to w in the given format.
+func Render(w io.Writer, g *Graph, format string) error {
+	switch format {
+	case FormatDOT:
+		return DOT(w, g)
+	case FormatMermaid:
+		return Mermaid(w, g)
+	case FormatSVG:
+		return SVG(w, g)
+	default:
+		return fmt.Errorf("unknown format %q (want %s, %s or %s)", format, FormatDOT, FormatMermaid, FormatSVG)
+	}
+}
+
+// fill returns the node's fill colour. Death wins over unreachable, then ending, then battle.
+func (n *Node) fill() string {
+	switch {
+	case n.Death:
+		return fillDeath
+	case n.Unreachable:
+		return fillUnreachable
+	case n.Ending:
+		return fillEnding
+	case n.Battle:
+		return fillBattle
+	default:
+		return fillPlain
+	}
+}
+
+// textColor returns the colour for the node's label.
+func (n *Node) textColor() string {
+	switch {
+	case n.Death:
+		return "#ffffff"
+	case n.Unreachable:
+		return colorMuted
+	default:
+		return "#000000"
+	}
+}
+
+// DOT writes g as a Graphviz digraph.
+func DOT(w io.Writer, g *Graph) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(g.Title))
+	b.WriteString("  rankdir=TB;\n")
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fontname=\"Helvetica\"];\n")
+	b.WriteString("  edge [fontname=\"Helvetica\", fontsize=10];\n")
+	for i := range g.Nodes {
+		n := &g.Nodes[i]
+		attrs := []string{
+			"fillcolor=" + dotQuote(n.fill()),
+			"fontcolor=" + dotQuote(n.textColor()),
+		}
+		if n.Ending {
+			attrs = append(attrs, "peripheries=2")
+		}
+		if n.Start {
+			attrs = append(attrs, "penwidth=2")
+		}
+		if n.Unreachable {
+			attrs = append(attrs, "style=\"rounded,filled,dashed\"", "color="+dotQuote(colorMuted))
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(n.ID), strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		c := edgeColors[e.Kind]
+		attrs := []string{"color=" + dotQuote(c), "fontcolor=" + dotQuote(c)}
+		attrs = append(attrs, "label="+dotQuote(edgeLabel(e)))
+		if e.Kind == game.EdgeDeath {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", dotQuote(e.From), dotQuote(e.To), strings.Join(attrs, ", "))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Mermaid writes g as a Mermaid flowchart.
+func Mermaid(w io.Writer, g *Graph) error {
+	var b strings.Builder
+	if g.Title != "" {
+		fmt.Fprintf(&b, "---\ntitle: %s\n---\n", g.Title)
+	}
+	b.WriteString("flowchart TD\n")
+	ids := make(map[string]string, len(g.Nodes))
+	for i := range g.Nodes {
+		ids[g.Nodes[i].ID] = fmt.Sprintf("n%d", i)
+	}
+	classes := map[string][]string{}
+	for i := range g.Nodes {
+		n := &g.Nodes[i]
+		open, closing := "[", "]"
+		if n.Ending {
+			open, closing = "([", "])"
+		}
+		fmt.Fprintf(&b, "  %s%s\"%s\"%s\n", ids[n.ID], open, mermaidEscape(n.ID), closing)
+		switch {
+		case n.Death:
+			classes["death"] = append(classes["death"], ids[n.ID])
+		case n.Unreachable:
+			classes["unreachable"] = append(classes["unreachable"], ids[n.ID])
+		case n.Ending:
+			classes["ending"] = append(classes["ending"], ids[n.ID])
+		case n.Battle:
+			classes["battle"] = append(classes["battle"], ids[n.ID])
+		}
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Kind == game.EdgeDeath {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  %s %s|\"%s\"| %s\n", ids[e.From], arrow, mermaidEscape(edgeLabel(e)), ids[e.To])
+	}
+	for i, e := range g.Edges {
+		fmt.Fprintf(&b, "  linkStyle %d stroke:%s,color:%s\n", i, edgeColors[e.Kind], edgeColors[e.Kind])
+	}
+	fmt.Fprintf(&b, "  classDef ending fill:%s\n", fillEnding)
+	fmt.Fprintf(&b, "  classDef battle fill:%s\n", fillBattle)
+	fmt.Fprintf(&b, "  classDef death fill:%s,color:#ffffff\n", fillDeath)
+	fmt.Fprintf(&b, "  classDef unreachable fill:%s,color:%s,stroke-dasharray:4 3\n", fillUnreachable, colorMuted)
+	for _, name := range []string{"ending", "battle", "death", "unreachable"} {
+		if len(classes[name]) > 0 {
+			fmt.Fprintf(&b, "  class %s %s\n", strings.Join(classes[name], ","), name)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// edgeLabel is the text shown on an edge: the kind, plus the choice key or answer.
+func edgeLabel(e game.Edge) string {
+	if e.Label == "" {
+		return e.Kind
+	}
+	return e.Kind + ": " + e.Label
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "\n", " ").Replace(s)
+}
@@ -0,0 +1,111 @@
+package storygraph
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"adventure/internal/game"
+)
+
+func testStory() *game.Story {
+	return &game.Story{
+		Start: "camp",
+		Nodes: map[string]*game.Node{
+			"camp": {Text: "Camp", Choices: []game.Choice{
+				{Key: "go", Next: "road"},
+				{Key: "lost", Next: "nowhere"},
+			}},
+			"road": {Text: "Road", Choices: []game.Choice{
+				{Key: "fight", Next: "camp", Battle: &game.Battle{OnVictoryNext: "home", OnDefeatNext: "death"}},
+			}},
+			"home":   {Text: "Home", Ending: true},
+			"death":  {Text: "Dead", Ending: true},
+			"hidden": {Text: "Hidden", Ending: true},
+		},
+	}
+}
+
+func findNode(t *testing.T, g *Graph, id string) Node {
+	t.Helper()
+	for _, n := range g.Nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+	t.Fatalf("node %q not in graph", id)
+	return Node{}
+}
+
+func TestBuild(t *testing.T) {
+	g := Build(testStory(), "Test")
+	if g.Nodes[0].ID != "camp" || !g.Nodes[0].Start || g.Nodes[0].Rank != 0 {
+		t.Errorf("Expected start node first at rank 0, got %+v", g.Nodes[0])
+	}
+	if n := findNode(t, g, "road"); !n.Battle || n.Rank != 1 {
+		t.Errorf("Expected road as a rank-1 battle, got %+v", n)
+	}
+	if n := findNode(t, g, "home"); !n.Ending || n.Unreachable || n.Rank != 2 {
+		t.Errorf("Expected home as a reachable rank-2 ending, got %+v", n)
+	}
+	if n := findNode(t, g, "death"); !n.Death {
+		t.Errorf("Expected death highlighted, got %+v", n)
+	}
+	if n := findNode(t, g, "hidden"); !n.Unreachable || n.Rank != 3 {
+		t.Errorf("Expected hidden unreachable below the rest, got %+v", n)
+	}
+	if n := findNode(t, g, "nowhere"); !n.Unreachable {
+		t.Errorf("Expected broken link target shown as unreachable, got %+v", n)
+	}
+	if empty := Build(nil, "x"); len(empty.Nodes) != 0 || empty.Title != "x" {
+		t.Errorf("Expected empty graph for nil story, got %+v", empty)
+	}
+}
+
+func TestDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, Build(testStory(), `The "Test"`), FormatDOT); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`digraph "The \"Test\"" {`,
+		`"camp" [fillcolor="#ffffff", fontcolor="#000000", penwidth=2];`,
+		`"hidden" [fillcolor="#eeeeee"`,
+		`"road" -> "home" [color="#1f7a1f", fontcolor="#1f7a1f", label="victory: fight"];`,
+		`"road" -> "death" [color="#555555", fontcolor="#555555", label="death", style=dashed];`,
+		`"road" -> "camp" [color="#4a90d9", fontcolor="#4a90d9", label="next: fight"];`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected DOT to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestMermaid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, Build(testStory(), "Test"), FormatMermaid); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"title: Test",
+		"flowchart TD",
+		`n0["camp"]`,
+		`(["home"])`,
+		`-->|"next: go"|`,
+		`-.->|"death"|`,
+		"linkStyle 0 stroke:",
+		"class ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected Mermaid to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestRender_UnknownFormat(t *testing.T) {
+	if err := Render(&bytes.Buffer{}, Build(testStory(), "Test"), "png"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
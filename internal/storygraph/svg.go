@@ -0,0 +1,173 @@
+package storygraph
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strings"
+
+	"adventure/internal/game"
+)
+
+// SVG layout, in user units.
+const (
+	svgMargin     = 24.0
+	svgNodeHeight = 30.0
+	svgCharWidth  = 7.5
+	svgNodePad    = 16.0
+	svgGapX       = 28.0
+	svgRowHeight  = 90.0
+	svgTitleSize  = 16.0
+	svgLegendRow  = 18.0
+)
+
+// box is a node's laid-out rectangle.
+type box struct {
+	x, y, w, h float64
+}
+
+func (b box) cx() float64 { return b.x + b.w/2 }
+func (b box) cy() float64 { return b.y + b.h/2 }
+
+// SVG writes g as a standalone SVG image. Nodes are laid out in rows by rank
+// (start at the top); edges to the same or an earlier row curve around the side.
+func SVG(w io.Writer, g *Graph) error {
+	boxes, width, height := layout(g)
+	top := svgMargin
+	if g.Title != "" {
+		top += svgTitleSize + 8
+	}
+	legendTop := top + height + 12
+	totalW := math.Max(width, 220) + 2*svgMargin
+	totalH := legendTop + float64(len(edgeKinds))*svgLegendRow + svgMargin
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="Helvetica, Arial, sans-serif">`+"\n", totalW, totalH, totalW, totalH)
+	b.WriteString("<defs>\n")
+	for _, k := range edgeKinds {
+		fmt.Fprintf(&b, `  <marker id="arrow-%s" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="7" markerHeight="7" orient="auto-start-reverse"><path d="M0,0 L10,5 L0,10 z" fill="%s"/></marker>`+"\n", k, edgeColors[k])
+	}
+	b.WriteString("</defs>\n")
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="#fafafa"/>`+"\n")
+	if g.Title != "" {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="%.0f" font-weight="bold">%s</text>`+"\n", svgMargin, svgMargin+svgTitleSize-4, svgTitleSize, html.EscapeString(g.Title))
+	}
+
+	fmt.Fprintf(&b, `<g transform="translate(%.1f,%.1f)">`+"\n", svgMargin, top)
+	for i, e := range g.Edges {
+		from, okFrom := boxes[e.From]
+		to, okTo := boxes[e.To]
+		if !okFrom || !okTo {
+			continue
+		}
+		writeEdge(&b, e, from, to, i)
+	}
+	for i := range g.Nodes {
+		n := &g.Nodes[i]
+		bx := boxes[n.ID]
+		stroke, strokeW, dash := "#333333", 1.0, ""
+		if n.Start {
+			strokeW = 2.5
+		}
+		if n.Unreachable {
+			stroke, dash = colorMuted, ` stroke-dasharray="4 3"`
+		}
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="6" fill="%s" stroke="%s" stroke-width="%.1f"%s/>`+"\n",
+			bx.x, bx.y, bx.w, bx.h, n.fill(), stroke, strokeW, dash)
+		if n.Ending {
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" rx="4" fill="none" stroke="%s"/>`+"\n",
+				bx.x+3, bx.y+3, bx.w-6, bx.h-6, stroke)
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="12" text-anchor="middle" fill="%s">%s</text>`+"\n",
+			bx.cx(), bx.cy()+4, n.textColor(), html.EscapeString(n.ID))
+	}
+	b.WriteString("</g>\n")
+
+	for i, k := range edgeKinds {
+		y := legendTop + float64(i)*svgLegendRow
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="2"/>`+"\n", svgMargin, y, svgMargin+28, y, edgeColors[k])
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="11">%s</text>`+"\n", svgMargin+36, y+4, k)
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// layout places nodes in rows by rank, centring each row. Returns the boxes
+// and the size of the area they cover.
+func layout(g *Graph) (boxes map[string]box, width, height float64) {
+	boxes = make(map[string]box, len(g.Nodes))
+	var rows [][]*Node
+	for i := range g.Nodes {
+		n := &g.Nodes[i]
+		for len(rows) <= n.Rank {
+			rows = append(rows, nil)
+		}
+		rows[n.Rank] = append(rows[n.Rank], n)
+	}
+	rowWidths := make([]float64, len(rows))
+	for r, row := range rows {
+		for i, n := range row {
+			if i > 0 {
+				rowWidths[r] += svgGapX
+			}
+			rowWidths[r] += nodeWidth(n.ID)
+		}
+		width = math.Max(width, rowWidths[r])
+	}
+	for r, row := range rows {
+		x := (width - rowWidths[r]) / 2
+		for _, n := range row {
+			w := nodeWidth(n.ID)
+			boxes[n.ID] = box{x: x, y: float64(r) * svgRowHeight, w: w, h: svgNodeHeight}
+			x += w + svgGapX
+		}
+	}
+	if len(rows) > 0 {
+		height = float64(len(rows)-1)*svgRowHeight + svgNodeHeight
+	}
+	return boxes, width, height
+}
+
+func nodeWidth(id string) float64 {
+	return float64(len(id))*svgCharWidth + 2*svgNodePad
+}
+
+// writeEdge draws one edge. Forward edges run from the bottom of the source
+// to the top of the target; self-loops and edges that go up or sideways bow
+// out to the right so they don't cross the boxes in between. i staggers the
+// bow so parallel back edges stay distinguishable.
+func writeEdge(b *strings.Builder, e game.Edge, from, to box, i int) {
+	color := edgeColors[e.Kind]
+	dash := ""
+	if e.Kind == game.EdgeDeath {
+		dash = ` stroke-dasharray="5 4"`
+	}
+	var d string
+	var lx, ly float64
+	switch {
+	case e.From == e.To:
+		x, y := from.x+from.w, from.cy()
+		d = fmt.Sprintf("M%.1f,%.1f C%.1f,%.1f %.1f,%.1f %.1f,%.1f", x, y-8, x+40, y-30, x+40, y+30, x, y+8)
+		lx, ly = x+44, y
+	case to.y > from.y:
+		x1, y1 := from.cx(), from.y+from.h
+		x2, y2 := to.cx(), to.y
+		d = fmt.Sprintf("M%.1f,%.1f L%.1f,%.1f", x1, y1, x2, y2)
+		lx, ly = (x1+x2)/2, (y1+y2)/2
+	default:
+		x1, y1 := from.x+from.w, from.cy()
+		x2, y2 := to.x+to.w, to.cy()
+		bow := 50 + float64(i%4)*15
+		cx := math.Max(x1, x2) + bow
+		d = fmt.Sprintf("M%.1f,%.1f C%.1f,%.1f %.1f,%.1f %.1f,%.1f", x1, y1, cx, y1, cx, y2, x2, y2)
+		lx, ly = cx-bow/4, (y1+y2)/2
+	}
+	fmt.Fprintf(b, `<path d="%s" fill="none" stroke="%s" stroke-width="1.5"%s marker-end="url(#arrow-%s)"><title>%s</title></path>`+"\n",
+		d, color, dash, e.Kind, html.EscapeString(e.From+" → "+e.To+" ("+edgeLabel(e)+")"))
+	if e.Label != "" {
+		fmt.Fprintf(b, `<text x="%.1f" y="%.1f" font-size="10" fill="%s" text-anchor="middle">%s</text>`+"\n",
+			lx, ly, color, html.EscapeString(e.Label))
+	}
+}
@@ -0,0 +1,62 @@
+package storygraph
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"adventure/internal/game"
+)
+
+func TestSVG_WellFormed(t *testing.T) {
+	story := testStory()
+	// Self-loop and back edge exercise the curved edge paths.
+	story.Nodes["home"].Choices = []game.Choice{{Key: "again", Next: "camp"}, {Key: "stay", Next: "home"}}
+
+	var buf bytes.Buffer
+	if err := Render(&buf, Build(story, "Camp & Road"), FormatSVG); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	out := buf.String()
+	dec := xml.NewDecoder(strings.NewReader(out))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("SVG is not well-formed XML: %v\n%s", err, out)
+		}
+	}
+	for _, want := range []string{
+		"<svg ",
+		"Camp &amp; Road",
+		`marker-end="url(#arrow-victory)"`,
+		`stroke-dasharray="5 4"`,
+		`>fight</text>`,
+		`fill="` + fillEnding + `"`,
+		`fill="` + fillBattle + `"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected SVG to contain %q", want)
+		}
+	}
+}
+
+func TestLayout_CentersRows(t *testing.T) {
+	g := Build(testStory(), "")
+	boxes, width, height := layout(g)
+	camp := boxes["camp"]
+	if camp.y != 0 || camp.cx() != width/2 {
+		t.Errorf("Expected the lone start node centred on the top row, got %+v (width %.1f)", camp, width)
+	}
+	if boxes["road"].y != svgRowHeight {
+		t.Errorf("Expected road on the second row, got %+v", boxes["road"])
+	}
+	if height <= 0 {
+		t.Errorf("Expected a positive height, got %.1f", height)
+	}
+	if _, w, h := layout(&Graph{}); w != 0 || h != 0 {
+		t.Errorf("Expected empty layout for empty graph, got %.1f x %.1f", w, h)
+	}
+}
@@ -0,0 +1,123 @@
+package storytest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"adventure/internal/game"
+)
+
+// Coverage tracks which nodes and choices the tests reached, per story.
+type Coverage struct {
+	nodes   map[string]map[string]bool // story ID -> node ID
+	choices map[string]map[string]bool // story ID -> node ID + "#" + choice key
+}
+
+// NewCoverage returns empty coverage.
+func NewCoverage() *Coverage {
+	return &Coverage{nodes: map[string]map[string]bool{}, choices: map[string]map[string]bool{}}
+}
+
+// record adds the nodes visited and choices taken in a finished playthrough
+// started in rootID. Visit and taken keys of sub-adventure nodes carry the
+// called story's ID as a "story/" prefix.
+func (c *Coverage) record(rootID string, st *game.PlayerState) {
+	for k := range st.Visits {
+		story, node := splitKey(rootID, k)
+		mark(c.nodes, story, node)
+	}
+	for k := range st.Taken {
+		story, choice := splitKey(rootID, k)
+		mark(c.choices, story, choice)
+	}
+}
+
+func splitKey(rootID, key string) (story, rest string) {
+	if s, r, ok := strings.Cut(key, "/"); ok {
+		return s, r
+	}
+	return rootID, key
+}
+
+func mark(m map[string]map[string]bool, story, key string) {
+	if m[story] == nil {
+		m[story] = map[string]bool{}
+	}
+	m[story][key] = true
+}
+
+// Stories returns the IDs of every story the tests reached, sorted. Library
+// stories entered via call are included.
+func (c *Coverage) Stories() []string {
+	return sortedKeys(c.nodes)
+}
+
+// StoryCoverage is the coverage of one story.
+type StoryCoverage struct {
+	Story            string
+	Nodes, Choices   int
+	CoveredNodes     int
+	CoveredChoices   int
+	UncoveredNodes   []string // sorted node IDs
+	UncoveredChoices []string // sorted "node#choice" keys
+}
+
+// Report returns coverage for each given story ID, in the order given.
+func (c *Coverage) Report(stories map[string]*game.Story, ids []string) []StoryCoverage {
+	out := make([]StoryCoverage, 0, len(ids))
+	for _, id := range ids {
+		s := stories[id]
+		if s == nil {
+			continue
+		}
+		sc := StoryCoverage{Story: id}
+		for _, nodeID := range sortedKeys(s.Nodes) {
+			sc.Nodes++
+			if c.nodes[id][nodeID] {
+				sc.CoveredNodes++
+			} else {
+				sc.UncoveredNodes = append(sc.UncoveredNodes, nodeID)
+			}
+			for _, ch := range s.Nodes[nodeID].Choices {
+				key := nodeID + "#" + ch.Key
+				sc.Choices++
+				if c.choices[id][key] {
+					sc.CoveredChoices++
+				} else {
+					sc.UncoveredChoices = append(sc.UncoveredChoices, key)
+				}
+			}
+		}
+		out = append(out, sc)
+	}
+	return out
+}
+
+// WriteCoverage prints one line per story with node and choice coverage and,
+// when verbose, what was not reached.
+func WriteCoverage(w io.Writer, cov []StoryCoverage, verbose bool) {
+	sort.SliceStable(cov, func(i, j int) bool { return cov[i].Story < cov[j].Story })
+	for _, sc := range cov {
+		fmt.Fprintf(w, "%-16s nodes %d/%d (%s)  choices %d/%d (%s)\n", sc.Story,
+			sc.CoveredNodes, sc.Nodes, percent(sc.CoveredNodes, sc.Nodes),
+			sc.CoveredChoices, sc.Choices, percent(sc.CoveredChoices, sc.Choices))
+		if !verbose {
+			continue
+		}
+		if len(sc.UncoveredNodes) > 0 {
+			fmt.Fprintf(w, "  uncovered nodes: %s\n", strings.Join(sc.UncoveredNodes, ", "))
+		}
+		if len(sc.UncoveredChoices) > 0 {
+			fmt.Fprintf(w, "  uncovered choices: %s\n", strings.Join(sc.UncoveredChoices, ", "))
+		}
+	}
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", 100*float64(n)/float64(total))
+}
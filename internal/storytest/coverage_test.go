@@ -0,0 +1,63 @@
+package storytest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"adventure/internal/game"
+)
+
+func TestCoverage(t *testing.T) {
+	stories := gateStories()
+	stories["lib"] = &game.Story{Start: "a", Nodes: map[string]*game.Node{
+		"a": {Choices: []game.Choice{{Key: "go", Next: "b"}}},
+		"b": {},
+	}}
+	c := NewCoverage()
+	c.record("gate", &game.PlayerState{
+		Visits: map[string]int{"gate": 1, "yard": 2, "lib/a": 1},
+		Taken:  map[string]int{"gate#yard": 1, "lib/a#go": 1},
+	})
+	if got := c.Stories(); len(got) != 2 || got[0] != "gate" || got[1] != "lib" {
+		t.Fatalf("Stories = %v", got)
+	}
+
+	cov := c.Report(stories, []string{"lib", "gate", "missing"})
+	if len(cov) != 2 {
+		t.Fatalf("Expected two stories, got %+v", cov)
+	}
+	gate := cov[1]
+	if gate.Story != "gate" || gate.Nodes != 4 || gate.CoveredNodes != 2 || gate.Choices != 3 || gate.CoveredChoices != 1 {
+		t.Errorf("Unexpected gate coverage %+v", gate)
+	}
+	if strings.Join(gate.UncoveredNodes, ",") != "death,home" || strings.Join(gate.UncoveredChoices, ",") != "gate#sneak,yard#duel" {
+		t.Errorf("Unexpected uncovered lists %+v", gate)
+	}
+
+	var buf bytes.Buffer
+	WriteCoverage(&buf, cov, true)
+	out := buf.String()
+	for _, want := range []string{
+		"gate             nodes 2/4 (50%)  choices 1/3 (33%)",
+		"lib              nodes 1/2 (50%)  choices 1/1 (100%)",
+		"uncovered nodes: death, home",
+		"uncovered choices: gate#sneak, yard#duel",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "gate ") > strings.Index(out, "lib ") {
+		t.Errorf("Expected stories sorted by ID:\n%s", out)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	if got := percent(0, 0); got != "-" {
+		t.Errorf("percent(0, 0) = %q", got)
+	}
+	if got := percent(2, 3); got != "67%" {
+		t.Errorf("percent(2, 3) = %q", got)
+	}
+}
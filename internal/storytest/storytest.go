@@ -0,0 +1,321 @@
+// Package storytest runs scripted playthroughs written in YAML by story
+// authors. Each test file lives next to its story, in stories/<id>/tests/, and
+// lists the choices to make, the dice to roll and what to expect after each
+// step. Runs use a fixed roller, so every test is deterministic.
+package storytest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"adventure/internal/game"
+)
+
+// File is one test file: a story and the playthroughs to run against it.
+type File struct {
+	Story string `yaml:"story"` // story ID; empty = the directory name above tests/
+	Tests []Case `yaml:"tests"`
+}
+
+// Case is one playthrough from a fresh character.
+type Case struct {
+	Name       string         `yaml:"name"`
+	Start      string         `yaml:"start"`      // start node; empty = the story's start
+	Stats      map[string]int `yaml:"stats"`      // starting stats; unset ones keep the new-player defaults
+	Difficulty string         `yaml:"difficulty"` // preset ID; its stat modifiers are applied
+	Flags      []string       `yaml:"flags"`      // flags set before the first step
+	Steps      []Step         `yaml:"steps"`
+}
+
+// Step is one choice and what should hold afterwards.
+type Step struct {
+	Choice string `yaml:"choice"` // choice key, e.g. "north" or "fight:attack:0"
+	Answer string `yaml:"answer"` // typed answer for prompt choices
+	Dice   []int  `yaml:"dice"`   // d6 results the step must consume, in order
+	Expect Expect `yaml:"expect"`
+}
+
+// Expect lists assertions checked after a step. Unset fields are not checked.
+type Expect struct {
+	Node    string          `yaml:"node"`
+	Story   string          `yaml:"story"` // current story ID (inside a sub-adventure)
+	Stats   map[string]int  `yaml:"stats"`
+	Flags   map[string]bool `yaml:"flags"`
+	Enemies *[]EnemyExpect  `yaml:"enemies"` // [] asserts that no battle is in progress
+	Ending  *bool           `yaml:"ending"`
+	Error   string          `yaml:"error"` // the step must be refused with a message containing this
+}
+
+// EnemyExpect matches one enemy in the current battle, in order.
+type EnemyExpect struct {
+	Name     string `yaml:"name"`
+	Health   *int   `yaml:"health"`
+	Strength *int   `yaml:"strength"`
+}
+
+// Result is the outcome of one Case.
+type Result struct {
+	File     string
+	Story    string
+	Name     string
+	Failures []string // "step N (choice): message"; the case stops at the first failing step
+}
+
+// Passed reports whether the case had no failures.
+func (r *Result) Passed() bool { return len(r.Failures) == 0 }
+
+// Discover returns the test files under dir/*/tests/, sorted.
+func Discover(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "tests", "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Load reads a test file, defaulting the story ID from its path.
+func Load(path string) (*File, error) {
+	b, err := os.ReadFile(path) // #nosec G304 -- path comes from Discover or the command line
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Story == "" {
+		f.Story = filepath.Base(filepath.Dir(filepath.Dir(path)))
+	}
+	return &f, nil
+}
+
+// Runner executes test files against a set of stories and collects coverage.
+type Runner struct {
+	Stories  map[string]*game.Story
+	Coverage *Coverage
+}
+
+// NewRunner returns a runner for the given stories.
+func NewRunner(stories map[string]*game.Story) *Runner {
+	return &Runner{Stories: stories, Coverage: NewCoverage()}
+}
+
+// RunFile runs every case in f; path is only used for reporting.
+func (r *Runner) RunFile(path string, f *File) []Result {
+	out := make([]Result, 0, len(f.Tests))
+	for i := range f.Tests {
+		res := Result{File: path, Story: f.Story, Name: f.Tests[i].Name}
+		if res.Name == "" {
+			res.Name = fmt.Sprintf("test %d", i+1)
+		}
+		res.Failures = r.runCase(f.Story, &f.Tests[i])
+		out = append(out, res)
+	}
+	return out
+}
+
+// dice is a fixed roller: it hands out the step's dice and notes misuse.
+type dice struct {
+	queue  []int
+	wanted int // rolls asked for after the queue ran out
+}
+
+func (d *dice) roll() int {
+	if len(d.queue) == 0 {
+		d.wanted++
+		return 1
+	}
+	v := d.queue[0]
+	d.queue = d.queue[1:]
+	return v
+}
+
+func (r *Runner) runCase(storyID string, c *Case) []string {
+	story := r.Stories[storyID]
+	if story == nil {
+		return []string{fmt.Sprintf("unknown story %q", storyID)}
+	}
+	start := c.Start
+	if start == "" {
+		start = story.Start
+	}
+	if story.Nodes[start] == nil {
+		return []string{fmt.Sprintf("unknown start node %q", start)}
+	}
+
+	if c.Difficulty != "" && story.Difficulty(c.Difficulty) == nil {
+		return []string{fmt.Sprintf("unknown difficulty %q", c.Difficulty)}
+	}
+
+	roller := &dice{}
+	e := &game.Engine{Stories: r.Stories, Dice: roller.roll}
+	st := game.NewPlayer(storyID, start)
+	for stat, v := range c.Stats {
+		if err := setStat(&st.Stats, stat, v); err != nil {
+			return []string{err.Error()}
+		}
+	}
+	st.BaseStats = st.Stats
+	st.Difficulty = c.Difficulty
+	st.Stats = story.ResolveDifficulty(c.Difficulty).ApplyToStats(st.BaseStats)
+	for _, f := range c.Flags {
+		st.Flags[f] = true
+	}
+	defer r.Coverage.record(storyID, &st)
+
+	for i := range c.Steps {
+		step := &c.Steps[i]
+		fail := func(format string, args ...any) []string {
+			return []string{fmt.Sprintf("step %d (%s): %s", i+1, step.Choice, fmt.Sprintf(format, args...))}
+		}
+		for _, d := range step.Dice {
+			if d < 1 || d > 6 {
+				return fail("die %d is not between 1 and 6", d)
+			}
+		}
+		roller.queue, roller.wanted = append([]int(nil), step.Dice...), 0
+
+		result, err := e.ApplyChoiceWithAnswer(&st, step.Choice, step.Answer)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if roller.wanted > 0 {
+			return fail("rolled %d more dice than the %d given", roller.wanted, len(step.Dice))
+		}
+		if len(roller.queue) > 0 {
+			return fail("%d of the %d dice given were not rolled", len(roller.queue), len(step.Dice))
+		}
+		if step.Expect.Error != "" {
+			if !strings.Contains(result.ErrorMessage, step.Expect.Error) {
+				return fail("expected the step to be refused with %q, got %q", step.Expect.Error, result.ErrorMessage)
+			}
+		} else if result.ErrorMessage != "" {
+			return fail("refused: %s", result.ErrorMessage)
+		}
+		if msgs := check(e, &st, &step.Expect); len(msgs) > 0 {
+			return fail("%s", strings.Join(msgs, "; "))
+		}
+	}
+	return nil
+}
+
+// check returns a message for every expectation that does not hold.
+func check(e *game.Engine, st *game.PlayerState, x *Expect) []string {
+	var msgs []string
+	if x.Node != "" && st.NodeID != x.Node {
+		msgs = append(msgs, fmt.Sprintf("node = %q, want %q", st.NodeID, x.Node))
+	}
+	if x.Story != "" && st.StoryID != x.Story {
+		msgs = append(msgs, fmt.Sprintf("story = %q, want %q", st.StoryID, x.Story))
+	}
+	for _, stat := range sortedKeys(x.Stats) {
+		got, err := getStat(&st.Stats, stat)
+		if err != nil {
+			msgs = append(msgs, err.Error())
+			continue
+		}
+		if got != x.Stats[stat] {
+			msgs = append(msgs, fmt.Sprintf("%s = %d, want %d", stat, got, x.Stats[stat]))
+		}
+	}
+	for _, f := range sortedKeys(x.Flags) {
+		if st.Flags[f] != x.Flags[f] {
+			msgs = append(msgs, fmt.Sprintf("flag %s = %v, want %v", f, st.Flags[f], x.Flags[f]))
+		}
+	}
+	if x.Enemies != nil {
+		want := *x.Enemies
+		if len(st.Enemies) != len(want) {
+			msgs = append(msgs, fmt.Sprintf("%d enemies, want %d", len(st.Enemies), len(want)))
+		} else {
+			for i, w := range want {
+				got := st.Enemies[i]
+				if w.Name != "" && got.Name != w.Name {
+					msgs = append(msgs, fmt.Sprintf("enemy %d name = %q, want %q", i, got.Name, w.Name))
+				}
+				if w.Health != nil && got.Health != *w.Health {
+					msgs = append(msgs, fmt.Sprintf("enemy %d health = %d, want %d", i, got.Health, *w.Health))
+				}
+				if w.Strength != nil && got.Strength != *w.Strength {
+					msgs = append(msgs, fmt.Sprintf("enemy %d strength = %d, want %d", i, got.Strength, *w.Strength))
+				}
+			}
+		}
+	}
+	if x.Ending != nil {
+		n, err := e.CurrentNode(st)
+		ending := err == nil && n.Ending
+		if ending != *x.Ending {
+			msgs = append(msgs, fmt.Sprintf("ending = %v, want %v", ending, *x.Ending))
+		}
+	}
+	return msgs
+}
+
+func statField(s *game.Stats, stat string) (*int, error) {
+	switch stat {
+	case game.StatStrength:
+		return &s.Strength, nil
+	case game.StatLuck:
+		return &s.Luck, nil
+	case game.StatHealth:
+		return &s.Health, nil
+	default:
+		return nil, fmt.Errorf("unknown stat %q", stat)
+	}
+}
+
+func getStat(s *game.Stats, stat string) (int, error) {
+	p, err := statField(s, stat)
+	if err != nil {
+		return 0, err
+	}
+	return *p, nil
+}
+
+func setStat(s *game.Stats, stat string, v int) error {
+	p, err := statField(s, stat)
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteResults prints a line per failing case (every case when verbose) and a
+// pass/fail total. It returns the number of failing cases.
+func WriteResults(w io.Writer, results []Result, verbose bool) int {
+	failed := 0
+	for i := range results {
+		r := &results[i]
+		if r.Passed() {
+			if verbose {
+				fmt.Fprintf(w, "ok    %s: %s\n", r.File, r.Name)
+			}
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "FAIL  %s: %s\n", r.File, r.Name)
+		for _, f := range r.Failures {
+			fmt.Fprintf(w, "      %s\n", f)
+		}
+	}
+	fmt.Fprintf(w, "%d passed, %d failed\n", len(results)-failed, failed)
+	return failed
+}
@@ -0,0 +1,254 @@
+package storytest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"adventure/internal/game"
+)
+
+// gateStories has a luck check at the gate, a duel and a flag-setting path.
+func gateStories() map[string]*game.Story {
+	return map[string]*game.Story{"gate": {
+		Start: "gate",
+		Difficulties: []game.Difficulty{
+			{ID: "easy", Stats: game.Stats{Health: 3}},
+			{ID: "normal", Default: true},
+		},
+		Nodes: map[string]*game.Node{
+			"gate": {Text: "Gate", Choices: []game.Choice{
+				{Key: "sneak", Check: &game.Check{Stat: game.StatLuck, Roll: "2d6", Target: "stat"}, OnSuccessNext: "home", OnFailureNext: "yard"},
+				{Key: "yard", Next: "yard", Effects: []game.Effect{{Op: game.OpSetFlag, Flag: "seen"}}},
+			}},
+			"yard": {Text: "Yard", Choices: []game.Choice{
+				{Key: "duel", Battle: &game.Battle{
+					Enemies:       []game.Enemy{{Name: "Guard", Strength: 5, Health: 2}},
+					OnVictoryNext: "home",
+				}},
+			}},
+			"home":  {Text: "Home", Ending: true},
+			"death": {Text: "Dead", Ending: true},
+		},
+	}}
+}
+
+func parseFile(t *testing.T, src string) *File {
+	t.Helper()
+	var f File
+	if err := yaml.Unmarshal([]byte(src), &f); err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	if f.Story == "" {
+		f.Story = "gate"
+	}
+	return &f
+}
+
+func TestRunFile_Passing(t *testing.T) {
+	f := parseFile(t, `
+tests:
+  - name: "sneak"
+    stats: {luck: 9}
+    steps:
+      - choice: "sneak"
+        dice: [4, 5]
+        expect: {node: "home", ending: true}
+  - name: "duel"
+    stats: {strength: 7, health: 5}
+    difficulty: "easy"
+    steps:
+      - choice: "yard"
+        expect: {node: "yard", flags: {seen: true}, stats: {health: 8}}
+      - choice: "duel"
+        dice: [6, 6, 1, 1]
+        expect: {node: "yard", enemies: [{name: "Guard", health: 1, strength: 5}], ending: false}
+      - choice: "duel:attack:0"
+        dice: [6, 6, 1, 1]
+        expect: {node: "home", enemies: []}
+`)
+	r := NewRunner(gateStories())
+	for _, res := range r.RunFile("gate.yaml", f) {
+		if !res.Passed() {
+			t.Errorf("%s: %v", res.Name, res.Failures)
+		}
+	}
+}
+
+func TestRunFile_Failures(t *testing.T) {
+	tests := []struct {
+		name, steps, want string
+	}{
+		{"wrong node", `
+      - choice: "sneak"
+        dice: [6, 6]
+        expect: {node: "home"}`, `step 1 (sneak): node = "yard", want "home"`},
+		{"missing dice", `
+      - choice: "sneak"
+        dice: [6]`, "step 1 (sneak): rolled 1 more dice than the 1 given"},
+		{"unused dice", `
+      - choice: "yard"
+        dice: [3]`, "step 1 (yard): 1 of the 1 dice given were not rolled"},
+		{"bad die", `
+      - choice: "sneak"
+        dice: [7, 1]`, "die 7 is not between 1 and 6"},
+		{"refused", `
+      - choice: "yard"
+      - choice: "sneak"`, "step 2 (sneak): refused: That choice doesn't exist."},
+		{"wrong refusal", `
+      - choice: "yard"
+        expect: {error: "nope"}`, `expected the step to be refused with "nope"`},
+		{"stats and flags", `
+      - choice: "yard"
+        expect: {stats: {health: 1, luck: 7}, flags: {seen: false}}`, "health = 12, want 1; flag seen = true, want false"},
+		{"unknown stat", `
+      - choice: "yard"
+        expect: {stats: {charm: 1}}`, `unknown stat "charm"`},
+		{"enemies", `
+      - choice: "yard"
+      - choice: "duel"
+        dice: [1, 1, 6, 6]
+        expect: {enemies: [{name: "Orc", health: 1, strength: 1}]}`, `enemy 0 name = "Guard", want "Orc"; enemy 0 health = 2, want 1; enemy 0 strength = 5, want 1`},
+		{"enemy count", `
+      - choice: "yard"
+        expect: {enemies: [{name: "Guard"}], ending: true}`, "0 enemies, want 1; ending = false, want true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := parseFile(t, "tests:\n  - steps:"+tt.steps+"\n")
+			res := NewRunner(gateStories()).RunFile("gate.yaml", f)
+			if len(res) != 1 || len(res[0].Failures) != 1 {
+				t.Fatalf("Expected one failure, got %+v", res)
+			}
+			if !strings.Contains(res[0].Failures[0], tt.want) {
+				t.Errorf("Failure = %q, want it to contain %q", res[0].Failures[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestRunFile_StopsAtFirstFailingStep(t *testing.T) {
+	f := parseFile(t, `
+tests:
+  - steps:
+      - choice: "yard"
+        expect: {node: "gate"}
+      - choice: "nowhere"
+`)
+	res := NewRunner(gateStories()).RunFile("gate.yaml", f)
+	if len(res[0].Failures) != 1 || !strings.HasPrefix(res[0].Failures[0], "step 1 ") {
+		t.Errorf("Expected only step 1 to fail, got %v", res[0].Failures)
+	}
+	if res[0].Name != "test 1" {
+		t.Errorf("Expected a default name, got %q", res[0].Name)
+	}
+}
+
+func TestRunFile_BadSetup(t *testing.T) {
+	tests := map[string]string{
+		`unknown story "nope"`:     "story: nope\ntests:\n  - steps: []\n",
+		`unknown start node "x"`:   "tests:\n  - start: x\n",
+		`unknown stat "charm"`:     "tests:\n  - stats: {charm: 3}\n",
+		`unknown difficulty "odd"`: "tests:\n  - difficulty: odd\n",
+	}
+	for want, src := range tests {
+		res := NewRunner(gateStories()).RunFile("gate.yaml", parseFile(t, src))
+		if len(res) != 1 || len(res[0].Failures) != 1 || res[0].Failures[0] != want {
+			t.Errorf("Expected %q, got %+v", want, res)
+		}
+	}
+}
+
+func TestDiscoverAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	testDir := filepath.Join(dir, "gate", "tests")
+	if err := os.MkdirAll(testDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range map[string]string{
+		"b.yaml": "tests:\n  - name: b\n",
+		"a.yaml": "story: other\ntests: []\n",
+	} {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths, err := Discover(dir)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if len(paths) != 2 || filepath.Base(paths[0]) != "a.yaml" || filepath.Base(paths[1]) != "b.yaml" {
+		t.Fatalf("Discover = %v", paths)
+	}
+	a, err := Load(paths[0])
+	if err != nil || a.Story != "other" {
+		t.Errorf("Expected explicit story, got %+v, %v", a, err)
+	}
+	b, err := Load(paths[1])
+	if err != nil || b.Story != "gate" || len(b.Tests) != 1 {
+		t.Errorf("Expected story from directory, got %+v, %v", b, err)
+	}
+
+	if err := os.WriteFile(paths[0], []byte("tests: {"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(paths[0]); err == nil || !strings.Contains(err.Error(), "a.yaml") {
+		t.Errorf("Expected a parse error naming the file, got %v", err)
+	}
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	results := []Result{
+		{File: "a.yaml", Name: "good"},
+		{File: "a.yaml", Name: "bad", Failures: []string{"step 2 (x): boom"}},
+	}
+	var buf bytes.Buffer
+	if n := WriteResults(&buf, results, false); n != 1 {
+		t.Errorf("Expected 1 failure, got %d", n)
+	}
+	out := buf.String()
+	if strings.Contains(out, "good") || !strings.Contains(out, "FAIL  a.yaml: bad") || !strings.Contains(out, "step 2 (x): boom") {
+		t.Errorf("Unexpected output:\n%s", out)
+	}
+	if !strings.Contains(out, "1 passed, 1 failed") {
+		t.Errorf("Expected a total line, got:\n%s", out)
+	}
+
+	buf.Reset()
+	WriteResults(&buf, results, true)
+	if !strings.Contains(buf.String(), "ok    a.yaml: good") {
+		t.Errorf("Expected passing tests in verbose output, got:\n%s", buf.String())
+	}
+}
+
+// TestStories runs the playthrough tests shipped with the repository's stories.
+func TestStories(t *testing.T) {
+	const dir = "../../stories"
+	stories, err := game.LoadStories(dir)
+	if err != nil {
+		t.Fatalf("LoadStories: %v", err)
+	}
+	paths, err := Discover(dir)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	r := NewRunner(stories)
+	for _, p := range paths {
+		f, err := Load(p)
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		for _, res := range r.RunFile(p, f) {
+			if !res.Passed() {
+				t.Errorf("%s: %s: %v", p, res.Name, res.Failures)
+			}
+		}
+	}
+}
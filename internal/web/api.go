@@ -0,0 +1,431 @@
+package web
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"adventure/internal/game"
+	"adventure/internal/session"
+)
+
+// API error codes returned alongside the game's Refusal* codes.
+const (
+	APIErrInvalidRequest    = "invalid_request"
+	APIErrSessionNotFound   = "session_not_found"
+	APIErrUnknownStory      = "unknown_story"
+	APIErrUnknownDifficulty = "unknown_difficulty"
+	APIErrInvalidAvatar     = "invalid_avatar"
+	APIErrNoRerollsLeft     = "no_rerolls_left"
+	APIErrNoSequelHero      = "no_sequel_hero"
+	APIErrAlreadyBegun      = "already_begun"
+	APIErrSetupLocked       = "setup_locked"
+	APIErrStaleRevision     = "stale_revision"
+	APIErrInternal          = "internal"
+)
+
+// maxAPIBody caps /api/v1 request bodies.
+const maxAPIBody = 1 << 16
+
+//go:embed openapi.yaml
+var openAPIDoc []byte
+
+// apiRoutes registers the JSON API under /api/v1.
+func (s *Server) apiRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/openapi.yaml", s.handleAPIDoc)
+	mux.HandleFunc("GET /api/v1/stories", s.handleAPIStories)
+	mux.HandleFunc("POST /api/v1/sessions", s.handleAPICreateSession)
+	mux.HandleFunc("GET /api/v1/sessions/{id}", s.handleAPINode)
+	mux.HandleFunc("POST /api/v1/sessions/{id}/reroll", s.handleAPIReroll)
+	mux.HandleFunc("POST /api/v1/sessions/{id}/begin", s.handleAPIBegin)
+	mux.HandleFunc("POST /api/v1/sessions/{id}/choices", s.handleAPIChoice)
+	mux.HandleFunc("GET /api/v1/sessions/{id}/history", s.handleAPIHistory)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v) //nolint:errcheck // the client has gone; nothing to report to
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, APIError{Error: APIErrorDetail{Code: code, Message: msg}})
+}
+
+// decodeAPIBody reads an optional JSON body into v. An empty body leaves v unchanged.
+func decodeAPIBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBody)).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeAPIError(w, http.StatusBadRequest, APIErrInvalidRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// apiSession loads the session named in the path and its revision, writing
+// an error if it is missing.
+func (s *Server) apiSession(w http.ResponseWriter, r *http.Request) (st game.PlayerState, rev int64, id string, ok bool) {
+	id = r.PathValue("id")
+	st, rev, ok, err := s.Store.GetRev(r.Context(), id)
+	if err != nil {
+		s.apiServerError(w, r, "failed to load session", err)
+		return game.PlayerState{}, 0, "", false
+	}
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, APIErrSessionNotFound, "no session with that ID")
+		return game.PlayerState{}, 0, "", false
+	}
+	logSession(r.Context(), id, &st)
+	return st, rev, id, true
+}
+
+// apiSave stores st if the session is still at rev, returning the new
+// revision. It writes a 409 if another request changed the session first.
+func (s *Server) apiSave(w http.ResponseWriter, r *http.Request, id string, st game.PlayerState, rev int64) (int64, bool) {
+	rev, err := s.Store.CompareAndPut(r.Context(), id, st, rev)
+	if err != nil {
+		s.writeAPISaveError(w, r, err)
+		return 0, false
+	}
+	return rev, true
+}
+
+// writeAPISaveError reports a failed CompareAndPut.
+func (s *Server) writeAPISaveError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, session.ErrConflict) {
+		writeAPIError(w, http.StatusConflict, APIErrStaleRevision, "the session changed while this request was handled; fetch it and try again")
+		return
+	}
+	s.apiServerError(w, r, "failed to save session", err)
+}
+
+// apiServerError logs err, the cause of a failed API request, and answers
+// 500 with msg.
+func (s *Server) apiServerError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	s.logger().ErrorContext(r.Context(), msg, slog.Any("err", err))
+	writeAPIError(w, http.StatusInternalServerError, APIErrInternal, msg)
+}
+
+// apiSetupBody picks the story and difficulty during character creation.
+type apiSetupBody struct {
+	StoryID    string `json:"storyId"`
+	Difficulty string `json:"difficulty"`
+}
+
+// applySetup validates and applies a story and difficulty choice to st.
+// It refuses once st has begun, or a change once the stats have been rerolled.
+func (s *Server) applySetup(w http.ResponseWriter, st *game.PlayerState, body *apiSetupBody) bool {
+	if st.Begun {
+		writeAPIError(w, http.StatusConflict, APIErrAlreadyBegun, errBegun.Error())
+		return false
+	}
+	if setupLocked(st, body.StoryID, body.Difficulty) {
+		writeAPIError(w, http.StatusConflict, APIErrSetupLocked, errSetupLocked.Error())
+		return false
+	}
+	if body.StoryID != "" {
+		story := s.playable(body.StoryID)
+		if story == nil {
+			writeAPIError(w, http.StatusBadRequest, APIErrUnknownStory, "no playable story "+body.StoryID)
+			return false
+		}
+		if st.StoryID != body.StoryID {
+			st.BeginRun(body.StoryID, story.Start)
+		}
+	}
+	if body.Difficulty != "" && s.Engine.Stories[st.StoryID].Difficulty(body.Difficulty) == nil {
+		writeAPIError(w, http.StatusBadRequest, APIErrUnknownDifficulty, "no difficulty "+body.Difficulty+" in "+st.StoryID)
+		return false
+	}
+	if body.StoryID != "" || body.Difficulty != "" {
+		id := body.Difficulty
+		if id == "" {
+			id = st.Difficulty
+		}
+		s.setDifficulty(st, id)
+	}
+	return true
+}
+
+func (s *Server) apiSetup(st *game.PlayerState, id string, dice [3][2]int) APISetup {
+	vm := s.startViewModel(st, id, dice)
+	return APISetup{
+		SessionID:   id,
+		StoryID:     st.StoryID,
+		Difficulty:  st.Difficulty,
+		Name:        st.Name,
+		Avatar:      st.Avatar,
+		Stats:       apiStats(st.Stats),
+		BaseStats:   apiStats(st.BaseStats),
+		Dice:        APIStatDice{Strength: dice[0], Luck: dice[1], Health: dice[2]},
+		RerollsLeft: vm.RerollsLeft,
+		Sequel:      vm.Sequel,
+	}
+}
+
+// GET /api/v1/openapi.yaml
+func (s *Server) handleAPIDoc(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(openAPIDoc) //nolint:errcheck // the client has gone; nothing to report to
+}
+
+// GET /api/v1/stories lists the playable stories and their difficulties.
+func (s *Server) handleAPIStories(w http.ResponseWriter, _ *http.Request) {
+	out := []APIStory{}
+	for _, opt := range s.adventureOptions() {
+		story := s.Engine.Stories[opt.ID]
+		as := APIStory{ID: opt.ID, Title: opt.Name, Difficulties: []APIDifficulty{}}
+		if story.Continues != nil {
+			as.Continues = story.Continues.From
+		}
+		def := story.DefaultDifficulty()
+		for _, d := range s.difficultyOptions(opt.ID) {
+			as.Difficulties = append(as.Difficulties, APIDifficulty{
+				ID: d.ID, Name: d.Name, Description: d.Description, Default: def != nil && def.ID == d.ID,
+			})
+		}
+		out = append(out, as)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	writeJSON(w, http.StatusOK, out)
+}
+
+// POST /api/v1/sessions creates a session with freshly rolled stats.
+func (s *Server) handleAPICreateSession(w http.ResponseWriter, r *http.Request) {
+	var body apiSetupBody
+	if !decodeAPIBody(w, r, &body) {
+		return
+	}
+	storyID := s.defaultStoryID()
+	story := s.playable(storyID)
+	if story == nil {
+		writeAPIError(w, http.StatusInternalServerError, APIErrInternal, "no adventure available")
+		return
+	}
+	st := game.NewPlayer(storyID, story.Start)
+	var dice [3][2]int
+	st.BaseStats, dice = game.RollStatsDetailed()
+	st.Stats = st.BaseStats
+	s.setDifficulty(&st, "")
+	if !s.applySetup(w, &st, &body) {
+		return
+	}
+	id := s.Store.NewID()
+	if _, ok := s.apiSave(w, r, id, st, 0); !ok {
+		return
+	}
+	w.Header().Set("Location", "/api/v1/sessions/"+id)
+	writeJSON(w, http.StatusCreated, s.apiSetup(&st, id, dice))
+}
+
+// POST /api/v1/sessions/{id}/reroll rerolls the stats if the difficulty allows.
+func (s *Server) handleAPIReroll(w http.ResponseWriter, r *http.Request) {
+	st, rev, id, ok := s.apiSession(w, r)
+	if !ok {
+		return
+	}
+	var body apiSetupBody
+	if !decodeAPIBody(w, r, &body) || !s.applySetup(w, &st, &body) {
+		return
+	}
+	allowance := s.storyDifficulty(&st).RerollAllowance()
+	if st.RerollUsed || st.Rerolls >= allowance {
+		writeAPIError(w, http.StatusConflict, APIErrNoRerollsLeft, "no rerolls left")
+		return
+	}
+	var dice [3][2]int
+	st.BaseStats, dice = game.RollStatsDetailed()
+	st.Stats = s.storyDifficulty(&st).ApplyToStats(st.BaseStats)
+	st.Rerolls++
+	st.RerollUsed = st.Rerolls >= allowance
+	if _, ok := s.apiSave(w, r, id, st, rev); !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.apiSetup(&st, id, dice))
+}
+
+// apiBeginBody starts a story.
+type apiBeginBody struct {
+	StoryID    string `json:"storyId"`
+	Difficulty string `json:"difficulty"`
+	Name       string `json:"name"`
+	Avatar     string `json:"avatar"`
+	Continue   bool   `json:"continue"` // carry over the hero from the story's prequel
+}
+
+// POST /api/v1/sessions/{id}/begin places the character at the story's start.
+func (s *Server) handleAPIBegin(w http.ResponseWriter, r *http.Request) {
+	st, rev, id, ok := s.apiSession(w, r)
+	if !ok {
+		return
+	}
+	var body apiBeginBody
+	if !decodeAPIBody(w, r, &body) {
+		return
+	}
+	if st.Begun {
+		writeAPIError(w, http.StatusConflict, APIErrAlreadyBegun, errBegun.Error())
+		return
+	}
+	if setupLocked(&st, body.StoryID, body.Difficulty) {
+		writeAPIError(w, http.StatusConflict, APIErrSetupLocked, errSetupLocked.Error())
+		return
+	}
+	storyID := body.StoryID
+	if storyID == "" {
+		storyID = st.StoryID
+	}
+	story := s.playable(storyID)
+	if story == nil {
+		writeAPIError(w, http.StatusBadRequest, APIErrUnknownStory, "no playable story "+storyID)
+		return
+	}
+	if body.Difficulty != "" && story.Difficulty(body.Difficulty) == nil {
+		writeAPIError(w, http.StatusBadRequest, APIErrUnknownDifficulty, "no difficulty "+body.Difficulty+" in "+storyID)
+		return
+	}
+	if body.Avatar != "" && !allowedAvatar(body.Avatar) {
+		writeAPIError(w, http.StatusBadRequest, APIErrInvalidAvatar, "avatar must be one of "+strings.Join(AvatarOptions, ", "))
+		return
+	}
+	difficulty := body.Difficulty
+	if difficulty == "" && storyID == st.StoryID {
+		difficulty = st.Difficulty
+	}
+
+	st.BeginRun(storyID, story.Start)
+	s.setDifficulty(&st, difficulty)
+	st.Name = sanitizeName(body.Name)
+	st.Avatar = body.Avatar
+	if st.Avatar == "" {
+		st.Avatar = game.DefaultAvatar
+	}
+	if body.Continue {
+		if err := s.Engine.BeginSequel(&st, storyID); err != nil {
+			writeAPIError(w, http.StatusConflict, APIErrNoSequelHero, err.Error())
+			return
+		}
+	}
+	st.MarkBegun()
+	rev, ok = s.apiSave(w, r, id, st, rev)
+	if !ok {
+		return
+	}
+	s.begunSaved(r.Context(), id, &st)
+	s.writeAPINode(w, r, &st, id, rev, nil)
+}
+
+// GET /api/v1/sessions/{id} returns the current node.
+func (s *Server) handleAPINode(w http.ResponseWriter, r *http.Request) {
+	st, rev, id, ok := s.apiSession(w, r)
+	if !ok {
+		return
+	}
+	s.writeAPINode(w, r, &st, id, rev, nil)
+}
+
+// apiChoiceBody submits a choice key and, for prompts, an answer. Revision,
+// when set, is the node's revision the choice was made at.
+type apiChoiceBody struct {
+	Choice   string `json:"choice"`
+	Answer   string `json:"answer"`
+	Revision *int64 `json:"revision"`
+}
+
+// POST /api/v1/sessions/{id}/choices applies a choice. A refused choice is a
+// 422 with the engine's reason code; the session is left as it was. With a
+// revision, a choice made at an older revision is a 409; without one, a
+// concurrent change is retried like /play.
+func (s *Server) handleAPIChoice(w http.ResponseWriter, r *http.Request) {
+	st, rev, id, ok := s.apiSession(w, r)
+	if !ok {
+		return
+	}
+	var body apiChoiceBody
+	if !decodeAPIBody(w, r, &body) {
+		return
+	}
+	if body.Choice == "" {
+		writeAPIError(w, http.StatusBadRequest, APIErrInvalidRequest, "choice is required")
+		return
+	}
+	for attempt := 1; ; attempt++ {
+		if body.Revision != nil && *body.Revision != rev {
+			writeAPIError(w, http.StatusConflict, APIErrStaleRevision, fmt.Sprintf("the session is at revision %d, not %d", rev, *body.Revision))
+			return
+		}
+		before := st
+		res, err := s.Engine.ApplyChoiceWithAnswer(&st, body.Choice, body.Answer)
+		if err != nil {
+			s.apiServerError(w, r, "failed to apply choice", err)
+			return
+		}
+		if res.ErrorMessage != "" {
+			writeAPIError(w, http.StatusUnprocessableEntity, res.ErrorCode, res.ErrorMessage)
+			return
+		}
+		newRev, err := s.Store.CompareAndPut(r.Context(), id, res.State, rev)
+		if err == nil {
+			s.stepSaved(r.Context(), id, &before, body.Choice, &res)
+			result := apiResult(&res)
+			if sv := s.recordScore(r.Context(), id, &res); sv != nil {
+				result.Score, result.Ranks = &sv.Score, sv.Ranks
+			}
+			s.writeAPINode(w, r, &res.State, id, newRev, result)
+			return
+		}
+		if !errors.Is(err, session.ErrConflict) || (body.Revision == nil && attempt == maxPlayAttempts) {
+			s.writeAPISaveError(w, r, err)
+			return
+		}
+		// Another request saved first: start again from what it saved.
+		if st, rev, id, ok = s.apiSession(w, r); !ok {
+			return
+		}
+	}
+}
+
+// GET /api/v1/sessions/{id}/history returns the run's path and the player's records.
+func (s *Server) handleAPIHistory(w http.ResponseWriter, r *http.Request) {
+	st, _, id, ok := s.apiSession(w, r)
+	if !ok {
+		return
+	}
+	h := APIHistory{
+		SessionID: id,
+		StoryID:   st.StoryID,
+		Path:      append([]string{}, st.VisitedNodes...),
+		Defeated:  map[string]int{},
+		Records:   map[string]APIRecord{},
+	}
+	for name, n := range st.Defeated {
+		h.Defeated[name] = n
+	}
+	for storyID, rec := range st.Records {
+		h.Records[storyID] = APIRecord{
+			Endings:      append([]string{}, rec.Endings...),
+			Achievements: append([]string{}, rec.Achievements...),
+		}
+	}
+	writeJSON(w, http.StatusOK, h)
+}
+
+func (s *Server) writeAPINode(w http.ResponseWriter, r *http.Request, st *game.PlayerState, id string, rev int64, result *APIResult) {
+	vm, err := s.makeViewModel(st, "", nil, nil, nil, nil)
+	if err != nil {
+		s.apiServerError(w, r, "failed to load the current node", err)
+		return
+	}
+	vm.SessionID = id
+	vm.Revision = rev
+	n := apiNode(&vm)
+	n.Result = result
+	writeJSON(w, http.StatusOK, n)
+}
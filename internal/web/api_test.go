@@ -0,0 +1,329 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"adventure/internal/game"
+)
+
+// apiCall sends a JSON request and decodes the response into out (if non-nil).
+func apiCall(t *testing.T, srv *Server, method, path, body string, out any) *httptest.ResponseRecorder {
+	t.Helper()
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, path, http.NoBody)
+	} else {
+		req = httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec
+}
+
+// expectAPIError checks status and error code.
+func expectAPIError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("Expected %d, got %d: %s", status, rec.Code, rec.Body.String())
+	}
+	var e APIError
+	if err := json.Unmarshal(rec.Body.Bytes(), &e); err != nil {
+		t.Fatalf("decode error body %q: %v", rec.Body.String(), err)
+	}
+	if e.Error.Code != code || e.Error.Message == "" {
+		t.Errorf("Expected code %q with a message, got %+v", code, e.Error)
+	}
+}
+
+// apiTestServer extends the test story with a prompt and a two-enemy battle,
+// and adds a library story that must stay hidden.
+func apiTestServer(t *testing.T) *Server {
+	t.Helper()
+	srv := testServer(t)
+	withDifficulties(srv)
+	story := srv.Engine.Stories[testStoryID]
+	story.Title = "Test Story"
+	story.Nodes["start"].Choices = append(story.Nodes["start"].Choices,
+		game.Choice{Key: "riddle", Text: "Answer", Prompt: &game.Prompt{
+			Question: "What walks on four legs?", Placeholder: "Answer",
+			Answers: []game.Answer{{Match: "man", Next: "end"}},
+		}},
+		game.Choice{Key: "fight", Text: "Fight", Next: "start", Battle: &game.Battle{
+			Enemies: []game.Enemy{
+				{Name: "Rat", Strength: 1, Health: 5},
+				{Name: "Bat", Strength: 1, Health: 5},
+			},
+			OnVictoryNext: "end",
+		}},
+		game.Choice{Key: "flag", Text: "Mark", Next: "start", Effects: []game.Effect{
+			{Op: game.OpSetFlag, Flag: "marked"}, {Op: game.OpAddItem, Item: "chalk"}, {Op: game.OpAddVar, Var: "marks", Value: 1},
+		}},
+	)
+	srv.Engine.Stories["lib"] = &game.Story{Library: true, Start: "x", Nodes: map[string]*game.Node{"x": {}}}
+	srv.Engine.Dice = func() int { return 3 }
+	return srv
+}
+
+// apiBegun creates a session and begins the test story, returning its ID.
+func apiBegun(t *testing.T, srv *Server) string {
+	t.Helper()
+	var setup APISetup
+	if rec := apiCall(t, srv, http.MethodPost, "/api/v1/sessions", "", &setup); rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := apiCall(t, srv, http.MethodPost, "/api/v1/sessions/"+setup.SessionID+"/begin", `{"name":"Ann"}`, nil); rec.Code != http.StatusOK {
+		t.Fatalf("begin: %d %s", rec.Code, rec.Body.String())
+	}
+	return setup.SessionID
+}
+
+func TestAPIStories(t *testing.T) {
+	srv := apiTestServer(t)
+	var stories []APIStory
+	rec := apiCall(t, srv, http.MethodGet, "/api/v1/stories", "", &stories)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected 200 JSON, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if len(stories) != 1 || stories[0].ID != testStoryID || stories[0].Title != "Test Story" {
+		t.Fatalf("Expected only the playable story, got %+v", stories)
+	}
+	d := stories[0].Difficulties
+	if len(d) != 3 || d[0].ID != "story" || d[0].Default || !d[1].Default || d[2].Name != "Hard" {
+		t.Errorf("Unexpected difficulties %+v", d)
+	}
+}
+
+func TestAPISessionFlow(t *testing.T) {
+	srv := apiTestServer(t)
+
+	var setup APISetup
+	rec := apiCall(t, srv, http.MethodPost, "/api/v1/sessions", `{"difficulty":"story"}`, &setup)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/api/v1/sessions/"+setup.SessionID {
+		t.Fatalf("Expected 201 with Location, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if setup.StoryID != testStoryID || setup.Difficulty != "story" || setup.RerollsLeft != 2 {
+		t.Errorf("Unexpected setup %+v", setup)
+	}
+	if setup.Stats.Health != setup.BaseStats.Health+4 || setup.Dice.Strength[0] < 1 || setup.Dice.Strength[0] > 6 {
+		t.Errorf("Expected story difficulty stats and real dice, got %+v", setup)
+	}
+	base := "/api/v1/sessions/" + setup.SessionID
+
+	for want := 1; want >= 0; want-- {
+		if rec := apiCall(t, srv, http.MethodPost, base+"/reroll", "", &setup); rec.Code != http.StatusOK || setup.RerollsLeft != want {
+			t.Fatalf("reroll: %d, rerollsLeft %d, want %d", rec.Code, setup.RerollsLeft, want)
+		}
+	}
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, base+"/reroll", "", nil), http.StatusConflict, APIErrNoRerollsLeft)
+
+	var node APINode
+	rec = apiCall(t, srv, http.MethodPost, base+"/begin", `{"name":"  Ann  ","avatar":"female_old"}`, &node)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("begin: %d %s", rec.Code, rec.Body.String())
+	}
+	if node.NodeID != "start" || node.Character.Name != "Ann" || node.Character.Avatar != "female_old" || node.Character.Difficulty != "story" {
+		t.Errorf("Unexpected node after begin %+v", node)
+	}
+	kinds := map[string]string{}
+	for _, c := range node.Choices {
+		kinds[c.Key] = c.Kind
+	}
+	if kinds["next"] != ChoiceKindChoice || kinds["riddle"] != ChoiceKindPrompt || kinds["fight"] != ChoiceKindBattle {
+		t.Errorf("Unexpected choice kinds %v", kinds)
+	}
+
+	if rec := apiCall(t, srv, http.MethodPost, base+"/choices", `{"choice":"flag"}`, &node); rec.Code != http.StatusOK {
+		t.Fatalf("flag: %d %s", rec.Code, rec.Body.String())
+	}
+	if len(node.Character.Flags) != 1 || node.Character.Flags[0] != "marked" || node.Result == nil {
+		t.Errorf("Expected the flag and a result, got %+v", node)
+	}
+	if node.Character.Items["chalk"] != 1 || node.Character.Vars["marks"] != 1 {
+		t.Errorf("Expected the chalk and one mark, got %v %v", node.Character.Items, node.Character.Vars)
+	}
+	if rec := apiCall(t, srv, http.MethodPost, base+"/choices", `{"choice":"next"}`, &node); rec.Code != http.StatusOK {
+		t.Fatalf("next: %d %s", rec.Code, rec.Body.String())
+	}
+	if node.NodeID != "end" || !node.Ending || len(node.Choices) != 0 || !node.Result.NewEnding {
+		t.Errorf("Expected the ending, got %+v", node)
+	}
+
+	var got APINode
+	if rec := apiCall(t, srv, http.MethodGet, base, "", &got); rec.Code != http.StatusOK || got.NodeID != "end" || got.Result != nil {
+		t.Errorf("GET node: %d %+v", rec.Code, got)
+	}
+
+	var h APIHistory
+	if rec := apiCall(t, srv, http.MethodGet, base+"/history", "", &h); rec.Code != http.StatusOK {
+		t.Fatalf("history: %d", rec.Code)
+	}
+	if strings.Join(h.Path, ",") != "start,end" || len(h.Records[testStoryID].Endings) != 1 {
+		t.Errorf("Unexpected history %+v", h)
+	}
+}
+
+func TestAPIChoice_Refusals(t *testing.T) {
+	srv := apiTestServer(t)
+	id := apiBegun(t, srv)
+	base := "/api/v1/sessions/" + id
+
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, base+"/choices", `{"choice":"nope"}`, nil), http.StatusUnprocessableEntity, game.RefusalUnknownChoice)
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, base+"/choices", `{"choice":"riddle"}`, nil), http.StatusUnprocessableEntity, game.RefusalAnswerRequired)
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, base+"/choices", `{"choice":"riddle","answer":"cat"}`, nil), http.StatusUnprocessableEntity, game.RefusalWrongAnswer)
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, base+"/choices", `{}`, nil), http.StatusBadRequest, APIErrInvalidRequest)
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, base+"/choices", `{"choice":`, nil), http.StatusBadRequest, APIErrInvalidRequest)
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, "/api/v1/sessions/missing/choices", `{"choice":"next"}`, nil), http.StatusNotFound, APIErrSessionNotFound)
+
+	// Refusals leave the session untouched.
+	var h APIHistory
+	apiCall(t, srv, http.MethodGet, base+"/history", "", &h)
+	if len(h.Path) != 1 {
+		t.Errorf("Expected no movement after refusals, got %v", h.Path)
+	}
+
+	var node APINode
+	if rec := apiCall(t, srv, http.MethodPost, base+"/choices", `{"choice":"riddle","answer":"Man"}`, &node); rec.Code != http.StatusOK || node.NodeID != "end" {
+		t.Errorf("Expected the right answer to pass, got %d %+v", rec.Code, node)
+	}
+}
+
+func TestAPIBattleChoices(t *testing.T) {
+	srv := apiTestServer(t)
+	id := apiBegun(t, srv)
+
+	var node APINode
+	rec := apiCall(t, srv, http.MethodPost, "/api/v1/sessions/"+id+"/choices", `{"choice":"fight"}`, &node)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fight: %d %s", rec.Code, rec.Body.String())
+	}
+	if len(node.Enemies) != 2 || node.Enemies[0].Name != "Rat" || node.Enemies[0].Health != 4 {
+		t.Errorf("Expected both enemies with the rat hit, got %+v", node.Enemies)
+	}
+	if r := node.Result; r == nil || r.PlayerDice == nil || r.EnemyDice == nil || *r.Outcome != game.OutcomePlayerHit {
+		t.Errorf("Expected battle dice and outcome, got %+v", r)
+	}
+	if len(node.Choices) != 5 {
+		t.Fatalf("Expected attack and luck per enemy plus run, got %+v", node.Choices)
+	}
+	c := node.Choices[3]
+	if c.Key != "fight:luck:1" || c.Kind != ChoiceKindLuck || c.Target == nil || *c.Target != 1 || c.Text != "Luck Bat" {
+		t.Errorf("Unexpected battle choice %+v", c)
+	}
+	if run := node.Choices[4]; run.Kind != ChoiceKindRun || run.Target != nil {
+		t.Errorf("Unexpected run choice %+v", run)
+	}
+}
+
+func TestAPIBegin_Errors(t *testing.T) {
+	srv := apiTestServer(t)
+	var setup APISetup
+	apiCall(t, srv, http.MethodPost, "/api/v1/sessions", "", &setup)
+	base := "/api/v1/sessions/" + setup.SessionID
+
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, base+"/begin", `{"storyId":"lib"}`, nil), http.StatusBadRequest, APIErrUnknownStory)
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, base+"/begin", `{"difficulty":"nightmare"}`, nil), http.StatusBadRequest, APIErrUnknownDifficulty)
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, base+"/begin", `{"avatar":"robot"}`, nil), http.StatusBadRequest, APIErrInvalidAvatar)
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, base+"/begin", `{"continue":true}`, nil), http.StatusConflict, APIErrNoSequelHero)
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, base+"/reroll", `{"storyId":"nope"}`, nil), http.StatusBadRequest, APIErrUnknownStory)
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, base+"/reroll", `{"difficulty":"nope"}`, nil), http.StatusBadRequest, APIErrUnknownDifficulty)
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, "/api/v1/sessions", `[`, nil), http.StatusBadRequest, APIErrInvalidRequest)
+	expectAPIError(t, apiCall(t, srv, http.MethodGet, "/api/v1/sessions/missing", "", nil), http.StatusNotFound, APIErrSessionNotFound)
+
+	begun := "/api/v1/sessions/" + apiBegun(t, srv)
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, begun+"/begin", `{"difficulty":"story"}`, nil), http.StatusConflict, APIErrAlreadyBegun)
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, begun+"/reroll", `{"difficulty":"story"}`, nil), http.StatusConflict, APIErrAlreadyBegun)
+	expectAPIError(t, apiCall(t, srv, http.MethodGet, "/api/v1/sessions/missing/history", "", nil), http.StatusNotFound, APIErrSessionNotFound)
+
+	// The story and difficulty are fixed at the first reroll.
+	apiCall(t, srv, http.MethodPost, "/api/v1/sessions", `{"difficulty":"story"}`, &setup)
+	rerolled := "/api/v1/sessions/" + setup.SessionID
+	if rec := apiCall(t, srv, http.MethodPost, rerolled+"/reroll", "", nil); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for the first reroll, got %d", rec.Code)
+	}
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, rerolled+"/reroll", `{"difficulty":"normal"}`, nil), http.StatusConflict, APIErrSetupLocked)
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, rerolled+"/begin", `{"difficulty":"hard"}`, nil), http.StatusConflict, APIErrSetupLocked)
+	if rec := apiCall(t, srv, http.MethodPost, rerolled+"/begin", `{"difficulty":"story"}`, nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 to begin on the rerolled difficulty, got %d", rec.Code)
+	}
+
+	// A choice made before begin begins the run.
+	apiCall(t, srv, http.MethodPost, "/api/v1/sessions", "", &setup)
+	played := "/api/v1/sessions/" + setup.SessionID
+	if rec := apiCall(t, srv, http.MethodPost, played+"/choices", `{"choice":"flag"}`, nil); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for the choice, got %d", rec.Code)
+	}
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, played+"/reroll", `{"difficulty":"story"}`, nil), http.StatusConflict, APIErrAlreadyBegun)
+
+	// Hard allows no rerolls at all.
+	apiCall(t, srv, http.MethodPost, "/api/v1/sessions", `{"difficulty":"hard"}`, &setup)
+	if setup.RerollsLeft != 0 {
+		t.Errorf("Expected no rerolls on hard, got %d", setup.RerollsLeft)
+	}
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, "/api/v1/sessions/"+setup.SessionID+"/reroll", "", nil), http.StatusConflict, APIErrNoRerollsLeft)
+}
+
+func TestAPIDoc(t *testing.T) {
+	srv := apiTestServer(t)
+	rec := apiCall(t, srv, http.MethodGet, "/api/v1/openapi.yaml", "", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/yaml" {
+		t.Fatalf("Expected the YAML document, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var doc struct {
+		OpenAPI string                    `yaml:"openapi"`
+		Paths   map[string]map[string]any `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("document does not parse: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("Expected OpenAPI 3, got %q", doc.OpenAPI)
+	}
+	// Every route registered in apiRoutes is documented.
+	for _, route := range []string{
+		"GET /openapi.yaml", "GET /stories", "POST /sessions", "GET /sessions/{id}",
+		"POST /sessions/{id}/reroll", "POST /sessions/{id}/begin",
+		"POST /sessions/{id}/choices", "GET /sessions/{id}/history",
+	} {
+		method, path, _ := strings.Cut(route, " ")
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("%s is not documented", route)
+		}
+	}
+}
+
+func TestAPIChoice_Revision(t *testing.T) {
+	srv := apiTestServer(t)
+	id := apiBegun(t, srv)
+	base := "/api/v1/sessions/" + id
+
+	var node APINode
+	apiCall(t, srv, http.MethodGet, base, "", &node)
+	first := node.Revision
+	if first == 0 {
+		t.Fatal("Expected a revision on the node")
+	}
+	body := `{"choice":"flag","revision":` + strconv.FormatInt(first, 10) + `}`
+	if rec := apiCall(t, srv, http.MethodPost, base+"/choices", body, &node); rec.Code != http.StatusOK || node.Revision <= first {
+		t.Fatalf("Expected the choice at the current revision to pass, got %d revision %d", rec.Code, node.Revision)
+	}
+	// The same request again, e.g. a retried submit, is stale.
+	expectAPIError(t, apiCall(t, srv, http.MethodPost, base+"/choices", body, nil), http.StatusConflict, APIErrStaleRevision)
+	var h APIHistory
+	apiCall(t, srv, http.MethodGet, base+"/history", "", &h)
+	if len(h.Path) != 1 {
+		t.Errorf("Expected the stale choice not to apply, got %v", h.Path)
+	}
+}
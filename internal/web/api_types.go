@@ -0,0 +1,239 @@
+package web
+
+import (
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+
+	"adventure/internal/game"
+	"adventure/internal/leaderboard"
+)
+
+// API choice kinds: what submitting a choice's key does.
+const (
+	ChoiceKindChoice = "choice" // move on (possibly after a check)
+	ChoiceKindPrompt = "prompt" // needs an answer
+	ChoiceKindBattle = "battle" // starts a battle (first round attacks the first enemy)
+	ChoiceKindAttack = "attack" // battle round against Target
+	ChoiceKindLuck   = "luck"   // luck attack against Target: double damage, -1 Luck
+	ChoiceKindRun    = "run"    // flee the battle
+)
+
+// APIError is the body of every non-2xx /api/v1 response.
+type APIError struct {
+	Error APIErrorDetail `json:"error"`
+}
+
+// APIErrorDetail is a machine-readable code plus a message for people.
+type APIErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// APIStats mirrors game.Stats with JSON names.
+type APIStats struct {
+	Strength int `json:"strength"`
+	Luck     int `json:"luck"`
+	Health   int `json:"health"`
+}
+
+// APIStatDice are the two d6 rolled for each stat.
+type APIStatDice struct {
+	Strength [2]int `json:"strength"`
+	Luck     [2]int `json:"luck"`
+	Health   [2]int `json:"health"`
+}
+
+// APISetup is a session during character creation.
+type APISetup struct {
+	SessionID   string        `json:"sessionId"`
+	StoryID     string        `json:"storyId"`
+	Difficulty  string        `json:"difficulty,omitempty"`
+	Name        string        `json:"name"`
+	Avatar      string        `json:"avatar"`
+	Stats       APIStats      `json:"stats"`     // after difficulty modifiers
+	BaseStats   APIStats      `json:"baseStats"` // as rolled
+	Dice        APIStatDice   `json:"dice"`
+	RerollsLeft int           `json:"rerollsLeft"`
+	Sequel      *SequelOption `json:"sequel,omitempty"`
+}
+
+// APICharacter is the player's character during play.
+type APICharacter struct {
+	Name       string         `json:"name"`
+	Avatar     string         `json:"avatar"`
+	Difficulty string         `json:"difficulty,omitempty"`
+	Stats      APIStats       `json:"stats"`
+	Flags      []string       `json:"flags"` // set flags, sorted
+	Items      map[string]int `json:"items"` // item name -> number carried
+	Vars       map[string]int `json:"vars"`  // story variables
+}
+
+// APIEnemy is one enemy in the current battle.
+type APIEnemy struct {
+	Name     string `json:"name"`
+	Strength int    `json:"strength"`
+	Health   int    `json:"health"`
+}
+
+// APIChoice is one key the client may submit.
+type APIChoice struct {
+	Key    string     `json:"key"`
+	Text   string     `json:"text"`
+	Kind   string     `json:"kind"`
+	Target *int       `json:"target,omitempty"` // enemy index for attack and luck
+	Prompt *APIPrompt `json:"prompt,omitempty"`
+}
+
+// APIPrompt describes the answer a prompt choice expects.
+type APIPrompt struct {
+	Question    string `json:"question"`
+	Placeholder string `json:"placeholder,omitempty"`
+}
+
+// APIAchievement is an unlocked achievement.
+type APIAchievement struct {
+	ID          string `json:"id"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// APIResult reports what the last choice did.
+type APIResult struct {
+	Roll       *int               `json:"roll,omitempty"`
+	PlayerDice *[2]int            `json:"playerDice,omitempty"`
+	EnemyDice  *[2]int            `json:"enemyDice,omitempty"`
+	Outcome    *string            `json:"outcome,omitempty"`
+	Unlocked   []APIAchievement   `json:"unlocked,omitempty"`
+	NewEnding  bool               `json:"newEnding"`
+	Score      *game.Score        `json:"score,omitempty"` // the step finished a scored run
+	Ranks      *leaderboard.Ranks `json:"ranks,omitempty"` // where the run placed on the leaderboards
+}
+
+// APINode is the current scene: what the web UI's game view shows.
+type APINode struct {
+	SessionID string       `json:"sessionId"`
+	Revision  int64        `json:"revision"` // changes with every write; send it with a choice to reject stale ones
+	StoryID   string       `json:"storyId"`
+	NodeID    string       `json:"nodeId"`
+	Text      string       `json:"text"`
+	Scenery   string       `json:"scenery,omitempty"`
+	Ending    bool         `json:"ending"`
+	Character APICharacter `json:"character"`
+	Enemies   []APIEnemy   `json:"enemies"`
+	Choices   []APIChoice  `json:"choices"`
+	Result    *APIResult   `json:"result,omitempty"`
+}
+
+// APIRecord is what the player has found in one story across runs.
+type APIRecord struct {
+	Endings      []string `json:"endings"`
+	Achievements []string `json:"achievements"`
+}
+
+// APIHistory is the current run's path and the player's records.
+type APIHistory struct {
+	SessionID string               `json:"sessionId"`
+	StoryID   string               `json:"storyId"`
+	Path      []string             `json:"path"`     // node IDs in the order visited this run
+	Defeated  map[string]int       `json:"defeated"` // enemy name -> times defeated this run
+	Records   map[string]APIRecord `json:"records"`  // story ID -> records
+}
+
+// APIDifficulty is a story's difficulty preset.
+type APIDifficulty struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     bool   `json:"default"`
+}
+
+// APIStory is a playable story.
+type APIStory struct {
+	ID           string          `json:"id"`
+	Title        string          `json:"title"`
+	Continues    string          `json:"continues,omitempty"` // prequel story ID
+	Difficulties []APIDifficulty `json:"difficulties"`
+}
+
+func apiStats(s game.Stats) APIStats {
+	return APIStats{Strength: s.Strength, Luck: s.Luck, Health: s.Health}
+}
+
+func apiCharacter(st *game.PlayerState) APICharacter {
+	c := APICharacter{
+		Name: st.Name, Avatar: st.Avatar, Difficulty: st.Difficulty, Stats: apiStats(st.Stats),
+		Flags: []string{}, Items: map[string]int{}, Vars: map[string]int{},
+	}
+	for f, on := range st.Flags {
+		if on {
+			c.Flags = append(c.Flags, f)
+		}
+	}
+	sort.Strings(c.Flags)
+	maps.Copy(c.Items, st.Items)
+	maps.Copy(c.Vars, st.Vars)
+	return c
+}
+
+// apiNode converts a game view model to its API form.
+func apiNode(vm *ViewModel) APINode {
+	n := APINode{
+		SessionID: vm.SessionID,
+		Revision:  vm.Revision,
+		StoryID:   vm.State.StoryID,
+		NodeID:    vm.State.NodeID,
+		Text:      vm.Node.Text,
+		Scenery:   vm.Node.Scenery,
+		Ending:    vm.Node.Ending,
+		Character: apiCharacter(&vm.State),
+		Enemies:   make([]APIEnemy, 0, len(vm.Enemies)),
+		Choices:   []APIChoice{},
+	}
+	for _, e := range vm.Enemies {
+		n.Enemies = append(n.Enemies, APIEnemy{Name: e.Name, Strength: e.Strength, Health: e.Health})
+	}
+	if vm.Node.Ending {
+		return n
+	}
+	if vm.EffectiveChoices != nil {
+		prefix := vm.BattleChoicePrefix + ":"
+		for _, bc := range vm.EffectiveChoices {
+			c := APIChoice{Key: bc.Key, Text: bc.Text, Kind: ChoiceKindRun}
+			action, target, _ := strings.Cut(strings.TrimPrefix(bc.Key, prefix), ":")
+			if idx, err := strconv.Atoi(target); err == nil && (action == ChoiceKindAttack || action == ChoiceKindLuck) {
+				c.Kind, c.Target = action, &idx
+			}
+			n.Choices = append(n.Choices, c)
+		}
+		return n
+	}
+	for i := range vm.Choices {
+		ch := &vm.Choices[i]
+		c := APIChoice{Key: ch.Key, Text: ch.Text, Kind: ChoiceKindChoice}
+		switch {
+		case ch.Prompt != nil:
+			c.Kind = ChoiceKindPrompt
+			c.Prompt = &APIPrompt{Question: ch.Prompt.Question, Placeholder: ch.Prompt.Placeholder}
+		case ch.Battle != nil:
+			c.Kind = ChoiceKindBattle
+		}
+		n.Choices = append(n.Choices, c)
+	}
+	return n
+}
+
+func apiResult(res *game.StepResult) *APIResult {
+	r := &APIResult{
+		Roll:       res.LastRoll,
+		PlayerDice: res.LastPlayerDice,
+		EnemyDice:  res.LastEnemyDice,
+		Outcome:    res.LastOutcome,
+		NewEnding:  res.NewEnding,
+	}
+	for _, a := range res.Unlocked {
+		r.Unlocked = append(r.Unlocked, APIAchievement{ID: a.ID, Title: a.Title, Description: a.Description})
+	}
+	return r
+}
@@ -1,21 +1,23 @@
 package web
 
 import (
-	"path/filepath"
+	"io/fs"
+	"path"
 	"strings"
 )
 
 const assetCacheControl = "public, max-age=3600"
 
-// storyAssetCandidates validates the request path and returns possible asset paths.
+// storyAssetCandidates validates the request path and returns possible asset
+// paths within the story files.
 func (s *Server) storyAssetCandidates(prefix, urlPath, subdir string, extensions []string) ([]string, bool) {
 	if !strings.HasPrefix(urlPath, prefix) {
 		return nil, false
 	}
 
-	path := strings.TrimPrefix(urlPath, prefix)
-	path = strings.Trim(path, "/")
-	parts := strings.SplitN(path, "/", 2)
+	rest := strings.TrimPrefix(urlPath, prefix)
+	rest = strings.Trim(rest, "/")
+	parts := strings.SplitN(rest, "/", 2)
 	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
 		return nil, false
 	}
@@ -25,16 +27,13 @@ func (s *Server) storyAssetCandidates(prefix, urlPath, subdir string, extensions
 		return nil, false
 	}
 
-	safeFilename := filepath.Clean(filename)
-	if safeFilename == "" || safeFilename == "." || strings.Contains(safeFilename, "..") ||
-		filepath.IsAbs(safeFilename) || strings.Contains(safeFilename, string(filepath.Separator)) {
+	if filename == "." || strings.Contains(filename, "..") || strings.ContainsAny(filename, `/\`) {
 		return nil, false
 	}
 
-	baseDir := filepath.Join(s.storiesBase(), storyID, subdir)
-	resolved := filepath.Join(baseDir, safeFilename)
-	rel, err := filepath.Rel(baseDir, resolved)
-	if err != nil || strings.Contains(rel, "..") {
+	baseDir := path.Join(storyID, subdir)
+	resolved := path.Join(baseDir, filename)
+	if !fs.ValidPath(resolved) || path.Dir(resolved) != baseDir {
 		return nil, false
 	}
 
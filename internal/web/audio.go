@@ -1,9 +1,10 @@
 package web
 
 import (
+	"io"
+	"io/fs"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
 	"strings"
 )
 
@@ -19,7 +20,7 @@ const (
 )
 
 // handleAudio serves scene audio from the per-story directory
-// stories/<storyID>/audio/. URL shape: /audio/<storyID>/<filename> (no extension;
+// <storyID>/audio/ of the story files. URL shape: /audio/<storyID>/<filename> (no extension;
 // server tries .mp3, .ogg, .wav, .m4a). StoryID must be in Engine.Stories; filename
 // must be safe (no path traversal).
 func (s *Server) handleAudio(w http.ResponseWriter, r *http.Request) {
@@ -34,17 +35,17 @@ func (s *Server) handleAudio(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var file *os.File
-	var fileInfo os.FileInfo
+	var file fs.File
+	var fileInfo fs.FileInfo
 	var filePath string
 	var contentType string
 	for _, p := range candidates {
-		f, err := os.Open(p) // #nosec G304 -- p is under validated baseDir (stories/<storyID>/audio)
+		f, err := s.storyFiles().Open(p)
 		if err != nil {
 			continue
 		}
 		info, err := f.Stat()
-		if err != nil || info.IsDir() {
+		if _, seeks := f.(io.ReadSeeker); err != nil || info.IsDir() || !seeks {
 			if closeErr := f.Close(); closeErr != nil {
 				_ = closeErr
 			}
@@ -53,7 +54,7 @@ func (s *Server) handleAudio(w http.ResponseWriter, r *http.Request) {
 		file = f
 		fileInfo = info
 		filePath = p
-		switch strings.ToLower(filepath.Ext(p)) {
+		switch strings.ToLower(path.Ext(p)) {
 		case ".mp3":
 			contentType = contentTypeMP3
 		case ".ogg":
@@ -79,5 +80,5 @@ func (s *Server) handleAudio(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Cache-Control", assetCacheControl)
-	http.ServeContent(w, r, filepath.Base(filePath), fileInfo.ModTime(), file)
+	http.ServeContent(w, r, path.Base(filePath), fileInfo.ModTime(), file.(io.ReadSeeker))
 }
@@ -32,7 +32,7 @@ func TestHandleAudio_ServesFileFromStoryDir(t *testing.T) {
 
 	srv := &Server{
 		Engine:     &game.Engine{Stories: map[string]*game.Story{audioTestStoryID: {Start: "a", Nodes: map[string]*game.Node{"a": {Text: "Start"}}}}},
-		StoriesDir: tmpDir,
+		StoryFiles: os.DirFS(tmpDir),
 	}
 	req := httptest.NewRequest(http.MethodGet, "/audio/"+audioTestStoryID+"/ambient", http.NoBody)
 	rec := httptest.NewRecorder()
@@ -53,7 +53,7 @@ func TestHandleAudio_UnknownStory_NotFound(t *testing.T) {
 	tmpDir := t.TempDir()
 	srv := &Server{
 		Engine:     &game.Engine{Stories: map[string]*game.Story{"other": {Start: "a", Nodes: map[string]*game.Node{"a": {Text: "Start"}}}}},
-		StoriesDir: tmpDir,
+		StoryFiles: os.DirFS(tmpDir),
 	}
 	req := httptest.NewRequest(http.MethodGet, "/audio/unknown_story/ambient", http.NoBody)
 	rec := httptest.NewRecorder()
@@ -73,7 +73,7 @@ func TestHandleAudio_NonexistentFile_NotFound(t *testing.T) {
 
 	srv := &Server{
 		Engine:     &game.Engine{Stories: map[string]*game.Story{audioTestStoryID: {Start: "a", Nodes: map[string]*game.Node{"a": {Text: "Start"}}}}},
-		StoriesDir: tmpDir,
+		StoryFiles: os.DirFS(tmpDir),
 	}
 	req := httptest.NewRequest(http.MethodGet, "/audio/"+audioTestStoryID+"/nonexistent", http.NoBody)
 	rec := httptest.NewRecorder()
@@ -88,7 +88,7 @@ func TestHandleAudio_PathTraversal_NotFound(t *testing.T) {
 	tmpDir := t.TempDir()
 	srv := &Server{
 		Engine:     &game.Engine{Stories: map[string]*game.Story{audioTestStoryID: {Start: "a", Nodes: map[string]*game.Node{"a": {Text: "Start"}}}}},
-		StoriesDir: tmpDir,
+		StoryFiles: os.DirFS(tmpDir),
 	}
 
 	tests := []struct {
@@ -124,7 +124,7 @@ func TestHandleAudio_MethodNotAllowed(t *testing.T) {
 	tmpDir := t.TempDir()
 	srv := &Server{
 		Engine:     &game.Engine{Stories: map[string]*game.Story{audioTestStoryID: {Start: "a", Nodes: map[string]*game.Node{"a": {Text: "Start"}}}}},
-		StoriesDir: tmpDir,
+		StoryFiles: os.DirFS(tmpDir),
 	}
 	req := httptest.NewRequest(http.MethodPost, "/audio/"+audioTestStoryID+"/ambient", http.NoBody)
 	rec := httptest.NewRecorder()
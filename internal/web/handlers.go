@@ -3,45 +3,170 @@ package web
 
 import (
 	"context"
+	"errors"
 	"html/template"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
+	"sync"
 
+	"adventure/internal/account"
+	"adventure/internal/analytics"
 	"adventure/internal/game"
+	"adventure/internal/leaderboard"
 	"adventure/internal/session"
 )
 
 // Server handles HTTP requests for the adventure game.
 type Server struct {
-	Engine     *game.Engine
-	Store      session.Store[game.PlayerState]
-	Tmpl       *template.Template
-	StoriesDir string // optional; base dir for stories (scenery handler; tests set to temp dir)
+	Engine       *game.Engine
+	Store        session.Store[game.PlayerState]
+	Tmpl         *template.Template
+	StoryFiles   fs.FS                // optional; story dirs with scenery and audio (default: ./stories)
+	StaticFiles  fs.FS                // optional; files served under /static/ (default: ./static)
+	DefaultStory string               // optional; story new players start, if it exists
+	Cookies      CookieOptions        // settings for the cookies the server sets
+	Parties      *Parties             // optional; nil disables party mode
+	Spectators   *Spectators          // optional; nil disables spectator links
+	Accounts     *account.Service     // optional; nil disables player accounts
+	Leaderboards *leaderboard.Service // optional; nil disables leaderboards
+	Analytics    *analytics.Recorder  // optional; nil disables play analytics
+	Metrics      *Metrics             // optional; nil disables metrics
+	Logger       *slog.Logger         // optional; nil logs to slog.Default
+	// AnalyticsPassword, when set, is asked for (HTTP basic auth) by the
+	// analytics pages.
+	AnalyticsPassword string
+	// MetricsPath is where Routes serves Metrics; "" keeps them off the
+	// game's routes, for serving Metrics.Registry on a private listener.
+	MetricsPath string
+
+	drainInit, drainOnce sync.Once
+	drained              chan struct{} // closed by Drain
 }
 
 const cookieName = "adventure_sid"
 
+// defaultStaticDir is served under /static/ when StaticFiles is unset.
+const defaultStaticDir = "static"
+
+// Cookie Secure modes.
+const (
+	CookieSecureAuto   = "auto"   // Secure when the request came over TLS
+	CookieSecureAlways = "always" // for TLS terminated by a proxy, as on Cloud Run
+	CookieSecureNever  = "never"
+)
+
+// CookieOptions are settings for the cookies the server sets.
+type CookieOptions struct {
+	Secure   string        // a CookieSecure mode; "" means CookieSecureAuto
+	Domain   string        // optional; "" scopes cookies to the host
+	SameSite http.SameSite // 0 means http.SameSiteLaxMode
+}
+
+// cookie returns an HTTP-only cookie for the whole site with the server's
+// cookie options. maxAge is as in http.Cookie: 0 for a session cookie, -1
+// to delete it.
+func (s *Server) cookie(r *http.Request, name, value string, maxAge int) *http.Cookie {
+	return s.Cookies.Cookie(r, name, value, maxAge)
+}
+
+// Cookie returns an HTTP-only cookie for the whole site with these options,
+// for r. maxAge is as in http.Cookie. A session.CookieStore's NewCookie can
+// be set to it so the game's cookie matches the server's.
+func (o CookieOptions) Cookie(r *http.Request, name, value string, maxAge int) *http.Cookie {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   o.Domain,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: o.SameSite,
+	}
+	switch o.Secure {
+	case CookieSecureAlways:
+		c.Secure = true
+	case CookieSecureNever:
+		c.Secure = false
+	}
+	if c.SameSite == 0 {
+		c.SameSite = http.SameSiteLaxMode
+	}
+	return c
+}
+
 // Routes returns an HTTP handler with all registered routes.
 func (s *Server) Routes() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleIndex)
 
 	mux.HandleFunc("/start", s.handleStart)
+	mux.HandleFunc("POST /restart", s.handleRestart)
 	mux.HandleFunc("/reroll", s.handleReroll)
 	mux.HandleFunc("/begin", s.handleBegin)
+	mux.HandleFunc("/difficulties", s.handleDifficulties)
 
 	mux.HandleFunc("/play", s.handlePlay)
+	mux.HandleFunc("GET /game", s.handleGame)
 	mux.HandleFunc("/map", s.handleMap)
+	mux.HandleFunc("/trophies", s.handleTrophies)
 	mux.HandleFunc("/scenery/", s.handleScenery)
 	mux.HandleFunc("/audio/", s.handleAudio)
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
-	return mux
+	static := s.StaticFiles
+	if static == nil {
+		static = os.DirFS(defaultStaticDir)
+	}
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+	s.apiRoutes(mux)
+	s.partyRoutes(mux)
+	s.spectateRoutes(mux)
+	s.accountRoutes(mux)
+	s.leaderboardRoutes(mux)
+	s.analyticsRoutes(mux)
+	s.healthRoutes(mux)
+	if s.Metrics != nil && s.MetricsPath != "" {
+		mux.Handle("GET "+s.MetricsPath, s.Metrics.Registry.Handler())
+	}
+	return s.Metrics.instrument(mux, s.withRequestLog(mux, withSessionCookies(mux)))
+}
+
+// withSessionCookies hands the request's cookies to the session store, for
+// stores that keep sessions in them. API clients name sessions by ID rather
+// than by cookie, so their sessions always stay on the server.
+func withSessionCookies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			r = r.WithContext(session.WithCookies(r.Context(), w, r))
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if a, _ := s.account(r); a != nil { // on error, carry on as a guest
+		http.Redirect(w, r, "/characters", http.StatusFound)
+		return
+	}
 	http.Redirect(w, r, "/start", http.StatusFound)
 }
 
+// maxPlayAttempts bounds how often /play re-reads the session and applies a
+// choice again when another request saved it first.
+const maxPlayAttempts = 3
+
+// staleMessage is shown when a choice comes from a page that is out of date.
+const staleMessage = "That page was out of date, so your choice was not made. This is where your adventure stands now."
+
+// POST /play applies a choice. The state is read and written with the
+// store's compare-and-swap, so two submissions can't both apply to the same
+// state. The form carries the revision it was rendered at: a choice from an
+// older page, such as a double click or one reached with the back button, is
+// rejected with 409 and the current view. Without a revision, a conflicting
+// save is retried on the fresh state.
 func (s *Server) handlePlay(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	if err := r.ParseForm(); err != nil {
@@ -49,42 +174,115 @@ func (s *Server) handlePlay(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	choice := r.FormValue("choice")
+	answer := r.FormValue("answer")
+	want, hasRev := int64(0), r.FormValue("rev") != ""
+	if hasRev {
+		var err error
+		if want, err = strconv.ParseInt(r.FormValue("rev"), 10, 64); err != nil {
+			http.Error(w, "bad revision", 400)
+			return
+		}
+	}
 
-	st, sessionID, found := s.getOrCreateState(ctx, w, r)
+	st, rev, sessionID, found := s.getOrCreateState(ctx, w, r)
 	if !found {
 		http.Redirect(w, r, "/start", http.StatusFound)
 		return
 	}
 
-	answer := r.FormValue("answer")
-
-	res, err := s.Engine.ApplyChoiceWithAnswer(&st, choice, answer)
-	if err != nil {
-		http.Error(w, err.Error(), 500)
-		return
-	}
-	if err := s.Store.Put(ctx, sessionID, res.State); err != nil {
-		http.Error(w, "failed to save state", 500)
-		return
+	for attempt := 1; ; attempt++ {
+		if hasRev && rev != want {
+			s.renderStale(w, r, &st, sessionID, rev)
+			return
+		}
+		before := st
+		res, err := s.Engine.ApplyChoiceWithAnswer(&st, choice, answer)
+		if err != nil {
+			s.serverError(w, r, "failed to apply choice", err)
+			return
+		}
+		newRev, err := s.Store.CompareAndPut(ctx, sessionID, res.State, rev)
+		if err == nil {
+			s.stepSaved(ctx, sessionID, &before, choice, &res)
+			s.renderPlay(w, r, &res, s.recordScore(ctx, sessionID, &res), sessionID, newRev)
+			return
+		}
+		if !errors.Is(err, session.ErrConflict) {
+			s.serverError(w, r, "failed to save state", err)
+			return
+		}
+		// Another request saved first: start again from what it saved.
+		var ok bool
+		if st, rev, ok, err = s.Store.GetRev(ctx, sessionID); err != nil {
+			s.serverError(w, r, "failed to load session", err)
+			return
+		}
+		if !ok {
+			http.Redirect(w, r, "/start", http.StatusFound)
+			return
+		}
+		if !hasRev && attempt == maxPlayAttempts {
+			s.renderStale(w, r, &st, sessionID, rev)
+			return
+		}
 	}
+}
+
+// stepSaved tells spectators, analytics and metrics about a step once it is
+// saved. before is the state the choice was applied to. The HTML and JSON
+// handlers both call it, so a step is seen the same way however it was made.
+func (s *Server) stepSaved(ctx context.Context, sessionID string, before *game.PlayerState, choice string, res *game.StepResult) {
+	s.Spectators.Publish(sessionID, res)
+	s.recordStep(ctx, sessionID, before, choice, res)
+	s.Metrics.Step(res)
+}
 
-	msg := res.ErrorMessage
-	vm, err := s.makeViewModel(&res.State, msg, res.LastRoll, res.LastOutcome, res.LastPlayerDice, res.LastEnemyDice)
+// begunSaved tells spectators and analytics that a session has begun its
+// adventure, once that is saved.
+func (s *Server) begunSaved(ctx context.Context, sessionID string, st *game.PlayerState) {
+	s.Spectators.Publish(sessionID, &game.StepResult{State: *st})
+	s.recordBegin(ctx, sessionID, st)
+}
+
+// renderPlay renders a step's result: the #game fragment and OOB sidebars.
+func (s *Server) renderPlay(w http.ResponseWriter, r *http.Request, res *game.StepResult, score *ScoreView, sessionID string, rev int64) {
+	vm, err := s.makeViewModel(&res.State, res.ErrorMessage, res.LastRoll, res.LastOutcome, res.LastPlayerDice, res.LastEnemyDice)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		s.serverError(w, r, "failed to load the current node", err)
 		return
 	}
 	vm.SessionID = sessionID
+	vm.Revision = rev
+	vm.Unlocked = res.Unlocked
+	vm.NewEnding = res.NewEnding
+	vm.Score = score
 
 	// htmx: return #game fragment + OOB sidebars; client skips sync and only runs dice animation
 	w.Header().Set("X-Adventure-OOB", "true")
-	if err := s.Tmpl.ExecuteTemplate(w, "game_response.html", vm); err != nil {
+	if err := s.executeTemplate(r.Context(), w, "game_response.html", vm); err != nil {
 		http.Error(w, "failed to render template", 500)
 		return
 	}
 }
 
-func (s *Server) getOrCreateState(ctx context.Context, w http.ResponseWriter, r *http.Request) (state game.PlayerState, sessionID string, found bool) {
+// renderStale rejects a choice made on an outdated page with 409, showing
+// the current state so the player can carry on from there.
+func (s *Server) renderStale(w http.ResponseWriter, r *http.Request, st *game.PlayerState, sessionID string, rev int64) {
+	vm, err := s.makeViewModel(st, staleMessage, nil, nil, nil, nil)
+	if err != nil {
+		s.serverError(w, r, "failed to load the current node", err)
+		return
+	}
+	vm.SessionID = sessionID
+	vm.Revision = rev
+	w.Header().Set("X-Adventure-OOB", "true")
+	w.WriteHeader(http.StatusConflict)
+	_ = s.executeTemplate(r.Context(), w, "game_response.html", vm) //nolint:errcheck // the status is already sent
+}
+
+// getOrCreateState loads the session named by the form or cookie, with its
+// revision, or creates a new one when there is neither.
+func (s *Server) getOrCreateState(ctx context.Context, w http.ResponseWriter, r *http.Request) (state game.PlayerState, rev int64, sessionID string, found bool) {
 	formID := r.FormValue("session_id")
 	cookieID := s.sessionID(r)
 	var id string
@@ -96,51 +294,32 @@ func (s *Server) getOrCreateState(ctx context.Context, w http.ResponseWriter, r
 	if id != "" {
 		var ok bool
 		var err error
-		state, ok, err = s.Store.Get(ctx, id)
+		state, rev, ok, err = s.Store.GetRev(ctx, id)
 		if err != nil {
-			return game.PlayerState{}, "", false
+			s.logger().ErrorContext(ctx, "failed to load session", slog.Any("err", err))
+			return game.PlayerState{}, 0, "", false
 		}
 		if ok {
+			logSession(ctx, id, &state)
 			if formID != "" && cookieID != formID {
-				http.SetCookie(w, &http.Cookie{
-					Name:     cookieName,
-					Value:    id,
-					Path:     "/",
-					HttpOnly: true,
-					Secure:   r.TLS != nil,
-					SameSite: http.SameSiteLaxMode,
-				})
+				http.SetCookie(w, s.cookie(r, cookieName, id, 0))
 			}
-			return state, id, true
+			return state, rev, id, true
 		}
 		// id was provided (e.g. cookie) but session not in store -> stale/invalid
-		return game.PlayerState{}, "", false
+		return game.PlayerState{}, 0, "", false
 	}
 	id = s.Store.NewID()
-	http.SetCookie(w, &http.Cookie{
-		Name:     cookieName,
-		Value:    id,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   r.TLS != nil,
-		SameSite: http.SameSiteLaxMode,
-	})
-	defaultStoryID := game.DefaultStoryID
-	defaultStory := s.Engine.Stories[defaultStoryID]
-	if defaultStory == nil {
-		for k, st := range s.Engine.Stories {
-			defaultStoryID = k
-			defaultStory = st
-			break
-		}
-	}
-	if defaultStory != nil {
+	http.SetCookie(w, s.cookie(r, cookieName, id, 0))
+	defaultStoryID := s.defaultStoryID()
+	if defaultStory := s.playable(defaultStoryID); defaultStory != nil {
 		state = game.NewPlayer(defaultStoryID, defaultStory.Start)
 	} else {
 		state = game.NewPlayer("", "")
 	}
-	_ = s.Store.Put(ctx, id, state) //nolint:errcheck // Best effort: continue even if store fails
-	return state, id, true
+	logSession(ctx, id, &state)
+	rev, _ = s.Store.CompareAndPut(ctx, id, state, 0) //nolint:errcheck // Best effort: continue even if store fails
+	return state, rev, id, true
 }
 
 func (s *Server) sessionID(r *http.Request) string {
@@ -160,6 +339,7 @@ type BattleChoice struct {
 // ViewModel contains data for rendering a game view.
 type ViewModel struct {
 	SessionID          string // sent with /play so session is found when cookie is missing (e.g. HTTP)
+	Revision           int64  // store revision the page shows; sent with /play to reject outdated choices
 	Node               *game.Node
 	State              game.PlayerState
 	Message            string
@@ -167,9 +347,15 @@ type ViewModel struct {
 	LastPlayerDice     *[2]int
 	LastEnemyDice      *[2]int
 	LastOutcome        *string
-	Enemies            []game.EnemyState // 1–3 or single horde for display
-	BattleChoicePrefix string            // e.g. "battle" for keys battle:attack:0
-	EffectiveChoices   []BattleChoice    // when in battle, synthetic choices; else nil
+	Choices            []game.Choice      // node choices whose conditions hold
+	Enemies            []game.EnemyState  // 1–3 or single horde for display
+	BattleChoicePrefix string             // e.g. "battle" for keys battle:attack:0
+	EffectiveChoices   []BattleChoice     // when in battle, synthetic choices; else nil
+	Unlocked           []game.Achievement // achievements unlocked by the last step
+	NewEnding          bool               // the last step reached an ending for the first time
+	Score              *ScoreView         // the last step finished a scored run
+	Party              *PartyView         // set when the game is a party: choices are voted on
+	Spectate           *SpectateView      // set when a spectator is watching: no choices
 }
 
 func (s *Server) makeViewModel(st *game.PlayerState, msg string, roll *int, outcome *string, playerDice, enemyDice *[2]int) (ViewModel, error) {
@@ -185,6 +371,7 @@ func (s *Server) makeViewModel(st *game.PlayerState, msg string, roll *int, outc
 		LastPlayerDice: playerDice,
 		LastEnemyDice:  enemyDice,
 		LastOutcome:    outcome,
+		Choices:        s.Engine.AvailableChoices(st, n),
 		Enemies:        st.Enemies,
 	}
 	if len(st.Enemies) > 0 {
@@ -0,0 +1,273 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"adventure/internal/account"
+	"adventure/internal/game"
+)
+
+const loginCookieName = "adventure_login"
+
+// accountRoutes registers the account endpoints when accounts are enabled.
+func (s *Server) accountRoutes(mux *http.ServeMux) {
+	if s.Accounts == nil {
+		return
+	}
+	mux.HandleFunc("GET /login", s.handleLoginPage)
+	mux.HandleFunc("POST /login", s.handleLogin)
+	mux.HandleFunc("POST /signup", s.handleSignup)
+	mux.HandleFunc("POST /logout", s.handleLogout)
+	mux.HandleFunc("GET /characters", s.handleCharacters)
+	mux.HandleFunc("POST /characters", s.handleNewCharacter)
+	mux.HandleFunc("POST /characters/{id}/play", s.handlePlayCharacter)
+}
+
+// account returns the logged-in account, or nil when accounts are disabled
+// or the player is not logged in.
+func (s *Server) account(r *http.Request) (*account.Account, error) {
+	if s.Accounts == nil {
+		return nil, nil
+	}
+	c, err := r.Cookie(loginCookieName)
+	if err != nil {
+		return nil, nil
+	}
+	a, ok, err := s.Accounts.LoggedIn(r.Context(), c.Value)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// setSessionCookie points the browser at session id.
+func (s *Server) setSessionCookie(w http.ResponseWriter, r *http.Request, id string) {
+	http.SetCookie(w, s.cookie(r, cookieName, id, 0))
+}
+
+// GET /login renders the log in and sign up forms.
+func (s *Server) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	s.renderAccount(w, r, http.StatusOK, AccountViewModel{})
+}
+
+func (s *Server) renderAccount(w http.ResponseWriter, r *http.Request, status int, vm AccountViewModel) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = s.executeTemplate(r.Context(), w, "layout.html", map[string]any{"Account": vm}) //nolint:errcheck // the status is already sent
+}
+
+// POST /login checks the username and password and logs the player in.
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	username := r.FormValue("username")
+	a, err := s.Accounts.Authenticate(r.Context(), username, r.FormValue("password"))
+	if errors.Is(err, account.ErrBadCredentials) {
+		s.renderAccount(w, r, http.StatusUnauthorized, AccountViewModel{Username: username, Error: err.Error()})
+		return
+	}
+	if err != nil {
+		s.serverError(w, r, "failed to load account", err)
+		return
+	}
+	s.startLogin(w, r, &a)
+}
+
+// POST /signup creates an account and logs the player in.
+func (s *Server) handleSignup(w http.ResponseWriter, r *http.Request) {
+	a, err := s.Accounts.Register(r.Context(), r.FormValue("username"), r.FormValue("password"))
+	switch {
+	case errors.Is(err, account.ErrUsernameTaken):
+		s.renderAccount(w, r, http.StatusConflict, AccountViewModel{Error: err.Error()})
+		return
+	case errors.Is(err, account.ErrInvalidUsername), errors.Is(err, account.ErrWeakPassword):
+		s.renderAccount(w, r, http.StatusBadRequest, AccountViewModel{Error: err.Error()})
+		return
+	case err != nil:
+		s.serverError(w, r, "failed to create account", err)
+		return
+	}
+	s.startLogin(w, r, &a)
+}
+
+// startLogin sets the login cookie and sends the player to their
+// characters. The game this browser was playing joins the account.
+func (s *Server) startLogin(w http.ResponseWriter, r *http.Request, a *account.Account) {
+	ctx := r.Context()
+	token, err := s.Accounts.Login(ctx, a.Username)
+	if err != nil {
+		s.serverError(w, r, "failed to log in", err)
+		return
+	}
+	if id := s.sessionID(r); id != "" && !a.Owns(id) {
+		if _, ok, err := s.Store.Get(ctx, id); err == nil && ok {
+			if err := s.Accounts.AddCharacter(ctx, a.Username, id); err != nil {
+				s.serverError(w, r, "failed to save account", err)
+				return
+			}
+		}
+	}
+	http.SetCookie(w, s.cookie(r, loginCookieName, token, int(s.Accounts.LoginLifetime().Seconds())))
+	http.Redirect(w, r, "/characters", http.StatusSeeOther)
+}
+
+// POST /logout ends the login. The browser also forgets the game it was
+// playing, which stays with the account.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(loginCookieName); err == nil {
+		if err := s.Accounts.Logout(r.Context(), c.Value); err != nil {
+			s.serverError(w, r, "failed to log out", err)
+			return
+		}
+	}
+	for _, name := range []string{loginCookieName, cookieName} {
+		http.SetCookie(w, s.cookie(r, name, "", -1))
+	}
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// GET /characters is the character select screen. Characters whose games
+// have expired are dropped from the account.
+func (s *Server) handleCharacters(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	a, err := s.account(r)
+	if err != nil {
+		s.serverError(w, r, "failed to load account", err)
+		return
+	}
+	if a == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	vm := CharactersViewModel{Username: a.Username}
+	current := s.sessionID(r)
+	var gone []string
+	for _, id := range a.Characters {
+		st, ok, err := s.Store.Get(ctx, id)
+		if err != nil {
+			s.serverError(w, r, "failed to load session", err)
+			return
+		}
+		if !ok {
+			gone = append(gone, id)
+			continue
+		}
+		vm.Characters = append(vm.Characters, s.characterView(id, &st, id == current))
+	}
+	if len(gone) > 0 {
+		if err := s.Accounts.RemoveCharacters(ctx, a.Username, gone...); err != nil {
+			s.serverError(w, r, "failed to save account", err)
+			return
+		}
+	}
+	if err := s.executeTemplate(r.Context(), w, "layout.html", map[string]any{"Characters": vm}); err != nil {
+		http.Error(w, "failed to render template", 500)
+		return
+	}
+}
+
+// characterView summarises a character for the select screen.
+func (s *Server) characterView(id string, st *game.PlayerState, current bool) CharacterView {
+	v := CharacterView{ID: id, Name: st.Name, Avatar: st.Avatar, Story: humanizeID(st.StoryID), Current: current}
+	if v.Name == "" {
+		v.Name = "Adventurer"
+	}
+	if story := s.Engine.Stories[st.StoryID]; story != nil && story.Title != "" {
+		v.Story = story.Title
+	}
+	switch node, err := s.Engine.CurrentNode(st); {
+	case !st.Begun:
+		v.Status = "Not yet begun"
+	case err == nil && node.Ending:
+		v.Status = "Finished"
+	default:
+		v.Status = "In progress"
+	}
+	return v
+}
+
+// POST /characters creates a character on the account and sends the player
+// to create it.
+func (s *Server) handleNewCharacter(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	a, err := s.account(r)
+	if err != nil {
+		s.serverError(w, r, "failed to load account", err)
+		return
+	}
+	if a == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if s.Engine.Stories[s.defaultStoryID()] == nil {
+		http.Error(w, "no adventure available", 500)
+		return
+	}
+	st, _ := s.newCharacter()
+	id := s.Store.NewID()
+	if err := s.Store.Put(ctx, id, st); err != nil {
+		s.serverError(w, r, "failed to save state", err)
+		return
+	}
+	if err := s.Accounts.AddCharacter(ctx, a.Username, id); err != nil {
+		s.serverError(w, r, "failed to save account", err)
+		return
+	}
+	s.setSessionCookie(w, r, id)
+	http.Redirect(w, r, "/start", http.StatusSeeOther)
+}
+
+// POST /characters/{id}/play switches this browser to one of the account's
+// characters and resumes their game.
+func (s *Server) handlePlayCharacter(w http.ResponseWriter, r *http.Request) {
+	a, err := s.account(r)
+	if err != nil {
+		s.serverError(w, r, "failed to load account", err)
+		return
+	}
+	if a == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	id := r.PathValue("id")
+	if !a.Owns(id) {
+		http.NotFound(w, r)
+		return
+	}
+	s.setSessionCookie(w, r, id)
+	http.Redirect(w, r, "/game", http.StatusSeeOther)
+}
+
+// GET /game renders the full page for the current game, so a player can
+// come back to it; a character not yet begun goes to /start instead.
+func (s *Server) handleGame(w http.ResponseWriter, r *http.Request) {
+	id := s.sessionID(r)
+	if id == "" {
+		http.Redirect(w, r, "/start", http.StatusFound)
+		return
+	}
+	st, rev, ok, err := s.Store.GetRev(r.Context(), id)
+	if err != nil {
+		s.serverError(w, r, "failed to load session", err)
+		return
+	}
+	if !ok || !st.Begun {
+		http.Redirect(w, r, "/start", http.StatusFound)
+		return
+	}
+	logSession(r.Context(), id, &st)
+	vm, err := s.makeViewModel(&st, "", nil, nil, nil, nil)
+	if err != nil {
+		s.serverError(w, r, "failed to load the current node", err)
+		return
+	}
+	vm.SessionID = id
+	vm.Revision = rev
+	w.Header().Set("Cache-Control", "no-store")
+	if err := s.executeTemplate(r.Context(), w, "layout.html", map[string]any{
+		"State": vm.State,
+		"Game":  vm,
+	}); err != nil {
+		http.Error(w, "failed to render template", 500)
+		return
+	}
+}
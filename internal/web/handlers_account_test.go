@@ -0,0 +1,187 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"adventure/internal/account"
+	"adventure/internal/session"
+)
+
+// accountTestClient is a browser for srv: it keeps cookies and does not
+// follow redirects, so tests can check where they go.
+type accountTestClient struct {
+	t  *testing.T
+	ts *httptest.Server
+	c  *http.Client
+}
+
+func newAccountTestClient(t *testing.T, ts *httptest.Server) *accountTestClient {
+	t.Helper()
+	jar, _ := cookiejar.New(nil)
+	return &accountTestClient{t: t, ts: ts, c: &http.Client{
+		Jar:           jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}}
+}
+
+// do sends a request and returns the status, Location and body.
+func (c *accountTestClient) do(method, path string, form url.Values) (int, string, string) {
+	c.t.Helper()
+	req, _ := http.NewRequest(method, c.ts.URL+path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := c.c.Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	return res.StatusCode, res.Header.Get("Location"), string(body)
+}
+
+func accountTestServer(t *testing.T) *Server {
+	srv := testServer(t)
+	srv.Accounts = &account.Service{
+		Users:      session.NewMemoryStore[account.Account](),
+		Logins:     session.NewMemoryStore[string](),
+		Iterations: 10,
+	}
+	return srv
+}
+
+func TestAccounts_SignupAndCharacters(t *testing.T) {
+	srv := accountTestServer(t)
+	ts := httptest.NewServer(srv.Routes())
+	defer ts.Close()
+	ctx := context.Background()
+
+	// A guest game is adopted by the account made from the same browser.
+	phone := newAccountTestClient(t, ts)
+	if code, _, body := phone.do(http.MethodGet, pathStart, nil); code != http.StatusOK || !strings.Contains(body, `href="/login"`) {
+		t.Fatalf("Expected the start page to offer a login, got %d", code)
+	}
+	guest := phone.sessionID(srv)
+	code, loc, _ := phone.do(http.MethodPost, "/signup", url.Values{"username": {"Ann"}, "password": {"long enough"}})
+	if code != http.StatusSeeOther || loc != "/characters" {
+		t.Fatalf("Expected a redirect to /characters, got %d %q", code, loc)
+	}
+	a, _, _ := srv.Accounts.Users.Get(ctx, "ann")
+	if len(a.Characters) != 1 || a.Characters[0] != guest {
+		t.Fatalf("Expected the guest game to join the account, got %v", a.Characters)
+	}
+	if _, loc, _ := phone.do(http.MethodGet, "/", nil); loc != "/characters" {
+		t.Errorf("Expected / to go to the character select, got %q", loc)
+	}
+
+	// A second character, begun so it can be resumed.
+	if _, loc, _ := phone.do(http.MethodPost, "/characters", nil); loc != pathStart {
+		t.Fatalf("Expected a new character to go to /start, got %q", loc)
+	}
+	a, _, _ = srv.Accounts.Users.Get(ctx, "ann")
+	second := a.Characters[1]
+	st, _, _ := srv.Store.Get(ctx, second)
+	st.Name, st.RerollUsed, st.Begun = "Brave Sir Robin", true, true
+	_ = srv.Store.Put(ctx, second, st)
+
+	// Another device logs in and finds both characters.
+	laptop := newAccountTestClient(t, ts)
+	if code, _, body := laptop.do(http.MethodPost, "/login", url.Values{"username": {"ann"}, "password": {"wrong password"}}); code != http.StatusUnauthorized || !strings.Contains(body, "wrong username or password") {
+		t.Errorf("Expected 401 for a wrong password, got %d", code)
+	}
+	if _, loc, _ := laptop.do(http.MethodPost, "/login", url.Values{"username": {"ann"}, "password": {"long enough"}}); loc != "/characters" {
+		t.Fatalf("Expected a redirect to /characters, got %q", loc)
+	}
+	if _, loc, _ := laptop.do(http.MethodGet, pathStart, nil); loc != "/characters" {
+		t.Errorf("Expected /start without a character to go to the select screen, got %q", loc)
+	}
+	code, _, body := laptop.do(http.MethodGet, "/characters", nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	assertContains(t, body, "Brave Sir Robin")
+	assertContains(t, body, "/characters/"+guest+"/play")
+	assertContains(t, body, "In progress")
+
+	if _, loc, _ := laptop.do(http.MethodPost, "/characters/"+second+"/play", nil); loc != "/game" {
+		t.Fatalf("Expected a redirect to /game, got %q", loc)
+	}
+	code, _, body = laptop.do(http.MethodGet, "/game", nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	assertContains(t, body, "You are at the start.")
+	assertContains(t, body, "Brave Sir Robin")
+	if code, _, _ := laptop.do(http.MethodPost, "/characters/not-mine/play", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for another account's character, got %d", code)
+	}
+
+	// Restarting swaps the character in place; an expired one is dropped.
+	laptop.do(http.MethodPost, "/restart", nil)
+	_ = srv.Store.Delete(ctx, guest)
+	laptop.do(http.MethodGet, "/characters", nil)
+	a, _, _ = srv.Accounts.Users.Get(ctx, "ann")
+	if len(a.Characters) != 1 || a.Characters[0] == second {
+		t.Errorf("Expected just the restarted character, got %v", a.Characters)
+	}
+
+	if _, loc, _ := laptop.do(http.MethodPost, "/logout", nil); loc != "/login" {
+		t.Errorf("Expected a redirect to /login, got %q", loc)
+	}
+	if _, loc, _ := laptop.do(http.MethodGet, "/characters", nil); loc != "/login" {
+		t.Errorf("Expected the select screen to need a login, got %q", loc)
+	}
+}
+
+func TestAccounts_SignupErrors(t *testing.T) {
+	srv := accountTestServer(t)
+	ts := httptest.NewServer(srv.Routes())
+	defer ts.Close()
+	c := newAccountTestClient(t, ts)
+	if code, _, body := c.do(http.MethodGet, "/login", nil); code != http.StatusOK || !strings.Contains(body, "Sign up") {
+		t.Errorf("Expected the login page, got %d", code)
+	}
+	if code, _, _ := c.do(http.MethodPost, "/signup", url.Values{"username": {"a b"}, "password": {"long enough"}}); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad username, got %d", code)
+	}
+	if code, _, _ := c.do(http.MethodPost, "/signup", url.Values{"username": {"ann"}, "password": {"short"}}); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a short password, got %d", code)
+	}
+	c.do(http.MethodPost, "/signup", url.Values{"username": {"ann"}, "password": {"long enough"}})
+	other := newAccountTestClient(t, ts)
+	if code, _, _ := other.do(http.MethodPost, "/signup", url.Values{"username": {"ANN"}, "password": {"long enough"}}); code != http.StatusConflict {
+		t.Errorf("Expected 409 for a taken username, got %d", code)
+	}
+
+	// Without accounts the endpoints do not exist, and /game needs a begun game.
+	plain := httptest.NewServer(testServer(t).Routes())
+	defer plain.Close()
+	c = newAccountTestClient(t, plain)
+	if _, loc, _ := c.do(http.MethodGet, "/login", nil); loc != pathStart {
+		t.Errorf("Expected /login to fall through to /start, got %q", loc)
+	}
+	if _, loc, _ := c.do(http.MethodGet, "/game", nil); loc != pathStart {
+		t.Errorf("Expected /game without a game to go to /start, got %q", loc)
+	}
+}
+
+// sessionID returns the game session the client's browser holds.
+func (c *accountTestClient) sessionID(srv *Server) string {
+	c.t.Helper()
+	u, _ := url.Parse(c.ts.URL)
+	for _, ck := range c.c.Jar.Cookies(u) {
+		if ck.Name == cookieName {
+			if _, ok, _ := srv.Store.Get(context.Background(), ck.Value); !ok {
+				c.t.Fatalf("Expected session %s in the store", ck.Value)
+			}
+			return ck.Value
+		}
+	}
+	c.t.Fatal("Expected a session cookie")
+	return ""
+}
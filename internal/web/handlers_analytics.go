@@ -0,0 +1,179 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"adventure/internal/analytics"
+	"adventure/internal/game"
+)
+
+// analyticsIdle is how long a run may go without an event before the
+// analytics page counts it as abandoned.
+const analyticsIdle = 30 * time.Minute
+
+// analyticsRoutes registers the analytics pages when analytics are enabled.
+func (s *Server) analyticsRoutes(mux *http.ServeMux) {
+	if s.Analytics == nil {
+		return
+	}
+	mux.HandleFunc("GET /analytics", s.authorOnly(s.handleAnalyticsIndex))
+	mux.HandleFunc("GET /analytics/{story}", s.authorOnly(s.handleAnalytics))
+	mux.HandleFunc("GET /analytics/{story}/events.csv", s.authorOnly(s.handleAnalyticsExport))
+	mux.HandleFunc("GET /analytics/{story}/events.json", s.authorOnly(s.handleAnalyticsExport))
+}
+
+// recordBegin logs the start of a run and sends it to the analytics sink.
+// Analytics are best effort: a sink error is logged but never fails the
+// player's request.
+func (s *Server) recordBegin(ctx context.Context, sessionID string, st *game.PlayerState) {
+	logSession(ctx, sessionID, st)
+	s.logger().InfoContext(ctx, "run begun")
+	if err := s.Analytics.Begin(ctx, sessionID, st); err != nil {
+		s.logger().WarnContext(ctx, "analytics sink failed", slog.Any("err", err))
+	}
+}
+
+// recordStep logs a step and sends it to the analytics sink, best effort.
+// before is the state the choice was applied to.
+func (s *Server) recordStep(ctx context.Context, sessionID string, before *game.PlayerState, choice string, res *game.StepResult) {
+	s.logger().DebugContext(ctx, "step",
+		slog.String("choice", choice),
+		slog.String("to_story", res.State.StoryID),
+		slog.String("to_node", res.State.NodeID),
+		slog.String("refused", res.ErrorCode))
+	if err := s.Analytics.Step(ctx, s.Engine, sessionID, before, choice, res); err != nil {
+		s.logger().WarnContext(ctx, "analytics sink failed", slog.Any("err", err))
+	}
+}
+
+// authorOnly asks for AnalyticsPassword with HTTP basic auth, when it is set.
+func (s *Server) authorOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.AnalyticsPassword != "" {
+			_, pw, ok := r.BasicAuth()
+			if !ok || subtle.ConstantTimeCompare([]byte(pw), []byte(s.AnalyticsPassword)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="analytics"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+// analyticsEvents returns the sink's events, or answers 501 when the sink
+// can't be read back.
+func (s *Server) analyticsEvents(w http.ResponseWriter, r *http.Request, storyID string) ([]analytics.Event, bool) {
+	src, ok := s.Analytics.Sink.(analytics.Source)
+	if !ok {
+		http.Error(w, "the analytics sink can't be read back", http.StatusNotImplemented)
+		return nil, false
+	}
+	events, err := src.Events(r.Context(), storyID)
+	if err != nil {
+		s.serverError(w, r, "failed to load events", err)
+		return nil, false
+	}
+	return events, true
+}
+
+// GET /analytics lists the stories to report on.
+func (s *Server) handleAnalyticsIndex(w http.ResponseWriter, r *http.Request) {
+	vm := AnalyticsViewModel{Stories: s.adventureOptions()}
+	sort.Slice(vm.Stories, func(i, j int) bool { return vm.Stories[i].Name < vm.Stories[j].Name })
+	s.renderAnalytics(w, r, vm)
+}
+
+// GET /analytics/{story} shows the story's funnel, choice popularity, check
+// pass rates, battles, deaths and drop-off points.
+func (s *Server) handleAnalytics(w http.ResponseWriter, r *http.Request) {
+	storyID := r.PathValue("story")
+	story := s.playable(storyID)
+	if story == nil {
+		http.NotFound(w, r)
+		return
+	}
+	events, ok := s.analyticsEvents(w, r, storyID)
+	if !ok {
+		return
+	}
+	rep := analytics.Summarize(storyID, events, time.Now().Add(-analyticsIdle))
+	vm := AnalyticsViewModel{StoryID: storyID, Story: storyID, Report: rep}
+	for _, opt := range s.adventureOptions() {
+		if opt.ID == storyID {
+			vm.Story = opt.Name
+		}
+	}
+	vm.Funnel = percentRows(rep.Funnel)
+	vm.Endings = percentRows(rep.Endings)
+	vm.Deaths = percentRows(rep.Deaths)
+	vm.DropOffs = percentRows(rep.DropOffs)
+	for _, c := range rep.Choices {
+		vm.Choices = append(vm.Choices, ChoiceRow{ChoiceStat: c, Text: choiceText(story, c.Node, c.Choice), Percent: 100 * c.Share})
+	}
+	for _, c := range rep.Checks {
+		vm.Checks = append(vm.Checks, CheckRow{CheckStat: c, Text: choiceText(story, c.Node, c.Choice), PassPercent: 100 * c.PassRate, ExpectedPercent: 100 * c.Expected})
+	}
+	s.renderAnalytics(w, r, vm)
+}
+
+func (s *Server) renderAnalytics(w http.ResponseWriter, r *http.Request, vm AnalyticsViewModel) {
+	if err := s.executeTemplate(r.Context(), w, "layout.html", map[string]any{"Analytics": vm}); err != nil {
+		http.Error(w, "failed to render template", 500)
+		return
+	}
+}
+
+// GET /analytics/{story}/events.csv and events.json export the story's raw
+// events; "all" exports every story's.
+func (s *Server) handleAnalyticsExport(w http.ResponseWriter, r *http.Request) {
+	storyID := r.PathValue("story")
+	if storyID == "all" {
+		storyID = ""
+	} else if s.playable(storyID) == nil {
+		http.NotFound(w, r)
+		return
+	}
+	events, ok := s.analyticsEvents(w, r, storyID)
+	if !ok {
+		return
+	}
+	name := r.PathValue("story") + "-events"
+	write := analytics.WriteJSON
+	if strings.HasSuffix(r.URL.Path, ".csv") {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.csv"`)
+		write = analytics.WriteCSV
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+	}
+	_ = write(w, events) //nolint:errcheck // the export is streamed; the status is already sent
+}
+
+// percentRows turns node shares into percentages for display.
+func percentRows(stats []analytics.NodeStat) []NodeRow {
+	rows := make([]NodeRow, len(stats))
+	for i, st := range stats {
+		rows[i] = NodeRow{NodeStat: st, Percent: 100 * st.Rate}
+	}
+	return rows
+}
+
+// choiceText is the text of a story's choice, or its key if it is gone.
+func choiceText(story *game.Story, nodeID, key string) string {
+	if n := story.Nodes[nodeID]; n != nil {
+		for _, ch := range n.Choices {
+			if ch.Key == key && ch.Text != "" {
+				return ch.Text
+			}
+		}
+	}
+	return key
+}
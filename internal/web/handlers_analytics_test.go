@@ -0,0 +1,90 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"adventure/internal/analytics"
+)
+
+func TestAnalytics_RecordsAndReports(t *testing.T) {
+	srv := apiTestServer(t)
+	sink := analytics.NewMemorySink()
+	srv.Analytics = analytics.NewRecorder(sink, []byte("key"))
+	id := apiBegun(t, srv)
+	if rec := apiCall(t, srv, http.MethodPost, "/api/v1/sessions/"+id+"/choices", `{"choice":"next"}`, nil); rec.Code != http.StatusOK {
+		t.Fatalf("choice: %d %s", rec.Code, rec.Body.String())
+	}
+
+	events, _ := sink.Events(context.Background(), testStoryID)
+	var kinds []string
+	for _, ev := range events {
+		if ev.Run == "" || strings.Contains(ev.Run, id) {
+			t.Errorf("Expected an anonymised run ID, got %q", ev.Run)
+		}
+		kinds = append(kinds, ev.Kind)
+	}
+	if got := strings.Join(kinds, ","); got != "start,choice,node,ending" {
+		t.Fatalf("Expected start,choice,node,ending, got %s", got)
+	}
+
+	rec := apiCall(t, srv, http.MethodGet, "/analytics/test", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	assertContains(t, body, "Test Story — Analytics")
+	assertContains(t, body, "1 runs · 1 finished")
+	assertContains(t, body, "Go next")
+
+	rec = apiCall(t, srv, http.MethodGet, "/analytics/test/events.csv", "", nil)
+	if lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n"); len(lines) != 5 || !strings.HasPrefix(lines[0], "time,story,run,kind") {
+		t.Errorf("Unexpected CSV export:\n%s", rec.Body.String())
+	}
+	var exported []analytics.Event
+	if rec := apiCall(t, srv, http.MethodGet, "/analytics/all/events.json", "", &exported); rec.Code != http.StatusOK || len(exported) != 4 {
+		t.Errorf("Expected 4 exported events, got %d (%d)", len(exported), rec.Code)
+	}
+	if rec := apiCall(t, srv, http.MethodGet, "/analytics/missing", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown story, got %d", rec.Code)
+	}
+}
+
+func TestAnalytics_Password(t *testing.T) {
+	srv := apiTestServer(t)
+	srv.Analytics = analytics.NewRecorder(analytics.NewMemorySink(), nil)
+	srv.AnalyticsPassword = "secret"
+	h := srv.Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/analytics", http.NoBody)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("Expected a basic auth challenge, got %d", rec.Code)
+	}
+	req.SetBasicAuth("author", "secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 with the password, got %d", rec.Code)
+	}
+
+	srv = apiTestServer(t)
+	if rec := apiCall(t, srv, http.MethodGet, "/analytics", "", nil); rec.Code == http.StatusOK {
+		t.Errorf("Expected no analytics page with analytics off")
+	}
+}
+
+func TestAnalytics_JSONExportIsArray(t *testing.T) {
+	srv := apiTestServer(t)
+	srv.Analytics = analytics.NewRecorder(analytics.NewMemorySink(), nil)
+	rec := apiCall(t, srv, http.MethodGet, "/analytics/test/events.json", "", nil)
+	var events []analytics.Event
+	if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil || events == nil {
+		t.Errorf("Expected an empty JSON array, got %q", rec.Body.String())
+	}
+}
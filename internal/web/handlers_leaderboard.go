@@ -0,0 +1,150 @@
+package web
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"adventure/internal/game"
+	"adventure/internal/leaderboard"
+)
+
+// Entries shown on a story's leaderboard and per story in the hall of fame.
+const (
+	leaderboardPageSize = 50
+	hallOfFameSize      = 3
+)
+
+// leaderboardRoutes registers the leaderboard pages when leaderboards are
+// enabled.
+func (s *Server) leaderboardRoutes(mux *http.ServeMux) {
+	if s.Leaderboards == nil {
+		return
+	}
+	mux.HandleFunc("GET /leaderboards/{story}", s.handleLeaderboard)
+	mux.HandleFunc("GET /hall-of-fame", s.handleHallOfFame)
+}
+
+// recordScore returns the view of a step's score, or nil if the step did
+// not finish a scored run. With leaderboards enabled the run is checked
+// against its step log and submitted; a run that fails the check or can't
+// be saved is still shown its score, just not ranked, and a warning says why.
+func (s *Server) recordScore(ctx context.Context, sessionID string, res *game.StepResult) *ScoreView {
+	if res.Score == nil {
+		return nil
+	}
+	v := &ScoreView{Score: *res.Score}
+	if s.Leaderboards == nil {
+		return v
+	}
+	v.StoryID = res.State.StoryID
+	if err := s.Engine.VerifyRun(&res.State); err != nil {
+		s.logger().WarnContext(ctx, "run not ranked: it does not follow the story",
+			slog.String("story", res.State.StoryID), slog.Any("err", err))
+		return v
+	}
+	st := &res.State
+	e := leaderboard.Entry{Character: leaderboard.CharacterKey(sessionID), Name: sanitizeName(st.Name), Avatar: st.Avatar, Score: *res.Score}
+	if e.Name == "" {
+		e.Name = "Adventurer"
+	}
+	if !allowedAvatar(e.Avatar) {
+		e.Avatar = game.DefaultAvatar
+	}
+	if d := s.Engine.Difficulty(st); d != nil {
+		e.Difficulty = d.Name
+	}
+	ranks, err := s.Leaderboards.Submit(ctx, st.StoryID, e)
+	if err != nil {
+		s.logger().WarnContext(ctx, "run not ranked: failed to save it to the leaderboard",
+			slog.String("story", st.StoryID), slog.Any("err", err))
+		return v
+	}
+	v.Ranks = &ranks
+	return v
+}
+
+// scoredStories lists the playable stories with a score model, by name.
+func (s *Server) scoredStories() []AdventureOption {
+	var out []AdventureOption
+	for _, opt := range s.adventureOptions() {
+		if s.Engine.Stories[opt.ID].Scoring != nil {
+			out = append(out, opt)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// GET /leaderboards/{story} shows a story's best runs, all-time or, with
+// ?period=week, this week.
+func (s *Server) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	storyID := r.PathValue("story")
+	vm := LeaderboardViewModel{StoryID: storyID, Period: leaderboard.AllTime, Stories: s.scoredStories()}
+	for _, opt := range vm.Stories {
+		if opt.ID == storyID {
+			vm.Story = opt.Name
+		}
+	}
+	if vm.Story == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.URL.Query().Get("period") == leaderboard.Weekly {
+		vm.Period = leaderboard.Weekly
+	}
+	entries, err := s.Leaderboards.Top(r.Context(), storyID, vm.Period, leaderboardPageSize)
+	if err != nil {
+		s.serverError(w, r, "failed to load leaderboard", err)
+		return
+	}
+	vm.Entries = leaderboardRows(entries, s.sessionID(r))
+	s.renderLeaderboard(w, r, map[string]any{"Leaderboard": vm})
+}
+
+// GET /hall-of-fame shows the all-time best runs of every scored story.
+func (s *Server) handleHallOfFame(w http.ResponseWriter, r *http.Request) {
+	var vm HallOfFameViewModel
+	for _, opt := range s.scoredStories() {
+		entries, err := s.Leaderboards.Top(r.Context(), opt.ID, leaderboard.AllTime, hallOfFameSize)
+		if err != nil {
+			s.serverError(w, r, "failed to load leaderboard", err)
+			return
+		}
+		vm.Stories = append(vm.Stories, HallOfFameStory{ID: opt.ID, Name: opt.Name, Entries: leaderboardRows(entries, s.sessionID(r))})
+	}
+	s.renderLeaderboard(w, r, map[string]any{"HallOfFame": vm})
+}
+
+// renderLeaderboard renders a leaderboard page with the player's character
+// in the sidebar, if they have one.
+func (s *Server) renderLeaderboard(w http.ResponseWriter, r *http.Request, data map[string]any) {
+	if id := s.sessionID(r); id != "" {
+		st, ok, err := s.Store.Get(r.Context(), id)
+		if err != nil {
+			s.serverError(w, r, "failed to load session", err)
+			return
+		}
+		if ok {
+			data["State"] = st
+		}
+	}
+	if err := s.executeTemplate(r.Context(), w, "layout.html", data); err != nil {
+		http.Error(w, "failed to render template", 500)
+		return
+	}
+}
+
+// leaderboardRows numbers entries from 1, marking the player's own.
+func leaderboardRows(entries []leaderboard.Entry, sessionID string) []LeaderboardRow {
+	rows := make([]LeaderboardRow, len(entries))
+	mine := ""
+	if sessionID != "" {
+		mine = leaderboard.CharacterKey(sessionID)
+	}
+	for i, e := range entries {
+		rows[i] = LeaderboardRow{Rank: i + 1, Entry: e, Mine: e.Character == mine}
+	}
+	return rows
+}
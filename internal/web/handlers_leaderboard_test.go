@@ -0,0 +1,140 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"adventure/internal/game"
+	"adventure/internal/leaderboard"
+	"adventure/internal/logging"
+	"adventure/internal/session"
+)
+
+// leaderboardTestServer scores the test story and enables leaderboards.
+func leaderboardTestServer(t *testing.T) *Server {
+	t.Helper()
+	srv := apiTestServer(t)
+	srv.Engine.Stories[testStoryID].Scoring = &game.Scoring{Ending: 100, Step: -1}
+	srv.Leaderboards = &leaderboard.Service{Store: session.NewMemoryStore[leaderboard.Board]()}
+	return srv
+}
+
+func TestLeaderboards_API(t *testing.T) {
+	srv := leaderboardTestServer(t)
+	id := apiBegun(t, srv)
+	var node APINode
+	if rec := apiCall(t, srv, http.MethodPost, "/api/v1/sessions/"+id+"/choices", `{"choice":"next"}`, &node); rec.Code != http.StatusOK {
+		t.Fatalf("choice: %d %s", rec.Code, rec.Body.String())
+	}
+	if r := node.Result; r == nil || r.Score == nil || r.Score.Total != 99 || r.Ranks == nil || r.Ranks.AllTime != 1 {
+		t.Fatalf("Expected a score of 99 ranked first, got %+v", node.Result)
+	}
+	top, _ := srv.Leaderboards.Top(context.Background(), testStoryID, leaderboard.AllTime, 0)
+	if len(top) != 1 || top[0].Name != "Ann" || top[0].Character != leaderboard.CharacterKey(id) {
+		t.Errorf("Unexpected board %+v", top)
+	}
+
+	// A run whose step log was tampered with is scored but not ranked, and
+	// the reason is logged.
+	var buf bytes.Buffer
+	srv.Logger, _ = logging.New(&buf, slog.LevelWarn, logging.FormatJSON)
+	other := apiBegun(t, srv)
+	st, _, _ := srv.Store.Get(context.Background(), other)
+	st.VisitedNodes = []string{"elsewhere"}
+	_ = srv.Store.Put(context.Background(), other, st)
+	node = APINode{}
+	apiCall(t, srv, http.MethodPost, "/api/v1/sessions/"+other+"/choices", `{"choice":"next"}`, &node)
+	if r := node.Result; r == nil || r.Score == nil || r.Ranks != nil {
+		t.Errorf("Expected an unranked score, got %+v", node.Result)
+	}
+	if top, _ := srv.Leaderboards.Top(context.Background(), testStoryID, leaderboard.AllTime, 0); len(top) != 1 {
+		t.Errorf("Expected the tampered run to stay off the board, got %d entries", len(top))
+	}
+	expectWarning(t, &buf, "run not ranked: it does not follow the story")
+}
+
+// downBoards fails every leaderboard read and write.
+type downBoards struct {
+	session.Store[leaderboard.Board]
+}
+
+func (downBoards) Get(context.Context, string) (leaderboard.Board, bool, error) {
+	return leaderboard.Board{}, false, errors.New("store down")
+}
+
+func (downBoards) GetRev(context.Context, string) (leaderboard.Board, int64, bool, error) {
+	return leaderboard.Board{}, 0, false, errors.New("store down")
+}
+
+// expectWarning checks buf holds a warning msg with the test story and an error.
+func expectWarning(t *testing.T, buf *bytes.Buffer, msg string) {
+	t.Helper()
+	for _, l := range logLines(t, buf) {
+		if l["msg"] == msg {
+			if l["level"] != "WARN" || l["story"] != testStoryID || l["err"] == nil {
+				t.Errorf("Expected a warning with the story and error, got %v", l)
+			}
+			return
+		}
+	}
+	t.Errorf("Expected a %q warning, got:\n%s", msg, buf.String())
+}
+
+func TestLeaderboards_SubmitFailureIsLogged(t *testing.T) {
+	srv := leaderboardTestServer(t)
+	srv.Leaderboards.Store = downBoards{}
+	var buf bytes.Buffer
+	srv.Logger, _ = logging.New(&buf, slog.LevelWarn, logging.FormatJSON)
+	id := apiBegun(t, srv)
+	var node APINode
+	if rec := apiCall(t, srv, http.MethodPost, "/api/v1/sessions/"+id+"/choices", `{"choice":"next"}`, &node); rec.Code != http.StatusOK {
+		t.Fatalf("choice: %d %s", rec.Code, rec.Body.String())
+	}
+	if r := node.Result; r == nil || r.Score == nil || r.Ranks != nil {
+		t.Errorf("Expected an unranked score, got %+v", node.Result)
+	}
+	expectWarning(t, &buf, "run not ranked: failed to save it to the leaderboard")
+}
+
+func TestLeaderboards_Pages(t *testing.T) {
+	srv := leaderboardTestServer(t)
+	ts := httptest.NewServer(srv.Routes())
+	defer ts.Close()
+	c := newAccountTestClient(t, ts)
+	c.do(http.MethodGet, pathStart, nil)
+	c.do(http.MethodPost, "/begin", url.Values{"name": {"  Brave Sir Robin  "}, "story_id": {testStoryID}})
+	code, _, body := c.do(http.MethodPost, "/play", url.Values{"choice": {"next"}})
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	assertContains(t, body, "Score: <strong>99</strong>")
+	assertContains(t, body, "#1 all-time")
+	assertContains(t, body, `href="/leaderboards/test"`)
+
+	for _, path := range []string{"/leaderboards/test", "/leaderboards/test?period=week"} {
+		code, _, body := c.do(http.MethodGet, path, nil)
+		if code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, code)
+		}
+		assertContains(t, body, "Brave Sir Robin")
+		assertContains(t, body, "character-current")
+	}
+	code, _, body = c.do(http.MethodGet, "/hall-of-fame", nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	assertContains(t, body, "Test Story")
+	assertContains(t, body, "Brave Sir Robin")
+	if code, _, _ := c.do(http.MethodGet, "/leaderboards/missing", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown story, got %d", code)
+	}
+	if code, _, _ := c.do(http.MethodGet, "/leaderboards/lib", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a library story, got %d", code)
+	}
+}
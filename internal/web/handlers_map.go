@@ -1,6 +1,7 @@
 package web
 
 import (
+	"log/slog"
 	"net/http"
 
 	"adventure/internal/mapgen"
@@ -31,16 +32,14 @@ func (s *Server) handleMap(w http.ResponseWriter, r *http.Request) {
 	if title == "" {
 		title = state.StoryID
 	}
-	storiesDir := s.storiesBase()
-	pdf, err := mapgen.Generate(st, state.VisitedNodes, state.NodeID, title, state.StoryID, storiesDir)
+	pdf, err := mapgen.Generate(st, state.VisitedNodes, state.NodeID, title, state.StoryID, s.storyFiles())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.serverError(w, r, "failed to draw the map", err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/pdf")
 	w.Header().Set("Content-Disposition", `attachment; filename="adventure-map.pdf"`)
 	if _, err := w.Write(pdf); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		s.logger().WarnContext(r.Context(), "failed to send the map", slog.Any("err", err))
 	}
 }
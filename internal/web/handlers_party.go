@@ -0,0 +1,226 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	voterCookieName = "adventure_voter"
+	// streamKeepAlive is how often an idle event stream sends a comment so
+	// proxies keep the connection open.
+	streamKeepAlive = 15 * time.Second
+)
+
+// partyRoutes registers the party endpoints when parties are enabled.
+func (s *Server) partyRoutes(mux *http.ServeMux) {
+	if s.Parties == nil {
+		return
+	}
+	mux.HandleFunc("POST /party", s.handlePartyCreate)
+	mux.HandleFunc("GET /party/{code}", s.handleParty)
+	mux.HandleFunc("GET /party/{code}/view", s.handlePartyView)
+	mux.HandleFunc("GET /party/{code}/events", s.handlePartyEvents)
+	mux.HandleFunc("POST /party/{code}/vote", s.handlePartyVote)
+	mux.HandleFunc("POST /party/{code}/close", s.handlePartyClose)
+}
+
+// POST /party starts a party from the player's current game and sends them to it.
+func (s *Server) handlePartyCreate(w http.ResponseWriter, r *http.Request) {
+	id := s.sessionID(r)
+	if id == "" {
+		http.Redirect(w, r, "/start", http.StatusSeeOther)
+		return
+	}
+	st, ok, err := s.Store.Get(r.Context(), id)
+	if err != nil {
+		s.serverError(w, r, "failed to load session", err)
+		return
+	}
+	if !ok {
+		http.Redirect(w, r, "/start", http.StatusSeeOther)
+		return
+	}
+	logSession(r.Context(), id, &st)
+	if _, err := s.Engine.CurrentNode(&st); err != nil {
+		s.logger().WarnContext(r.Context(), "no node to start a party at", slog.Any("err", err))
+		http.Redirect(w, r, "/start", http.StatusSeeOther)
+		return
+	}
+	p := s.Parties.Create(s, st, s.voterID(w, r))
+	http.Redirect(w, r, "/party/"+p.Code, http.StatusSeeOther)
+}
+
+// GET /party/{code} renders the party page; late joiners land on the current node.
+func (s *Server) handleParty(w http.ResponseWriter, r *http.Request) {
+	p := s.party(w, r)
+	if p == nil {
+		return
+	}
+	vm, err := s.partyViewModel(p, s.voterID(w, r))
+	if err != nil {
+		s.serverError(w, r, "failed to load the current node", err)
+		return
+	}
+	if err := s.executeTemplate(r.Context(), w, "layout.html", map[string]any{
+		"State": vm.State,
+		"Game":  vm,
+	}); err != nil {
+		http.Error(w, "failed to render template", 500)
+		return
+	}
+}
+
+// GET /party/{code}/view renders the #game fragment and sidebars for a refresh.
+func (s *Server) handlePartyView(w http.ResponseWriter, r *http.Request) {
+	p := s.party(w, r)
+	if p == nil {
+		return
+	}
+	vm, err := s.partyViewModel(p, s.voterID(w, r))
+	if err != nil {
+		s.serverError(w, r, "failed to load the current node", err)
+		return
+	}
+	w.Header().Set("X-Adventure-OOB", "true")
+	if err := s.executeTemplate(r.Context(), w, "game_response.html", vm); err != nil {
+		http.Error(w, "failed to render template", 500)
+		return
+	}
+}
+
+// GET /party/{code}/events streams Server-Sent Events: "node" with the round
+// number whenever a vote is applied (clients then fetch the view), and
+// "votes" with the live partySnapshot as JSON.
+func (s *Server) handlePartyEvents(w http.ResponseWriter, r *http.Request) {
+	p := s.party(w, r)
+	if p == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", 500)
+		return
+	}
+	// The stream outlives the server's write timeout.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{}) //nolint:errcheck // not all writers support deadlines
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	changed, unsubscribe := p.Subscribe()
+	defer unsubscribe()
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	round := -1
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.drainDone():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-changed:
+			snap := p.Snapshot()
+			if snap.Round != round {
+				round = snap.Round
+				fmt.Fprintf(w, "event: node\ndata: %d\n\n", round)
+			}
+			data, err := json.Marshal(snap)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: votes\ndata: %s\n\n", data)
+		}
+		flusher.Flush()
+	}
+}
+
+// POST /party/{code}/vote records the browser's vote for the current round.
+// Votes for another round or for a choice not on offer get 409.
+func (s *Server) handlePartyVote(w http.ResponseWriter, r *http.Request) {
+	p := s.party(w, r)
+	if p == nil {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", 400)
+		return
+	}
+	round, err := strconv.Atoi(r.FormValue("round"))
+	if err != nil {
+		http.Error(w, "bad round", 400)
+		return
+	}
+	if !p.Vote(s.voterID(w, r), round, r.FormValue("choice"), r.FormValue("answer")) {
+		http.Error(w, "vote is closed or choice not on offer", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /party/{code}/close lets the host end the current vote early.
+func (s *Server) handlePartyClose(w http.ResponseWriter, r *http.Request) {
+	p := s.party(w, r)
+	if p == nil {
+		return
+	}
+	if s.voterID(w, r) != p.Host {
+		http.Error(w, "only the host can close a vote", http.StatusForbidden)
+		return
+	}
+	_, _, round := p.View()
+	if !p.Close(round) {
+		http.Error(w, "no votes yet", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// party returns the party named in the path, or writes 404 and returns nil.
+func (s *Server) party(w http.ResponseWriter, r *http.Request) *Party {
+	p := s.Parties.Get(r.PathValue("code"))
+	if p == nil {
+		http.Error(w, "party not found", http.StatusNotFound)
+	}
+	return p
+}
+
+// voterID returns the browser's voter ID, setting the cookie on first use.
+// It is separate from the game session so guests need not create a character.
+func (s *Server) voterID(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(voterCookieName); err == nil && c.Value != "" {
+		return c.Value
+	}
+	id := s.Store.NewID()
+	http.SetCookie(w, s.cookie(r, voterCookieName, id, 0))
+	// Later calls in the same request see the new cookie.
+	r.AddCookie(&http.Cookie{Name: voterCookieName, Value: id})
+	return id
+}
+
+// partyViewModel builds the game view of p's current node with party voting.
+func (s *Server) partyViewModel(p *Party, voter string) (ViewModel, error) {
+	st, last, round := p.View()
+	vm, err := s.makeViewModel(&st, last.ErrorMessage, last.LastRoll, last.LastOutcome, last.LastPlayerDice, last.LastEnemyDice)
+	if err != nil {
+		return ViewModel{}, err
+	}
+	vm.Unlocked = last.Unlocked
+	vm.NewEnding = last.NewEnding
+	snap := p.Snapshot()
+	vm.Party = &PartyView{Code: p.Code, Round: round, Host: voter == p.Host, Voters: snap.Voters}
+	if !vm.Node.Ending {
+		vm.Party.Options = partyOptions(&vm)
+		for i := range vm.Party.Options {
+			vm.Party.Options[i].Votes = snap.Counts[vm.Party.Options[i].Key]
+		}
+	}
+	return vm, nil
+}
@@ -0,0 +1,209 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"adventure/internal/game"
+)
+
+// partyRequest sends a request as the browser with the given voter ID.
+func partyRequest(srv *Server, method, path, voter string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if voter != "" {
+		req.AddCookie(&http.Cookie{Name: voterCookieName, Value: voter})
+	}
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandlePartyCreate(t *testing.T) {
+	srv := partyTestServer(t)
+	ctx := context.Background()
+	id := srv.Store.NewID()
+	st := game.NewPlayer(testStoryID, "start")
+	st.Name = "Ann"
+	if err := srv.Store.Put(ctx, id, st); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/party", http.NoBody)
+	req.AddCookie(&http.Cookie{Name: cookieName, Value: id})
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("Expected 303, got %d", rec.Code)
+	}
+	code := strings.TrimPrefix(rec.Header().Get("Location"), "/party/")
+	p := srv.Parties.Get(code)
+	if p == nil {
+		t.Fatalf("Expected a party at %q", rec.Header().Get("Location"))
+	}
+	var voter string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == voterCookieName {
+			voter = c.Value
+		}
+	}
+	if voter == "" || p.Host != voter {
+		t.Errorf("Expected the creator to host, got host %q voter %q", p.Host, voter)
+	}
+	if pst, _, _ := p.View(); pst.Name != "Ann" {
+		t.Errorf("Expected the party to copy the game, got %+v", pst)
+	}
+
+	// Without a game there is nothing to share.
+	rec = partyRequest(srv, http.MethodPost, "/party", "", nil)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != pathStart {
+		t.Errorf("Expected a redirect to start, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestHandleParty_Page(t *testing.T) {
+	srv := partyTestServer(t)
+	p := newTestParty(srv)
+	p.Vote("guest", 0, "next", "")
+
+	rec := partyRequest(srv, http.MethodGet, "/party/"+p.Code, "host", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`data-party="` + p.Code + `"`,
+		`hx-post="/party/` + p.Code + `/vote"`,
+		`<span class="vote-count" data-key="next">1</span>`,
+		"What walks on four legs?",
+		"Close vote now",
+		"You are at the start.",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected page to contain %q", want)
+		}
+	}
+
+	// Guests get a voter cookie and no host controls.
+	rec = partyRequest(srv, http.MethodGet, "/party/"+p.Code, "", nil)
+	if strings.Contains(rec.Body.String(), "Close vote now") {
+		t.Error("Expected no close button for guests")
+	}
+	if len(rec.Result().Cookies()) != 1 || rec.Result().Cookies()[0].Name != voterCookieName {
+		t.Errorf("Expected a voter cookie, got %v", rec.Result().Cookies())
+	}
+
+	if rec := partyRequest(srv, http.MethodGet, "/party/NOPE42", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown party, got %d", rec.Code)
+	}
+}
+
+func TestHandlePartyVoteAndClose(t *testing.T) {
+	srv := partyTestServer(t)
+	p := newTestParty(srv)
+	base := "/party/" + p.Code
+
+	if rec := partyRequest(srv, http.MethodPost, base+"/vote", "guest", url.Values{"choice": {"fight"}, "round": {"0"}}); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", rec.Code)
+	}
+	if rec := partyRequest(srv, http.MethodPost, base+"/vote", "guest", url.Values{"choice": {"fight"}, "round": {"x"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad round, got %d", rec.Code)
+	}
+	if rec := partyRequest(srv, http.MethodPost, base+"/vote", "guest", url.Values{"choice": {"nope"}, "round": {"0"}}); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a choice not on offer, got %d", rec.Code)
+	}
+	if rec := partyRequest(srv, http.MethodPost, base+"/close", "guest", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a guest closing, got %d", rec.Code)
+	}
+	if rec := partyRequest(srv, http.MethodPost, base+"/close", "host", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", rec.Code)
+	}
+	if rec := partyRequest(srv, http.MethodPost, base+"/close", "host", nil); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 closing a vote without votes, got %d", rec.Code)
+	}
+
+	// The refreshed view shows the battle actions to vote on.
+	rec := partyRequest(srv, http.MethodGet, base+"/view", "guest", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Adventure-OOB") != "true" {
+		t.Fatalf("Expected the OOB fragment, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{`data-party-round="1"`, `{"choice":"fight:luck:1","round":"1"}`, "Luck Bat", `data-key="fight:run"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected view to contain %q", want)
+		}
+	}
+}
+
+func TestHandlePartyEvents(t *testing.T) {
+	srv := partyTestServer(t)
+	p := newTestParty(srv)
+	ts := httptest.NewServer(srv.Routes())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/party/"+p.Code+"/events", http.NoBody)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET events: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", ct)
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	next := func() (event, data string) {
+		t.Helper()
+		for lines.Scan() {
+			line := lines.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			case line == "" && event != "":
+				return event, data
+			}
+		}
+		t.Fatalf("stream ended: %v", lines.Err())
+		return "", ""
+	}
+
+	// A late joiner is told the current round, then the live votes.
+	if ev, data := next(); ev != "node" || data != "0" {
+		t.Fatalf("Expected node 0, got %s %s", ev, data)
+	}
+	var snap partySnapshot
+	if ev, data := next(); ev != "votes" || json.Unmarshal([]byte(data), &snap) != nil || snap.Voters != 1 {
+		t.Fatalf("Expected votes with one voter, got %s %s", ev, data)
+	}
+
+	p.Vote("guest", 0, "next", "")
+	if ev, data := next(); ev != "votes" || json.Unmarshal([]byte(data), &snap) != nil || snap.Counts["next"] != 1 {
+		t.Fatalf("Expected the vote, got %s %s", ev, data)
+	}
+	p.Close(0)
+	if ev, data := next(); ev != "node" || data != "1" {
+		t.Fatalf("Expected node 1, got %s %s", ev, data)
+	}
+	if ev, data := next(); ev != "votes" || json.Unmarshal([]byte(data), &snap) != nil || !snap.Ended {
+		t.Fatalf("Expected the ending, got %s %s", ev, data)
+	}
+}
+
+func TestPartyRoutes_Disabled(t *testing.T) {
+	srv := testServer(t)
+	rec := partyRequest(srv, http.MethodPost, "/party", "", nil)
+	if rec.Code == http.StatusSeeOther {
+		t.Errorf("Expected no party routes when parties are disabled, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,191 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"adventure/internal/session"
+)
+
+// spectateRoutes registers the spectator endpoints when spectating is enabled.
+func (s *Server) spectateRoutes(mux *http.ServeMux) {
+	if s.Spectators == nil {
+		return
+	}
+	mux.HandleFunc("POST /spectate", s.handleSpectateLink)
+	mux.HandleFunc("POST /spectate/revoke", s.handleSpectateRevoke)
+	mux.HandleFunc("GET /watch/{token}", s.handleWatch)
+	mux.HandleFunc("GET /watch/{token}/view", s.handleWatchView)
+	mux.HandleFunc("GET /watch/{token}/overlay", s.handleWatchOverlay)
+	mux.HandleFunc("GET /watch/{token}/overlay/view", s.handleWatchOverlayView)
+	mux.HandleFunc("GET /watch/{token}/events", s.handleWatchEvents)
+}
+
+// POST /spectate returns the player's read-only share link, creating it if needed.
+func (s *Server) handleSpectateLink(w http.ResponseWriter, r *http.Request) {
+	id := s.sessionID(r)
+	if id == "" {
+		http.Error(w, "no game to share", http.StatusNotFound)
+		return
+	}
+	st, ok, err := s.Store.Get(r.Context(), id)
+	if err != nil {
+		s.serverError(w, r, "failed to load session", err)
+		return
+	} else if !ok {
+		http.Error(w, "no game to share", http.StatusNotFound)
+		return
+	}
+	token := s.Spectators.Link(id)
+	s.Spectators.seed(id, &st)
+	s.renderSpectateLink(w, r, token)
+}
+
+// POST /spectate/revoke disables the player's share link; a later POST
+// /spectate makes a new one.
+func (s *Server) handleSpectateRevoke(w http.ResponseWriter, r *http.Request) {
+	if id := s.sessionID(r); id != "" {
+		s.Spectators.Revoke(id)
+	}
+	s.renderSpectateLink(w, r, "")
+}
+
+func (s *Server) renderSpectateLink(w http.ResponseWriter, r *http.Request, token string) {
+	if err := s.executeTemplate(r.Context(), w, "spectate_link.html", map[string]any{"Token": token}); err != nil {
+		http.Error(w, "failed to render template", 500)
+	}
+}
+
+// GET /watch/{token} renders the read-only game page.
+func (s *Server) handleWatch(w http.ResponseWriter, r *http.Request) {
+	vm, ok := s.watchViewModel(w, r, "/view", "#game")
+	if !ok {
+		return
+	}
+	if err := s.executeTemplate(r.Context(), w, "layout.html", map[string]any{
+		"State":    vm.State,
+		"Game":     vm,
+		"Spectate": vm.Spectate,
+	}); err != nil {
+		http.Error(w, "failed to render template", 500)
+		return
+	}
+}
+
+// GET /watch/{token}/view renders the read-only #game fragment and sidebars.
+func (s *Server) handleWatchView(w http.ResponseWriter, r *http.Request) {
+	vm, ok := s.watchViewModel(w, r, "/view", "#game")
+	if !ok {
+		return
+	}
+	w.Header().Set("X-Adventure-OOB", "true")
+	if err := s.executeTemplate(r.Context(), w, "game_response.html", vm); err != nil {
+		http.Error(w, "failed to render template", 500)
+		return
+	}
+}
+
+// GET /watch/{token}/overlay renders a minimal page for capture in streaming
+// software: scenery, stats, the last dice and the enemies.
+func (s *Server) handleWatchOverlay(w http.ResponseWriter, r *http.Request) {
+	s.renderWatchOverlay(w, r, "overlay.html")
+}
+
+// GET /watch/{token}/overlay/view renders the overlay content for a refresh.
+func (s *Server) handleWatchOverlayView(w http.ResponseWriter, r *http.Request) {
+	s.renderWatchOverlay(w, r, "overlay_content.html")
+}
+
+func (s *Server) renderWatchOverlay(w http.ResponseWriter, r *http.Request, name string) {
+	vm, ok := s.watchViewModel(w, r, "/overlay/view", "#overlay")
+	if !ok {
+		return
+	}
+	if err := s.executeTemplate(r.Context(), w, name, vm); err != nil {
+		http.Error(w, "failed to render template", 500)
+		return
+	}
+}
+
+// GET /watch/{token}/events streams Server-Sent Events: "update" with the
+// session's version whenever the player takes a step (viewers then fetch
+// the view), and "revoked" when the link is revoked, after which the
+// stream ends.
+func (s *Server) handleWatchEvents(w http.ResponseWriter, r *http.Request) {
+	changed, state, cancel, ok := s.Spectators.subscribe(r.PathValue("token"))
+	if !ok {
+		http.Error(w, "spectator link not found", http.StatusNotFound)
+		return
+	}
+	defer cancel()
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", 500)
+		return
+	}
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{}) //nolint:errcheck // not all writers support deadlines
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.drainDone():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-changed:
+			version, revoked := state()
+			if revoked {
+				fmt.Fprint(w, "event: revoked\ndata: \n\n")
+				flusher.Flush()
+				return
+			}
+			fmt.Fprintf(w, "event: update\ndata: %d\n\n", version)
+		}
+		flusher.Flush()
+	}
+}
+
+// watchViewModel builds the read-only view of the session behind the path's
+// token, refreshed from the given path suffix into target. It writes 404 for
+// an unknown or revoked token. The view never carries the session ID.
+//
+// The session is read without the spectator's cookies, which never hold it.
+// A session the store cannot find that way, such as one kept in the
+// player's cookie, is shown as last published to the spectator hub.
+func (s *Server) watchViewModel(w http.ResponseWriter, r *http.Request, view, target string) (ViewModel, bool) {
+	token := r.PathValue("token")
+	sessionID, version, last, ok := s.Spectators.lookup(token)
+	if !ok {
+		http.Error(w, "spectator link not found", http.StatusNotFound)
+		return ViewModel{}, false
+	}
+	st, ok, err := s.Store.Get(session.WithoutCookies(r.Context()), sessionID)
+	if err != nil {
+		s.serverError(w, r, "failed to load session", err)
+		return ViewModel{}, false
+	}
+	if !ok && last.State.NodeID != "" {
+		st, ok = last.State, true
+	}
+	if !ok {
+		http.Error(w, "spectator link not found", http.StatusNotFound)
+		return ViewModel{}, false
+	}
+	logSession(r.Context(), sessionID, &st)
+	vm, err := s.makeViewModel(&st, last.ErrorMessage, last.LastRoll, last.LastOutcome, last.LastPlayerDice, last.LastEnemyDice)
+	if err != nil {
+		s.serverError(w, r, "failed to load the current node", err)
+		return ViewModel{}, false
+	}
+	vm.Unlocked = last.Unlocked
+	vm.NewEnding = last.NewEnding
+	vm.Spectate = &SpectateView{Token: token, Version: version, View: "/watch/" + token + view, Target: target}
+	return vm, true
+}
@@ -0,0 +1,242 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"adventure/internal/game"
+	"adventure/internal/session"
+)
+
+// spectateTestServer returns apiTestServer's story with spectating enabled
+// and a player session, whose ID it returns.
+func spectateTestServer(t *testing.T) (srv *Server, sessionID string) {
+	t.Helper()
+	srv = apiTestServer(t)
+	srv.Spectators = NewSpectators()
+	sessionID = srv.Store.NewID()
+	st := game.NewPlayer(testStoryID, "start")
+	st.Name = "Ann"
+	st.Stats = game.Stats{Strength: 10, Luck: 8, Health: 20}
+	if err := srv.Store.Put(context.Background(), sessionID, st); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	return srv, sessionID
+}
+
+// playerRequest sends a form request with the player's session cookie.
+func playerRequest(srv *Server, method, path, sessionID string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if sessionID != "" {
+		req.AddCookie(&http.Cookie{Name: cookieName, Value: sessionID})
+	}
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	return rec
+}
+
+var watchLink = regexp.MustCompile(`/watch/([0-9a-f]+)"`)
+
+func TestHandleSpectateLink(t *testing.T) {
+	srv, sid := spectateTestServer(t)
+
+	rec := playerRequest(srv, http.MethodPost, "/spectate", sid, nil)
+	m := watchLink.FindStringSubmatch(rec.Body.String())
+	if rec.Code != http.StatusOK || m == nil {
+		t.Fatalf("Expected a watch link, got %d %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "/watch/"+m[1]+"/overlay") || !strings.Contains(rec.Body.String(), "Revoke link") {
+		t.Error("Expected the overlay link and a revoke button")
+	}
+	if rec := playerRequest(srv, http.MethodPost, "/spectate", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without a game, got %d", rec.Code)
+	}
+
+	rec = playerRequest(srv, http.MethodPost, "/spectate/revoke", sid, nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Share spectator link") {
+		t.Errorf("Expected the share button back, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := playerRequest(srv, http.MethodGet, "/watch/"+m[1], "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a revoked link, got %d", rec.Code)
+	}
+}
+
+func TestHandleWatch_ReadOnly(t *testing.T) {
+	srv, sid := spectateTestServer(t)
+	tok := srv.Spectators.Link(sid)
+	playerRequest(srv, http.MethodPost, "/play", sid, url.Values{"choice": {"fight"}})
+
+	for _, path := range []string{"/watch/" + tok, "/watch/" + tok + "/view", "/watch/" + tok + "/overlay", "/watch/" + tok + "/overlay/view"} {
+		rec := playerRequest(srv, http.MethodGet, path, "", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, rec.Code)
+		}
+		body := rec.Body.String()
+		if strings.Contains(body, sid) {
+			t.Errorf("%s: the page reveals the session ID", path)
+		}
+		if strings.Contains(body, `hx-post="/play"`) || strings.Contains(body, "Host a party") {
+			t.Errorf("%s: expected no controls", path)
+		}
+		if !strings.Contains(body, `data-watch="`+tok+`"`) || !strings.Contains(body, `data-watch-version="1"`) {
+			t.Errorf("%s: expected live refresh attributes", path)
+		}
+	}
+
+	page := playerRequest(srv, http.MethodGet, "/watch/"+tok, "", nil).Body.String()
+	for _, want := range []string{"Watching live", `data-enemy-name="Rat"`, `class="player-dice-update" data-dice1="3"`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected watch page to contain %q", want)
+		}
+	}
+	overlay := playerRequest(srv, http.MethodGet, "/watch/"+tok+"/overlay", "", nil).Body.String()
+	for _, want := range []string{`<div id="overlay"`, "Rat STR 1 HEALTH 4", `data-watch-view="/watch/` + tok + `/overlay/view"`, `data-face="3"`} {
+		if !strings.Contains(overlay, want) {
+			t.Errorf("Expected overlay to contain %q", want)
+		}
+	}
+	if rec := playerRequest(srv, http.MethodGet, "/watch/"+sid, "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected the session ID not to work as a token, got %d", rec.Code)
+	}
+}
+
+func TestHandleWatch_CookieSessions(t *testing.T) {
+	srv := apiTestServer(t)
+	srv.Spectators = NewSpectators()
+	fallback := session.NewMemoryStore[game.PlayerState]()
+	store, err := session.NewCookieStore[game.PlayerState]([][]byte{[]byte("0123456789abcdef0123456789abcdef")}, fallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Store = store
+
+	// The player's session lives only in their cookie.
+	sid := store.NewID()
+	st := game.NewPlayer(testStoryID, "start")
+	st.Name = "Ann"
+	put := httptest.NewRecorder()
+	ctx := session.WithCookies(context.Background(), put, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	if err := store.Put(ctx, sid, st); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	cookies := append(put.Result().Cookies(), &http.Cookie{Name: cookieName, Value: sid})
+	send := func(method, path string, form url.Values, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		srv.Routes().ServeHTTP(rec, req)
+		return rec
+	}
+
+	m := watchLink.FindStringSubmatch(send(http.MethodPost, "/spectate", nil, cookies).Body.String())
+	if m == nil {
+		t.Fatal("Expected a watch link")
+	}
+	tok := m[1]
+	if rec := send(http.MethodGet, "/watch/"+tok, nil, nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Watching live") {
+		t.Fatalf("Expected the watch page before any step, got %d", rec.Code)
+	}
+
+	if rec := send(http.MethodPost, "/play", url.Values{"choice": {"fight"}}, cookies); rec.Code != http.StatusOK {
+		t.Fatalf("play: %d", rec.Code)
+	}
+	// The spectator's own cookies name another session.
+	other := []*http.Cookie{{Name: cookieName, Value: store.NewID()}}
+	rec := send(http.MethodGet, "/watch/"+tok, nil, other)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `data-enemy-name="Rat"`) {
+		t.Errorf("Expected the watched step, got %d", rec.Code)
+	}
+	if fallback.Len() != 0 {
+		t.Errorf("Expected nothing kept on the server, got %d sessions", fallback.Len())
+	}
+}
+
+func TestHandleWatchEvents(t *testing.T) {
+	srv, sid := spectateTestServer(t)
+	tok := srv.Spectators.Link(sid)
+	ts := httptest.NewServer(srv.Routes())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/watch/"+tok+"/events", http.NoBody)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET events: %v", err)
+	}
+	defer resp.Body.Close()
+
+	lines := bufio.NewScanner(resp.Body)
+	next := func() (event, data string) {
+		t.Helper()
+		for lines.Scan() {
+			line := lines.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			case line == "" && event != "":
+				return event, data
+			}
+		}
+		return "", ""
+	}
+
+	if ev, data := next(); ev != "update" || data != "0" {
+		t.Fatalf("Expected update 0, got %q %q", ev, data)
+	}
+	playerRequest(srv, http.MethodPost, "/play", sid, url.Values{"choice": {"next"}})
+	if ev, data := next(); ev != "update" || data != "1" {
+		t.Fatalf("Expected update 1, got %q %q", ev, data)
+	}
+	srv.Spectators.Revoke(sid)
+	if ev, _ := next(); ev != "revoked" {
+		t.Fatalf("Expected revoked, got %q", ev)
+	}
+	if ev, _ := next(); ev != "" {
+		t.Errorf("Expected the stream to end, got %q", ev)
+	}
+
+	if rec := playerRequest(srv, http.MethodGet, "/watch/"+tok+"/events", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a revoked link, got %d", rec.Code)
+	}
+}
+
+func TestSpectate_APIPlay(t *testing.T) {
+	srv, sid := spectateTestServer(t)
+	tok := srv.Spectators.Link(sid)
+	base := "/api/v1/sessions/" + sid
+
+	if rec := apiCall(t, srv, http.MethodPost, base+"/begin", `{"name":"Ann"}`, nil); rec.Code != http.StatusOK {
+		t.Fatalf("begin: %d %s", rec.Code, rec.Body.String())
+	}
+	if _, v, last, _ := srv.Spectators.lookup(tok); v != 1 || !last.State.Begun {
+		t.Errorf("Expected viewers to see the API begin, got version %d %+v", v, last.State)
+	}
+	if rec := apiCall(t, srv, http.MethodPost, base+"/choices", `{"choice":"fight"}`, nil); rec.Code != http.StatusOK {
+		t.Fatalf("choice: %d %s", rec.Code, rec.Body.String())
+	}
+	if _, v, last, _ := srv.Spectators.lookup(tok); v != 2 || len(last.State.Enemies) == 0 || last.LastOutcome == nil {
+		t.Errorf("Expected viewers to see the API battle round, got version %d %+v", v, last)
+	}
+}
+
+func TestSpectateRoutes_Disabled(t *testing.T) {
+	srv := testServer(t)
+	rec := playerRequest(srv, http.MethodPost, "/spectate", "", nil)
+	if rec.Code == http.StatusOK || rec.Code == http.StatusNotFound && strings.Contains(rec.Body.String(), "no game to share") {
+		t.Errorf("Expected no spectator routes when disabled, got %d", rec.Code)
+	}
+}
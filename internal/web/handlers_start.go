@@ -1,14 +1,34 @@
 package web
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"adventure/internal/game"
+	"adventure/internal/session"
 )
 
 const maxNameLen = 64
 
+// errBegun is returned when a session that has already begun its adventure
+// tries to begin again or change its character. Only /restart starts over.
+var errBegun = errors.New("this adventure has already begun; restart to create a new character")
+
+// errSetupLocked is returned when a character that has rerolled its stats
+// asks for another story or difficulty.
+var errSetupLocked = errors.New("the story and difficulty are fixed once the stats have been rerolled")
+
+// sanitizeName trims a player-chosen character name and cuts it to
+// maxNameLen bytes.
+func sanitizeName(name string) string {
+	name = strings.TrimSpace(name)
+	if len(name) > maxNameLen {
+		name = name[:maxNameLen]
+	}
+	return name
+}
+
 func allowedAvatar(id string) bool {
 	for _, a := range AvatarOptions {
 		if a == id {
@@ -25,6 +45,9 @@ func (s *Server) adventureOptions() []AdventureOption {
 	}
 	out := make([]AdventureOption, 0, len(s.Engine.Stories))
 	for id, story := range s.Engine.Stories {
+		if story.Library {
+			continue
+		}
 		name := story.Title
 		if name == "" && id != "" {
 			name = strings.ToUpper(id[:1]) + id[1:]
@@ -34,19 +57,122 @@ func (s *Server) adventureOptions() []AdventureOption {
 	return out
 }
 
+// difficultyOptions builds the selectable difficulty presets for a story.
+func (s *Server) difficultyOptions(storyID string) []DifficultyOption {
+	if s.Engine == nil || s.Engine.Stories == nil {
+		return nil
+	}
+	story := s.Engine.Stories[storyID]
+	if story == nil {
+		return nil
+	}
+	out := make([]DifficultyOption, 0, len(story.Difficulties))
+	for _, d := range story.Difficulties {
+		name := d.Name
+		if name == "" && d.ID != "" {
+			name = strings.ToUpper(d.ID[:1]) + d.ID[1:]
+		}
+		out = append(out, DifficultyOption{ID: d.ID, Name: name, Description: d.Description})
+	}
+	return out
+}
+
+// storyDifficulty returns the preset chosen for st's story, or the story default.
+func (s *Server) storyDifficulty(st *game.PlayerState) *game.Difficulty {
+	if s.Engine == nil || s.Engine.Stories == nil {
+		return nil
+	}
+	return s.Engine.Stories[st.StoryID].ResolveDifficulty(st.Difficulty)
+}
+
+// setDifficulty records the preset for id (or the story default) on st and
+// recomputes starting stats from the rolled base stats. Presets only apply
+// during character creation: once st has begun it does nothing, so the
+// difficulty can't be switched and stats can't be restored mid-run.
+func (s *Server) setDifficulty(st *game.PlayerState, id string) {
+	if st.Begun {
+		return
+	}
+	st.Difficulty = ""
+	d := s.storyDifficulty(&game.PlayerState{StoryID: st.StoryID, Difficulty: id})
+	if d != nil {
+		st.Difficulty = d.ID
+	}
+	if st.BaseStats != (game.Stats{}) {
+		st.Stats = d.ApplyToStats(st.BaseStats)
+	}
+}
+
+// setupLocked reports whether choosing storyID and difficulty would change
+// a character that has already rerolled. The story and difficulty are fixed
+// at the first reroll, so rerolls always count against the preset the player
+// begins with. Empty IDs keep the current choice.
+func setupLocked(st *game.PlayerState, storyID, difficulty string) bool {
+	if st.Rerolls == 0 {
+		return false
+	}
+	return (storyID != "" && storyID != st.StoryID) || (difficulty != "" && difficulty != st.Difficulty)
+}
+
+// startViewModel builds the character creation view for st.
+func (s *Server) startViewModel(st *game.PlayerState, sessionID string, statDice [3][2]int) StartViewModel {
+	rerollsLeft := 0
+	if !st.RerollUsed {
+		rerollsLeft = s.storyDifficulty(st).RerollAllowance() - st.Rerolls
+		if rerollsLeft < 0 {
+			rerollsLeft = 0
+		}
+	}
+	return StartViewModel{
+		Stats:             st.Stats,
+		StrengthDice:      statDice[0],
+		LuckDice:          statDice[1],
+		HealthDice:        statDice[2],
+		RerollUsed:        rerollsLeft == 0,
+		RerollsLeft:       rerollsLeft,
+		SetupLocked:       st.Rerolls > 0,
+		SessionID:         sessionID,
+		Name:              st.Name,
+		Avatar:            st.Avatar,
+		AvatarOptions:     AvatarOptions,
+		StoryID:           st.StoryID,
+		AdventureOptions:  s.adventureOptions(),
+		Difficulty:        st.Difficulty,
+		DifficultyOptions: s.difficultyOptions(st.StoryID),
+		Sequel:            s.sequelOption(st, st.StoryID),
+	}
+}
+
 func (s *Server) defaultStoryID() string {
 	if s.Engine == nil || s.Engine.Stories == nil {
 		return game.DefaultStoryID
 	}
+	if s.DefaultStory != "" && s.Engine.Stories[s.DefaultStory] != nil {
+		return s.DefaultStory
+	}
 	if s.Engine.Stories[game.DefaultStoryID] != nil {
 		return game.DefaultStoryID
 	}
-	for id := range s.Engine.Stories {
-		return id
+	for id, story := range s.Engine.Stories {
+		if !story.Library {
+			return id
+		}
 	}
 	return game.DefaultStoryID
 }
 
+// playable returns the story with the given ID if a player may start it.
+// Library stories only hold sub-adventures and are never started directly.
+func (s *Server) playable(storyID string) *game.Story {
+	if s.Engine == nil {
+		return nil
+	}
+	if story := s.Engine.Stories[storyID]; story != nil && !story.Library {
+		return story
+	}
+	return nil
+}
+
 // GET /start
 func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -54,22 +180,23 @@ func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
 	// Prevent caching so the user always sees the stats we just saved
 	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate")
 
+	acct, err := s.account(r)
+	if err != nil {
+		s.serverError(w, r, "failed to load account", err)
+		return
+	}
 	id := s.sessionID(r)
+	if id == "" && acct != nil {
+		// A logged-in player picks a character first.
+		http.Redirect(w, r, "/characters", http.StatusFound)
+		return
+	}
 	if id == "" {
 		id = s.Store.NewID()
-		http.SetCookie(w, &http.Cookie{
-			Name:     cookieName,
-			Value:    id,
-			Path:     "/",
-			HttpOnly: true,
-			Secure:   r.TLS != nil,
-			SameSite: http.SameSiteLaxMode,
-		})
+		http.SetCookie(w, s.cookie(r, cookieName, id, 0))
 	}
 
-	defaultID := s.defaultStoryID()
-	defaultStory := s.Engine.Stories[defaultID]
-	if defaultStory == nil {
+	if s.Engine.Stories[s.defaultStoryID()] == nil {
 		http.Error(w, "no adventure available", 500)
 		return
 	}
@@ -78,37 +205,40 @@ func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
 	var statDice [3][2]int
 	existing, ok, err := s.Store.Get(ctx, id)
 	if err != nil {
-		http.Error(w, "failed to load session", 500)
+		s.serverError(w, r, "failed to load session", err)
+		return
+	}
+	if ok && existing.Begun {
+		http.Redirect(w, r, "/game", http.StatusFound)
 		return
 	}
 	if ok {
 		st = existing
 		_, statDice = game.RollStatsDetailed()
 	} else {
-		st = game.NewPlayer(defaultID, defaultStory.Start)
-		st.Stats, statDice = game.RollStatsDetailed()
+		st, statDice = s.newCharacter()
 		if err := s.Store.Put(ctx, id, st); err != nil {
-			http.Error(w, "failed to save state", 500)
+			s.serverError(w, r, "failed to save state", err)
+			return
+		}
+	}
+
+	if acct != nil && !acct.Owns(id) {
+		if err := s.Accounts.AddCharacter(ctx, acct.Username, id); err != nil {
+			s.serverError(w, r, "failed to save account", err)
 			return
 		}
 	}
 
-	vm := StartViewModel{
-		Stats:            st.Stats,
-		StrengthDice:     statDice[0],
-		LuckDice:         statDice[1],
-		HealthDice:       statDice[2],
-		RerollUsed:       st.RerollUsed,
-		SessionID:        id,
-		Name:             st.Name,
-		Avatar:           st.Avatar,
-		AvatarOptions:    AvatarOptions,
-		StoryID:          st.StoryID,
-		AdventureOptions: s.adventureOptions(),
+	vm := s.startViewModel(&st, id, statDice)
+	vm.Accounts = s.Accounts != nil
+	vm.Leaderboards = s.Leaderboards != nil
+	if acct != nil {
+		vm.Username = acct.Username
 	}
 
 	// IMPORTANT: render layout, but tell it to use start.html
-	if err := s.Tmpl.ExecuteTemplate(w, "layout.html", map[string]any{
+	if err := s.executeTemplate(r.Context(), w, "layout.html", map[string]any{
 		"Start": vm,
 	}); err != nil {
 		http.Error(w, "failed to render template", 500)
@@ -116,62 +246,194 @@ func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// newCharacter returns a fresh player at the default story's start with
+// newly rolled stats, and the dice rolled for them.
+func (s *Server) newCharacter() (game.PlayerState, [3][2]int) {
+	defaultID := s.defaultStoryID()
+	st := game.NewPlayer(defaultID, s.Engine.Stories[defaultID].Start)
+	var statDice [3][2]int
+	st.BaseStats, statDice = game.RollStatsDetailed()
+	st.Stats = st.BaseStats
+	s.setDifficulty(&st, "")
+	return st, statDice
+}
+
+// POST /restart starts over: the current session is deleted from the store,
+// its spectator link revoked, and a new session with a fresh character is
+// created under a new ID. Only the trophies (per-story Records) carry over.
+// A logged-in player's account swaps the old character for the new one.
+func (s *Server) handleRestart(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if s.Engine.Stories[s.defaultStoryID()] == nil {
+		http.Error(w, "no adventure available", 500)
+		return
+	}
+	st, _ := s.newCharacter()
+	if old := s.sessionID(r); old != "" {
+		prev, ok, err := s.Store.Get(ctx, old)
+		if err != nil {
+			s.serverError(w, r, "failed to load session", err)
+			return
+		}
+		if ok {
+			st.Records = prev.Records
+		}
+		if err := s.Store.Delete(ctx, old); err != nil {
+			s.serverError(w, r, "failed to delete session", err)
+			return
+		}
+		if s.Spectators != nil {
+			s.Spectators.Revoke(old)
+		}
+	}
+	id := s.Store.NewID()
+	if err := s.Store.Put(ctx, id, st); err != nil {
+		s.serverError(w, r, "failed to save state", err)
+		return
+	}
+	if acct, err := s.account(r); err != nil {
+		s.serverError(w, r, "failed to load account", err)
+		return
+	} else if acct != nil {
+		if err := s.Accounts.ReplaceCharacter(ctx, acct.Username, s.sessionID(r), id); err != nil {
+			s.serverError(w, r, "failed to save account", err)
+			return
+		}
+	}
+	http.SetCookie(w, s.cookie(r, cookieName, id, 0))
+	http.Redirect(w, r, "/start", http.StatusSeeOther)
+}
+
 // POST /reroll
 func (s *Server) handleReroll(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	st, sessionID, found := s.getOrCreateState(ctx, w, r)
+	st, _, sessionID, found := s.getOrCreateState(ctx, w, r)
 	if !found {
 		http.Redirect(w, r, "/start", http.StatusFound)
 		return
 	}
 
+	if st.Begun {
+		http.Error(w, errBegun.Error(), http.StatusConflict)
+		return
+	}
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "bad form", 400)
 		return
 	}
 	// Preserve current name and avatar from the form so reroll doesn't reset character selection
-	name := strings.TrimSpace(r.FormValue("name"))
-	if len(name) > maxNameLen {
-		name = name[:maxNameLen]
-	}
-	st.Name = name
+	st.Name = sanitizeName(r.FormValue("name"))
 	avatar := r.FormValue("avatar")
 	if allowedAvatar(avatar) {
 		st.Avatar = avatar
 	}
-	storyID := r.FormValue("story_id")
-	if s.Engine != nil && s.Engine.Stories != nil && s.Engine.Stories[storyID] != nil {
-		st.StoryID = storyID
+	// After the first reroll the story and difficulty stay as they were.
+	if st.Rerolls == 0 {
+		storyID := r.FormValue("story_id")
+		if s.playable(storyID) != nil {
+			st.StoryID = storyID
+		}
+		s.setDifficulty(&st, r.FormValue("difficulty"))
 	}
 
 	var statDice [3][2]int
-	if !st.RerollUsed {
-		stats, dice := game.RollStatsDetailed()
-		st.Stats = stats
-		st.RerollUsed = true
-		statDice = dice
+	allowance := s.storyDifficulty(&st).RerollAllowance()
+	if !st.RerollUsed && st.Rerolls < allowance {
+		st.BaseStats, statDice = game.RollStatsDetailed()
+		st.Stats = s.storyDifficulty(&st).ApplyToStats(st.BaseStats)
+		st.Rerolls++
+		st.RerollUsed = st.Rerolls >= allowance
 	} else {
 		_, statDice = game.RollStatsDetailed()
 	}
 	if err := s.Store.Put(ctx, sessionID, st); err != nil {
-		http.Error(w, "failed to save state", 500)
+		s.serverError(w, r, "failed to save state", err)
 		return
 	}
 
-	vm := StartViewModel{
-		Stats:            st.Stats,
-		StrengthDice:     statDice[0],
-		LuckDice:         statDice[1],
-		HealthDice:       statDice[2],
-		RerollUsed:       st.RerollUsed,
-		SessionID:        sessionID,
-		Name:             st.Name,
-		Avatar:           st.Avatar,
-		AvatarOptions:    AvatarOptions,
-		StoryID:          st.StoryID,
-		AdventureOptions: s.adventureOptions(),
-	}
-	if err := s.Tmpl.ExecuteTemplate(w, "start.html", vm); err != nil {
+	vm := s.startViewModel(&st, sessionID, statDice)
+	if err := s.executeTemplate(r.Context(), w, "start.html", vm); err != nil {
+		http.Error(w, "failed to render template", 500)
+		return
+	}
+}
+
+// beginStory applies the start form (story, difficulty, name, avatar) to st
+// and places the player at the story's start node. Once the stats have been
+// rerolled the form's story and difficulty are ignored in favour of st's.
+// With continue=1 the hero from the story's prequel is carried over; it
+// fails if they do not qualify. It returns errBegun if st has already begun.
+func (s *Server) beginStory(st *game.PlayerState, r *http.Request) error {
+	if st.Begun {
+		return errBegun
+	}
+	storyID, difficulty := r.FormValue("story_id"), r.FormValue("difficulty")
+	if st.Rerolls > 0 {
+		storyID, difficulty = st.StoryID, st.Difficulty
+	}
+	if s.playable(storyID) == nil {
+		storyID = s.defaultStoryID()
+	}
+	if story := s.Engine.Stories[storyID]; story != nil {
+		st.BeginRun(storyID, story.Start)
+	}
+	s.setDifficulty(st, difficulty)
+	st.Name = sanitizeName(r.FormValue("name"))
+	avatar := r.FormValue("avatar")
+	if !allowedAvatar(avatar) {
+		avatar = game.DefaultAvatar
+	}
+	st.Avatar = avatar
+	if r.FormValue("continue") == "1" {
+		if err := s.Engine.BeginSequel(st, storyID); err != nil {
+			return err
+		}
+	}
+	st.MarkBegun()
+	return nil
+}
+
+// sequelOption describes the hero st may carry into storyID, or nil.
+func (s *Server) sequelOption(st *game.PlayerState, storyID string) *SequelOption {
+	if s.Engine == nil {
+		return nil
+	}
+	h := s.Engine.SequelHero(st, storyID)
+	if h == nil {
+		return nil
+	}
+	from := s.Engine.Stories[storyID].Continues.From
+	opt := &SequelOption{From: humanizeID(from), Hero: h.Name}
+	if prequel := s.Engine.Stories[from]; prequel != nil && prequel.Title != "" {
+		opt.From = prequel.Title
+	}
+	if opt.Hero == "" {
+		opt.Hero = "your hero"
+	}
+	return opt
+}
+
+// GET /difficulties?story_id=... renders the difficulty selector (and sequel
+// option, when the session has a qualifying hero) for a story on the start page.
+func (s *Server) handleDifficulties(w http.ResponseWriter, r *http.Request) {
+	storyID := r.FormValue("story_id")
+	if s.playable(storyID) == nil {
+		storyID = s.defaultStoryID()
+	}
+	vm := StartViewModel{StoryID: storyID, DifficultyOptions: s.difficultyOptions(storyID)}
+	if d := s.storyDifficulty(&game.PlayerState{StoryID: storyID}); d != nil {
+		vm.Difficulty = d.ID
+	}
+	id := r.FormValue("session_id")
+	if id == "" {
+		id = s.sessionID(r)
+	}
+	if id != "" {
+		if st, ok, err := s.Store.Get(r.Context(), id); err == nil && ok {
+			vm.Sequel = s.sequelOption(&st, storyID)
+		}
+	}
+	if err := s.executeTemplate(r.Context(), w, "difficulty_select.html", vm); err != nil {
 		http.Error(w, "failed to render template", 500)
 		return
 	}
@@ -188,106 +450,87 @@ func (s *Server) handleBegin(w http.ResponseWriter, r *http.Request) {
 	// Prefer session_id from the start page so we always load the session that has the stats just shown
 	sessionIDFromForm := r.FormValue("session_id")
 	if sessionIDFromForm != "" {
-		st, ok, err := s.Store.Get(ctx, sessionIDFromForm)
+		st, rev, ok, err := s.Store.GetRev(ctx, sessionIDFromForm)
 		if err == nil && ok {
 			// Set cookie so future requests have it
-			http.SetCookie(w, &http.Cookie{
-				Name:     cookieName,
-				Value:    sessionIDFromForm,
-				Path:     "/",
-				HttpOnly: true,
-				Secure:   r.TLS != nil,
-				SameSite: http.SameSiteLaxMode,
-			})
-			storyID := r.FormValue("story_id")
-			if s.Engine.Stories[storyID] != nil {
-				st.StoryID = storyID
-				st.NodeID = s.Engine.Stories[storyID].Start
-				st.VisitedNodes = []string{st.NodeID}
-			} else {
-				defaultID := s.defaultStoryID()
-				if s.Engine.Stories[defaultID] != nil {
-					st.StoryID = defaultID
-					st.NodeID = s.Engine.Stories[defaultID].Start
-					st.VisitedNodes = []string{st.NodeID}
-				}
-			}
-			name := strings.TrimSpace(r.FormValue("name"))
-			if len(name) > maxNameLen {
-				name = name[:maxNameLen]
-			}
-			st.Name = name
-			avatar := r.FormValue("avatar")
-			if !allowedAvatar(avatar) {
-				avatar = game.DefaultAvatar
+			http.SetCookie(w, s.cookie(r, cookieName, sessionIDFromForm, 0))
+			if err := s.beginStory(&st, r); err != nil {
+				beginError(w, err)
+				return
 			}
-			st.Avatar = avatar
-			// Lock rerolls once the adventure begins.
-			st.RerollUsed = true
-			if err := s.Store.Put(ctx, sessionIDFromForm, st); err != nil {
-				http.Error(w, "failed to save state", 500)
+			rev, ok := s.saveBegun(w, r, sessionIDFromForm, &st, rev)
+			if !ok {
 				return
 			}
+			s.begunSaved(ctx, sessionIDFromForm, &st)
 			vm, err := s.makeViewModel(&st, "", nil, nil, nil, nil)
 			if err != nil {
-				http.Error(w, err.Error(), 500)
+				s.serverError(w, r, "failed to load the current node", err)
 				return
 			}
 			vm.SessionID = sessionIDFromForm
+			vm.Revision = rev
 			w.Header().Set("X-Adventure-OOB", "true")
-			if err := s.Tmpl.ExecuteTemplate(w, "game_response.html", vm); err != nil {
-				http.Error(w, err.Error(), 500)
+			if err := s.executeTemplate(r.Context(), w, "game_response.html", vm); err != nil {
+				http.Error(w, "failed to render template", 500)
 				return
 			}
 			return
 		}
 	}
 
-	st, sessionID, found := s.getOrCreateState(ctx, w, r)
+	st, rev, sessionID, found := s.getOrCreateState(ctx, w, r)
 	if !found {
 		http.Redirect(w, r, "/start", http.StatusFound)
 		return
 	}
 
-	storyID := r.FormValue("story_id")
-	if s.Engine.Stories[storyID] != nil {
-		st.StoryID = storyID
-		st.NodeID = s.Engine.Stories[storyID].Start
-		st.VisitedNodes = []string{st.NodeID}
-	} else {
-		defaultID := s.defaultStoryID()
-		if s.Engine.Stories[defaultID] != nil {
-			st.StoryID = defaultID
-			st.NodeID = s.Engine.Stories[defaultID].Start
-			st.VisitedNodes = []string{st.NodeID}
-		}
-	}
-	name := strings.TrimSpace(r.FormValue("name"))
-	if len(name) > maxNameLen {
-		name = name[:maxNameLen]
-	}
-	st.Name = name
-	avatar := r.FormValue("avatar")
-	if !allowedAvatar(avatar) {
-		avatar = game.DefaultAvatar
+	if err := s.beginStory(&st, r); err != nil {
+		beginError(w, err)
+		return
 	}
-	st.Avatar = avatar
-	// Lock rerolls once the adventure begins.
-	st.RerollUsed = true
-	if err := s.Store.Put(ctx, sessionID, st); err != nil {
-		http.Error(w, "failed to save state", 500)
+	rev, ok := s.saveBegun(w, r, sessionID, &st, rev)
+	if !ok {
 		return
 	}
+	s.begunSaved(ctx, sessionID, &st)
 
 	vm, err := s.makeViewModel(&st, "", nil, nil, nil, nil)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		s.serverError(w, r, "failed to load the current node", err)
 		return
 	}
 	vm.SessionID = sessionID
+	vm.Revision = rev
 	w.Header().Set("X-Adventure-OOB", "true")
-	if err := s.Tmpl.ExecuteTemplate(w, "game_response.html", vm); err != nil {
+	if err := s.executeTemplate(r.Context(), w, "game_response.html", vm); err != nil {
 		http.Error(w, "failed to render template", 500)
 		return
 	}
 }
+
+// beginError answers a failed beginStory: 409 when the adventure has
+// already begun, 403 when the hero does not qualify for a sequel.
+func beginError(w http.ResponseWriter, err error) {
+	status := http.StatusForbidden
+	if errors.Is(err, errBegun) {
+		status = http.StatusConflict
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// saveBegun stores a newly begun story if the session is still at rev,
+// returning the new revision. When another request changed the session
+// first, it answers 409 so the player reloads rather than overwriting it.
+func (s *Server) saveBegun(w http.ResponseWriter, r *http.Request, id string, st *game.PlayerState, rev int64) (int64, bool) {
+	rev, err := s.Store.CompareAndPut(r.Context(), id, *st, rev)
+	if errors.Is(err, session.ErrConflict) {
+		http.Error(w, "the game changed in another tab; reload to continue", http.StatusConflict)
+		return 0, false
+	}
+	if err != nil {
+		s.serverError(w, r, "failed to save state", err)
+		return 0, false
+	}
+	return rev, true
+}
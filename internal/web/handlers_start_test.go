@@ -1,7 +1,13 @@
 package web
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"adventure/internal/game"
 )
 
 func TestAllowedAvatar(t *testing.T) {
@@ -20,3 +26,376 @@ func TestAllowedAvatar(t *testing.T) {
 		t.Error("allowedAvatar(\"male_youngx\") = true, want false")
 	}
 }
+
+// withDifficulties adds story/normal/hard presets to the test story.
+func withDifficulties(srv *Server) {
+	two, zero := 2, 0
+	srv.Engine.Stories[testStoryID].Difficulties = []game.Difficulty{
+		{ID: "story", Name: "Story", Stats: game.Stats{Health: 4}, Rerolls: &two},
+		{ID: "normal", Default: true},
+		{ID: "hard", Stats: game.Stats{Luck: -1}, Rerolls: &zero},
+	}
+}
+
+func TestHandleReroll_DifficultyAllowance(t *testing.T) {
+	srv := testServer(t)
+	withDifficulties(srv)
+	ctx := context.Background()
+	st := game.NewPlayer(testStoryID, "start")
+	id := srv.Store.NewID()
+	if err := srv.Store.Put(ctx, id, st); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	reroll := func(difficulty string) game.PlayerState {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/reroll", strings.NewReader("session_id="+id+"&story_id="+testStoryID+"&difficulty="+difficulty))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: cookieName, Value: id})
+		rec := httptest.NewRecorder()
+		srv.Routes().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		updated, _, err := srv.Store.Get(ctx, id)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		return updated
+	}
+
+	first := reroll("story")
+	if first.Difficulty != "story" || first.Rerolls != 1 || first.RerollUsed {
+		t.Fatalf("Expected one of two story rerolls used, got difficulty=%q rerolls=%d used=%v", first.Difficulty, first.Rerolls, first.RerollUsed)
+	}
+	if first.Stats.Health != first.BaseStats.Health+4 {
+		t.Errorf("Expected story preset to add 4 Health, got base %d stats %d", first.BaseStats.Health, first.Stats.Health)
+	}
+	second := reroll("story")
+	if second.Rerolls != 2 || !second.RerollUsed {
+		t.Errorf("Expected rerolls exhausted after two, got rerolls=%d used=%v", second.Rerolls, second.RerollUsed)
+	}
+	third := reroll("story")
+	if third.BaseStats != second.BaseStats {
+		t.Error("Expected no reroll once the allowance is spent")
+	}
+}
+
+func TestHandleBegin_RecordsDifficulty(t *testing.T) {
+	srv := testServer(t)
+	withDifficulties(srv)
+	ctx := context.Background()
+	st := game.NewPlayer(testStoryID, "start")
+	st.BaseStats = game.Stats{Strength: 10, Luck: 8, Health: 12}
+	st.Stats = st.BaseStats
+	id := srv.Store.NewID()
+	if err := srv.Store.Put(ctx, id, st); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/begin", strings.NewReader("session_id="+id+"&story_id="+testStoryID+"&difficulty=hard"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	updated, _, err := srv.Store.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if updated.Difficulty != "hard" {
+		t.Errorf("Expected difficulty 'hard', got %q", updated.Difficulty)
+	}
+	if updated.Stats.Luck != 7 {
+		t.Errorf("Expected hard preset to apply -1 Luck to base 8, got %d", updated.Stats.Luck)
+	}
+}
+
+func TestHandleBegin_LocksDifficultyAfterBegin(t *testing.T) {
+	srv := testServer(t)
+	withDifficulties(srv)
+	ctx := context.Background()
+	st := game.NewPlayer(testStoryID, "start")
+	st.BaseStats = game.Stats{Strength: 10, Luck: 8, Health: 12}
+	st.Stats = st.BaseStats
+	id := srv.Store.NewID()
+	if err := srv.Store.Put(ctx, id, st); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	post := func(path, form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("session_id="+id+"&story_id="+testStoryID+"&"+form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: cookieName, Value: id})
+		rec := httptest.NewRecorder()
+		srv.Routes().ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := post("/begin", "difficulty=hard"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	hurt, _, _ := srv.Store.Get(ctx, id)
+	hurt.Stats.Health = 1
+	if err := srv.Store.Put(ctx, id, hurt); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if rec := post("/begin", "difficulty=story"); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a second begin, got %d", rec.Code)
+	}
+	if rec := post("/reroll", "difficulty=story"); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a reroll after begin, got %d", rec.Code)
+	}
+	updated, _, _ := srv.Store.Get(ctx, id)
+	if updated.Difficulty != "hard" || updated.Stats != hurt.Stats {
+		t.Errorf("Expected the hard run to be left alone, got %q %+v", updated.Difficulty, updated.Stats)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, pathStart, http.NoBody)
+	req.AddCookie(&http.Cookie{Name: cookieName, Value: id})
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/game" {
+		t.Errorf("Expected /start to send a begun game to /game, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestHandlePlay_LocksDifficultyWithoutBegin(t *testing.T) {
+	srv := testServer(t)
+	withDifficulties(srv)
+	ctx := context.Background()
+	st := game.NewPlayer(testStoryID, "start")
+	st.BaseStats = game.Stats{Strength: 10, Luck: 8, Health: 12}
+	st.Stats = st.BaseStats
+	id := srv.Store.NewID()
+	if err := srv.Store.Put(ctx, id, st); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	post := func(path, form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: cookieName, Value: id})
+		rec := httptest.NewRecorder()
+		srv.Routes().ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Playing straight from the start page, without /begin, begins the run.
+	if rec := post("/play", "choice=next"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	played, _, _ := srv.Store.Get(ctx, id)
+	if !played.Begun || !played.RerollUsed {
+		t.Fatalf("Expected a step to begin the run, got begun=%v rerollUsed=%v", played.Begun, played.RerollUsed)
+	}
+	if rec := post("/reroll", "story_id="+testStoryID+"&difficulty=story"); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a reroll mid-run, got %d", rec.Code)
+	}
+	updated, _, _ := srv.Store.Get(ctx, id)
+	if updated.Difficulty != played.Difficulty || updated.Stats != played.Stats {
+		t.Errorf("Expected the run's difficulty and stats left alone, got %q %+v", updated.Difficulty, updated.Stats)
+	}
+}
+
+func TestHandleReroll_FixesDifficultyAtFirstRoll(t *testing.T) {
+	srv := testServer(t)
+	withDifficulties(srv)
+	ctx := context.Background()
+	id := srv.Store.NewID()
+	if err := srv.Store.Put(ctx, id, game.NewPlayer(testStoryID, "start")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	post := func(path, difficulty string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("session_id="+id+"&story_id="+testStoryID+"&difficulty="+difficulty))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: cookieName, Value: id})
+		rec := httptest.NewRecorder()
+		srv.Routes().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, rec.Code)
+		}
+	}
+
+	// Spend both of story's rerolls, then try to begin on hard (no rerolls).
+	post("/reroll", "story")
+	post("/reroll", "hard")
+	rerolled, _, _ := srv.Store.Get(ctx, id)
+	if rerolled.Difficulty != "story" || rerolled.Rerolls != 2 {
+		t.Fatalf("Expected both rerolls counted against story, got %q with %d", rerolled.Difficulty, rerolled.Rerolls)
+	}
+	post("/begin", "hard")
+	begun, _, _ := srv.Store.Get(ctx, id)
+	if begun.Difficulty != "story" || begun.Stats.Health != begun.BaseStats.Health+4 {
+		t.Errorf("Expected the run to begin on story, the difficulty rerolled under, got %q %+v", begun.Difficulty, begun.Stats)
+	}
+}
+
+func TestHandleStart_ShowsDifficulties(t *testing.T) {
+	srv := testServer(t)
+	withDifficulties(srv)
+	req := httptest.NewRequest(http.MethodGet, pathStart, http.NoBody)
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	body := rec.Body.String()
+	assertContains(t, body, `name="difficulty"`)
+	assertContains(t, body, `value="normal" selected`)
+}
+
+func TestHandleDifficulties(t *testing.T) {
+	srv := testServer(t)
+	withDifficulties(srv)
+	req := httptest.NewRequest(http.MethodGet, "/difficulties?story_id="+testStoryID, http.NoBody)
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	assertContains(t, body, `id="difficulty-select"`)
+	assertContains(t, body, ">Story<")
+	assertContains(t, body, ">Hard<")
+}
+
+func TestLibraryStoriesNotPlayable(t *testing.T) {
+	srv := testServer(t)
+	srv.Engine.Stories["lib"] = &game.Story{
+		Title:   "Shared Duels",
+		Library: true,
+		Start:   "ring",
+		Nodes:   map[string]*game.Node{"ring": {Text: "A ring of rope."}},
+	}
+	req := httptest.NewRequest(http.MethodGet, pathStart, http.NoBody)
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	assertNotContains(t, rec.Body.String(), "Shared Duels")
+
+	ctx := context.Background()
+	id := srv.Store.NewID()
+	if err := srv.Store.Put(ctx, id, game.NewPlayer(testStoryID, "start")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/begin", strings.NewReader("session_id="+id+"&story_id=lib"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	updated, _, err := srv.Store.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if updated.StoryID != testStoryID {
+		t.Errorf("Expected begin to fall back to a playable story, got %q", updated.StoryID)
+	}
+}
+
+func TestHandleBegin_Sequel(t *testing.T) {
+	srv := testServer(t)
+	srv.Engine.Stories["sequel"] = &game.Story{
+		Title: "The Sequel",
+		Start: "again",
+		Continues: &game.Continuation{
+			From: testStoryID, Endings: []string{"end"},
+			Items: map[string]string{"lamp": "lamp"}, Vars: map[string]string{"gold": "gold"},
+		},
+		Nodes: map[string]*game.Node{"again": {Text: "Here we go again."}},
+	}
+	ctx := context.Background()
+	st := game.NewPlayer(testStoryID, "start")
+	id := srv.Store.NewID()
+	if err := srv.Store.Put(ctx, id, st); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	begin := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/begin", strings.NewReader("session_id="+id+"&story_id=sequel&continue=1&name=Other"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		srv.Routes().ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := begin(); rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 before finishing the prequel, got %d", rec.Code)
+	}
+
+	st.Name = "Ada"
+	st.Stats = game.Stats{Strength: 11, Luck: 6, Health: 9}
+	st.Records = map[string]game.StoryRecord{testStoryID: {
+		Endings: []string{"end"},
+		Hero: &game.Hero{
+			Ending: "end", Name: "Ada", Stats: st.Stats,
+			Items: map[string]int{"lamp": 1}, Vars: map[string]int{"gold": 12},
+		},
+	}}
+	if err := srv.Store.Put(ctx, id, st); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/difficulties?story_id=sequel&session_id="+id, http.NoBody)
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	assertContains(t, rec.Body.String(), "Continue with Ada from Test")
+
+	if rec := begin(); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	updated, _, err := srv.Store.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if updated.StoryID != "sequel" || updated.Name != "Ada" || updated.Stats.Strength != 11 {
+		t.Errorf("Expected Ada to continue into the sequel, got %s %q %+v", updated.StoryID, updated.Name, updated.Stats)
+	}
+	if updated.Items["lamp"] != 1 || updated.Vars["gold"] != 12 {
+		t.Errorf("Expected Ada's lamp and gold carried over, got %v %v", updated.Items, updated.Vars)
+	}
+}
+
+func TestHandleRestart(t *testing.T) {
+	srv, id := spectateTestServer(t)
+	ctx := context.Background()
+	st, _, _ := srv.Store.Get(ctx, id)
+	st.NodeID = "end"
+	st.Records = map[string]game.StoryRecord{testStoryID: {Endings: []string{"end"}}}
+	if err := srv.Store.Put(ctx, id, st); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	token := srv.Spectators.Link(id)
+
+	req := httptest.NewRequest(http.MethodPost, "/restart", http.NoBody)
+	req.AddCookie(&http.Cookie{Name: cookieName, Value: id})
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != pathStart {
+		t.Fatalf("Expected a redirect to /start, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if _, ok, _ := srv.Store.Get(ctx, id); ok {
+		t.Error("Expected the old session to be deleted")
+	}
+	if _, _, _, ok := srv.Spectators.lookup(token); ok {
+		t.Error("Expected the old spectator link to be revoked")
+	}
+	var newID string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == cookieName {
+			newID = c.Value
+		}
+	}
+	if newID == "" || newID == id {
+		t.Fatalf("Expected a new session cookie, got %q", newID)
+	}
+	fresh, ok, _ := srv.Store.Get(ctx, newID)
+	if !ok || fresh.NodeID != "start" || fresh.Stats == (game.Stats{}) {
+		t.Errorf("Expected a fresh character at the start, got %+v %v", fresh, ok)
+	}
+	if !fresh.Record(testStoryID).HasEnding("end") {
+		t.Error("Expected trophies to carry over")
+	}
+	if ids, _ := srv.Store.List(ctx); len(ids) != 1 {
+		t.Errorf("Expected only the new session in the store, got %v", ids)
+	}
+
+	// Without a session there is nothing to delete.
+	rec = httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/restart", http.NoBody))
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("Expected a redirect, got %d", rec.Code)
+	}
+}
@@ -7,10 +7,12 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/fstest"
 
+	"adventure"
 	"adventure/internal/game"
 	"adventure/internal/session"
 )
@@ -38,19 +40,13 @@ func testServer(t *testing.T) *Server {
 	engine := &game.Engine{Stories: map[string]*game.Story{testStoryID: story}}
 	store := session.NewMemoryStore[game.PlayerState]()
 
-	tmplDir := filepath.Join("..", "..", "templates")
-	tmpl := template.Must(template.ParseFiles(
-		filepath.Join(tmplDir, "layout.html"),
-		filepath.Join(tmplDir, "layout_head.html"),
-		filepath.Join(tmplDir, "sidebar_left.html"),
-		filepath.Join(tmplDir, "sidebar_right.html"),
-		filepath.Join(tmplDir, "sidebar_left_oob.html"),
-		filepath.Join(tmplDir, "sidebar_right_oob.html"),
-		filepath.Join(tmplDir, "game.html"),
-		filepath.Join(tmplDir, "game_response.html"),
-		filepath.Join(tmplDir, "start.html"),
-	))
-	return &Server{Engine: engine, Store: store, Tmpl: tmpl}
+	return &Server{Engine: engine, Store: store, Tmpl: testTemplates(t)}
+}
+
+// testTemplates parses the embedded templates, as cmd/server does.
+func testTemplates(t *testing.T) *template.Template {
+	t.Helper()
+	return template.Must(template.ParseFS(adventure.Templates(), "*.html"))
 }
 
 const pathStart = "/start"
@@ -68,6 +64,21 @@ func TestHandleIndex(t *testing.T) {
 	}
 }
 
+func TestStaticFiles(t *testing.T) {
+	srv := testServer(t)
+	srv.StaticFiles = fstest.MapFS{"app.css": {Data: []byte("body{}")}}
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/app.css", http.NoBody))
+	if rec.Code != http.StatusOK || rec.Body.String() != "body{}" {
+		t.Errorf("Expected app.css from StaticFiles, got %d %q", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/missing.css", http.NoBody))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing file, got %d", rec.Code)
+	}
+}
+
 func TestHandleStart(t *testing.T) {
 	srv := testServer(t)
 	req := httptest.NewRequest(http.MethodGet, pathStart, http.NoBody)
@@ -200,6 +211,122 @@ func TestHandlePlay(t *testing.T) {
 	}
 }
 
+// postPlay submits form to /play with the session cookie.
+func postPlay(srv *Server, id, form string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/play", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: cookieName, Value: id})
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandlePlay_StaleRevision(t *testing.T) {
+	srv := apiTestServer(t)
+	ctx := context.Background()
+	id := srv.Store.NewID()
+	rev, err := srv.Store.CompareAndPut(ctx, id, game.NewPlayer(testStoryID, "start"), 0)
+	if err != nil {
+		t.Fatalf("CompareAndPut: %v", err)
+	}
+	form := "choice=flag&rev=" + strconv.FormatInt(rev, 10)
+
+	rec := postPlay(srv, id, form)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	_, next, _, _ := srv.Store.GetRev(ctx, id)
+	assertContains(t, rec.Body.String(), `"rev":"`+strconv.FormatInt(next, 10)+`"`)
+
+	// A double click sends the same form again: refused with the current view.
+	rec = postPlay(srv, id, form)
+	if rec.Code != http.StatusConflict || rec.Header().Get("X-Adventure-OOB") != "true" {
+		t.Fatalf("Expected 409 with the game view, got %d", rec.Code)
+	}
+	assertContains(t, rec.Body.String(), "That page was out of date")
+	assertContains(t, rec.Body.String(), `"rev":"`+strconv.FormatInt(next, 10)+`"`)
+	if _, rev, _, _ := srv.Store.GetRev(ctx, id); rev != next {
+		t.Errorf("Expected the stale choice not to be saved, revision %d -> %d", next, rev)
+	}
+
+	if rec := postPlay(srv, id, "choice=flag&rev=x"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad revision, got %d", rec.Code)
+	}
+}
+
+// racingStore lets another write land between a handler's read and its
+// compare-and-swap, as a second tab would.
+type racingStore struct {
+	session.Store[game.PlayerState]
+	races int
+	other func(*game.PlayerState)
+}
+
+func (s *racingStore) CompareAndPut(ctx context.Context, id string, v game.PlayerState, rev int64) (int64, error) {
+	if s.races > 0 {
+		s.races--
+		st, _, _ := s.Store.Get(ctx, id)
+		s.other(&st)
+		_ = s.Store.Put(ctx, id, st)
+	}
+	return s.Store.CompareAndPut(ctx, id, v, rev)
+}
+
+func TestMemoryStore_LosingStepLeavesStateAlone(t *testing.T) {
+	srv := testServer(t)
+	ctx := context.Background()
+	id := srv.Store.NewID()
+	_ = srv.Store.Put(ctx, id, game.NewPlayer(testStoryID, "start"))
+
+	// Two requests read the session and step it; only the first may save.
+	a, rev, _, _ := srv.Store.GetRev(ctx, id)
+	b, _, _, _ := srv.Store.GetRev(ctx, id)
+	resA, _ := srv.Engine.ApplyChoice(&a, "next")
+	resB, _ := srv.Engine.ApplyChoice(&b, "next")
+	if _, err := srv.Store.CompareAndPut(ctx, id, resA.State, rev); err != nil {
+		t.Fatalf("CompareAndPut: %v", err)
+	}
+	if _, err := srv.Store.CompareAndPut(ctx, id, resB.State, rev); !errors.Is(err, session.ErrConflict) {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+	st, _, _ := srv.Store.Get(ctx, id)
+	if st.Visits["end"] != 1 || len(st.VisitedNodes) != 2 {
+		t.Errorf("Expected only the winning step stored, got visits %v path %v", st.Visits, st.VisitedNodes)
+	}
+}
+
+func TestHandlePlay_RetriesConflicts(t *testing.T) {
+	srv := apiTestServer(t)
+	ctx := context.Background()
+	store := &racingStore{Store: srv.Store, other: func(st *game.PlayerState) { st.Stats.Luck = 11 }}
+	srv.Store = store
+	id := srv.Store.NewID()
+	_ = srv.Store.Put(ctx, id, game.NewPlayer(testStoryID, "start"))
+
+	// Without a revision the choice is applied again to what the other tab saved.
+	store.races = 1
+	if rec := postPlay(srv, id, "choice=flag"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 after a retry, got %d", rec.Code)
+	}
+	st, _, _ := srv.Store.Get(ctx, id)
+	if st.Stats.Luck != 11 || !st.Flags["marked"] {
+		t.Errorf("Expected both writes to survive, got luck %d flags %v", st.Stats.Luck, st.Flags)
+	}
+
+	// It gives up after maxPlayAttempts.
+	store.races = maxPlayAttempts
+	if rec := postPlay(srv, id, "choice=flag"); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 once retries run out, got %d", rec.Code)
+	}
+
+	// With a revision, a conflict is not retried: the page is stale.
+	_, rev, _, _ := srv.Store.GetRev(ctx, id)
+	store.races = 1
+	if rec := postPlay(srv, id, "choice=flag&rev="+strconv.FormatInt(rev, 10)); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a conflict with a revision, got %d", rec.Code)
+	}
+}
+
 func TestHandlePlay_UnknownSessionRedirectsToStart(t *testing.T) {
 	srv := testServer(t)
 	// Cookie with ID that was never Put so Get returns not found -> redirect to /start
@@ -216,6 +343,61 @@ func TestHandlePlay_UnknownSessionRedirectsToStart(t *testing.T) {
 	}
 }
 
+func TestHandlePlay_CookieSessions(t *testing.T) {
+	srv := testServer(t)
+	fallback := session.NewMemoryStore[game.PlayerState]()
+	store, err := session.NewCookieStore[game.PlayerState]([][]byte{[]byte("0123456789abcdef0123456789abcdef")}, fallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Store = store
+	srv.Cookies = CookieOptions{Secure: CookieSecureAlways, Domain: "example.com"}
+	store.NewCookie = srv.Cookies.Cookie
+
+	play := func(cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/play", strings.NewReader("choice=next"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		srv.Routes().ServeHTTP(rec, req)
+		return rec
+	}
+	rec := play(nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	assertContains(t, rec.Body.String(), "The end.")
+	cookies := rec.Result().Cookies()
+	var state *http.Cookie
+	for _, c := range cookies {
+		if c.Name == session.DefaultCookieName {
+			state = c
+		}
+	}
+	if state == nil {
+		t.Fatalf("Expected the session in a cookie, got %v", cookies)
+	}
+	if !state.Secure || state.Domain != "example.com" || state.SameSite != http.SameSiteLaxMode {
+		t.Errorf("Expected the server's cookie options, got %+v", state)
+	}
+	if fallback.Len() != 0 {
+		t.Errorf("Expected nothing kept on the server, got %d sessions", fallback.Len())
+	}
+
+	// A tampered cookie is an unknown session.
+	if state.Value[0] == 'A' {
+		state.Value = "B" + state.Value[1:]
+	} else {
+		state.Value = "A" + state.Value[1:]
+	}
+	rec = play(cookies)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != pathStart {
+		t.Errorf("Expected a redirect to %s, got %d %q", pathStart, rec.Code, rec.Header().Get("Location"))
+	}
+}
+
 func TestHandlePlay_NoCookie_CreatesStateAndRenders(t *testing.T) {
 	srv := testServer(t)
 	// No cookie: getOrCreateState creates new session and state from default story, then applies choice.
@@ -415,19 +597,7 @@ func testBattleServer(t *testing.T, battleNext string) *Server {
 	story := &game.Story{Start: "start", Nodes: nodes}
 	engine := &game.Engine{Stories: map[string]*game.Story{testStoryID: story}}
 	store := session.NewMemoryStore[game.PlayerState]()
-	tmplDir := filepath.Join("..", "..", "templates")
-	tmpl := template.Must(template.ParseFiles(
-		filepath.Join(tmplDir, "layout.html"),
-		filepath.Join(tmplDir, "layout_head.html"),
-		filepath.Join(tmplDir, "sidebar_left.html"),
-		filepath.Join(tmplDir, "sidebar_right.html"),
-		filepath.Join(tmplDir, "sidebar_left_oob.html"),
-		filepath.Join(tmplDir, "sidebar_right_oob.html"),
-		filepath.Join(tmplDir, "game.html"),
-		filepath.Join(tmplDir, "game_response.html"),
-		filepath.Join(tmplDir, "start.html"),
-	))
-	return &Server{Engine: engine, Store: store, Tmpl: tmpl}
+	return &Server{Engine: engine, Store: store, Tmpl: testTemplates(t)}
 }
 
 func TestBattleRunAwayWithoutNext(t *testing.T) {
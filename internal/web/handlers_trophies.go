@@ -0,0 +1,88 @@
+package web
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"adventure/internal/game"
+)
+
+// undiscoveredHint is shown for a locked ending or achievement without a story hint.
+const undiscoveredHint = "Not yet discovered."
+
+// GET /trophies lists, per story, the endings and achievements the player has
+// found, with spoiler-safe hints for the rest.
+func (s *Server) handleTrophies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data := map[string]any{}
+	var st game.PlayerState
+	if id := s.sessionID(r); id != "" {
+		existing, ok, err := s.Store.Get(r.Context(), id)
+		if err != nil {
+			s.serverError(w, r, "failed to load session", err)
+			return
+		}
+		if ok {
+			st = existing
+			data["State"] = st
+		}
+	}
+	data["Trophies"] = s.trophiesViewModel(&st)
+	if err := s.executeTemplate(r.Context(), w, "layout.html", data); err != nil {
+		http.Error(w, "failed to render template", 500)
+		return
+	}
+}
+
+// trophiesViewModel builds the endings catalogue for every story, sorted by name.
+func (s *Server) trophiesViewModel(st *game.PlayerState) TrophiesViewModel {
+	var vm TrophiesViewModel
+	for _, opt := range s.adventureOptions() {
+		story := s.Engine.Stories[opt.ID]
+		rec := st.Record(opt.ID)
+		entry := StoryTrophies{ID: opt.ID, Name: opt.Name}
+		for _, nodeID := range story.Endings() {
+			n := story.Nodes[nodeID]
+			e := TrophyEntry{Title: n.Title, Hint: n.Hint, Unlocked: rec.HasEnding(nodeID)}
+			if e.Title == "" {
+				e.Title = humanizeID(nodeID)
+			}
+			if e.Hint == "" {
+				e.Hint = undiscoveredHint
+			}
+			if e.Unlocked {
+				entry.EndingsFound++
+			}
+			entry.Endings = append(entry.Endings, e)
+		}
+		for _, a := range story.Achievements {
+			e := TrophyEntry{Title: a.Title, Description: a.Description, Hint: a.Hint, Unlocked: rec.HasAchievement(a.ID)}
+			if e.Title == "" {
+				e.Title = humanizeID(a.ID)
+			}
+			if e.Hint == "" {
+				e.Hint = undiscoveredHint
+			}
+			if e.Unlocked {
+				entry.AchievementsFound++
+			}
+			entry.Achievements = append(entry.Achievements, e)
+		}
+		vm.Stories = append(vm.Stories, entry)
+	}
+	sort.Slice(vm.Stories, func(i, j int) bool { return vm.Stories[i].Name < vm.Stories[j].Name })
+	return vm
+}
+
+// humanizeID turns a node or achievement ID like "goblin_victory" into "Goblin victory".
+func humanizeID(id string) string {
+	id = strings.TrimSpace(strings.ReplaceAll(id, "_", " "))
+	if id == "" {
+		return id
+	}
+	return strings.ToUpper(id[:1]) + id[1:]
+}
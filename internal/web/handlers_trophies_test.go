@@ -0,0 +1,103 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"adventure/internal/game"
+)
+
+func TestHandleTrophies_SpoilerSafe(t *testing.T) {
+	srv := testServer(t)
+	story := srv.Engine.Stories[testStoryID]
+	story.Nodes["end"].Title = "The Quiet End"
+	story.Nodes["secret"] = &game.Node{Text: "Secret text", Ending: true, Hint: "Look behind the waterfall"}
+	story.Achievements = []game.Achievement{
+		{ID: "finisher", Title: "Finisher", Description: "Reached the end", Trigger: game.Trigger{Ending: true}},
+		{ID: "explorer", Title: "Explorer", Hint: "Find every ending"},
+	}
+
+	ctx := context.Background()
+	st := game.NewPlayer(testStoryID, "start")
+	st.Records = map[string]game.StoryRecord{
+		testStoryID: {Endings: []string{"end"}, Achievements: []string{"finisher"}},
+	}
+	id := srv.Store.NewID()
+	if err := srv.Store.Put(ctx, id, st); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/trophies", http.NoBody)
+	req.AddCookie(&http.Cookie{Name: cookieName, Value: id})
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	assertContains(t, body, "The Quiet End")
+	assertContains(t, body, "Endings found: 1 / 2")
+	assertContains(t, body, "Look behind the waterfall")
+	assertNotContains(t, body, "Secret text")
+	assertContains(t, body, "Reached the end")
+	assertContains(t, body, "Find every ending")
+}
+
+func TestHandleTrophies_NoSession(t *testing.T) {
+	srv := testServer(t)
+	req := httptest.NewRequest(http.MethodGet, "/trophies", http.NoBody)
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	assertContains(t, rec.Body.String(), "Endings found: 0 / 1")
+
+	req = httptest.NewRequest(http.MethodPost, "/trophies", http.NoBody)
+	rec = httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestHandlePlay_ShowsUnlockedAchievement(t *testing.T) {
+	srv := testServer(t)
+	srv.Engine.Stories[testStoryID].Achievements = []game.Achievement{
+		{ID: "done", Title: "All Done", Trigger: game.Trigger{Node: "end"}},
+	}
+	ctx := context.Background()
+	id := srv.Store.NewID()
+	if err := srv.Store.Put(ctx, id, game.NewPlayer(testStoryID, "start")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/play", strings.NewReader("choice=next"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: cookieName, Value: id})
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	body := rec.Body.String()
+	assertContains(t, body, "Achievement unlocked")
+	assertContains(t, body, "All Done")
+	assertContains(t, body, "New ending discovered")
+
+	updated, _, err := srv.Store.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !updated.Record(testStoryID).HasAchievement("done") {
+		t.Error("Expected achievement persisted on the session")
+	}
+}
+
+func TestHumanizeID(t *testing.T) {
+	if got := humanizeID("goblin_victory"); got != "Goblin victory" {
+		t.Errorf("humanizeID = %q", got)
+	}
+	if got := humanizeID(""); got != "" {
+		t.Errorf("humanizeID(\"\") = %q", got)
+	}
+}
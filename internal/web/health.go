@@ -0,0 +1,78 @@
+package web
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// readyTimeout bounds the session store ping made by /readyz.
+const readyTimeout = 2 * time.Second
+
+// Health is the body of /healthz and /readyz.
+type Health struct {
+	Status   string `json:"status"` // "ok" or "unavailable"
+	Stories  int    `json:"stories"`
+	Store    string `json:"store,omitempty"` // "ok" or "unreachable"; /readyz only
+	Draining bool   `json:"draining,omitempty"`
+}
+
+func (s *Server) healthRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+}
+
+// GET /healthz answers 200 while the server is up with its stories loaded.
+// It doesn't touch the session store, so a store outage doesn't get the
+// server restarted.
+func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	h := Health{Status: "ok", Stories: len(s.Engine.Stories)}
+	status := http.StatusOK
+	if h.Stories == 0 {
+		h.Status, status = "unavailable", http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, h)
+}
+
+// GET /readyz answers 200 when the server can take players: its stories are
+// loaded, the session store answers a ping and it isn't shutting down.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	h := Health{Status: "ok", Stories: len(s.Engine.Stories), Store: "ok", Draining: s.draining()}
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+	if err := s.Store.Ping(ctx); err != nil {
+		s.logger().WarnContext(r.Context(), "session store unreachable", slog.Any("err", err))
+		h.Store = "unreachable"
+	}
+	status := http.StatusOK
+	if h.Stories == 0 || h.Store != "ok" || h.Draining {
+		h.Status, status = "unavailable", http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, h)
+}
+
+// Drain marks the server as shutting down: /readyz starts failing so load
+// balancers stop sending players, and party and spectator event streams end,
+// their browsers reconnecting elsewhere, so they don't hold up
+// http.Server.Shutdown. Call it when shutdown begins, such as from
+// http.Server.RegisterOnShutdown.
+func (s *Server) Drain() {
+	done := s.drainDone()
+	s.drainOnce.Do(func() { close(done) })
+}
+
+// drainDone is closed by Drain.
+func (s *Server) drainDone() chan struct{} {
+	s.drainInit.Do(func() { s.drained = make(chan struct{}) })
+	return s.drained
+}
+
+func (s *Server) draining() bool {
+	select {
+	case <-s.drainDone():
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"adventure/internal/game"
+	"adventure/internal/session"
+)
+
+// unreachableStore fails its ping, like a store whose server is down.
+type unreachableStore struct {
+	session.Store[game.PlayerState]
+}
+
+func (unreachableStore) Ping(context.Context) error { return errors.New("connection refused") }
+
+func getHealth(t *testing.T, srv *Server, path string) (int, Health) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+	var h Health
+	if err := json.Unmarshal(rec.Body.Bytes(), &h); err != nil {
+		t.Fatalf("%s: %v in %q", path, err, rec.Body.String())
+	}
+	return rec.Code, h
+}
+
+func TestHealthz(t *testing.T) {
+	srv := testServer(t)
+	if code, h := getHealth(t, srv, "/healthz"); code != http.StatusOK || h.Status != "ok" || h.Stories != 1 {
+		t.Errorf("Expected healthy with 1 story, got %d %+v", code, h)
+	}
+	srv.Store = unreachableStore{srv.Store}
+	if code, _ := getHealth(t, srv, "/healthz"); code != http.StatusOK {
+		t.Errorf("Expected /healthz not to depend on the store, got %d", code)
+	}
+	srv.Engine.Stories = nil
+	if code, h := getHealth(t, srv, "/healthz"); code != http.StatusServiceUnavailable || h.Status != "unavailable" {
+		t.Errorf("Expected unhealthy without stories, got %d %+v", code, h)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	srv := testServer(t)
+	if code, h := getHealth(t, srv, "/readyz"); code != http.StatusOK || h.Store != "ok" || h.Draining {
+		t.Errorf("Expected ready, got %d %+v", code, h)
+	}
+
+	down := testServer(t)
+	down.Store = unreachableStore{down.Store}
+	if code, h := getHealth(t, down, "/readyz"); code != http.StatusServiceUnavailable || h.Store != "unreachable" {
+		t.Errorf("Expected not ready with the store down, got %d %+v", code, h)
+	}
+
+	srv.Drain()
+	srv.Drain()
+	if code, h := getHealth(t, srv, "/readyz"); code != http.StatusServiceUnavailable || !h.Draining {
+		t.Errorf("Expected not ready while draining, got %d %+v", code, h)
+	}
+}
+
+func TestDrain_EndsEventStreams(t *testing.T) {
+	srv, sid := spectateTestServer(t)
+	tok := srv.Spectators.Link(sid)
+	ts := httptest.NewServer(srv.Routes())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/watch/"+tok+"/events", http.NoBody)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET events: %v", err)
+	}
+	defer resp.Body.Close()
+	r := bufio.NewReader(resp.Body)
+	if _, err := r.ReadString('\n'); err != nil {
+		t.Fatalf("Expected the first event, got %v", err)
+	}
+
+	srv.Drain()
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Errorf("Expected the stream to end cleanly on Drain, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"adventure/internal/game"
+	"adventure/internal/logging"
+)
+
+// requestIDHeader carries a request's ID, from a proxy that set one or as
+// the server made it, so log lines can be matched to a response.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen is the longest request ID taken from a client.
+const maxRequestIDLen = 64
+
+// logger returns the server's logger, or slog's default.
+func (s *Server) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+// withRequestLog gives each request an ID and log fields, and logs the
+// request when it finishes. Requests are logged by mux's route pattern
+// rather than their path, which can hold session IDs and spectator tokens.
+func (s *Server) withRequestLog(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		ctx := logging.NewContext(r.Context(), slog.String("request_id", id))
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		level := slog.LevelInfo
+		if route == "GET /healthz" || route == "GET /readyz" {
+			level = slog.LevelDebug // probes would drown out the players
+		}
+		if sw.code >= 500 {
+			level = slog.LevelError
+		}
+		s.logger().Log(ctx, level, "request",
+			slog.String("method", r.Method),
+			slog.String("route", route),
+			slog.Int("status", sw.code),
+			slog.Duration("duration", time.Since(start)))
+	})
+}
+
+// validRequestID accepts short IDs of letters, digits and -_. only, so a
+// client can't forge log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b) //nolint:errcheck // crypto/rand.Read does not fail
+	return hex.EncodeToString(b)
+}
+
+// logSession adds the session, hashed, and its story and node to the
+// request's log lines.
+func logSession(ctx context.Context, sessionID string, st *game.PlayerState) {
+	attrs := []slog.Attr{slog.String("session", logging.HashID(sessionID))}
+	if st != nil {
+		attrs = append(attrs, slog.String("story", st.StoryID), slog.String("node", st.NodeID))
+	}
+	logging.Add(ctx, attrs...)
+}
+
+// serverError logs err, the cause of a failed request, and answers 500 with
+// msg.
+func (s *Server) serverError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	s.logger().ErrorContext(r.Context(), msg, slog.Any("err", err))
+	http.Error(w, msg, http.StatusInternalServerError)
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"adventure/internal/logging"
+)
+
+// logLines decodes the JSON log lines in buf.
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(l), &m); err != nil {
+			t.Fatalf("bad log line %q: %v", l, err)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func TestRequestLog(t *testing.T) {
+	srv := apiTestServer(t)
+	var buf bytes.Buffer
+	srv.Logger, _ = logging.New(&buf, slog.LevelDebug, logging.FormatJSON)
+	id := apiBegun(t, srv)
+	buf.Reset()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/"+id+"/choices", strings.NewReader(`{"choice":"next"}`))
+	req.Header.Set("X-Request-ID", "abc-123")
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Request-ID"); got != "abc-123" {
+		t.Errorf("Expected the request ID echoed, got %q", got)
+	}
+	if strings.Contains(buf.String(), id) {
+		t.Error("Expected the session ID not to be logged")
+	}
+	lines := logLines(t, &buf)
+	last := lines[len(lines)-1]
+	for k, want := range map[string]any{
+		"msg": "request", "request_id": "abc-123", "session": logging.HashID(id),
+		"story": testStoryID, "node": "start", "status": float64(200),
+	} {
+		if last[k] != want {
+			t.Errorf("Expected %s=%v in the request line, got %v", k, want, last[k])
+		}
+	}
+	if lines[0]["msg"] != "step" || lines[0]["choice"] != "next" || lines[0]["request_id"] != "abc-123" {
+		t.Errorf("Expected a step line first, got %v", lines[0])
+	}
+
+	// A forged request ID is replaced.
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/stories", http.NoBody)
+	req.Header.Set("X-Request-ID", "a b\nc")
+	rec = httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Request-ID"); got == "" || got == "a b\nc" {
+		t.Errorf("Expected a fresh request ID, got %q", got)
+	}
+}
+
+func TestServerErrorLogsCause(t *testing.T) {
+	srv := testServer(t)
+	var buf bytes.Buffer
+	srv.Logger, _ = logging.New(&buf, slog.LevelInfo, logging.FormatJSON)
+	srv.Store = failingStore{srv.Store}
+	req := httptest.NewRequest(http.MethodGet, "/game", http.NoBody)
+	req.AddCookie(&http.Cookie{Name: cookieName, Value: "x"})
+	rec := httptest.NewRecorder()
+	srv.Routes().ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", rec.Code)
+	}
+	lines := logLines(t, &buf)
+	if lines[0]["msg"] != "failed to load session" || lines[0]["err"] != "store down" || lines[0]["level"] != "ERROR" {
+		t.Errorf("Expected the cause logged, got %v", lines[0])
+	}
+	if last := lines[len(lines)-1]; last["msg"] != "request" || last["level"] != "ERROR" {
+		t.Errorf("Expected the request logged as an error, got %v", last)
+	}
+}
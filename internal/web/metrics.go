@@ -0,0 +1,227 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"adventure/internal/game"
+	"adventure/internal/metrics"
+	"adventure/internal/session"
+)
+
+// DefaultMetricsPath is where Routes serves the metrics.
+const DefaultMetricsPath = "/metrics"
+
+// sessionCountTimeout bounds how long a scrape waits to count sessions.
+const sessionCountTimeout = 5 * time.Second
+
+// Metrics are the game server's Prometheus metrics. A nil *Metrics records
+// nothing.
+type Metrics struct {
+	Registry *metrics.Registry
+
+	requests     *metrics.Counter   // route, method, code
+	latency      *metrics.Histogram // route
+	steps        *metrics.Counter   // story
+	rounds       *metrics.Counter   // story
+	renderErrors *metrics.Counter   // template
+	storeErrors  *metrics.Counter   // op
+	assets       *metrics.Counter   // kind, result
+}
+
+// NewMetrics returns the server's metrics in a new registry.
+func NewMetrics() *Metrics {
+	r := metrics.NewRegistry()
+	return &Metrics{
+		Registry:     r,
+		requests:     r.Counter("adventure_http_requests_total", "HTTP requests by route pattern, method and status code.", "route", "method", "code"),
+		latency:      r.Histogram("adventure_http_request_duration_seconds", "HTTP request latency by route pattern.", nil, "route"),
+		steps:        r.Counter("adventure_story_steps_total", "Choices applied, by story.", "story"),
+		rounds:       r.Counter("adventure_battle_rounds_total", "Battle rounds resolved, by story.", "story"),
+		renderErrors: r.Counter("adventure_template_errors_total", "Templates that failed to render, by template.", "template"),
+		storeErrors:  r.Counter("adventure_session_store_errors_total", "Session store calls that failed, by operation.", "op"),
+		assets:       r.Counter("adventure_asset_requests_total", "Scenery, audio and static file requests by kind and result (hit: answered 304 from the browser's cache).", "kind", "result"),
+	}
+}
+
+// InstrumentStore counts store's errors and reports its live sessions as
+// adventure_active_sessions, and returns the store to use. Call it once.
+func (m *Metrics) InstrumentStore(store session.Store[game.PlayerState]) session.Store[game.PlayerState] {
+	if m == nil {
+		return store
+	}
+	m.Registry.GaugeFunc("adventure_active_sessions", "Live sessions in the session store.", func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), sessionCountTimeout)
+		defer cancel()
+		ids, err := store.List(ctx)
+		if err != nil {
+			m.storeErrors.Inc("list")
+			return 0
+		}
+		return float64(len(ids))
+	})
+	return &instrumentedStore{Store: store, m: m}
+}
+
+// Step counts a step that was applied, and its battle round if it had one.
+func (m *Metrics) Step(res *game.StepResult) {
+	if m == nil || res.ErrorMessage != "" {
+		return
+	}
+	m.steps.Inc(res.State.StoryID)
+	if res.LastEnemyDice != nil {
+		m.rounds.Inc(res.State.StoryID)
+	}
+}
+
+// instrument counts and times the requests handled by next. mux names the
+// route, by its pattern, so the label's values stay few.
+func (m *Metrics) instrument(mux *http.ServeMux, next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(sw, r)
+		m.latency.Observe(time.Since(start).Seconds(), route)
+		m.requests.Inc(route, r.Method, strconv.Itoa(sw.code))
+		if kind := assetKind(route); kind != "" {
+			m.assets.Inc(kind, assetResult(sw.code))
+		}
+	})
+}
+
+// assetKind names the asset routes.
+func assetKind(route string) string {
+	switch route {
+	case "/scenery/":
+		return "scenery"
+	case "/audio/":
+		return "audio"
+	case "/static/":
+		return "static"
+	}
+	return ""
+}
+
+func assetResult(code int) string {
+	switch {
+	case code == http.StatusNotModified:
+		return "hit"
+	case code < 300:
+		return "served"
+	case code == http.StatusNotFound:
+		return "missing"
+	}
+	return "error"
+}
+
+// statusWriter remembers the status code written. It passes Flush through
+// for the event streams.
+type statusWriter struct {
+	http.ResponseWriter
+	code  int
+	wrote bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wrote {
+		w.code, w.wrote = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	w.wrote = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// instrumentedStore counts a session store's errors. Revision conflicts are
+// not errors: they are how the store refuses a stale write.
+type instrumentedStore struct {
+	session.Store[game.PlayerState]
+	m *Metrics
+}
+
+func (s *instrumentedStore) count(op string, err error) {
+	if err != nil && !errors.Is(err, session.ErrConflict) {
+		s.m.storeErrors.Inc(op)
+	}
+}
+
+func (s *instrumentedStore) Get(ctx context.Context, id string) (game.PlayerState, bool, error) {
+	v, ok, err := s.Store.Get(ctx, id)
+	s.count("get", err)
+	return v, ok, err
+}
+
+func (s *instrumentedStore) GetRev(ctx context.Context, id string) (game.PlayerState, int64, bool, error) {
+	v, rev, ok, err := s.Store.GetRev(ctx, id)
+	s.count("get", err)
+	return v, rev, ok, err
+}
+
+func (s *instrumentedStore) CompareAndPut(ctx context.Context, id string, v game.PlayerState, rev int64) (int64, error) {
+	newRev, err := s.Store.CompareAndPut(ctx, id, v, rev)
+	s.count("put", err)
+	return newRev, err
+}
+
+func (s *instrumentedStore) Put(ctx context.Context, id string, v game.PlayerState) error {
+	err := s.Store.Put(ctx, id, v)
+	s.count("put", err)
+	return err
+}
+
+func (s *instrumentedStore) PutTTL(ctx context.Context, id string, v game.PlayerState, ttl time.Duration) error {
+	err := s.Store.PutTTL(ctx, id, v, ttl)
+	s.count("put", err)
+	return err
+}
+
+func (s *instrumentedStore) Delete(ctx context.Context, id string) error {
+	err := s.Store.Delete(ctx, id)
+	s.count("delete", err)
+	return err
+}
+
+func (s *instrumentedStore) List(ctx context.Context) ([]string, error) {
+	ids, err := s.Store.List(ctx)
+	s.count("list", err)
+	return ids, err
+}
+
+// executeTemplate renders the named template, logging and counting
+// failures.
+func (s *Server) executeTemplate(ctx context.Context, w io.Writer, name string, data any) error {
+	err := s.Tmpl.ExecuteTemplate(w, name, data)
+	if err != nil {
+		s.logger().ErrorContext(ctx, "template failed", slog.String("template", name), slog.Any("err", err))
+		if s.Metrics != nil {
+			s.Metrics.renderErrors.Inc(name)
+		}
+	}
+	return err
+}
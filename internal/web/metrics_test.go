@@ -0,0 +1,110 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"adventure/internal/game"
+	"adventure/internal/session"
+)
+
+func TestMetrics_Endpoint(t *testing.T) {
+	srv := apiTestServer(t)
+	srv.Metrics = NewMetrics()
+	srv.Store = srv.Metrics.InstrumentStore(srv.Store)
+	srv.MetricsPath = DefaultMetricsPath
+
+	id := apiBegun(t, srv)
+	apiCall(t, srv, http.MethodPost, "/api/v1/sessions/"+id+"/choices", `{"choice":"fight:attack:0"}`, nil)
+	apiCall(t, srv, http.MethodPost, "/api/v1/sessions/"+id+"/choices", `{"choice":"nope"}`, nil)
+
+	rec := apiCall(t, srv, http.MethodGet, "/metrics", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`adventure_http_requests_total{route="POST /api/v1/sessions/{id}/choices",method="POST",code="200"} 1`,
+		`adventure_http_request_duration_seconds_count{route="POST /api/v1/sessions"} 1`,
+		`adventure_story_steps_total{story="test"} 1`,
+		`adventure_battle_rounds_total{story="test"} 1`,
+		"adventure_active_sessions 1",
+	} {
+		assertContains(t, body, want)
+	}
+}
+
+func TestMetrics_OffByDefault(t *testing.T) {
+	srv := apiTestServer(t)
+	srv.Metrics = NewMetrics()
+	if rec := apiCall(t, srv, http.MethodGet, "/metrics", "", nil); strings.Contains(rec.Body.String(), "# TYPE") {
+		t.Error("Expected no metrics without a MetricsPath")
+	}
+}
+
+func TestMetrics_TemplateAndStoreErrors(t *testing.T) {
+	srv := testServer(t)
+	srv.Metrics = NewMetrics()
+	srv.Store = srv.Metrics.InstrumentStore(failingStore{srv.Store})
+	if err := srv.executeTemplate(context.Background(), httptest.NewRecorder(), "missing.html", nil); err == nil {
+		t.Fatal("Expected an error for a missing template")
+	}
+	_, _, _ = srv.Store.Get(context.Background(), "x")
+	if v := srv.Metrics.renderErrors.Value("missing.html"); v != 1 {
+		t.Errorf("Expected 1 template error, got %v", v)
+	}
+	if v := srv.Metrics.storeErrors.Value("get"); v != 1 {
+		t.Errorf("Expected 1 store error, got %v", v)
+	}
+}
+
+func TestMetrics_AssetCacheHits(t *testing.T) {
+	tmpDir := t.TempDir()
+	dir := filepath.Join(tmpDir, sceneryTestStoryID, "scenery")
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "forest.png"), minimalPNG(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{
+		Engine:     &game.Engine{Stories: map[string]*game.Story{sceneryTestStoryID: {Start: "a", Nodes: map[string]*game.Node{"a": {Text: "Start"}}}}},
+		StoryFiles: os.DirFS(tmpDir),
+		Metrics:    NewMetrics(),
+	}
+	h := srv.Routes()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/scenery/"+sceneryTestStoryID+"/forest", http.NoBody))
+	req := httptest.NewRequest(http.MethodGet, "/scenery/"+sceneryTestStoryID+"/forest", http.NoBody)
+	req.Header.Set("If-Modified-Since", rec.Header().Get("Last-Modified"))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("Expected 304, got %d", rec.Code)
+	}
+	if v := srv.Metrics.assets.Value("scenery", "hit"); v != 1 {
+		t.Errorf("Expected 1 cache hit, got %v", v)
+	}
+	if v := srv.Metrics.assets.Value("scenery", "served"); v != 1 {
+		t.Errorf("Expected 1 served asset, got %v", v)
+	}
+}
+
+// failingStore fails every read.
+type failingStore struct {
+	session.Store[game.PlayerState]
+}
+
+func (failingStore) Get(context.Context, string) (game.PlayerState, bool, error) {
+	return game.PlayerState{}, false, errors.New("store down")
+}
+
+func (failingStore) GetRev(context.Context, string) (game.PlayerState, int64, bool, error) {
+	return game.PlayerState{}, 0, false, errors.New("store down")
+}
@@ -0,0 +1,327 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"adventure/internal/game"
+)
+
+const (
+	// DefaultPartyVoteTime is how long a party vote stays open after its first vote.
+	DefaultPartyVoteTime = 20 * time.Second
+	// DefaultPartyMaxIdle is how long a party with nobody connected is kept.
+	DefaultPartyMaxIdle = 6 * time.Hour
+
+	partyCodeLen = 6
+	// partyCodeChars leaves out letters and digits that are easy to confuse.
+	partyCodeChars = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// Parties holds the party sessions: one shared game that a group plays by
+// voting on each choice. Create it with NewParties.
+type Parties struct {
+	// VoteTime is how long voting stays open after a round's first vote.
+	VoteTime time.Duration
+	// MaxIdle is how long a party nobody is connected to is kept.
+	MaxIdle time.Duration
+
+	mu sync.Mutex
+	m  map[string]*Party
+}
+
+// NewParties returns an empty party hub with the default timings.
+func NewParties() *Parties {
+	return &Parties{VoteTime: DefaultPartyVoteTime, MaxIdle: DefaultPartyMaxIdle, m: map[string]*Party{}}
+}
+
+// Party is one shared game. Code, Host, srv and voteTime are set by Create
+// and never change. The fields after mu are guarded by it; the timer's
+// callback runs without mu and takes it through Close, and subscribers
+// receive on their channels without it.
+type Party struct {
+	Code string
+	Host string // voter ID of the browser that created the party
+
+	srv      *Server
+	voteTime time.Duration
+
+	mu       sync.Mutex
+	st       game.PlayerState
+	last     game.StepResult // result of the last applied vote, for display
+	round    int             // increases each time a vote is applied
+	votes    map[string]partyVote
+	seq      int // orders votes so ties go to the earliest
+	deadline time.Time
+	timer    *time.Timer
+	subs     map[chan struct{}]struct{}
+	active   time.Time // last vote or disconnect, for pruning
+}
+
+// partyVote is one voter's pick in the current round.
+type partyVote struct {
+	Choice string
+	Answer string
+	Seq    int
+}
+
+// partySnapshot is the live vote state sent to connected browsers.
+type partySnapshot struct {
+	Round    int            `json:"round"`
+	Counts   map[string]int `json:"counts"`   // choice key -> votes
+	Voters   int            `json:"voters"`   // connected browsers
+	ClosesIn int64          `json:"closesIn"` // milliseconds until the vote closes; 0 before the first vote
+	Ended    bool           `json:"ended"`
+}
+
+// Create starts a party playing a copy of st, hosted by the voter host. The
+// copy is deep, so the party's steps never reach the host's saved game.
+func (ps *Parties) Create(srv *Server, st game.PlayerState, host string) *Party {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.prune()
+	code := newPartyCode()
+	for ps.m[code] != nil {
+		code = newPartyCode()
+	}
+	p := &Party{
+		Code:     code,
+		Host:     host,
+		srv:      srv,
+		voteTime: ps.VoteTime,
+		st:       st.Clone(),
+		votes:    map[string]partyVote{},
+		subs:     map[chan struct{}]struct{}{},
+		active:   time.Now(),
+	}
+	ps.m[code] = p
+	return p
+}
+
+// Get returns the party with the given code, or nil.
+func (ps *Parties) Get(code string) *Party {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.m[code]
+}
+
+// prune drops parties nobody has been connected to for MaxIdle. ps.mu must be held.
+func (ps *Parties) prune() {
+	for code, p := range ps.m {
+		p.mu.Lock()
+		idle := len(p.subs) == 0 && time.Since(p.active) > ps.MaxIdle
+		if idle && p.timer != nil {
+			p.timer.Stop()
+		}
+		p.mu.Unlock()
+		if idle {
+			delete(ps.m, code)
+		}
+	}
+}
+
+func newPartyCode() string {
+	b := make([]byte, partyCodeLen)
+	_, _ = rand.Read(b) //nolint:errcheck // crypto/rand.Read does not fail
+	for i := range b {
+		b[i] = partyCodeChars[int(b[i])%len(partyCodeChars)]
+	}
+	return string(b)
+}
+
+// View returns the party's current state, the last vote's result and round.
+func (p *Party) View() (st game.PlayerState, last game.StepResult, round int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.st, p.last, p.round
+}
+
+// Vote records voter's pick for round, replacing any earlier pick, and starts
+// the countdown on a round's first vote. It returns false when the vote is
+// stale (another round is open) or choice is not on offer.
+func (p *Party) Vote(voter string, round int, choice, answer string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if round != p.round || !p.offered(choice) {
+		return false
+	}
+	p.seq++
+	p.votes[voter] = partyVote{Choice: choice, Answer: answer, Seq: p.seq}
+	p.active = time.Now()
+	if p.timer == nil {
+		p.deadline = time.Now().Add(p.voteTime)
+		p.timer = time.AfterFunc(p.voteTime, func() { p.Close(round) })
+	}
+	p.notify()
+	return true
+}
+
+// offered reports whether key is one of the current node's choices, battle
+// actions included. p.mu must be held.
+func (p *Party) offered(key string) bool {
+	vm, err := p.srv.makeViewModel(&p.st, "", nil, nil, nil, nil)
+	if err != nil || vm.Node.Ending {
+		return false
+	}
+	for _, o := range partyOptions(&vm) {
+		if o.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Close ends the vote for round, if it is still open and has votes, and
+// applies the winning choice through the engine: the most votes, ties going
+// to the choice voted for first. For a prompt the most common answer among
+// its voters is used, again ties to the earliest. It reports whether a
+// choice was applied.
+func (p *Party) Close(round int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if round != p.round || len(p.votes) == 0 {
+		return false
+	}
+	choice, answer := tally(p.votes)
+	before := p.st
+	res, err := p.srv.Engine.ApplyChoiceWithAnswer(&p.st, choice, answer)
+	if err != nil {
+		res = game.StepResult{State: p.st, ErrorMessage: err.Error()}
+	}
+	p.srv.recordStep(context.Background(), "party:"+p.Code, &before, choice, &res)
+	p.srv.Metrics.Step(&res)
+	p.st, p.last = res.State, res
+	p.round++
+	p.votes = map[string]partyVote{}
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.notify()
+	return true
+}
+
+// tally picks the winning choice and, for prompts, answer.
+func tally(votes map[string]partyVote) (choice, answer string) {
+	type count struct{ n, first int }
+	choices := map[string]*count{}
+	for _, v := range votes {
+		c := choices[v.Choice]
+		if c == nil {
+			c = &count{first: v.Seq}
+			choices[v.Choice] = c
+		}
+		c.n++
+		c.first = min(c.first, v.Seq)
+	}
+	var best *count
+	for k, c := range choices {
+		if best == nil || c.n > best.n || c.n == best.n && c.first < best.first {
+			choice, best = k, c
+		}
+	}
+	answers := map[string]*count{}
+	var bestAnswer *count
+	for _, v := range votes {
+		if v.Choice != choice {
+			continue
+		}
+		a := game.NormalizeAnswer(v.Answer)
+		c := answers[a]
+		if c == nil {
+			c = &count{first: v.Seq}
+			answers[a] = c
+		}
+		c.n++
+		c.first = min(c.first, v.Seq)
+	}
+	for a, c := range answers {
+		if bestAnswer == nil || c.n > bestAnswer.n || c.n == bestAnswer.n && c.first < bestAnswer.first {
+			answer, bestAnswer = a, c
+		}
+	}
+	return choice, answer
+}
+
+// Snapshot returns the live vote counts and countdown.
+func (p *Party) Snapshot() partySnapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	snap := partySnapshot{Round: p.round, Counts: map[string]int{}, Voters: len(p.subs)}
+	for _, v := range p.votes {
+		snap.Counts[v.Choice]++
+	}
+	if p.timer != nil {
+		snap.ClosesIn = max(time.Until(p.deadline).Milliseconds(), 0)
+	}
+	if n, err := p.srv.Engine.CurrentNode(&p.st); err == nil {
+		snap.Ended = n.Ending
+	}
+	return snap
+}
+
+// Subscribe returns a channel that is signalled whenever the party changes,
+// and a function to unsubscribe. Signals are coalesced: a subscriber reads
+// the current Snapshot when signalled rather than a queue of events.
+func (p *Party) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	p.mu.Lock()
+	p.subs[ch] = struct{}{}
+	p.notify()
+	p.mu.Unlock()
+	return ch, func() {
+		p.mu.Lock()
+		delete(p.subs, ch)
+		p.active = time.Now()
+		p.notify()
+		p.mu.Unlock()
+	}
+}
+
+// notify signals every subscriber without blocking. p.mu must be held.
+func (p *Party) notify() {
+	for ch := range p.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// PartyOption is one choice the party can vote for.
+type PartyOption struct {
+	Key    string
+	Text   string
+	Prompt *game.Prompt
+	Votes  int
+}
+
+// PartyView is what game.html needs to show party voting instead of the
+// single-player choices.
+type PartyView struct {
+	Code    string
+	Round   int
+	Host    bool // the viewer created the party and may close votes early
+	Voters  int
+	Options []PartyOption
+}
+
+// partyOptions lists the choices to vote on: the battle actions during a
+// battle, otherwise the node's available choices, as the game view shows them.
+func partyOptions(vm *ViewModel) []PartyOption {
+	if vm.EffectiveChoices != nil {
+		out := make([]PartyOption, 0, len(vm.EffectiveChoices))
+		for _, c := range vm.EffectiveChoices {
+			out = append(out, PartyOption{Key: c.Key, Text: c.Text})
+		}
+		return out
+	}
+	out := make([]PartyOption, 0, len(vm.Choices))
+	for i := range vm.Choices {
+		c := &vm.Choices[i]
+		out = append(out, PartyOption{Key: c.Key, Text: c.Text, Prompt: c.Prompt})
+	}
+	return out
+}
@@ -0,0 +1,194 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"adventure/internal/game"
+)
+
+// partyTestServer returns apiTestServer's story with parties enabled and a
+// vote time long enough that tests close votes themselves.
+func partyTestServer(t *testing.T) *Server {
+	t.Helper()
+	srv := apiTestServer(t)
+	srv.Parties = NewParties()
+	srv.Parties.VoteTime = time.Hour
+	return srv
+}
+
+func newTestParty(srv *Server) *Party {
+	st := game.NewPlayer(testStoryID, "start")
+	st.Stats = game.Stats{Strength: 10, Luck: 8, Health: 20}
+	return srv.Parties.Create(srv, st, "host")
+}
+
+func TestTally(t *testing.T) {
+	tests := []struct {
+		name         string
+		votes        map[string]partyVote
+		choice, want string
+	}{
+		{"majority", map[string]partyVote{
+			"a": {Choice: "north", Seq: 1},
+			"b": {Choice: "south", Seq: 2},
+			"c": {Choice: "south", Seq: 3},
+		}, "south", ""},
+		{"tie goes to the first vote", map[string]partyVote{
+			"a": {Choice: "south", Seq: 2},
+			"b": {Choice: "north", Seq: 1},
+		}, "north", ""},
+		{"most common answer", map[string]partyVote{
+			"a": {Choice: "riddle", Answer: "A man", Seq: 1},
+			"b": {Choice: "riddle", Answer: "man!", Seq: 2},
+			"c": {Choice: "riddle", Answer: "MAN", Seq: 3},
+			"d": {Choice: "north", Seq: 4},
+		}, "riddle", "man"},
+		{"answer tie goes to the first", map[string]partyVote{
+			"a": {Choice: "riddle", Answer: "cat", Seq: 2},
+			"b": {Choice: "riddle", Answer: "dog", Seq: 1},
+		}, "riddle", "dog"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			choice, answer := tally(tt.votes)
+			if choice != tt.choice || answer != tt.want {
+				t.Errorf("tally = %q, %q; want %q, %q", choice, answer, tt.choice, tt.want)
+			}
+		})
+	}
+}
+
+func TestParty_VoteAndClose(t *testing.T) {
+	srv := partyTestServer(t)
+	p := newTestParty(srv)
+	if len(p.Code) != partyCodeLen || srv.Parties.Get(p.Code) != p {
+		t.Fatalf("Expected a registered party, got code %q", p.Code)
+	}
+
+	if p.Vote("a", 1, "next", "") {
+		t.Error("Expected a vote for another round to be refused")
+	}
+	if p.Vote("a", 0, "nope", "") {
+		t.Error("Expected a vote for a choice not on offer to be refused")
+	}
+	if p.Close(0) {
+		t.Error("Expected closing a vote without votes to do nothing")
+	}
+	if !p.Vote("a", 0, "flag", "") || !p.Vote("b", 0, "next", "") || !p.Vote("a", 0, "next", "") {
+		t.Fatal("Expected votes to be accepted")
+	}
+	snap := p.Snapshot()
+	if snap.Counts["next"] != 2 || snap.Counts["flag"] != 0 || snap.ClosesIn <= 0 {
+		t.Errorf("Expected a changed vote and a running countdown, got %+v", snap)
+	}
+	if !p.Close(0) {
+		t.Fatal("Expected the vote to close")
+	}
+	st, _, round := p.View()
+	if st.NodeID != "end" || round != 1 {
+		t.Errorf("Expected the winning choice applied, got node %q round %d", st.NodeID, round)
+	}
+	if snap := p.Snapshot(); !snap.Ended || len(snap.Counts) != 0 || snap.ClosesIn != 0 {
+		t.Errorf("Expected a fresh, ended round, got %+v", snap)
+	}
+	if p.Vote("a", 1, "next", "") {
+		t.Error("Expected no votes after the ending")
+	}
+}
+
+func TestParties_CreateCopiesState(t *testing.T) {
+	srv := partyTestServer(t)
+	st := game.NewPlayer(testStoryID, "start")
+	p := srv.Parties.Create(srv, st, "host")
+	if !p.Vote("a", 0, "flag", "") || !p.Close(0) {
+		t.Fatal("Expected the vote to close")
+	}
+	if st.Flags["marked"] || st.Visits["start"] != 1 || len(st.VisitedNodes) != 1 {
+		t.Errorf("Expected the host's state untouched, got flags %v visits %v", st.Flags, st.Visits)
+	}
+}
+
+func TestParty_BattleAndPrompt(t *testing.T) {
+	srv := partyTestServer(t)
+	p := newTestParty(srv)
+
+	p.Vote("a", 0, "riddle", "cat")
+	p.Close(0)
+	st, last, _ := p.View()
+	if st.NodeID != "start" || last.ErrorCode != game.RefusalWrongAnswer {
+		t.Fatalf("Expected a wrong answer to be refused, got %q %+v", st.NodeID, last)
+	}
+
+	p.Vote("a", 1, "fight", "")
+	p.Close(1)
+	if st, _, _ = p.View(); len(st.Enemies) != 2 {
+		t.Fatalf("Expected a battle, got %+v", st.Enemies)
+	}
+	if p.Vote("a", 2, "next", "") {
+		t.Error("Expected node choices to be refused during a battle")
+	}
+	if !p.Vote("a", 2, "fight:attack:1", "") {
+		t.Fatal("Expected a battle action vote")
+	}
+	p.Close(2)
+	if st, _, _ = p.View(); st.Enemies[1].Health != 4 {
+		t.Errorf("Expected the bat hit, got %+v", st.Enemies)
+	}
+}
+
+func TestParty_TimerClosesVote(t *testing.T) {
+	srv := partyTestServer(t)
+	srv.Parties.VoteTime = 10 * time.Millisecond
+	p := newTestParty(srv)
+	changed, unsubscribe := p.Subscribe()
+	defer unsubscribe()
+	<-changed // subscribing signals once
+
+	p.Vote("a", 0, "next", "")
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case <-changed:
+			if _, _, round := p.View(); round == 1 {
+				return
+			}
+		case <-deadline:
+			t.Fatal("Expected the countdown to close the vote")
+		}
+	}
+}
+
+func TestParty_SubscribeCountsVoters(t *testing.T) {
+	srv := partyTestServer(t)
+	p := newTestParty(srv)
+	_, un1 := p.Subscribe()
+	_, un2 := p.Subscribe()
+	if n := p.Snapshot().Voters; n != 2 {
+		t.Errorf("Expected 2 connected, got %d", n)
+	}
+	un1()
+	un2()
+	if n := p.Snapshot().Voters; n != 0 {
+		t.Errorf("Expected 0 connected, got %d", n)
+	}
+}
+
+func TestParties_Prune(t *testing.T) {
+	srv := partyTestServer(t)
+	srv.Parties.MaxIdle = time.Millisecond
+	idle := newTestParty(srv)
+	idle.Vote("a", 0, "next", "")
+	busy := newTestParty(srv)
+	_, unsubscribe := busy.Subscribe()
+	defer unsubscribe()
+	time.Sleep(5 * time.Millisecond)
+
+	fresh := newTestParty(srv)
+	if srv.Parties.Get(idle.Code) != nil {
+		t.Error("Expected the idle party to be pruned")
+	}
+	if srv.Parties.Get(busy.Code) == nil || srv.Parties.Get(fresh.Code) == nil {
+		t.Error("Expected connected and new parties to be kept")
+	}
+}
@@ -1,10 +1,13 @@
 package web
 
 import (
+	"bytes"
+	"io/fs"
 	"net/http"
 	"os"
-	"path/filepath"
+	"path"
 	"strings"
+	"time"
 )
 
 // defaultStoriesDir is the default base directory for story files (YAML and per-story scenery).
@@ -16,20 +19,20 @@ const (
 	contentTypeJPEG = "image/jpeg"
 )
 
-// storiesBase returns the base directory for stories (used for resolving scenery paths).
-// Tests may set Server.StoriesDir to use a temp dir.
-func (s *Server) storiesBase() string {
-	if s.StoriesDir != "" {
-		return s.StoriesDir
+// storyFiles returns the story files scenery and audio are read from.
+// Tests may set Server.StoryFiles to use a temp dir.
+func (s *Server) storyFiles() fs.FS {
+	if s.StoryFiles != nil {
+		return s.StoryFiles
 	}
-	return defaultStoriesDir
+	return os.DirFS(defaultStoriesDir)
 }
 
 // sceneryExtensions lists file extensions to try when the YAML value has no extension.
 var sceneryExtensions = []string{".png", ".jpg", ".jpeg"}
 
 // handleScenery serves scenery images from the per-story strict directory
-// stories/<storyID>/scenery/. URL shape: /scenery/<storyID>/<filename> (no extension;
+// <storyID>/scenery/ of the story files. URL shape: /scenery/<storyID>/<filename> (no extension;
 // server tries .png, .jpg, .jpeg). StoryID must be in Engine.Stories; filename must
 // be safe (no path traversal).
 func (s *Server) handleScenery(w http.ResponseWriter, r *http.Request) {
@@ -45,14 +48,18 @@ func (s *Server) handleScenery(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var body []byte
+	var modTime time.Time
 	var contentType string
 	for _, p := range candidates {
-		b, err := os.ReadFile(p) // #nosec G304 -- p is under validated baseDir (stories/<storyID>/scenery)
+		b, err := fs.ReadFile(s.storyFiles(), p)
 		if err != nil {
 			continue
 		}
 		body = b
-		switch strings.ToLower(filepath.Ext(p)) {
+		if info, err := fs.Stat(s.storyFiles(), p); err == nil {
+			modTime = info.ModTime()
+		}
+		switch strings.ToLower(path.Ext(p)) {
 		case ".jpg", ".jpeg":
 			contentType = contentTypeJPEG
 		default:
@@ -67,8 +74,6 @@ func (s *Server) handleScenery(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Cache-Control", assetCacheControl)
-	if _, err := w.Write(body); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	// ServeContent answers If-Modified-Since with 304 Not Modified.
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(body))
 }
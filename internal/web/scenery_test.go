@@ -54,7 +54,7 @@ func TestHandleScenery_ServesFileFromStoryDir(t *testing.T) {
 
 	srv := &Server{
 		Engine:     &game.Engine{Stories: map[string]*game.Story{sceneryTestStoryID: {Start: "a", Nodes: map[string]*game.Node{"a": {Text: "Start"}}}}},
-		StoriesDir: tmpDir,
+		StoryFiles: os.DirFS(tmpDir),
 	}
 	req := httptest.NewRequest(http.MethodGet, "/scenery/"+sceneryTestStoryID+"/forest", http.NoBody)
 	rec := httptest.NewRecorder()
@@ -89,7 +89,7 @@ func TestHandleScenery_ServesJPG(t *testing.T) {
 
 	srv := &Server{
 		Engine:     &game.Engine{Stories: map[string]*game.Story{sceneryTestStoryID: {Start: "a", Nodes: map[string]*game.Node{"a": {Text: "Start"}}}}},
-		StoriesDir: tmpDir,
+		StoryFiles: os.DirFS(tmpDir),
 	}
 	req := httptest.NewRequest(http.MethodGet, "/scenery/"+sceneryTestStoryID+"/sunset", http.NoBody)
 	rec := httptest.NewRecorder()
@@ -114,7 +114,7 @@ func TestHandleScenery_UnknownStory_NotFound(t *testing.T) {
 	tmpDir := t.TempDir()
 	srv := &Server{
 		Engine:     &game.Engine{Stories: map[string]*game.Story{"other": {Start: "a", Nodes: map[string]*game.Node{"a": {Text: "Start"}}}}},
-		StoriesDir: tmpDir,
+		StoryFiles: os.DirFS(tmpDir),
 	}
 	req := httptest.NewRequest(http.MethodGet, "/scenery/unknown_story/forest", http.NoBody)
 	rec := httptest.NewRecorder()
@@ -134,7 +134,7 @@ func TestHandleScenery_NonexistentFile_NotFound(t *testing.T) {
 
 	srv := &Server{
 		Engine:     &game.Engine{Stories: map[string]*game.Story{sceneryTestStoryID: {Start: "a", Nodes: map[string]*game.Node{"a": {Text: "Start"}}}}},
-		StoriesDir: tmpDir,
+		StoryFiles: os.DirFS(tmpDir),
 	}
 	req := httptest.NewRequest(http.MethodGet, "/scenery/"+sceneryTestStoryID+"/nonexistent", http.NoBody)
 	rec := httptest.NewRecorder()
@@ -149,7 +149,7 @@ func TestHandleScenery_PathTraversal_NotFound(t *testing.T) {
 	tmpDir := t.TempDir()
 	srv := &Server{
 		Engine:     &game.Engine{Stories: map[string]*game.Story{sceneryTestStoryID: {Start: "a", Nodes: map[string]*game.Node{"a": {Text: "Start"}}}}},
-		StoriesDir: tmpDir,
+		StoryFiles: os.DirFS(tmpDir),
 	}
 
 	// Call handler directly so path is not normalized by the mux (which would redirect).
@@ -186,7 +186,7 @@ func TestHandleScenery_MethodNotAllowed(t *testing.T) {
 	tmpDir := t.TempDir()
 	srv := &Server{
 		Engine:     &game.Engine{Stories: map[string]*game.Story{sceneryTestStoryID: {Start: "a", Nodes: map[string]*game.Node{"a": {Text: "Start"}}}}},
-		StoriesDir: tmpDir,
+		StoryFiles: os.DirFS(tmpDir),
 	}
 	req := httptest.NewRequest(http.MethodPost, "/scenery/"+sceneryTestStoryID+"/forest", http.NoBody)
 	rec := httptest.NewRecorder()
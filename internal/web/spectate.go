@@ -0,0 +1,202 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"adventure/internal/game"
+)
+
+// spectateTokenBytes is the size of a spectator token. Tokens are random and
+// unrelated to the session ID, so a share link cannot be used to play.
+const spectateTokenBytes = 16
+
+// DefaultSpectateMaxIdle is how long a link nobody watches is kept after its
+// game was last played: a week, like the default session TTL.
+const DefaultSpectateMaxIdle = 7 * 24 * time.Hour
+
+// Spectators holds read-only share links to live sessions and the last step
+// of each watched session, so viewers see the dice as well as the state.
+// Create it with NewSpectators.
+type Spectators struct {
+	// MaxIdle is how long a link nobody watches is kept after its game was
+	// last played. Set it to the session TTL so links go with their games;
+	// 0 keeps links until they are revoked.
+	MaxIdle time.Duration
+
+	mu        sync.Mutex
+	byToken   map[string]*watch
+	bySession map[string]*watch
+}
+
+// NewSpectators returns an empty spectator hub with the default expiry.
+func NewSpectators() *Spectators {
+	return &Spectators{MaxIdle: DefaultSpectateMaxIdle, byToken: map[string]*watch{}, bySession: map[string]*watch{}}
+}
+
+// SpectateView is what templates need to keep a read-only view live.
+type SpectateView struct {
+	Token   string
+	Version int    // last version rendered; newer "update" events refresh
+	View    string // URL of the fragment to refresh from
+	Target  string // element the fragment replaces the content of
+}
+
+// watch is one session's share link and its viewers. All fields but the
+// IDs are guarded by Spectators.mu.
+type watch struct {
+	token     string
+	sessionID string
+	version   int             // increases on every published step
+	last      game.StepResult // the last published step
+	revoked   bool
+	subs      map[chan struct{}]struct{}
+	active    time.Time // last step or viewer leaving, for pruning
+}
+
+// Link returns the session's spectator token, creating one if needed. It
+// also drops the links that have been idle for MaxIdle.
+func (sp *Spectators) Link(sessionID string) string {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.prune()
+	if w := sp.bySession[sessionID]; w != nil {
+		return w.token
+	}
+	b := make([]byte, spectateTokenBytes)
+	_, _ = rand.Read(b) //nolint:errcheck // crypto/rand.Read does not fail
+	w := &watch{token: hex.EncodeToString(b), sessionID: sessionID, subs: map[chan struct{}]struct{}{}, active: time.Now()}
+	sp.byToken[w.token] = w
+	sp.bySession[sessionID] = w
+	return w.token
+}
+
+// Revoke removes the session's share link; open viewers are told and
+// disconnected. It reports whether there was a link.
+func (sp *Spectators) Revoke(sessionID string) bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	w := sp.bySession[sessionID]
+	if w == nil {
+		return false
+	}
+	sp.drop(w)
+	return true
+}
+
+// drop removes w's link and tells its viewers. Spectators.mu must be held.
+func (sp *Spectators) drop(w *watch) {
+	delete(sp.bySession, w.sessionID)
+	delete(sp.byToken, w.token)
+	w.revoked = true
+	w.notify()
+}
+
+// idle reports whether nobody watches w and its game has not been played
+// for MaxIdle. Spectators.mu must be held.
+func (sp *Spectators) idle(w *watch) bool {
+	return sp.MaxIdle > 0 && len(w.subs) == 0 && time.Since(w.active) > sp.MaxIdle
+}
+
+// prune drops the links idle for MaxIdle. Spectators.mu must be held.
+func (sp *Spectators) prune() {
+	for _, w := range sp.byToken {
+		if sp.idle(w) {
+			sp.drop(w)
+		}
+	}
+}
+
+// watching returns the live link for token, dropping it if it has been idle
+// for MaxIdle. Spectators.mu must be held.
+func (sp *Spectators) watching(token string) *watch {
+	w := sp.byToken[token]
+	if w != nil && sp.idle(w) {
+		sp.drop(w)
+		return nil
+	}
+	return w
+}
+
+// Publish records a step taken in a session and wakes its viewers. It does
+// nothing when sp is nil or nobody shares the session.
+func (sp *Spectators) Publish(sessionID string, res *game.StepResult) {
+	if sp == nil {
+		return
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	w := sp.bySession[sessionID]
+	if w == nil {
+		return
+	}
+	w.version++
+	w.last = *res
+	w.active = time.Now()
+	w.notify()
+}
+
+// seed gives the session's link a state to show until its first Publish,
+// for stores the server cannot read the session back from, such as cookies.
+// It does nothing once a step was published or when nobody shares the
+// session.
+func (sp *Spectators) seed(sessionID string, st *game.PlayerState) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if w := sp.bySession[sessionID]; w != nil && w.version == 0 {
+		w.last = game.StepResult{State: *st}
+		w.active = time.Now()
+	}
+}
+
+// lookup returns the session, version and last step behind token. An
+// expired token is unknown.
+func (sp *Spectators) lookup(token string) (sessionID string, version int, last game.StepResult, ok bool) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	w := sp.watching(token)
+	if w == nil {
+		return "", 0, game.StepResult{}, false
+	}
+	return w.sessionID, w.version, w.last, true
+}
+
+// subscribe returns a channel signalled when token's session changes or the
+// link is revoked, a function reporting the current version and whether the
+// link was revoked, and a function to unsubscribe. ok is false for an
+// unknown or expired token.
+func (sp *Spectators) subscribe(token string) (changed <-chan struct{}, state func() (version int, revoked bool), cancel func(), ok bool) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	w := sp.watching(token)
+	if w == nil {
+		return nil, nil, nil, false
+	}
+	ch := make(chan struct{}, 1)
+	w.subs[ch] = struct{}{}
+	ch <- struct{}{}
+	state = func() (int, bool) {
+		sp.mu.Lock()
+		defer sp.mu.Unlock()
+		return w.version, w.revoked
+	}
+	cancel = func() {
+		sp.mu.Lock()
+		defer sp.mu.Unlock()
+		delete(w.subs, ch)
+		w.active = time.Now()
+	}
+	return ch, state, cancel, true
+}
+
+// notify signals every viewer without blocking. Spectators.mu must be held.
+func (w *watch) notify() {
+	for ch := range w.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"adventure/internal/game"
+)
+
+func TestSpectators_LinkRevoke(t *testing.T) {
+	sp := NewSpectators()
+	tok := sp.Link("sid")
+	if len(tok) != 2*spectateTokenBytes || tok == "sid" {
+		t.Fatalf("Expected a random token, got %q", tok)
+	}
+	if again := sp.Link("sid"); again != tok {
+		t.Errorf("Expected the same link for the same session, got %q", again)
+	}
+	if other := sp.Link("other"); other == tok {
+		t.Error("Expected different sessions to get different links")
+	}
+	if id, _, _, ok := sp.lookup(tok); !ok || id != "sid" {
+		t.Errorf("lookup = %q, %v", id, ok)
+	}
+
+	if !sp.Revoke("sid") || sp.Revoke("sid") {
+		t.Error("Expected the first revoke only to find a link")
+	}
+	if _, _, _, ok := sp.lookup(tok); ok {
+		t.Error("Expected a revoked token to be gone")
+	}
+	if fresh := sp.Link("sid"); fresh == tok {
+		t.Error("Expected a new token after revoking")
+	}
+}
+
+func TestSpectators_Publish(t *testing.T) {
+	var nilHub *Spectators
+	nilHub.Publish("sid", &game.StepResult{}) // disabled spectating is a no-op
+
+	sp := NewSpectators()
+	sp.Publish("sid", &game.StepResult{}) // not shared: ignored
+	tok := sp.Link("sid")
+	changed, state, cancel, ok := sp.subscribe(tok)
+	if !ok {
+		t.Fatal("Expected to subscribe")
+	}
+	defer cancel()
+	<-changed // subscribing signals once
+
+	roll := 9
+	sp.Publish("sid", &game.StepResult{LastRoll: &roll})
+	<-changed
+	version, revoked := state()
+	_, v, last, _ := sp.lookup(tok)
+	if version != 1 || v != 1 || revoked || last.LastRoll == nil || *last.LastRoll != 9 {
+		t.Errorf("Expected version 1 with the roll, got %d %d %v %+v", version, v, revoked, last)
+	}
+
+	sp.Revoke("sid")
+	<-changed
+	if _, revoked := state(); !revoked {
+		t.Error("Expected viewers to see the revoke")
+	}
+	if _, _, _, ok := sp.subscribe(tok); ok {
+		t.Error("Expected no subscription to a revoked link")
+	}
+}
+
+func TestSpectators_Expire(t *testing.T) {
+	sp := NewSpectators()
+	sp.MaxIdle = time.Hour
+	stale := sp.Link("stale")
+	watched := sp.Link("watched")
+	_, _, cancel, ok := sp.subscribe(watched)
+	if !ok {
+		t.Fatal("Expected to subscribe")
+	}
+	defer cancel()
+	for _, w := range sp.byToken {
+		w.active = time.Now().Add(-2 * time.Hour)
+	}
+
+	if _, _, _, ok := sp.lookup(stale); ok {
+		t.Error("Expected a link idle for MaxIdle to expire")
+	}
+	if _, _, _, ok := sp.lookup(watched); !ok {
+		t.Error("Expected a link with a viewer to be kept")
+	}
+
+	// Creating a link prunes the idle ones nobody looked up.
+	sp.Link("again")
+	sp.bySession["again"].active = time.Now().Add(-2 * time.Hour)
+	sp.Link("other")
+	if _, ok := sp.bySession["again"]; ok || len(sp.byToken) != 2 {
+		t.Errorf("Expected only the watched and new links left, got %d", len(sp.byToken))
+	}
+
+	// 0 keeps links until they are revoked.
+	sp.MaxIdle = 0
+	sp.bySession["other"].active = time.Time{}
+	if _, _, _, ok := sp.lookup(sp.Link("other")); !ok {
+		t.Error("Expected links kept forever with no MaxIdle")
+	}
+}
@@ -1,6 +1,10 @@
 package web
 
-import "adventure/internal/game"
+import (
+	"adventure/internal/analytics"
+	"adventure/internal/game"
+	"adventure/internal/leaderboard"
+)
 
 // AvatarOptions is the list of allowed avatar IDs for validation and templates.
 var AvatarOptions = []string{"male_young", "male_old", "female_young", "female_old"}
@@ -11,17 +15,155 @@ type AdventureOption struct {
 	Name string
 }
 
+// DifficultyOption is one selectable difficulty preset for the chosen adventure.
+type DifficultyOption struct {
+	ID          string
+	Name        string
+	Description string
+}
+
 // StartViewModel contains data for rendering the character creation screen.
 type StartViewModel struct {
-	Stats            game.Stats
-	StrengthDice     [2]int // two d6 for Strength
-	LuckDice         [2]int
-	HealthDice       [2]int
-	RerollUsed       bool
-	SessionID        string   // so Begin request can use same session if cookie not sent
-	Name             string   // character display name
-	Avatar           string   // avatar ID
-	AvatarOptions    []string // allowed avatar IDs for the selector
-	StoryID          string   // selected adventure ID
-	AdventureOptions []AdventureOption
+	Stats             game.Stats
+	StrengthDice      [2]int // two d6 for Strength
+	LuckDice          [2]int
+	HealthDice        [2]int
+	RerollUsed        bool // true when no rerolls remain
+	RerollsLeft       int
+	SetupLocked       bool     // story and difficulty are fixed: the stats have been rerolled
+	SessionID         string   // so Begin request can use same session if cookie not sent
+	Name              string   // character display name
+	Avatar            string   // avatar ID
+	AvatarOptions     []string // allowed avatar IDs for the selector
+	StoryID           string   // selected adventure ID
+	AdventureOptions  []AdventureOption
+	Difficulty        string // selected difficulty preset ID
+	DifficultyOptions []DifficultyOption
+	Sequel            *SequelOption // set when a finished hero may continue into the selected story
+	Accounts          bool          // accounts are enabled: offer to log in
+	Username          string        // logged-in account, if any
+	Leaderboards      bool          // leaderboards are enabled: link to the hall of fame
+}
+
+// SequelOption offers to continue into a sequel with a hero from its prequel.
+type SequelOption struct {
+	From string `json:"from"` // prequel display name
+	Hero string `json:"hero"` // hero's name
+}
+
+// TrophyEntry is one ending or achievement in the catalogue. Locked entries
+// show only Title and Hint so the page stays spoiler-safe.
+type TrophyEntry struct {
+	Title       string
+	Description string
+	Hint        string
+	Unlocked    bool
+}
+
+// StoryTrophies lists one story's endings and achievements.
+type StoryTrophies struct {
+	ID                string
+	Name              string
+	Endings           []TrophyEntry
+	EndingsFound      int
+	Achievements      []TrophyEntry
+	AchievementsFound int
+}
+
+// TrophiesViewModel contains data for rendering the trophy page.
+type TrophiesViewModel struct {
+	Stories []StoryTrophies
+}
+
+// AccountViewModel contains data for rendering the log in and sign up page.
+type AccountViewModel struct {
+	Username string // prefilled after a failed login
+	Error    string
+}
+
+// CharacterView is one of an account's characters on the select screen.
+type CharacterView struct {
+	ID      string
+	Name    string
+	Avatar  string
+	Story   string // story display name
+	Status  string // e.g. "In progress"
+	Current bool   // the character this browser is playing
+}
+
+// CharactersViewModel contains data for rendering the character select screen.
+type CharactersViewModel struct {
+	Username   string
+	Characters []CharacterView
+}
+
+// ScoreView is the score of a run that just finished.
+type ScoreView struct {
+	Score   game.Score
+	StoryID string             // set when leaderboards are enabled: links to the story's board
+	Ranks   *leaderboard.Ranks // set when the run was entered on the leaderboards
+}
+
+// LeaderboardRow is a numbered leaderboard entry.
+type LeaderboardRow struct {
+	Rank  int
+	Entry leaderboard.Entry
+	Mine  bool // the entry is the viewer's character
+}
+
+// LeaderboardViewModel contains data for rendering a story's leaderboard.
+type LeaderboardViewModel struct {
+	StoryID string
+	Story   string // story display name
+	Period  string // leaderboard.AllTime or leaderboard.Weekly
+	Entries []LeaderboardRow
+	Stories []AdventureOption // scored stories, for switching boards
+}
+
+// HallOfFameStory is one story's best runs in the hall of fame.
+type HallOfFameStory struct {
+	ID      string
+	Name    string
+	Entries []LeaderboardRow
+}
+
+// HallOfFameViewModel contains data for rendering the hall of fame.
+type HallOfFameViewModel struct {
+	Stories []HallOfFameStory
+}
+
+// NodeRow is a node count with its share as a percentage.
+type NodeRow struct {
+	analytics.NodeStat
+	Percent float64
+}
+
+// ChoiceRow is a choice's popularity, with the choice's text.
+type ChoiceRow struct {
+	analytics.ChoiceStat
+	Text    string
+	Percent float64
+}
+
+// CheckRow is a check's pass rate against its expected rate, as percentages.
+type CheckRow struct {
+	analytics.CheckStat
+	Text            string
+	PassPercent     float64
+	ExpectedPercent float64
+}
+
+// AnalyticsViewModel contains data for rendering the analytics pages: the
+// story list, or one story's report.
+type AnalyticsViewModel struct {
+	Stories  []AdventureOption // index: stories to report on
+	StoryID  string
+	Story    string // story display name
+	Report   *analytics.Report
+	Funnel   []NodeRow
+	Choices  []ChoiceRow
+	Checks   []CheckRow
+	Endings  []NodeRow
+	Deaths   []NodeRow
+	DropOffs []NodeRow
 }
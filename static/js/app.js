@@ -269,8 +269,146 @@
     }
   }
 
+  var partySource = null;
+  var partyTimer = null;
+  var partyDeadline = 0;
+
+  /** Text for the party countdown: ms until the vote closes, 0 before the first vote. */
+  function partyCountdownText(ms) {
+    if (ms <= 0) return 'Waiting for the first vote';
+    return 'Vote closes in ' + Math.ceil(ms / 1000) + 's';
+  }
+
+  /** Close the party's event stream and stop the countdown. */
+  function stopParty() {
+    if (partyTimer) {
+      clearInterval(partyTimer);
+      partyTimer = null;
+    }
+    if (partySource) {
+      partySource.close();
+      partySource = null;
+    }
+    partyDeadline = 0;
+  }
+
+  function tickPartyCountdown() {
+    const el = document.querySelector('.party-countdown');
+    if (!el) {
+      // The party is no longer on the page.
+      stopParty();
+      return;
+    }
+    if (el.getAttribute('data-ended') === 'true') return;
+    if (partyDeadline === 0) {
+      el.textContent = partyCountdownText(0);
+      return;
+    }
+    el.textContent = partyCountdownText(Math.max(partyDeadline - Date.now(), 1));
+  }
+
+  /** Apply a party "votes" event: counts per choice, connected browsers and the countdown. */
+  function updatePartyVotes(snap) {
+    const choices = document.querySelector('.party-choices');
+    if (!choices || !snap) return;
+    if (String(snap.round) !== choices.getAttribute('data-party-round')) return;
+    const counts = snap.counts || {};
+    choices.querySelectorAll('.vote-count').forEach(function (el) {
+      el.textContent = String(counts[el.getAttribute('data-key')] || 0);
+    });
+    const voters = choices.querySelector('.party-voters');
+    if (voters) voters.textContent = String(snap.voters || 0);
+    const countdown = choices.querySelector('.party-countdown');
+    if (snap.ended) {
+      if (countdown) {
+        countdown.textContent = 'The party is over';
+        countdown.setAttribute('data-ended', 'true');
+      }
+      stopParty();
+      return;
+    }
+    partyDeadline = snap.closesIn > 0 ? Date.now() + snap.closesIn : 0;
+    tickPartyCountdown();
+  }
+
+  /** Apply a party "node" event: refresh the view when a vote has moved the story on. */
+  function onPartyNode(code, round) {
+    const choices = document.querySelector('.party-choices');
+    if (!choices || choices.getAttribute('data-party-round') === String(round)) return;
+    partyDeadline = 0;
+    if (global.htmx) {
+      global.htmx.ajax('GET', '/party/' + code + '/view', { target: '#game', swap: 'innerHTML' });
+    }
+  }
+
+  /** Connect to the party's event stream when the page shows a party. */
+  function initParty() {
+    const choices = document.querySelector('.party-choices[data-party]');
+    if (!choices || partySource || typeof EventSource === 'undefined') return;
+    const code = choices.getAttribute('data-party');
+    partySource = new EventSource('/party/' + code + '/events');
+    partySource.addEventListener('node', function (evt) { onPartyNode(code, evt.data); });
+    partySource.addEventListener('votes', function (evt) {
+      try {
+        updatePartyVotes(JSON.parse(evt.data));
+      } catch (e) {
+        /* ignore malformed events */
+      }
+    });
+    partyTimer = setInterval(tickPartyCountdown, 250);
+  }
+
+  var watchSource = null;
+
+  /** Apply a spectator "update" event: refresh the view when the player has moved on. */
+  function onWatchUpdate(version) {
+    const el = document.querySelector('[data-watch]');
+    if (!el || el.getAttribute('data-watch-version') === String(version)) return;
+    if (global.htmx) {
+      global.htmx.ajax('GET', el.getAttribute('data-watch-view'), {
+        target: el.getAttribute('data-watch-target'),
+        swap: 'innerHTML'
+      });
+    }
+  }
+
+  /** Apply a spectator "revoked" event: stop listening and say why. */
+  function onWatchRevoked() {
+    if (watchSource) {
+      watchSource.close();
+      watchSource = null;
+    }
+    const status = document.querySelector('.watch-status');
+    if (status) status.textContent = 'This spectator link has been revoked.';
+  }
+
+  /** Connect to the spectator event stream when the page is a read-only view. */
+  function initWatch() {
+    const el = document.querySelector('[data-watch]');
+    if (!el || watchSource || typeof EventSource === 'undefined') return;
+    watchSource = new EventSource('/watch/' + el.getAttribute('data-watch') + '/events');
+    watchSource.addEventListener('update', function (evt) { onWatchUpdate(evt.data); });
+    watchSource.addEventListener('revoked', onWatchRevoked);
+  }
+
+  /**
+   * Let htmx swap in the current game when the server refuses a choice made
+   * on an outdated page (409 with the game view), instead of dropping it.
+   */
+  function onBeforeSwap(evt) {
+    const d = evt.detail;
+    const xhr = d && d.xhr;
+    if (xhr && xhr.status === 409 && xhr.getResponseHeader && xhr.getResponseHeader('X-Adventure-OOB') === 'true') {
+      d.shouldSwap = true;
+      d.isError = false;
+    }
+  }
+
   function init() {
     runUpdaters();
+    initParty();
+    initWatch();
+    document.body.addEventListener('htmx:beforeSwap', onBeforeSwap);
     document.body.addEventListener('htmx:afterSwap', function (evt) {
       if (evt.detail && evt.detail.target && evt.detail.target.id !== 'game') return;
       runUpdaters();
@@ -294,6 +432,14 @@
     updateSceneryImage,
     animateSidebarDice,
     startStoryTextAutoScroll,
+    partyCountdownText,
+    updatePartyVotes,
+    onPartyNode,
+    initParty,
+    onWatchUpdate,
+    onWatchRevoked,
+    initWatch,
+    onBeforeSwap,
     init
   };
 
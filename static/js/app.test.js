@@ -337,4 +337,143 @@ describe('AdventureUI', function () {
       jest.useRealTimers();
     });
   });
+  describe('party', function () {
+    function partyChoices(round) {
+      document.getElementById('game').innerHTML =
+        '<div class="choices-area party-choices" data-party="ABC234" data-party-round="' + round + '">' +
+        '  <span class="party-voters">0</span><span class="party-countdown"></span>' +
+        '  <button><span class="vote-count" data-key="north">0</span></button>' +
+        '  <button><span class="vote-count" data-key="south">0</span></button>' +
+        '</div>';
+    }
+
+    it('formats the countdown', function () {
+      expect(AdventureUI.partyCountdownText(0)).toBe('Waiting for the first vote');
+      expect(AdventureUI.partyCountdownText(4100)).toBe('Vote closes in 5s');
+    });
+
+    it('updates vote counts, voters and countdown for the current round', function () {
+      partyChoices(2);
+      AdventureUI.updatePartyVotes({ round: 2, counts: { north: 3 }, voters: 5, closesIn: 9500 });
+      const counts = document.querySelectorAll('.vote-count');
+      expect(counts[0].textContent).toBe('3');
+      expect(counts[1].textContent).toBe('0');
+      expect(document.querySelector('.party-voters').textContent).toBe('5');
+      expect(document.querySelector('.party-countdown').textContent).toBe('Vote closes in 10s');
+    });
+
+    it('ignores votes for another round', function () {
+      partyChoices(2);
+      AdventureUI.updatePartyVotes({ round: 1, counts: { north: 3 }, voters: 5, closesIn: 0 });
+      expect(document.querySelector('.vote-count').textContent).toBe('0');
+    });
+
+    it('shows when the party has ended', function () {
+      partyChoices(4);
+      AdventureUI.updatePartyVotes({ round: 4, counts: {}, voters: 1, closesIn: 0, ended: true });
+      expect(document.querySelector('.party-countdown').textContent).toBe('The party is over');
+    });
+
+    it('refreshes the view only when the round changes', function () {
+      partyChoices(2);
+      window.htmx = { ajax: jest.fn() };
+      AdventureUI.onPartyNode('ABC234', '2');
+      expect(window.htmx.ajax).not.toHaveBeenCalled();
+      AdventureUI.onPartyNode('ABC234', '3');
+      expect(window.htmx.ajax).toHaveBeenCalledWith('GET', '/party/ABC234/view', { target: '#game', swap: 'innerHTML' });
+      delete window.htmx;
+    });
+
+    it('stops the countdown and closes the stream when the party ends', function () {
+      partyChoices(4);
+      const source = { addEventListener: jest.fn(), close: jest.fn() };
+      global.EventSource = jest.fn(function () { return source; });
+      jest.useFakeTimers();
+      AdventureUI.initParty();
+      expect(jest.getTimerCount()).toBe(1);
+      AdventureUI.updatePartyVotes({ round: 4, counts: {}, voters: 1, closesIn: 0, ended: true });
+      expect(source.close).toHaveBeenCalled();
+      expect(jest.getTimerCount()).toBe(0);
+      jest.useRealTimers();
+      delete global.EventSource;
+    });
+
+    it('connects to the event stream once', function () {
+      partyChoices(0);
+      const sources = [];
+      global.EventSource = jest.fn(function (url) {
+        this.url = url;
+        this.addEventListener = jest.fn();
+        sources.push(this);
+      });
+      jest.useFakeTimers();
+      AdventureUI.initParty();
+      AdventureUI.initParty();
+      expect(sources.length).toBe(1);
+      expect(sources[0].url).toBe('/party/ABC234/events');
+      jest.useRealTimers();
+      delete global.EventSource;
+    });
+  });
+  describe('onBeforeSwap', function () {
+    function swapEvent(status, oob) {
+      return {
+        detail: {
+          shouldSwap: false,
+          isError: true,
+          xhr: { status: status, getResponseHeader: function () { return oob ? 'true' : null; } }
+        }
+      };
+    }
+
+    it('swaps in the current game for a refused stale choice', function () {
+      const evt = swapEvent(409, true);
+      AdventureUI.onBeforeSwap(evt);
+      expect(evt.detail.shouldSwap).toBe(true);
+      expect(evt.detail.isError).toBe(false);
+    });
+
+    it('leaves other errors alone', function () {
+      for (const evt of [swapEvent(409, false), swapEvent(500, true)]) {
+        AdventureUI.onBeforeSwap(evt);
+        expect(evt.detail.shouldSwap).toBe(false);
+      }
+    });
+  });
+  describe('spectate', function () {
+    function watching(version) {
+      document.getElementById('game').innerHTML =
+        '<div class="choices-area spectate-status" data-watch="tok" data-watch-version="' + version + '"' +
+        ' data-watch-view="/watch/tok/view" data-watch-target="#game"><p class="watch-status">Watching live</p></div>';
+    }
+
+    it('refreshes the view only for a newer version', function () {
+      watching(3);
+      window.htmx = { ajax: jest.fn() };
+      AdventureUI.onWatchUpdate('3');
+      expect(window.htmx.ajax).not.toHaveBeenCalled();
+      AdventureUI.onWatchUpdate('4');
+      expect(window.htmx.ajax).toHaveBeenCalledWith('GET', '/watch/tok/view', { target: '#game', swap: 'innerHTML' });
+      delete window.htmx;
+    });
+
+    it('connects once and stops when the link is revoked', function () {
+      watching(0);
+      const sources = [];
+      global.EventSource = jest.fn(function (url) {
+        this.url = url;
+        this.addEventListener = jest.fn();
+        this.close = jest.fn();
+        sources.push(this);
+      });
+      AdventureUI.initWatch();
+      AdventureUI.initWatch();
+      expect(sources.length).toBe(1);
+      expect(sources[0].url).toBe('/watch/tok/events');
+      AdventureUI.onWatchRevoked();
+      expect(sources[0].close).toHaveBeenCalled();
+      expect(document.querySelector('.watch-status').textContent).toBe('This spectator link has been revoked.');
+      delete global.EventSource;
+    });
+  });
 });